@@ -17,6 +17,7 @@ const (
 	ParamsErrCode
 	ServiceErrCode
 	SonyflakeErrCode
+	LockedErrCode
 )
 
 var CodeMsg = map[int]string{
@@ -30,6 +31,7 @@ var CodeMsg = map[int]string{
 	ParamsErrCode:    "params error",
 	ServiceErrCode:   "service error",
 	SonyflakeErrCode: "sonyflake error",
+	LockedErrCode:    "account locked",
 }
 
 type ApiError struct {
@@ -76,6 +78,15 @@ func Forbidden() *ApiError {
 	}
 }
 
+func TooManyRequests() *ApiError {
+	_, file, line, _ := runtime.Caller(1)
+	stack := fmt.Sprintf("%s:%d", file, line)
+	return &ApiError{
+		httpCode: http.StatusTooManyRequests,
+		Stack:    stack,
+	}
+}
+
 func BadRequest() *ApiError {
 	_, file, line, _ := runtime.Caller(1)
 	stack := fmt.Sprintf("%s:%d", file, line)
@@ -7,9 +7,11 @@ import (
 	"os/signal"
 	"qqlx/base/conf"
 	"qqlx/base/constant"
+	"qqlx/base/health"
 	"qqlx/base/server"
 	"sync"
 	"syscall"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -26,11 +28,11 @@ type Application struct {
 	signals []os.Signal
 }
 
-func NewApplication(e *gin.Engine) *Application {
+func NewApplication(e *gin.Engine, roleExpiry *server.RoleExpiryWorker, accessExpiry *server.AccessRequestExpiryWorker, sessionPurge *server.SessionPurgeWorker, auditCheckpoint *server.AuditCheckpointWorker) *Application {
 	return newApp(
 		withName(conf.GetProjectName()),
 		withVersion(constant.ServerVersion),
-		withServer(server.NewServer(e)),
+		withServer(server.NewServer(e), server.NewMetricsServer(), roleExpiry, accessExpiry, sessionPurge, auditCheckpoint),
 	)
 }
 
@@ -110,7 +112,14 @@ func (app *Application) Run(ctx context.Context) error {
 }
 
 // Stop application stop
+//
+// readyz 先返回失败, 等待 server.shutdownDelay 让负载均衡摘除流量后再关闭服务
 func (app *Application) Stop() error {
+	health.Drain()
+	if delay := conf.GetShutdownDelay(); delay > 0 {
+		zap.S().Infof("readiness set to failing, wait %s before shutdown", delay)
+		time.Sleep(delay)
+	}
 	wg := sync.WaitGroup{}
 	for _, s := range app.servers {
 		wg.Add(1)
@@ -31,6 +31,20 @@ func GetServerBind() string {
 	return bind
 }
 
+// GetTrustedProxies 可信的反向代理地址或网段, 只信任这些代理设置的 X-Forwarded-For, 为空时使用连接的地址作为客户端 IP
+func GetTrustedProxies() []string {
+	return viper.GetStringSlice("server.trustedProxies")
+}
+
+// GetMetricsBind /metrics 的监听地址, 默认只监听本机
+func GetMetricsBind() string {
+	bind := viper.GetString("server.metricsBind")
+	if bind == "" {
+		bind = constant.DefaultMetricsBind
+	}
+	return bind
+}
+
 func GetProjectName() string {
 	projectName := viper.GetString("server.projectName")
 	if projectName == "" {
@@ -55,6 +69,98 @@ func GetCasbinModelPath() (string, error) {
 	return modlPath, nil
 }
 
+// GetRoleExpiryInterval 检查临时角色授权是否过期的间隔, 默认 1 分钟
+func GetRoleExpiryInterval() time.Duration {
+	interval := viper.GetDuration("server.roleExpiryInterval")
+	if interval <= 0 {
+		return time.Minute
+	}
+	return interval
+}
+
+// GetSessionPurgeInterval 清理过期和已结束会话的间隔, 默认 1 小时
+func GetSessionPurgeInterval() time.Duration {
+	interval := viper.GetDuration("server.sessionPurgeInterval")
+	if interval <= 0 {
+		return time.Hour
+	}
+	return interval
+}
+
+// GetAccessRequestTTL 未审批的角色访问申请的有效期, 默认 72 小时
+func GetAccessRequestTTL() time.Duration {
+	ttl := viper.GetDuration("server.accessRequestTTL")
+	if ttl <= 0 {
+		return 72 * time.Hour
+	}
+	return ttl
+}
+
+// GetAuditHmacKey 审计记录哈希链和检查点签名的密钥, 为空时签名可以被伪造, 返回错误
+func GetAuditHmacKey() (string, error) {
+	key := viper.GetString("audit.hmacKey")
+	if key == "" {
+		return "", fmt.Errorf("audit.hmacKey is empty")
+	}
+	return key, nil
+}
+
+// GetAuditCheckpointFile 签名检查点追加写入的文件, 为空时不导出
+func GetAuditCheckpointFile() string {
+	return viper.GetString("audit.checkpointFile")
+}
+
+// GetAuditCheckpointInterval 导出签名检查点的间隔, 默认 1 小时
+func GetAuditCheckpointInterval() time.Duration {
+	interval := viper.GetDuration("audit.checkpointInterval")
+	if interval <= 0 {
+		return time.Hour
+	}
+	return interval
+}
+
+// GetTraceExporter 链路追踪导出方式, otlp 或 stdout, 为空时不导出
+func GetTraceExporter() string {
+	return viper.GetString("trace.exporter")
+}
+
+// GetTraceEndpoint otlp 导出地址, 为空时使用 OTEL_EXPORTER_OTLP_ENDPOINT 或 localhost:4318
+func GetTraceEndpoint() string {
+	return viper.GetString("trace.endpoint")
+}
+
+// GetTraceInsecure otlp 导出不使用 TLS
+func GetTraceInsecure() bool {
+	return viper.GetBool("trace.insecure")
+}
+
+// GetTraceSampleRatio 没有上游采样决定时的采样比例, 默认全部采样
+func GetTraceSampleRatio() float64 {
+	if !viper.IsSet("trace.sampleRatio") {
+		return 1
+	}
+	return viper.GetFloat64("trace.sampleRatio")
+}
+
+// GetHealthTimeout 每个就绪检查的超时时间, 默认 2 秒
+func GetHealthTimeout() time.Duration {
+	timeout := viper.GetDuration("server.healthTimeout")
+	if timeout <= 0 {
+		return 2 * time.Second
+	}
+	return timeout
+}
+
+// GetShutdownDelay 停止时就绪检查失败后等待负载均衡摘除流量的时间, 默认不等待
+func GetShutdownDelay() time.Duration {
+	return viper.GetDuration("server.shutdownDelay")
+}
+
+// GetCasbinCatalog 启动时对比路由和策略, 为空不执行, report 只输出差异, apply 创建缺少的策略
+func GetCasbinCatalog() string {
+	return viper.GetString("casbin.catalog")
+}
+
 func GetJwtSecret() (string, error) {
 	secret := viper.GetString("jwt.secret")
 	if secret == "" {
@@ -63,6 +169,33 @@ func GetJwtSecret() (string, error) {
 	return secret, nil
 }
 
+// JwtKey jwt 非对称签名密钥文件
+type JwtKey struct {
+	Kid        string `mapstructure:"kid"`
+	PrivateKey string `mapstructure:"privateKey"`
+	PublicKey  string `mapstructure:"publicKey"`
+}
+
+func GetJwtAlgorithm() string {
+	algorithm := viper.GetString("jwt.algorithm")
+	if algorithm == "" {
+		return constant.DefaultJwtAlgorithm
+	}
+	return algorithm
+}
+
+func GetJwtActiveKid() string {
+	return viper.GetString("jwt.activeKid")
+}
+
+func GetJwtKeys() ([]JwtKey, error) {
+	var keys []JwtKey
+	if err := viper.UnmarshalKey("jwt.keys", &keys); err != nil {
+		return nil, fmt.Errorf("failed to parser jwt.keys err: %v", err)
+	}
+	return keys, nil
+}
+
 func GetJwtIssuer() string {
 	issuer := viper.GetString("jwt.issuer")
 	if issuer == "" {
@@ -84,6 +217,18 @@ func GetJwtExpirationTime() (time.Duration, error) {
 	return expireTime, nil
 }
 
+func GetJwtRefreshExpirationTime() (time.Duration, error) {
+	expireTime := viper.GetDuration("jwt.refreshExpireTime")
+	if expireTime == 0 {
+		expire, err := time.ParseDuration(constant.DefaultJwtRefreshExpireTime)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parser jwt.refreshExpireTime err: %v", err)
+		}
+		return expire, nil
+	}
+	return expireTime, nil
+}
+
 func GetCasbinDsn() (string, error) {
 	user := viper.GetString("mysql.username")
 	if user == "" {
@@ -280,3 +425,271 @@ func GetLdapGroupFilter() (string, error) {
 	}
 	return groupSearchFilter, nil
 }
+
+func GetOidcEnable() bool {
+	return viper.GetBool("oidc.enable")
+}
+
+func GetOidcIssuer() (string, error) {
+	issuer := viper.GetString("oidc.issuer")
+	if issuer == "" {
+		return "", fmt.Errorf("oidc.issuer is empty")
+	}
+	return issuer, nil
+}
+
+func GetOidcClientID() (string, error) {
+	clientID := viper.GetString("oidc.clientID")
+	if clientID == "" {
+		return "", fmt.Errorf("oidc.clientID is empty")
+	}
+	return clientID, nil
+}
+
+func GetOidcClientSecret() string {
+	return viper.GetString("oidc.clientSecret")
+}
+
+func GetOidcRedirectURL() (string, error) {
+	redirectURL := viper.GetString("oidc.redirectURL")
+	if redirectURL == "" {
+		return "", fmt.Errorf("oidc.redirectURL is empty")
+	}
+	return redirectURL, nil
+}
+
+func GetOidcScopes() []string {
+	return viper.GetStringSlice("oidc.scopes")
+}
+
+// GetOidcClaim 获取用户信息映射使用的 claim 名称, 未配置时使用 defaultClaim
+func GetOidcClaim(name, defaultClaim string) string {
+	claim := viper.GetString("oidc.claims." + name)
+	if claim == "" {
+		return defaultClaim
+	}
+	return claim
+}
+
+// GetOidcCookieSecure oidc state cookie 是否只通过 HTTPS 发送, 默认 true
+func GetOidcCookieSecure() bool {
+	if !viper.IsSet("oidc.cookieSecure") {
+		return true
+	}
+	return viper.GetBool("oidc.cookieSecure")
+}
+
+func GetOidcAutoProvision() bool {
+	return viper.GetBool("oidc.autoProvision")
+}
+
+// OidcGroupRole OIDC 组与角色的映射
+type OidcGroupRole struct {
+	Group string `mapstructure:"group"`
+	Role  string `mapstructure:"role"`
+}
+
+func GetOidcGroupRoles() ([]OidcGroupRole, error) {
+	var groupRoles []OidcGroupRole
+	if err := viper.UnmarshalKey("oidc.groupRoles", &groupRoles); err != nil {
+		return nil, fmt.Errorf("failed to parser oidc.groupRoles err: %v", err)
+	}
+	return groupRoles, nil
+}
+
+// GetMfaIssuer otpauth URI 中显示的发行方, 默认使用项目名称
+func GetMfaIssuer() string {
+	issuer := viper.GetString("mfa.issuer")
+	if issuer == "" {
+		return GetProjectName()
+	}
+	return issuer
+}
+
+// GetMfaRequireAdmin admin 用户是否必须启用 MFA
+func GetMfaRequireAdmin() bool {
+	return viper.GetBool("mfa.requireAdmin")
+}
+
+const (
+	MailDriverSMTP = "smtp"
+	MailDriverLog  = "log"
+)
+
+// GetMailDriver 邮件发送方式, smtp 或 log, 默认 log
+func GetMailDriver() string {
+	driver := viper.GetString("mail.driver")
+	if driver == "" {
+		return MailDriverLog
+	}
+	return driver
+}
+
+func GetMailSMTPHost() (string, error) {
+	host := viper.GetString("mail.smtp.host")
+	if host == "" {
+		return "", fmt.Errorf("mail.smtp.host is empty")
+	}
+	return host, nil
+}
+
+func GetMailSMTPPort() int {
+	port := viper.GetInt("mail.smtp.port")
+	if port == 0 {
+		return 587
+	}
+	return port
+}
+
+func GetMailSMTPUsername() string {
+	return viper.GetString("mail.smtp.username")
+}
+
+func GetMailSMTPPassword() string {
+	return viper.GetString("mail.smtp.password")
+}
+
+func GetMailFrom() (string, error) {
+	from := viper.GetString("mail.from")
+	if from == "" {
+		return "", fmt.Errorf("mail.from is empty")
+	}
+	return from, nil
+}
+
+// GetMailVerifyURL 邮箱验证链接, %s 替换为 token
+func GetMailVerifyURL() string {
+	return viper.GetString("mail.verifyURL")
+}
+
+// GetMailResetURL 重置密码链接, %s 替换为 token
+func GetMailResetURL() string {
+	return viper.GetString("mail.resetURL")
+}
+
+// GetMailTokenKey 邮件 token 签名密钥, 与 server.salt 分开配置
+func GetMailTokenKey() (string, error) {
+	key := viper.GetString("mail.tokenKey")
+	if key == "" {
+		return "", fmt.Errorf("mail.tokenKey is empty")
+	}
+	return key, nil
+}
+
+func GetMailVerifyExpireTime() (time.Duration, error) {
+	expire := viper.GetString("mail.verifyExpireTime")
+	if expire == "" {
+		expire = constant.DefaultMailVerifyExpireTime
+	}
+	d, err := time.ParseDuration(expire)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parser mail.verifyExpireTime err: %v", err)
+	}
+	return d, nil
+}
+
+func GetMailResetExpireTime() (time.Duration, error) {
+	expire := viper.GetString("mail.resetExpireTime")
+	if expire == "" {
+		expire = constant.DefaultMailResetExpireTime
+	}
+	d, err := time.ParseDuration(expire)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parser mail.resetExpireTime err: %v", err)
+	}
+	return d, nil
+}
+
+// GetMailRequireVerified 是否要求验证邮箱后才能登录
+func GetMailRequireVerified() bool {
+	return viper.GetBool("mail.requireVerified")
+}
+
+// GetLoginMaxFailures 单个账号在统计窗口内允许的登录失败次数, 超过后锁定
+func GetLoginMaxFailures() int {
+	n := viper.GetInt("login.maxFailures")
+	if n <= 0 {
+		return constant.DefaultLoginMaxFailures
+	}
+	return n
+}
+
+// GetLoginMaxIPFailures 单个来源 IP 在统计窗口内允许的登录失败次数, 超过后锁定
+func GetLoginMaxIPFailures() int {
+	n := viper.GetInt("login.maxIPFailures")
+	if n <= 0 {
+		return constant.DefaultLoginMaxIPFailures
+	}
+	return n
+}
+
+func GetLoginFailureWindow() (time.Duration, error) {
+	return getDuration("login.failureWindow", constant.DefaultLoginFailureWindow)
+}
+
+func GetLoginLockoutTime() (time.Duration, error) {
+	return getDuration("login.lockoutTime", constant.DefaultLoginLockoutTime)
+}
+
+func GetLoginDelayBase() (time.Duration, error) {
+	return getDuration("login.delayBase", constant.DefaultLoginDelayBase)
+}
+
+func GetLoginMaxDelay() (time.Duration, error) {
+	return getDuration("login.maxDelay", constant.DefaultLoginMaxDelay)
+}
+
+func getDuration(key, defaultValue string) (time.Duration, error) {
+	value := viper.GetString(key)
+	if value == "" {
+		value = defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parser %s err: %v", key, err)
+	}
+	return d, nil
+}
+
+// GetPasswordMinLength 密码最小长度, 默认 8
+func GetPasswordMinLength() int {
+	n := viper.GetInt("password.minLength")
+	if n <= 0 {
+		return constant.DefaultPasswordMinLength
+	}
+	return n
+}
+
+func GetPasswordRequireUpper() bool {
+	return viper.GetBool("password.requireUpper")
+}
+
+func GetPasswordRequireLower() bool {
+	return viper.GetBool("password.requireLower")
+}
+
+func GetPasswordRequireDigit() bool {
+	return viper.GetBool("password.requireDigit")
+}
+
+func GetPasswordRequireSymbol() bool {
+	return viper.GetBool("password.requireSymbol")
+}
+
+func GetPasswordBanIdentity() bool {
+	return viper.GetBool("password.banIdentity")
+}
+
+func GetPasswordBannedListFile() string {
+	return viper.GetString("password.bannedListFile")
+}
+
+// GetPasswordHistory 不能重复使用最近 N 次的密码
+func GetPasswordHistory() int {
+	return viper.GetInt("password.history")
+}
+
+// GetPasswordMaxAge 密码最长使用时间, 0 表示不限制
+func GetPasswordMaxAge() (time.Duration, error) {
+	return getDuration("password.maxAge", "0s")
+}
@@ -6,16 +6,140 @@ const (
 )
 
 const (
-	ServerVersion        = "1.0.0"
-	DefaultServerBind    = "0.0.0.0:8080"
-	DefaultServerName    = "qqlx"
-	DefaultJwtExpireTime = "12h"
-	DefaultJwtIssuer     = "qqlx"
-	DefaultLoglevel      = "info"
-	DefaultRedisIncrKey  = "machine_id"
-	AuthMidwareKey       = "user"
-	LogErrMidwareKey     = "error"
-	TraceID              = "traceID"
+	ServerVersion               = "1.0.0"
+	DefaultServerBind           = "0.0.0.0:8080"
+	DefaultMetricsBind          = "127.0.0.1:9090"
+	DefaultServerName           = "qqlx"
+	DefaultJwtExpireTime        = "30m"
+	DefaultJwtRefreshExpireTime = "168h"
+	DefaultJwtIssuer            = "qqlx"
+	DefaultJwtAlgorithm         = "HS256"
+	DefaultMailVerifyExpireTime = "24h"
+	DefaultMailResetExpireTime  = "30m"
+	DefaultPasswordMinLength    = 8
+	DefaultLoginMaxFailures     = 5
+	DefaultLoginMaxIPFailures   = 20
+	DefaultLoginFailureWindow   = "15m"
+	DefaultLoginLockoutTime     = "15m"
+	DefaultLoginDelayBase       = "1s"
+	DefaultLoginMaxDelay        = "30s"
+	DefaultLoglevel             = "info"
+	DefaultRedisIncrKey         = "machine_id"
+	AuthMidwareKey              = "user"
+	LogErrMidwareKey            = "error"
+	TraceID                     = "traceID"
+	ClientIPKey                 = "clientIP"
+	UserAgentKey                = "userAgent"
+)
+
+// casbin 策略效果
+const (
+	PolicyEffectAllow = "allow"
+	PolicyEffectDeny  = "deny"
+	// PolicyConditionNone 没有条件的策略在 casbin 中的条件
+	PolicyConditionNone = "true"
+)
+
+// 审计事件
+const (
+	AuditUserRegister       = "user.register"
+	AuditUserLogin          = "user.login"
+	AuditUserLoginMfa       = "user.loginMfa"
+	AuditUserUpdate         = "user.update"
+	AuditUserDisable        = "user.disable"
+	AuditUserEnable         = "user.enable"
+	AuditUserUnlock         = "user.unlock"
+	AuditUserUpdatePassword = "user.updatePassword"
+	AuditUserResetPassword  = "user.resetPassword"
+	AuditUserVerifyEmail    = "user.verifyEmail"
+	AuditUserAddRole        = "user.addRole"
+	AuditUserRemoveRole     = "user.removeRole"
+	AuditRoleCreate         = "role.create"
+	AuditRoleUpdate         = "role.update"
+	AuditRoleDelete         = "role.delete"
+	AuditRoleUpdateParents  = "role.updateParents"
+	AuditRoleAddPolicy      = "role.addPolicy"
+	AuditRoleDeletePolicy   = "role.deletePolicy"
+	AuditRoleApprovers      = "role.updateApprovers"
+	AuditPolicyCreate       = "policy.create"
+	AuditPolicyUpdate       = "policy.update"
+	AuditPolicyDelete       = "policy.delete"
+	// AuditRoleExpired 临时角色授权过期
+	AuditRoleExpired = "role.expired"
+	// AuditAccessRequested 申请角色
+	AuditAccessRequested = "access.requested"
+	// AuditAccessApproved 角色申请通过
+	AuditAccessApproved = "access.approved"
+	// AuditAccessRejected 角色申请被拒绝
+	AuditAccessRejected = "access.rejected"
+	// AuditAccessExpired 角色申请超时未审批
+	AuditAccessExpired = "access.expired"
+	// AuditUserLoginOidc OIDC 登录
+	AuditUserLoginOidc = "user.loginOidc"
+	// AuditUserLinkOidc 已登录用户绑定 OIDC 账号
+	AuditUserLinkOidc = "user.linkOidc"
+	// AuditUserResetMfa 管理员重置用户 MFA
+	AuditUserResetMfa = "user.resetMfa"
+	// AuditSessionKill 结束会话
+	AuditSessionKill = "session.kill"
+	// AuditApiKeyCreate 创建 API key
+	AuditApiKeyCreate = "apiKey.create"
+	// AuditApiKeyRevoke 吊销 API key
+	AuditApiKeyRevoke = "apiKey.revoke"
+	// AuditServiceAccountCreate 创建服务账号
+	AuditServiceAccountCreate = "serviceAccount.create"
+	// AuditTenantSetMember 添加或更新租户成员
+	AuditTenantSetMember = "tenant.setMember"
+	// AuditTenantRemoveMember 移除租户成员
+	AuditTenantRemoveMember = "tenant.removeMember"
+	// AuditTenantSetMemberRoles 设置成员的租户角色
+	AuditTenantSetMemberRoles = "tenant.setMemberRoles"
+	// AuditTenantCreateRole 创建租户角色
+	AuditTenantCreateRole = "tenant.createRole"
+	// AuditTenantDeleteRole 删除租户角色
+	AuditTenantDeleteRole = "tenant.deleteRole"
+	// AuditRevisionRollback 回滚角色策略版本
+	AuditRevisionRollback = "revision.rollback"
+	// AuditPolicyCatalogApply 按路由目录创建策略
+	AuditPolicyCatalogApply = "policy.catalogApply"
+)
+
+// 审计对象类型
+const (
+	AuditTargetUser          = "user"
+	AuditTargetRole          = "role"
+	AuditTargetPolicy        = "policy"
+	AuditTargetAccessRequest = "accessRequest"
+	AuditTargetTenant        = "tenant"
+	AuditTargetApiKey        = "apiKey"
+	AuditTargetSession       = "session"
+	AuditTargetRevision      = "revision"
+)
+
+// 策略目录
+const (
+	// ApiPrefix 接口路由前缀, 生成策略名称时去掉
+	ApiPrefix = "/api/v1"
+	// CatalogReport 启动时只输出路由和策略的差异
+	CatalogReport = "report"
+	// CatalogApply 启动时为缺少策略的鉴权路由创建策略
+	CatalogApply = "apply"
+)
+
+// 多租户
+const (
+	// CasbinGlobalDomain 全局角色的 casbin domain, 在所有租户内生效
+	CasbinGlobalDomain = "*"
+	// CasbinTenantSubjectPrefix 租户角色 casbin subject 的前缀, 格式为 tenant:<租户ID>:<角色名>
+	CasbinTenantSubjectPrefix = "tenant:"
+	// TenantHeader 请求头中的租户ID, 路由包含 :tenant 时以路由为准
+	TenantHeader = "X-Tenant-ID"
+	// TenantParam 路由中的租户ID参数
+	TenantParam = "tenant"
+	// TenantAdminRole 租户管理员角色, 租户成员设置为管理员时在该租户内拥有此角色
+	TenantAdminRole = "tenant-admin"
+	// TenantPathPrefix 租户角色只能使用该前缀下的策略
+	TenantPathPrefix = "/api/v1/tenants/:tenant/"
 )
 
 // redis
@@ -24,4 +148,69 @@ const (
 	DefaultRedisExpireTime = "30s"
 	// RoleCacheKeyPrefix RedisKeyPrefix redis 角色缓存 key 前缀
 	RoleCacheKeyPrefix = "role"
+	// TenantRoleCacheKeyPrefix redis 用户在租户内的角色缓存 key 前缀
+	TenantRoleCacheKeyPrefix = "tenant_role"
+	// RefreshTokenCacheKeyPrefix redis refresh token 缓存 key 前缀
+	RefreshTokenCacheKeyPrefix = "refresh"
+	// RefreshUsedCacheKeyPrefix redis refresh token 已使用标记 key 前缀
+	RefreshUsedCacheKeyPrefix = "refresh_used"
+	// RefreshFamilyCacheKeyPrefix redis refresh token 家族吊销标记 key 前缀
+	RefreshFamilyCacheKeyPrefix = "refresh_family"
+	// TokenRevokedCacheKeyPrefix redis 已吊销 token(jti) key 前缀
+	TokenRevokedCacheKeyPrefix = "token_revoked"
+	// TokenValidAfterCacheKeyPrefix redis 用户 token 生效时间 key 前缀, 早于该时间签发的 token 均失效
+	TokenValidAfterCacheKeyPrefix = "token_valid_after"
+	// OidcStateCacheKeyPrefix redis oidc 登录 state key 前缀
+	OidcStateCacheKeyPrefix = "oidc_state"
+	// MfaTicketCacheKeyPrefix redis mfa 登录票据 key 前缀
+	MfaTicketCacheKeyPrefix = "mfa_ticket"
+	// MfaTicketAttemptsCacheKeyPrefix redis mfa 登录票据验证码错误次数 key 前缀
+	MfaTicketAttemptsCacheKeyPrefix = "mfa_ticket_attempts"
+	// MfaEnrollCacheKeyPrefix redis mfa 待确认密钥 key 前缀
+	MfaEnrollCacheKeyPrefix = "mfa_enroll"
+	// MfaUsedCacheKeyPrefix redis 已使用的 totp 周期 key 前缀, 防止验证码重放
+	MfaUsedCacheKeyPrefix = "mfa_used"
+	// MfaRecoveryUsedCacheKeyPrefix redis 已使用的恢复码 key 前缀, 防止并发请求重复使用同一恢复码
+	MfaRecoveryUsedCacheKeyPrefix = "mfa_recovery_used"
+	// MailTokenCacheKeyPrefix redis 邮件 token(邮箱验证, 重置密码) key 前缀
+	MailTokenCacheKeyPrefix = "mail_token"
+	// MailTokenUsedCacheKeyPrefix redis 邮件 token 已使用标记 key 前缀
+	MailTokenUsedCacheKeyPrefix = "mail_token_used"
+	// LoginFailureCacheKeyPrefix redis 登录失败计数 key 前缀
+	LoginFailureCacheKeyPrefix = "login_failures"
+	// LoginLockedCacheKeyPrefix redis 登录锁定标记 key 前缀
+	LoginLockedCacheKeyPrefix = "login_locked"
+	// LoginDelayCacheKeyPrefix redis 登录延迟标记 key 前缀, 存在时拒绝登录
+	LoginDelayCacheKeyPrefix = "login_delay"
+	// PasswordTicketCacheKeyPrefix redis 修改过期密码票据 key 前缀
+	PasswordTicketCacheKeyPrefix = "password_ticket"
+	// SessionSeenCacheKeyPrefix redis 会话访问标记 key 前缀, 存在时不更新最近访问时间
+	SessionSeenCacheKeyPrefix = "session_seen"
+)
+
+const (
+	// OidcStateExpireTime oidc 登录 state 有效期
+	OidcStateExpireTime = "10m"
+	// OidcStateCookie 保存 oidc 登录 state 的 cookie, 回调时校验
+	OidcStateCookie = "qqlx_oidc_state"
+	// OidcStateCookiePath oidc state cookie 只在回调接口发送
+	OidcStateCookiePath = "/api/v1/auth/oidc"
+	// MfaTicketExpireTime mfa 登录票据有效期
+	MfaTicketExpireTime = "5m"
+	// MfaEnrollExpireTime mfa 待确认密钥有效期
+	MfaEnrollExpireTime = "10m"
+	// MfaTicketMaxAttempts 每个 mfa 登录票据允许的验证码错误次数
+	MfaTicketMaxAttempts = 5
+	// MfaRecoveryCodeCount 恢复码数量
+	MfaRecoveryCodeCount = 10
+	// PasswordTicketExpireTime 密码过期后修改密码票据有效期
+	PasswordTicketExpireTime = "10m"
+	// ApiKeyPrefix API key 前缀, 认证时据此区分 API key 与 JWT
+	ApiKeyPrefix = "qqlx_"
+	// ApiKeyTouchInterval API key 最近使用时间的最小更新间隔(秒)
+	ApiKeyTouchInterval = 60
+	// SessionTouchInterval 会话最近访问时间的最小更新间隔
+	SessionTouchInterval = "1m"
+	// ServiceAccountEmailDomain 服务账号的邮箱域名, 服务账号不接收邮件
+	ServiceAccountEmailDomain = "serviceaccount.local"
 )
@@ -1,9 +1,13 @@
 package data
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"qqlx/base/conf"
+	"qqlx/base/constant"
+	"qqlx/base/health"
 
 	"go.uber.org/zap"
 
@@ -42,6 +46,28 @@ func InitCasbin() (e *casbin.Enforcer, err error) {
 		return nil, fmt.Errorf("failed to load adapter, %w", err)
 	}
 
+	// 增加 eft 之前创建的策略按 allow 处理, 需要在加载策略之前迁移
+	err = a.GetDb().Model(&gormadapter.CasbinRule{}).
+		Where("ptype = ? AND (v3 = '' OR v3 IS NULL)", "p").
+		Update("v3", constant.PolicyEffectAllow).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate policy effect, %w", err)
+	}
+	// 增加 dom 之前创建的策略为全局策略
+	err = a.GetDb().Model(&gormadapter.CasbinRule{}).
+		Where("ptype = ? AND (v4 = '' OR v4 IS NULL)", "p").
+		Update("v4", constant.CasbinGlobalDomain).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate policy domain, %w", err)
+	}
+	// 增加 cond 之前创建的策略没有条件
+	err = a.GetDb().Model(&gormadapter.CasbinRule{}).
+		Where("ptype = ? AND (v5 = '' OR v5 IS NULL)", "p").
+		Update("v5", constant.PolicyConditionNone).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate policy condition, %w", err)
+	}
+
 	// 初始化casbin
 	e, err = casbin.NewEnforcer(m, a)
 	if err != nil {
@@ -52,6 +78,16 @@ func InitCasbin() (e *casbin.Enforcer, err error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to load policy, %w", err)
 	}
+	health.Register("casbin", func(context.Context) error {
+		policies, err := e.GetPolicy()
+		if err != nil {
+			return err
+		}
+		if len(policies) == 0 {
+			return errors.New("casbin policy is not loaded")
+		}
+		return nil
+	})
 	zap.S().Info("casbin init success")
 	return e, nil
 }
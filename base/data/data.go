@@ -8,6 +8,9 @@ import (
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 	"qqlx/base/conf"
+	"qqlx/base/health"
+	"qqlx/base/metrics"
+	"qqlx/base/tracing"
 )
 
 func InitMySQL() (*gorm.DB, func(), error) {
@@ -30,6 +33,12 @@ func InitMySQL() (*gorm.DB, func(), error) {
 	if err != nil {
 		return nil, nil, fmt.Errorf("exception in initializing mysql database, %w", err)
 	}
+	if err = dbInstance.Use(metrics.GormPlugin{}); err != nil {
+		return nil, nil, err
+	}
+	if err = dbInstance.Use(tracing.GormPlugin{}); err != nil {
+		return nil, nil, err
+	}
 
 	// 确保数据库连接已建立
 	sqlDB, err := dbInstance.DB()
@@ -50,6 +59,7 @@ func InitMySQL() (*gorm.DB, func(), error) {
 	// 设置连接的最大生命周期
 	sqlDB.SetConnMaxLifetime(conf.GetMysqlMaxLifetime())
 
+	health.Register("mysql", sqlDB.PingContext)
 	zap.S().Info("mysql connect success")
 	return dbInstance, func() { _ = sqlDB.Close() }, nil
 }
@@ -1,10 +1,12 @@
 package data
 
 import (
+	"context"
 	"fmt"
 	"github.com/go-ldap/ldap/v3"
 	"go.uber.org/zap"
 	"qqlx/base/conf"
+	"qqlx/base/health"
 )
 
 func InitLdap() (l *ldap.Conn, close func(), err error) {
@@ -35,6 +37,12 @@ func InitLdap() (l *ldap.Conn, close func(), err error) {
 	if err != nil {
 		return nil, nil, fmt.Errorf("bind ldap failed, username: %s, err: %w", username, err)
 	}
+	// 查询 root DSE 检查连接和绑定是否可用
+	health.Register("ldap", func(context.Context) error {
+		_, err := l.Search(ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 1, 0, false,
+			"(objectClass=*)", []string{"1.1"}, nil))
+		return err
+	})
 	zap.S().Info("ldap connect success")
 	return l, func() { _ = l.Close() }, nil
 }
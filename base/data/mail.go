@@ -0,0 +1,31 @@
+package data
+
+import (
+	"qqlx/base/conf"
+	"qqlx/base/interfaces"
+	"qqlx/pkg/mailer"
+
+	"go.uber.org/zap"
+)
+
+func InitMailer() (interfaces.Mailer, error) {
+	if conf.GetMailDriver() != conf.MailDriverSMTP {
+		zap.S().Info("mail driver is log, mails will only be logged")
+		return mailer.NewLogMailer(), nil
+	}
+	host, err := conf.GetMailSMTPHost()
+	if err != nil {
+		return nil, err
+	}
+	from, err := conf.GetMailFrom()
+	if err != nil {
+		return nil, err
+	}
+	return mailer.NewSMTPMailer(&mailer.SMTPConfig{
+		Host:     host,
+		Port:     conf.GetMailSMTPPort(),
+		Username: conf.GetMailSMTPUsername(),
+		Password: conf.GetMailSMTPPassword(),
+		From:     from,
+	}), nil
+}
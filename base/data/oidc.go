@@ -0,0 +1,45 @@
+package data
+
+import (
+	"context"
+	"qqlx/base/conf"
+	"qqlx/pkg/oidc"
+
+	"go.uber.org/zap"
+)
+
+func InitOIDC(ctx context.Context) (*oidc.Client, error) {
+	if !conf.GetOidcEnable() {
+		return nil, nil
+	}
+
+	issuer, err := conf.GetOidcIssuer()
+	if err != nil {
+		return nil, err
+	}
+	clientID, err := conf.GetOidcClientID()
+	if err != nil {
+		return nil, err
+	}
+	redirectURL, err := conf.GetOidcRedirectURL()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := oidc.NewClient(ctx, &oidc.Config{
+		Issuer:        issuer,
+		ClientID:      clientID,
+		ClientSecret:  conf.GetOidcClientSecret(),
+		RedirectURL:   redirectURL,
+		Scopes:        conf.GetOidcScopes(),
+		NameClaim:     conf.GetOidcClaim("name", "preferred_username"),
+		EmailClaim:    conf.GetOidcClaim("email", "email"),
+		NickNameClaim: conf.GetOidcClaim("nickName", "name"),
+		GroupsClaim:   conf.GetOidcClaim("groups", "groups"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	zap.S().Info("oidc provider discovery success")
+	return client, nil
+}
@@ -6,6 +6,9 @@ import (
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 	"qqlx/base/conf"
+	"qqlx/base/health"
+	"qqlx/base/metrics"
+	"qqlx/base/tracing"
 )
 
 func CreateRDB(ctx context.Context) (*redis.Client, error) {
@@ -33,10 +36,13 @@ func initSingleRedis(ctx context.Context) (*redis.Client, error) {
 		Password: password,
 		DB:       conf.GetRedisDB(),
 	})
+	rdb.AddHook(metrics.RedisHook{})
+	rdb.AddHook(tracing.RedisHook{})
 	err = rdb.Ping(ctx).Err()
 	if err != nil {
 		return nil, fmt.Errorf("redis connect failed: %w", err)
 	}
+	registerRedisHealth(rdb)
 	zap.S().Info("redis connect success")
 	return rdb, nil
 }
@@ -66,10 +72,19 @@ func initSentinelRedis(ctx context.Context) (*redis.Client, error) {
 		RouteByLatency:   true,
 		DB:               conf.GetRedisDB(),
 	})
+	rdb.AddHook(metrics.RedisHook{})
+	rdb.AddHook(tracing.RedisHook{})
 	err = rdb.Ping(ctx).Err()
 	if err != nil {
 		return nil, fmt.Errorf("redis sentinel connect failed: %w", err)
 	}
+	registerRedisHealth(rdb)
 	zap.S().Info("redis sentinel connect success")
 	return rdb, nil
 }
+
+func registerRedisHealth(rdb *redis.Client) {
+	health.Register("redis", func(ctx context.Context) error {
+		return rdb.Ping(ctx).Err()
+	})
+}
@@ -0,0 +1,129 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"qqlx/base/conf"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 检查结果
+const (
+	StatusOK           = "ok"
+	StatusFail         = "fail"
+	StatusShuttingDown = "shutting down"
+)
+
+var errTimeout = errors.New("health check timed out")
+
+// Check 检查依赖是否可用, 需要在 ctx 超时后尽快返回
+type Check func(ctx context.Context) error
+
+type Component struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Response struct {
+	Status     string                `json:"status"`
+	Components map[string]*Component `json:"components,omitempty"`
+}
+
+var (
+	mu     sync.RWMutex
+	checks = make(map[string]Check)
+	// draining 应用停止时设置, 之后 readyz 一直返回失败
+	draining atomic.Bool
+)
+
+// Register 注册就绪检查, 同名的检查会被替换, 由 base/data 中的初始化函数调用
+func Register(name string, check Check) {
+	mu.Lock()
+	defer mu.Unlock()
+	checks[name] = check
+}
+
+// Drain 将就绪状态设置为失败, 负载均衡停止转发新请求
+func Drain() {
+	draining.Store(true)
+}
+
+// Ready 并发执行所有检查, 每个检查最多执行 health.timeout
+func Ready(ctx context.Context) *Response {
+	if draining.Load() {
+		return &Response{Status: StatusShuttingDown}
+	}
+	mu.RLock()
+	current := make(map[string]Check, len(checks))
+	for name, check := range checks {
+		current[name] = check
+	}
+	mu.RUnlock()
+
+	res := &Response{Status: StatusOK, Components: make(map[string]*Component, len(current))}
+	var (
+		wg       sync.WaitGroup
+		resultMu sync.Mutex
+	)
+	for name, check := range current {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			component := run(ctx, check)
+			resultMu.Lock()
+			defer resultMu.Unlock()
+			res.Components[name] = component
+			if component.Status != StatusOK {
+				res.Status = StatusFail
+			}
+		}(name, check)
+	}
+	wg.Wait()
+	return res
+}
+
+// run 执行检查, 检查没有按 ctx 返回时不再等待
+func run(ctx context.Context, check Check) *Component {
+	ctx, cancel := context.WithTimeout(ctx, conf.GetHealthTimeout())
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = errTimeout
+	}
+	component := &Component{
+		Status:    StatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		component.Status = StatusFail
+		component.Error = err.Error()
+	}
+	return component
+}
+
+// LivezHandler 进程存活即返回成功, 不检查依赖
+func LivezHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, &Response{Status: StatusOK})
+}
+
+// ReadyzHandler 依赖都可用时返回 200, 否则返回 503
+func ReadyzHandler(c *gin.Context) {
+	res := Ready(c.Request.Context())
+	code := http.StatusOK
+	if res.Status != StatusOK {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, res)
+}
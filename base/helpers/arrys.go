@@ -53,3 +53,56 @@ func FindMissingByID[T IDed](existing []T, target []int) []int {
 	}
 	return missing
 }
+
+// FindMissing 返回 target 中不在 existing 中的元素
+func FindMissing[T comparable](existing []T, target []T) []T {
+	set := make(map[T]struct{}, len(existing))
+	for _, item := range existing {
+		set[item] = struct{}{}
+	}
+
+	var missing []T
+	for _, item := range target {
+		if _, ok := set[item]; !ok {
+			missing = append(missing, item)
+		}
+	}
+	return missing
+}
+
+// GetIDs 返回 items 的 ID
+func GetIDs[T IDed](items []T) []int {
+	ids := make([]int, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.GetID())
+	}
+	return ids
+}
+
+// GetNames 返回 items 的 Name
+func GetNames[T Named](items []T) []string {
+	names := make([]string, 0, len(items))
+	for _, item := range items {
+		names = append(names, item.GetName())
+	}
+	return names
+}
+
+// ScopeRoles 返回 roles 中同时存在于 scope 的角色, scope 为 nil 时不限制
+func ScopeRoles(roles, scope []string) []string {
+	if scope == nil {
+		return roles
+	}
+	scopeSet := make(map[string]struct{}, len(scope))
+	for _, role := range scope {
+		scopeSet[role] = struct{}{}
+	}
+
+	result := make([]string, 0, len(roles))
+	for _, role := range roles {
+		if _, ok := scopeSet[role]; ok {
+			result = append(result, role)
+		}
+	}
+	return result
+}
@@ -8,3 +8,82 @@ import (
 func GetRoleCacheKey(name string) string {
 	return fmt.Sprintf("%s:%s", constant.RoleCacheKeyPrefix, name)
 }
+
+// GetTenantRoleCacheKey 用户在租户内的角色缓存 key
+func GetTenantRoleCacheKey(tenantID int, name string) string {
+	return fmt.Sprintf("%s:%d:%s", constant.TenantRoleCacheKeyPrefix, tenantID, name)
+}
+
+func GetRefreshTokenCacheKey(hash string) string {
+	return fmt.Sprintf("%s:%s", constant.RefreshTokenCacheKeyPrefix, hash)
+}
+
+func GetRefreshUsedCacheKey(hash string) string {
+	return fmt.Sprintf("%s:%s", constant.RefreshUsedCacheKeyPrefix, hash)
+}
+
+func GetRefreshFamilyCacheKey(familyID string) string {
+	return fmt.Sprintf("%s:%s", constant.RefreshFamilyCacheKeyPrefix, familyID)
+}
+
+func GetTokenRevokedCacheKey(jti string) string {
+	return fmt.Sprintf("%s:%s", constant.TokenRevokedCacheKeyPrefix, jti)
+}
+
+func GetTokenValidAfterCacheKey(userID int) string {
+	return fmt.Sprintf("%s:%d", constant.TokenValidAfterCacheKeyPrefix, userID)
+}
+
+func GetOidcStateCacheKey(state string) string {
+	return fmt.Sprintf("%s:%s", constant.OidcStateCacheKeyPrefix, state)
+}
+
+func GetMfaTicketCacheKey(ticket string) string {
+	return fmt.Sprintf("%s:%s", constant.MfaTicketCacheKeyPrefix, ticket)
+}
+
+func GetMfaTicketAttemptsCacheKey(ticket string) string {
+	return fmt.Sprintf("%s:%s", constant.MfaTicketAttemptsCacheKeyPrefix, ticket)
+}
+
+func GetMfaEnrollCacheKey(userID int) string {
+	return fmt.Sprintf("%s:%d", constant.MfaEnrollCacheKeyPrefix, userID)
+}
+
+func GetMfaUsedCacheKey(userID int, step int64) string {
+	return fmt.Sprintf("%s:%d:%d", constant.MfaUsedCacheKeyPrefix, userID, step)
+}
+
+func GetMfaRecoveryUsedCacheKey(userID int, hash string) string {
+	return fmt.Sprintf("%s:%d:%s", constant.MfaRecoveryUsedCacheKeyPrefix, userID, hash)
+}
+
+func GetMailTokenCacheKey(purpose, hash string) string {
+	return fmt.Sprintf("%s:%s:%s", constant.MailTokenCacheKeyPrefix, purpose, hash)
+}
+
+func GetMailTokenUsedCacheKey(purpose, hash string) string {
+	return fmt.Sprintf("%s:%s:%s", constant.MailTokenUsedCacheKeyPrefix, purpose, hash)
+}
+
+// GetLoginFailureCacheKey scope 为 account 或 ip
+func GetLoginFailureCacheKey(scope, id string) string {
+	return fmt.Sprintf("%s:%s:%s", constant.LoginFailureCacheKeyPrefix, scope, id)
+}
+
+// GetLoginLockedCacheKey scope 为 account 或 ip
+func GetLoginLockedCacheKey(scope, id string) string {
+	return fmt.Sprintf("%s:%s:%s", constant.LoginLockedCacheKeyPrefix, scope, id)
+}
+
+func GetLoginDelayCacheKey(account string) string {
+	return fmt.Sprintf("%s:%s", constant.LoginDelayCacheKeyPrefix, account)
+}
+
+func GetPasswordTicketCacheKey(ticket string) string {
+	return fmt.Sprintf("%s:%s", constant.PasswordTicketCacheKeyPrefix, ticket)
+}
+
+func GetSessionSeenCacheKey(sessionID string) string {
+	return fmt.Sprintf("%s:%s", constant.SessionSeenCacheKeyPrefix, sessionID)
+}
@@ -1,14 +1,112 @@
 package helpers
 
-import "qqlx/model"
+import (
+	"fmt"
+	"qqlx/base/constant"
+	"qqlx/model"
+	"strconv"
+	"strings"
+	"time"
+)
 
-// GetCasbinRole  获取role拥有的权限
+// ActiveRoleNames 未过期的角色名称, ttl 为最早过期的临时角色剩余时间, 没有临时角色时为 0
+func ActiveRoleNames(assignments []model.UserRole, now time.Time) (names []string, ttl time.Duration) {
+	for _, assignment := range assignments {
+		if assignment.Role == nil {
+			continue
+		}
+		if assignment.ExpiresAt != 0 {
+			remaining := time.Unix(int64(assignment.ExpiresAt), 0).Sub(now)
+			if remaining <= 0 {
+				continue
+			}
+			if ttl == 0 || remaining < ttl {
+				ttl = remaining
+			}
+		}
+		names = append(names, assignment.Role.Name)
+	}
+	return names, ttl
+}
+
+// GetCasbinRole  获取role拥有的权限, 租户角色的权限只在所属租户内生效
 //
-// policys [][]string{role, path, method}
-func GetCasbinRole(roleName string, policys []model.Policy) [][]string {
+// policys [][]string{role, path, method, eft, dom, cond}
+func GetCasbinRole(role *model.Role, policys []model.Policy) [][]string {
+	domain := GetCasbinDomain(role.TenantID)
 	save := make([][]string, len(policys))
 	for i, policy := range policys {
-		save[i] = []string{roleName, policy.Path, policy.Method}
+		save[i] = []string{GetCasbinSubject(role.TenantID, role.Name), policy.Path, policy.Method, PolicyEffect(policy.Effect), domain, PolicyCondition(policy.Condition)}
 	}
 	return save
 }
+
+// GetCasbinSubject 角色在 casbin 中的 subject, 租户角色加上租户前缀, 避免与其他租户或全局的同名角色共用策略和父角色
+func GetCasbinSubject(tenantID int, name string) string {
+	if tenantID == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s%d:%s", constant.CasbinTenantSubjectPrefix, tenantID, name)
+}
+
+// ParseCasbinSubject 从 casbin subject 解析出角色所属租户和角色名
+func ParseCasbinSubject(subject string) (tenantID int, name string) {
+	rest, ok := strings.CutPrefix(subject, constant.CasbinTenantSubjectPrefix)
+	if !ok {
+		return 0, subject
+	}
+	id, name, ok := strings.Cut(rest, ":")
+	if !ok {
+		return 0, subject
+	}
+	tenantID, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, subject
+	}
+	return tenantID, name
+}
+
+// GetCasbinDomain 租户对应的 casbin domain, 全局为 *
+func GetCasbinDomain(tenantID int) string {
+	if tenantID == 0 {
+		return constant.CasbinGlobalDomain
+	}
+	return strconv.Itoa(tenantID)
+}
+
+// PolicyEffect 策略效果, 未设置时为 allow
+func PolicyEffect(effect string) string {
+	if effect == "" {
+		return constant.PolicyEffectAllow
+	}
+	return effect
+}
+
+// PolicyCondition 策略条件, 为空时没有条件
+func PolicyCondition(condition string) string {
+	if condition == "" {
+		return constant.PolicyConditionNone
+	}
+	return condition
+}
+
+// RoleHasCycle 判断为角色设置父角色后是否形成环
+//
+// parents 当前所有角色的父角色 map[角色ID][]父角色ID
+func RoleHasCycle(parents map[int][]int, id int, newParents []int) bool {
+	visited := make(map[int]struct{}, len(parents))
+	stack := append([]int(nil), newParents...)
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if current == id {
+			return true
+		}
+		if _, ok := visited[current]; ok {
+			continue
+		}
+		visited[current] = struct{}{}
+		stack = append(stack, parents[current]...)
+	}
+	return false
+}
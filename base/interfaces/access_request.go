@@ -0,0 +1,31 @@
+package interfaces
+
+import (
+	"context"
+	"qqlx/model"
+	"qqlx/store/rbac"
+)
+
+// AccessRequestStoreInterface 角色访问申请
+type AccessRequestStoreInterface interface {
+	Create(ctx context.Context, request *model.AccessRequest) (err error)
+	// Query 查询申请, 同时加载申请人、角色和角色的审批人
+	Query(ctx context.Context, id int) (request *model.AccessRequest, err error)
+	Save(ctx context.Context, request *model.AccessRequest) (err error)
+	// Decide 申请状态为 from 时更新状态和审批信息
+	//
+	// @param request 更新后的申请
+	// @param from 更新前的状态
+	// @return ok 是否更新, 状态已被其他请求修改时为 false
+	// @return err 错误
+	Decide(ctx context.Context, request *model.AccessRequest, from string) (ok bool, err error)
+	// List 按申请时间倒序查询
+	//
+	// @param page 页码, -1 不分页
+	// @param pageSize 每页数量, -1 不分页
+	// @param options 查询选项
+	// @return total 总数
+	// @return requests 申请列表
+	// @return err 错误
+	List(ctx context.Context, page, pageSize int, options ...rbac.AccessRequestQueryOption) (total int64, requests []model.AccessRequest, err error)
+}
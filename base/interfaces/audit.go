@@ -0,0 +1,29 @@
+package interfaces
+
+import (
+	"context"
+	"qqlx/model"
+	"qqlx/store/audit"
+)
+
+// AuditStoreInterface 审计记录
+type AuditStoreInterface interface {
+	// Append 追加记录, 同一时间只有一个追加
+	//
+	// @param event 记录, 写入后设置 ID、PrevHash 和 Hash
+	// @param seal 根据 PrevHash 和记录内容计算 Hash
+	// @return err 错误
+	Append(ctx context.Context, event *model.AuditEvent, seal func(event *model.AuditEvent) string) (err error)
+	Query(ctx context.Context, id int) (event *model.AuditEvent, err error)
+	// Chain 哈希链的链头, 没有记录时为空
+	Chain(ctx context.Context) (chain *model.AuditChain, err error)
+	// ListAfter 按 id 正序查询, 用于校验哈希链
+	ListAfter(ctx context.Context, afterID, limit int) (events []model.AuditEvent, err error)
+	// List 按 id 倒序查询
+	//
+	// @param limit 最多返回的数量
+	// @param options 查询选项
+	// @return events 审计记录
+	// @return err 错误
+	List(ctx context.Context, limit int, options ...audit.QueryOption) (events []model.AuditEvent, err error)
+}
@@ -36,11 +36,25 @@ type CacheInterface interface {
 	// @param expireTime 过期时间
 	// @return err 错误
 	SetInt64(ctx context.Context, key string, value int64, expireTime *time.Duration) (err error)
+	// SetNX 键不存在时设置字符串
+	//
+	// @param key 键
+	// @param value 值
+	// @param expireTime 过期时间
+	// @return ok 是否设置成功, 键已存在时返回 false
+	// @return err 错误
+	SetNX(ctx context.Context, key, value string, expireTime *time.Duration) (ok bool, err error)
 	// Incr 自增
 	// @param key 键
 	// @return int64 自增后的值
 	// @return err 错误
 	Incr(ctx context.Context, key string) (int64, error)
+	// Expire 设置过期时间
+	//
+	// @param key 键
+	// @param expireTime 过期时间
+	// @return err 错误
+	Expire(ctx context.Context, key string, expireTime time.Duration) (err error)
 	// Del 删除
 	//
 	// @param key 键
@@ -2,6 +2,7 @@ package interfaces
 
 import (
 	"context"
+	"qqlx/store/rbac"
 )
 
 // CasbinInterface casbin 权限接口
@@ -9,25 +10,37 @@ type CasbinInterface interface {
 	// GetRolePolicyByName 获取角色策略
 	//
 	// @param role 角色名
-	// @return polices 策略, polices [][]string{role, path, method}
+	// @return polices 策略, polices [][]string{role, path, method, eft, dom, cond}
 	// @return err 错误
 	GetRolePolicyByName(ctx context.Context, role string) (polices [][]string, err error)
 	// CreateRolePolices 创建角色策略
 	//
-	// @param polices 策略, polices [][]string{role, path, method}
+	// @param polices 策略, polices [][]string{role, path, method, eft, dom, cond}
 	// @return err 错误
 	CreateRolePolices(ctx context.Context, polices [][]string) (err error)
 	// DeleteRolePolices 删除角色策略
 	//
-	// @param polices 策略, polices [][]string{role, path, method}
+	// @param polices 策略, polices [][]string{role, path, method, eft, dom, cond}
 	// @return err 错误
 	DeleteRolePolices(ctx context.Context, polices [][]string) (err error)
 	// UpdateRolePolices 更新角色策略
 	//
 	// @param roleName 角色名
-	// @param polices 策略, polices [][]string{role, path, method}
+	// @param polices 策略, polices [][]string{role, path, method, eft, dom, cond}
 	// @return err 错误
 	UpdateRolePolices(ctx context.Context, roleName string, polices [][]string) (err error)
+	// GetImplicitRolePolices 获取角色的有效策略, 包含从父角色继承的策略
+	//
+	// @param role 角色名
+	// @return polices 策略, polices [][]string{来源角色, path, method, eft, dom, cond}
+	// @return err 错误
+	GetImplicitRolePolices(ctx context.Context, role string) (polices [][]string, err error)
+	// SetRoleParents 设置角色继承关系, 替换原有的 g 规则
+	//
+	// @param role 角色名
+	// @param parents 父角色名, 为空时清空
+	// @return err 错误
+	SetRoleParents(ctx context.Context, role string, parents []string) (err error)
 }
 
 // Authorizer 登录时验证用户权限
@@ -35,7 +48,17 @@ type Authorizer interface {
 	// EnforceWithCtx 验证用户是否具有权限
 	//
 	// @param sub 用户名
+	// @param dom 租户, 全局为 *
 	// @param obj 资源
 	// @param act 操作
-	EnforceWithCtx(ctx context.Context, sub, obj, act string) (ok bool, err error)
+	// @param env 条件策略使用的请求属性
+	EnforceWithCtx(ctx context.Context, sub, dom, obj, act string, env *rbac.ConditionEnv) (ok bool, err error)
+	// EnforceRolesWithCtx 使用用户的所有角色鉴权, 任一角色匹配到 deny 策略时拒绝
+	//
+	// @param roles 角色名
+	EnforceRolesWithCtx(ctx context.Context, roles []string, dom, obj, act string, env *rbac.ConditionEnv) (ok bool, err error)
+	// ExplainWithCtx 鉴权并返回决定结果的策略
+	//
+	// @return explain 策略, explain []string{role, path, method, eft, dom, cond}, 没有匹配的策略时为空
+	ExplainWithCtx(ctx context.Context, sub, dom, obj, act string, env *rbac.ConditionEnv) (ok bool, explain []string, err error)
 }
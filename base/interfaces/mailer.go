@@ -0,0 +1,14 @@
+package interfaces
+
+import "context"
+
+// Mailer 邮件发送
+type Mailer interface {
+	// Send 发送纯文本邮件
+	//
+	// @param to 收件人
+	// @param subject 主题
+	// @param body 正文
+	// @return err 错误
+	Send(ctx context.Context, to []string, subject, body string) (err error)
+}
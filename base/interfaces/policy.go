@@ -3,6 +3,7 @@ package interfaces
 import (
 	"context"
 	"qqlx/model"
+	"qqlx/schema"
 	"qqlx/store/rbac"
 )
 
@@ -24,3 +25,9 @@ type PolicyStoreInterface interface {
 	Delete(ctx context.Context, policy *model.Policy, options ...rbac.PolicyDeleteOption) (err error)
 	List(ctx context.Context, page, pageSize int, options ...rbac.PolicyQueryOption) (total int64, polices []model.Policy, err error)
 }
+
+// RouteCatalogInterface 路由目录
+type RouteCatalogInterface interface {
+	// Routes 当前注册的路由
+	Routes() []schema.CatalogRoute
+}
@@ -0,0 +1,25 @@
+package interfaces
+
+import (
+	"context"
+	"qqlx/model"
+	"qqlx/store/rbac"
+)
+
+// RevisionStoreInterface 角色策略变更记录
+type RevisionStoreInterface interface {
+	Create(ctx context.Context, revision *model.RbacRevision) (err error)
+	Query(ctx context.Context, id int) (revision *model.RbacRevision, err error)
+	// List 按版本倒序查询, 不返回快照
+	List(ctx context.Context, page, pageSize int) (total int64, revisions []model.RbacRevision, err error)
+	// Snapshot 当前所有角色的策略ID
+	//
+	// @return snapshot 角色名对应的策略ID
+	Snapshot(ctx context.Context) (snapshot map[string][]int, err error)
+	// Restore 在事务中恢复角色的策略、父角色以及策略的效果和条件
+	//
+	// @param restore 需要恢复的数据
+	// @param sync 同步 casbin 策略, 返回错误时回滚事务
+	// @return err 错误
+	Restore(ctx context.Context, restore *rbac.RevisionRestore, sync func() error) (err error)
+}
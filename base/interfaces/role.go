@@ -40,4 +40,19 @@ type RolePolicyStoreInterface interface {
 	// @param policy 策略, 需要删除的策略
 	// @return err 错误
 	DeletePolicy(ctx context.Context, role *model.Role, policy []model.Policy) (err error)
+
+	// ReplaceParents 在事务中替换父角色
+	//
+	// @param role 角色
+	// @param parents 父角色, 为空时清空
+	// @param sync 同步 casbin, 返回错误时回滚事务
+	// @return err 错误
+	ReplaceParents(ctx context.Context, role *model.Role, parents []model.Role, sync func() error) (err error)
+
+	// ReplaceApprovers 替换审批人
+	//
+	// @param role 角色
+	// @param approvers 审批人, 为空时清空
+	// @return err 错误
+	ReplaceApprovers(ctx context.Context, role *model.Role, approvers []model.User) (err error)
 }
@@ -0,0 +1,41 @@
+package interfaces
+
+import (
+	"context"
+	"qqlx/model"
+)
+
+// TenantStoreInterface 租户CRUD
+type TenantStoreInterface interface {
+	Create(ctx context.Context, tenant *model.Tenant) (err error)
+	Query(ctx context.Context, id int) (tenant *model.Tenant, err error)
+	QueryByName(ctx context.Context, name string) (tenant *model.Tenant, err error)
+	List(ctx context.Context) (tenants []model.Tenant, err error)
+	Delete(ctx context.Context, tenant *model.Tenant) (err error)
+}
+
+// TenantMemberStoreInterface 租户成员
+type TenantMemberStoreInterface interface {
+	// Query 查询租户成员, 同时加载成员在租户内的角色
+	//
+	// @param tenantID 租户ID
+	// @param userID 用户ID
+	// @return member 成员
+	// @return err 错误
+	Query(ctx context.Context, tenantID, userID int) (member *model.TenantMember, err error)
+	List(ctx context.Context, tenantID int) (members []model.TenantMember, err error)
+	Save(ctx context.Context, member *model.TenantMember) (err error)
+	// ReplaceRoles 替换成员在租户内的角色
+	//
+	// @param member 成员
+	// @param roles 角色, 为空时清空
+	// @return err 错误
+	ReplaceRoles(ctx context.Context, member *model.TenantMember, roles []model.Role) (err error)
+	// CountByRole 统计拥有该租户角色的成员数量
+	//
+	// @param roleID 角色ID
+	// @return count 数量
+	// @return err 错误
+	CountByRole(ctx context.Context, roleID int) (count int64, err error)
+	Delete(ctx context.Context, member *model.TenantMember) (err error)
+}
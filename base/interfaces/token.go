@@ -0,0 +1,35 @@
+package interfaces
+
+import (
+	"context"
+	"qqlx/pkg/jwt"
+)
+
+// TokenRevocationInterface token 吊销校验
+type TokenRevocationInterface interface {
+	// IsRevoked 判断 token 是否已被吊销
+	//
+	// @param claims token 解析后的声明
+	// @return revoked 是否已吊销
+	// @return err 错误
+	IsRevoked(ctx context.Context, claims *jwt.MyClaims) (revoked bool, err error)
+}
+
+// ApiKeyAuthenticatorInterface API key 认证
+type ApiKeyAuthenticatorInterface interface {
+	// Authenticate 校验 API key, 返回与登录 token 相同的声明
+	//
+	// @param key API key
+	// @return claims 声明
+	// @return err 错误
+	Authenticate(ctx context.Context, key string) (claims *jwt.MyClaims, err error)
+}
+
+// SessionTouchInterface 记录会话访问
+type SessionTouchInterface interface {
+	// Touch 更新 token 所在会话的最近访问时间
+	//
+	// @param claims token 解析后的声明
+	// @return err 错误
+	Touch(ctx context.Context, claims *jwt.MyClaims) (err error)
+}
@@ -47,4 +47,105 @@ type UserRoleStoreInterface interface {
 	// @param roles 角色
 	// @return err 错误
 	DeleteRoles(ctx context.Context, user *model.User, roles []model.Role) (err error)
+	// SetRoleExpiry 设置用户角色的过期时间和原因
+	//
+	// @param expiresAt 过期时间, 0 为永久
+	SetRoleExpiry(ctx context.Context, userID int, roleIDs []int, expiresAt int, reason string) (err error)
+	// ListAssignments 用户的角色授权, 同时加载角色
+	ListAssignments(ctx context.Context, userID int) (assignments []model.UserRole, err error)
+	// ListActive 用户未过期的角色授权, 同时加载角色, 鉴权时使用
+	//
+	// @param now 当前时间戳
+	ListActive(ctx context.Context, userID int, now int) (assignments []model.UserRole, err error)
+	// ListExpired 已过期的角色授权, 同时加载角色
+	//
+	// @param now 当前时间戳
+	ListExpired(ctx context.Context, now int) (assignments []model.UserRole, err error)
+}
+
+// PasswordHistoryStoreInterface 用户历史密码
+type PasswordHistoryStoreInterface interface {
+	Create(ctx context.Context, history *model.PasswordHistory) (err error)
+	// ListRecent 查询最近的历史密码
+	//
+	// @param userID 用户ID
+	// @param limit 数量
+	// @return histories 历史密码, 按时间倒序
+	// @return err 错误
+	ListRecent(ctx context.Context, userID, limit int) (histories []model.PasswordHistory, err error)
+	// Prune 删除较早的历史密码
+	//
+	// @param userID 用户ID
+	// @param keep 保留的数量
+	// @return err 错误
+	Prune(ctx context.Context, userID, keep int) (err error)
+}
+
+// ApiKeyStoreInterface API key
+type ApiKeyStoreInterface interface {
+	Create(ctx context.Context, key *model.ApiKey) (err error)
+	// QueryByHash 根据摘要查询, 同时加载所属用户
+	//
+	// @param hash key 的 sha256 摘要
+	// @return key API key
+	// @return err 错误
+	QueryByHash(ctx context.Context, hash string) (key *model.ApiKey, err error)
+	// Query 查询用户的 API key
+	//
+	// @param id API key ID
+	// @param userID 所属用户ID
+	// @return key API key
+	// @return err 错误
+	Query(ctx context.Context, id, userID int) (key *model.ApiKey, err error)
+	List(ctx context.Context, userID int) (keys []model.ApiKey, err error)
+	Delete(ctx context.Context, key *model.ApiKey) (err error)
+	// Touch 更新最近使用时间
+	//
+	// @param id API key ID
+	// @param lastUsedAt 最近使用时间
+	// @return err 错误
+	Touch(ctx context.Context, id, lastUsedAt int) (err error)
+}
+
+// SessionStoreInterface 登录会话
+type SessionStoreInterface interface {
+	Create(ctx context.Context, session *model.Session) (err error)
+	// Query 查询用户的会话
+	//
+	// @param id 会话ID
+	// @param userID 用户ID
+	// @return session 会话
+	// @return err 错误
+	Query(ctx context.Context, id string, userID int) (session *model.Session, err error)
+	// List 查询用户未过期的会话
+	//
+	// @param userID 用户ID
+	// @return sessions 会话, 按创建时间倒序
+	// @return err 错误
+	List(ctx context.Context, userID int) (sessions []model.Session, err error)
+	// Rotate 刷新 token 后更新会话对应的 access token
+	//
+	// @param id 会话ID
+	// @param tokenID access token jti
+	// @param expiresAt 会话过期时间
+	// @return err 错误
+	Rotate(ctx context.Context, id, tokenID string, expiresAt int) (err error)
+	// Touch 更新最近访问时间
+	//
+	// @param id 会话ID
+	// @param lastSeenAt 最近访问时间
+	// @return err 错误
+	Touch(ctx context.Context, id string, lastSeenAt int) (err error)
+	Delete(ctx context.Context, id string) (err error)
+	// DeleteByUser 删除用户的所有会话
+	//
+	// @param userID 用户ID
+	// @return err 错误
+	DeleteByUser(ctx context.Context, userID int) (err error)
+	// Purge 物理删除已过期和已软删除的会话
+	//
+	// @param now 当前时间, 过期时间不晚于该时间的会话被删除
+	// @return count 删除的会话数量
+	// @return err 错误
+	Purge(ctx context.Context, now int) (count int64, err error)
 }
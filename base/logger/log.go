@@ -6,6 +6,7 @@ import (
 	"qqlx/base/conf"
 	"qqlx/base/constant"
 
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -50,13 +51,17 @@ func InitLogger() {
 	zap.S().Infof("log initialization successful, log level: %s", logLevelStr)
 }
 
+// WithContext ctx 中有 span 时附加 otelTraceID 和 spanID, 与链路追踪关联
 func WithContext(ctx context.Context, addCaller bool) *zap.SugaredLogger {
+	lg := zap.S()
 	if addCaller {
-		lg := zap.S().WithOptions(zap.AddCaller())
+		lg = lg.WithOptions(zap.AddCaller())
 		if traceID := ctx.Value(constant.TraceID).(string); traceID != "" {
-			return lg.With(constant.TraceID, traceID)
+			lg = lg.With(constant.TraceID, traceID)
 		}
-		return lg
 	}
-	return zap.S()
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		lg = lg.With("otelTraceID", sc.TraceID().String(), "spanID", sc.SpanID().String())
+	}
+	return lg
 }
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const gormStartKey = "metrics:start"
+
+// GormPlugin 统计 gorm 的 create/query/update/delete/row/raw 耗时
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "metrics"
+}
+
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	for _, err := range []error{
+		callback.Create().Before("gorm:create").Register("metrics:before_create", gormBefore),
+		callback.Create().After("gorm:create").Register("metrics:after_create", gormAfter("create")),
+		callback.Query().Before("gorm:query").Register("metrics:before_query", gormBefore),
+		callback.Query().After("gorm:query").Register("metrics:after_query", gormAfter("query")),
+		callback.Update().Before("gorm:update").Register("metrics:before_update", gormBefore),
+		callback.Update().After("gorm:update").Register("metrics:after_update", gormAfter("update")),
+		callback.Delete().Before("gorm:delete").Register("metrics:before_delete", gormBefore),
+		callback.Delete().After("gorm:delete").Register("metrics:after_delete", gormAfter("delete")),
+		callback.Row().Before("gorm:row").Register("metrics:before_row", gormBefore),
+		callback.Row().After("gorm:row").Register("metrics:after_row", gormAfter("row")),
+		callback.Raw().Before("gorm:raw").Register("metrics:before_raw", gormBefore),
+		callback.Raw().After("gorm:raw").Register("metrics:after_raw", gormAfter("raw")),
+	} {
+		if err != nil {
+			return fmt.Errorf("register gorm metrics callback failed, %w", err)
+		}
+	}
+	return nil
+}
+
+func gormBefore(db *gorm.DB) {
+	db.InstanceSet(gormStartKey, time.Now())
+}
+
+func gormAfter(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(gormStartKey)
+		if !ok {
+			return
+		}
+		start, ok := value.(time.Time)
+		if !ok {
+			return
+		}
+		err := db.Error
+		// 查询不到记录是正常的业务结果
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = nil
+		}
+		DBQueryDuration.WithLabelValues(operation, db.Statement.Table, Result(err)).Observe(time.Since(start).Seconds())
+	}
+}
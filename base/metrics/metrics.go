@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "qqlx"
+
+// 鉴权结果和缓存结果标签
+const (
+	ResultAllow = "allow"
+	ResultDeny  = "deny"
+	ResultError = "error"
+	ResultHit   = "hit"
+	ResultMiss  = "miss"
+)
+
+var (
+	// HTTPRequestDuration 按路由模板统计请求耗时, 未匹配的路由为 unmatched
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// AuthFailures 认证和鉴权失败次数, stage 为 authentication 或 authorization
+	AuthFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "auth",
+		Name:      "failures_total",
+		Help:      "Authentication and authorization failures by reason.",
+	}, []string{"stage", "reason"})
+
+	// CasbinEnforceDuration casbin 鉴权耗时
+	CasbinEnforceDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "casbin",
+		Name:      "enforce_duration_seconds",
+		Help:      "Casbin enforce latency.",
+		Buckets:   []float64{.0001, .0005, .001, .005, .01, .05, .1},
+	})
+
+	// CasbinDecisions casbin 鉴权结果
+	CasbinDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "casbin",
+		Name:      "decisions_total",
+		Help:      "Casbin enforce decisions.",
+	}, []string{"result"})
+
+	// RoleCacheRequests 鉴权时读取用户角色缓存的命中情况, scope 为 global 或 tenant
+	RoleCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "role_cache",
+		Name:      "requests_total",
+		Help:      "Role cache lookups by result.",
+	}, []string{"scope", "result"})
+
+	// DBQueryDuration gorm 操作耗时
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "GORM operation latency by operation and table.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "table", "result"})
+
+	// RedisCommandDuration redis 命令耗时, pipeline 统计为一次
+	RedisCommandDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "redis",
+		Name:      "command_duration_seconds",
+		Help:      "Redis command latency by command.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"command", "result"})
+
+	// LdapOperationDuration ldap 操作耗时
+	LdapOperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "ldap",
+		Name:      "operation_duration_seconds",
+		Help:      "LDAP operation latency by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "result"})
+
+	// IDGenerateErrors sonyflake 生成 ID 失败次数
+	IDGenerateErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sonyflake",
+		Name:      "errors_total",
+		Help:      "Sonyflake ID generation errors.",
+	})
+)
+
+// Handler 暴露默认注册表中的指标
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Result err 对应的结果标签
+func Result(err error) string {
+	if err != nil {
+		return ResultError
+	}
+	return "success"
+}
+
+// ObserveLdap 记录一次 ldap 操作的耗时
+func ObserveLdap(operation string, start time.Time, err error) {
+	LdapOperationDuration.WithLabelValues(operation, Result(err)).Observe(time.Since(start).Seconds())
+}
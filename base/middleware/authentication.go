@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"qqlx/base/apierr"
 	"qqlx/base/constant"
+	"qqlx/base/interfaces"
+	"qqlx/base/logger"
 	"qqlx/base/reason"
 	"qqlx/pkg/jwt"
 
@@ -14,8 +16,22 @@ import (
 
 const auth = "auth failed"
 
-// Authentication 基于JWT的认证中间件
-func Authentication() gin.HandlerFunc {
+type AuthenticationMiddleware struct {
+	revocation interfaces.TokenRevocationInterface
+	apiKey     interfaces.ApiKeyAuthenticatorInterface
+	session    interfaces.SessionTouchInterface
+}
+
+func NewAuthentication(revocation interfaces.TokenRevocationInterface, apiKey interfaces.ApiKeyAuthenticatorInterface, session interfaces.SessionTouchInterface) *AuthenticationMiddleware {
+	return &AuthenticationMiddleware{
+		revocation: revocation,
+		apiKey:     apiKey,
+		session:    session,
+	}
+}
+
+// Authentication 基于JWT或API key的认证中间件
+func (receive *AuthenticationMiddleware) Authentication() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.Request.Header.Get("Authorization")
 		if authHeader == "" {
@@ -27,17 +43,43 @@ func Authentication() gin.HandlerFunc {
 			authenticationDenied(c, apierr.Unauthorized().Set(apierr.AuthErrCode, auth, reason.ErrHeaderMalformed))
 			return
 		}
+		// API key 每次都查询数据库, 吊销立即生效, 不需要检查吊销记录
+		if strings.HasPrefix(parts[1], constant.ApiKeyPrefix) {
+			mc, err := receive.apiKey.Authenticate(c, parts[1])
+			if err != nil {
+				authenticationDenied(c, apierr.Unauthorized().Set(apierr.AuthErrCode, auth, err))
+				return
+			}
+			c.Set(constant.AuthMidwareKey, mc)
+			c.Next()
+			return
+		}
 		mc, err := jwt.ParseToken(parts[1])
 		if err != nil {
 			authenticationDenied(c, apierr.Unauthorized().Set(apierr.AuthErrCode, auth, err))
 			return
 		}
+		// 校验 token 是否已被吊销(注销、禁用、修改密码)
+		revoked, err := receive.revocation.IsRevoked(c, mc)
+		if err != nil {
+			authenticationDenied(c, apierr.Unauthorized().Set(apierr.AuthErrCode, auth, err))
+			return
+		}
+		if revoked {
+			authenticationDenied(c, apierr.Unauthorized().Set(apierr.AuthErrCode, auth, reason.ErrTokenRevoked))
+			return
+		}
+		// 更新会话最近访问时间失败不影响请求
+		if err = receive.session.Touch(c, mc); err != nil {
+			logger.WithContext(c, true).Errorf("touch session failed, userName: %s, err: %v", mc.UserName, err)
+		}
 		c.Set(constant.AuthMidwareKey, mc)
 		c.Next()
 	}
 }
 
 func authenticationDenied(c *gin.Context, err error) {
+	observeAuthFailure(stageAuthentication, err)
 	c.Set(constant.LogErrMidwareKey, err)
 	c.JSON(http.StatusUnauthorized, newRes(apierr.AuthErrCode))
 	c.Abort()
@@ -1,96 +1,196 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"qqlx/base/apierr"
 	"qqlx/base/constant"
 	"qqlx/base/helpers"
 	"qqlx/base/interfaces"
 	"qqlx/base/logger"
+	"qqlx/base/metrics"
 	"qqlx/base/reason"
 	"qqlx/model"
 	"qqlx/pkg/jwt"
 	"qqlx/store/cache"
+	"qqlx/store/rbac"
 	"qqlx/store/userstore"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 const authFailed = "authentication failed"
 
 type AuthorizationMiddleware struct {
-	cache      interfaces.CacheInterface
-	authorizer interfaces.Authorizer
-	userStore  interfaces.UserStoreInterface
+	cache         interfaces.CacheInterface
+	authorizer    interfaces.Authorizer
+	userStore     interfaces.UserStoreInterface
+	userRoleStore interfaces.UserRoleStoreInterface
+	memberStore   interfaces.TenantMemberStoreInterface
 }
 
-func NewAuthorization(cache interfaces.CacheInterface, authorizer interfaces.Authorizer, userStore interfaces.UserStoreInterface) *AuthorizationMiddleware {
+func NewAuthorization(cache interfaces.CacheInterface, authorizer interfaces.Authorizer, userStore interfaces.UserStoreInterface, userRoleStore interfaces.UserRoleStoreInterface, memberStore interfaces.TenantMemberStoreInterface) *AuthorizationMiddleware {
 	return &AuthorizationMiddleware{
-		cache:      cache,
-		authorizer: authorizer,
-		userStore:  userStore,
+		cache:         cache,
+		authorizer:    authorizer,
+		userStore:     userStore,
+		userRoleStore: userRoleStore,
+		memberStore:   memberStore,
 	}
 }
 
 // Authorization 基于 Casbin 的鉴权中间件
+//
+// 租户ID 取自路由 :tenant, 其次为 X-Tenant-ID 请求头, 都没有时只使用全局角色
 func (receive *AuthorizationMiddleware) Authorization() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var (
-			allowed bool
-			user    *model.User
-		)
+		var allowed bool
 		claims, err := jwt.GetMyClaims(c)
 		if err != nil {
 			permissionDenied(c, apierr.Unauthorized().Set(apierr.ForbiddenErrCode, authFailed, err))
 			return
 		}
-		key := helpers.GetRoleCacheKey(claims.UserName)
-		roleName, err := receive.cache.GetSet(c, key)
+		tenantID, err := tenantFromRequest(c)
+		if err != nil {
+			permissionDenied(c, apierr.Forbidden().Set(apierr.ForbiddenErrCode, authFailed, err))
+			return
+		}
+		roleName, err := receive.globalRoles(c, claims.UserName)
 		if err != nil {
 			permissionDenied(c, apierr.Unauthorized().Set(apierr.ForbiddenErrCode, authFailed, err))
 			return
 		}
-		_roleName := make([]any, 0, 10)
-		if len(roleName) == 0 {
-			user, err = receive.userStore.Query(c, userstore.Name(claims.UserName), userstore.LoadRoles())
+		if tenantID != 0 {
+			tenantRoles, err := receive.tenantRoles(c, tenantID, claims.UserID, claims.UserName)
 			if err != nil {
 				permissionDenied(c, apierr.Unauthorized().Set(apierr.ForbiddenErrCode, authFailed, err))
 				return
 			}
-
-			if len(user.Roles) == 0 {
-				permissionDenied(c, apierr.Unauthorized().Set(apierr.ForbiddenErrCode, authFailed, reason.ErrRoleNotFound))
-				return
-			}
-
-			for _, role := range user.Roles {
-				_roleName = append(_roleName, role.Name)
-				roleName = append(roleName, role.Name)
-			}
-			_ = receive.cache.SetSet(c, key, _roleName, &cache.NeverExpires)
-			logger.WithContext(c, true).Debugf("user: %s, set roles: %v", user.Name, _roleName)
+			roleName = append(roleName, tenantRoles...)
+		}
+		if len(roleName) == 0 {
+			permissionDenied(c, apierr.Unauthorized().Set(apierr.ForbiddenErrCode, authFailed, reason.ErrRoleNotFound))
+			return
 		}
 
-		// 判断是否有权限D
-		for _, role := range roleName {
-			allowed, err = receive.authorizer.EnforceWithCtx(c, role, c.Request.URL.Path, c.Request.Method)
-			if err != nil {
-				permissionDenied(c, apierr.Forbidden().Set(apierr.ForbiddenErrCode, "unknown error", err))
-				return
-			}
-			if allowed {
-				c.Next()
-				return
-			}
+		// API key 限制了可使用的角色时只使用交集
+		roleName = helpers.ScopeRoles(roleName, claims.Roles)
+		domain := helpers.GetCasbinDomain(tenantID)
+		env := receive.conditionEnv(c, claims.UserID, tenantID)
+		// 判断是否有权限, 任一角色匹配到 deny 策略时拒绝
+		allowed, err = receive.authorizer.EnforceRolesWithCtx(c, roleName, domain, c.Request.URL.Path, c.Request.Method, env)
+		if err != nil {
+			permissionDenied(c, apierr.Forbidden().Set(apierr.ForbiddenErrCode, "unknown error", err))
+			return
+		}
+		if allowed {
+			c.Next()
+			return
 		}
-		// 所有角色都无权限，最终拒绝
+		// 没有角色允许或被 deny 策略拒绝
 		permissionDenied(c, apierr.Forbidden().Set(apierr.ForbiddenErrCode, authFailed, reason.ErrPermission))
-		logger.WithContext(c, true).Errorf("permission denied: user=%s, roles=%v, path=%s, method=%s",
-			claims.UserName, roleName, c.Request.URL.Path, c.Request.Method)
+		logger.WithContext(c, true).Errorf("permission denied: user=%s, tenant=%d, roles=%v, path=%s, method=%s",
+			claims.UserName, tenantID, roleName, c.Request.URL.Path, c.Request.Method)
+	}
+}
+
+// globalRoles 用户未过期的全局角色, 优先读取缓存, 有临时角色时缓存在最早的过期时间失效
+func (receive *AuthorizationMiddleware) globalRoles(c *gin.Context, userName string) (roleName []string, err error) {
+	key := helpers.GetRoleCacheKey(userName)
+	roleName, err = receive.cache.GetSet(c, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(roleName) > 0 {
+		metrics.RoleCacheRequests.WithLabelValues("global", metrics.ResultHit).Inc()
+		return roleName, nil
+	}
+	metrics.RoleCacheRequests.WithLabelValues("global", metrics.ResultMiss).Inc()
+	user, err := receive.userStore.Query(c, userstore.Name(userName))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	assignments, err := receive.userRoleStore.ListActive(c, user.ID, int(now.Unix()))
+	if err != nil {
+		return nil, err
+	}
+	roleName, ttl := helpers.ActiveRoleNames(assignments, now)
+	_roleName := make([]any, 0, len(roleName))
+	for _, name := range roleName {
+		_roleName = append(_roleName, name)
+	}
+	if len(_roleName) > 0 {
+		_ = receive.cache.SetSet(c, key, _roleName, cache.ExpireIn(ttl))
+		logger.WithContext(c, true).Debugf("user: %s, set roles: %v", user.Name, _roleName)
+	}
+	return roleName, nil
+}
+
+// tenantRoles 用户在租户内的角色, 租户管理员额外拥有 tenant-admin 角色, 非成员没有租户角色
+func (receive *AuthorizationMiddleware) tenantRoles(c *gin.Context, tenantID, userID int, userName string) (roleName []string, err error) {
+	key := helpers.GetTenantRoleCacheKey(tenantID, userName)
+	roleName, err = receive.cache.GetSet(c, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(roleName) > 0 {
+		metrics.RoleCacheRequests.WithLabelValues("tenant", metrics.ResultHit).Inc()
+		return roleName, nil
+	}
+	metrics.RoleCacheRequests.WithLabelValues("tenant", metrics.ResultMiss).Inc()
+	member, err := receive.memberStore.Query(c, tenantID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if member.Admin {
+		roleName = append(roleName, constant.TenantAdminRole)
+	}
+	for _, role := range member.Roles {
+		roleName = append(roleName, helpers.GetCasbinSubject(role.TenantID, role.Name))
+	}
+	if len(roleName) > 0 {
+		_roleName := make([]any, 0, len(roleName))
+		for _, name := range roleName {
+			_roleName = append(_roleName, name)
+		}
+		_ = receive.cache.SetSet(c, key, _roleName, &cache.NeverExpires)
+	}
+	return roleName, nil
+}
+
+// conditionEnv 条件策略使用的请求属性, :id 对应的用户只在条件需要时查询
+func (receive *AuthorizationMiddleware) conditionEnv(c *gin.Context, userID, tenantID int) *rbac.ConditionEnv {
+	resourceID, _ := strconv.Atoi(c.Param("id"))
+	return rbac.NewConditionEnv(userID, resourceID, tenantID, time.Now(), func(id int) (*model.User, error) {
+		return receive.userStore.Query(c, userstore.ID(id))
+	})
+}
+
+// tenantFromRequest 请求所在的租户, 0 表示不在租户内
+func tenantFromRequest(c *gin.Context) (int, error) {
+	value := c.Param(constant.TenantParam)
+	if value == "" {
+		value = c.GetHeader(constant.TenantHeader)
+	}
+	if value == "" {
+		return 0, nil
+	}
+	tenantID, err := strconv.Atoi(value)
+	if err != nil || tenantID < 0 {
+		return 0, reason.ErrTenantNotFound
 	}
+	return tenantID, nil
 }
 
 func permissionDenied(c *gin.Context, err error) {
+	observeAuthFailure(stageAuthorization, err)
 	c.Set(constant.LogErrMidwareKey, err)
 	c.JSON(http.StatusForbidden, newRes(apierr.ForbiddenErrCode))
 	c.Abort()
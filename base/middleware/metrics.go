@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"qqlx/base/metrics"
+	"qqlx/base/reason"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	jwtlib "github.com/golang-jwt/jwt/v5"
+)
+
+// 认证失败的阶段
+const (
+	stageAuthentication = "authentication"
+	stageAuthorization  = "authorization"
+)
+
+// authFailureReasons 认证失败时统计的原因, 其他错误统计为 other
+var authFailureReasons = []error{
+	reason.ErrHeaderEmpty,
+	reason.ErrHeaderMalformed,
+	reason.ErrTokenInvalid,
+	reason.ErrTokenMode,
+	reason.ErrTokenRevoked,
+	reason.ErrTokenKidUnknown,
+	jwtlib.ErrTokenExpired,
+	jwtlib.ErrTokenSignatureInvalid,
+	jwtlib.ErrTokenMalformed,
+	reason.ErrApiKeyInvalid,
+	reason.ErrApiKeyExpired,
+	reason.ErrUserNotFound,
+	reason.ErrUserIsDisable,
+	reason.ErrTenantNotFound,
+	reason.ErrRoleNotFound,
+	reason.ErrPermission,
+}
+
+// metricsMethods 统计时保留的请求方法, 其他方法统计为 other 避免标签过多
+var metricsMethods = map[string]struct{}{
+	http.MethodGet:     {},
+	http.MethodHead:    {},
+	http.MethodPost:    {},
+	http.MethodPut:     {},
+	http.MethodPatch:   {},
+	http.MethodDelete:  {},
+	http.MethodConnect: {},
+	http.MethodOptions: {},
+	http.MethodTrace:   {},
+}
+
+// MetricsMiddleware 按路由模板统计请求耗时, 不使用原始路径避免标签过多
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		if _, ok := metricsMethods[method]; !ok {
+			method = "other"
+		}
+		metrics.HTTPRequestDuration.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+// observeAuthFailure 统计认证失败的原因
+func observeAuthFailure(stage string, err error) {
+	label := "other"
+	for _, e := range authFailureReasons {
+		if errors.Is(err, e) {
+			label = e.Error()
+			break
+		}
+	}
+	metrics.AuthFailures.WithLabelValues(stage, label).Inc()
+}
@@ -11,4 +11,5 @@ var ProviderMiddleware = wire.NewSet(
 	wire.Bind(new(interfaces.Authorizer), new(*rbac.Authentication)),
 	rbac.NewAuthentication,
 	NewAuthorization,
+	NewAuthentication,
 )
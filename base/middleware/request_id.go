@@ -5,17 +5,31 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// RequestIDMiddleware 请求 ID 使用当前 trace 的 ID, 没有 trace 时随机生成
 func RequestIDMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		requestID := uuid.New().String()
+		if sc := trace.SpanContextFromContext(c.Request.Context()); sc.HasTraceID() {
+			requestID = sc.TraceID().String()
+		}
 		c.Set(constant.TraceID, requestID)
 		c.Header("X-Request-ID", requestID)
 		c.Next()
 	}
 }
 
+// ClientMiddleware 记录客户端 IP 和 User-Agent, 供登录会话使用
+func ClientMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(constant.ClientIPKey, c.ClientIP())
+		c.Set(constant.UserAgentKey, c.Request.UserAgent())
+		c.Next()
+	}
+}
+
 func GetTraceID(c *gin.Context) string {
 	if requestID, exists := c.Get(constant.TraceID); exists {
 		return requestID.(string)
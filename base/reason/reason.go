@@ -3,26 +3,76 @@ package reason
 import "errors"
 
 var (
-	ErrParams            = errors.New("params error")
-	ErrPermission        = errors.New("permission denied")
-	ErrHeaderEmpty       = errors.New("auth in the request header is empty")
-	ErrTokenMode         = errors.New("token mode error")
-	ErrTokenInvalid      = errors.New("token is invalid")
-	ErrHeaderMalformed   = errors.New("the auth format in the request header is incorrect")
-	ErrLdapGroupNotFound = errors.New("ldap group not found")
-	ErrLdapUserNotFound  = errors.New("ldap user not found")
-	ErrRoleNotFound      = errors.New("role does not exist")
-	ErrRoleHasUser       = errors.New("role has user")
-	ErrRoleIsEmpty       = errors.New("role is empty")
-	ErrRoleExists        = errors.New("role already exists")
-	ErrUserNotFound      = errors.New("user does not exist")
-	ErrUserIsDisable     = errors.New("user has been disabled")
-	ErrUserExists        = errors.New("user already exists")
-	ErrUserIsEmpty       = errors.New("user is empty")
-	ErrEncryptPassword   = errors.New("failed to encrypt password")
-	ErrAdminUserNotAllow = errors.New("admin user cannot operate")
-	ErrInvalidPassword   = errors.New("password is invalid")
-	ErrPolicyNotFound    = errors.New("policy does not exist")
-	ErrPolicyUsedByRole  = errors.New("policy has been used by role")
-	ErrNameInvalid       = errors.New("name must contain only letters")
+	ErrParams                = errors.New("params error")
+	ErrPermission            = errors.New("permission denied")
+	ErrHeaderEmpty           = errors.New("auth in the request header is empty")
+	ErrTokenMode             = errors.New("token mode error")
+	ErrTokenInvalid          = errors.New("token is invalid")
+	ErrTokenRevoked          = errors.New("token has been revoked")
+	ErrTokenKidUnknown       = errors.New("token signing key is unknown")
+	ErrOidcDisabled          = errors.New("oidc login is not enabled")
+	ErrOidcState             = errors.New("oidc state is invalid or expired")
+	ErrOidcNonce             = errors.New("oidc id_token nonce mismatch")
+	ErrOidcIDTokenMissing    = errors.New("oidc token response has no id_token")
+	ErrOidcEmailMissing      = errors.New("oidc id_token has no email claim")
+	ErrOidcNotProvisioned    = errors.New("oidc user is not provisioned")
+	ErrOidcEmailUnverified   = errors.New("oidc email is not verified")
+	ErrOidcLinkRequired      = errors.New("oidc identity must be linked by the local account first")
+	ErrOidcSubjectLinked     = errors.New("oidc identity is already linked to another user")
+	ErrHeaderMalformed       = errors.New("the auth format in the request header is incorrect")
+	ErrLdapGroupNotFound     = errors.New("ldap group not found")
+	ErrLdapUserNotFound      = errors.New("ldap user not found")
+	ErrRoleNotFound          = errors.New("role does not exist")
+	ErrRoleHasUser           = errors.New("role has user")
+	ErrRoleIsEmpty           = errors.New("role is empty")
+	ErrRoleExists            = errors.New("role already exists")
+	ErrUserNotFound          = errors.New("user does not exist")
+	ErrUserIsDisable         = errors.New("user has been disabled")
+	ErrUserExists            = errors.New("user already exists")
+	ErrUserIsEmpty           = errors.New("user is empty")
+	ErrEncryptPassword       = errors.New("failed to encrypt password")
+	ErrAdminUserNotAllow     = errors.New("admin user cannot operate")
+	ErrInvalidPassword       = errors.New("password is invalid")
+	ErrPolicyNotFound        = errors.New("policy does not exist")
+	ErrPolicyUsedByRole      = errors.New("policy has been used by role")
+	ErrNameInvalid           = errors.New("name must contain only letters")
+	ErrRefreshInvalid        = errors.New("refresh token is invalid or expired")
+	ErrRefreshReused         = errors.New("refresh token has been reused, token family revoked")
+	ErrMfaTicketInvalid      = errors.New("mfa ticket is invalid or expired")
+	ErrMfaCodeInvalid        = errors.New("mfa code is invalid")
+	ErrMfaAlreadyEnabled     = errors.New("mfa is already enabled")
+	ErrMfaNotEnrolled        = errors.New("mfa enrollment not found or expired")
+	ErrMailTokenInvalid      = errors.New("mail token is invalid or expired")
+	ErrUserNotVerified       = errors.New("user email has not been verified")
+	ErrUserLocked            = errors.New("account is temporarily locked due to too many failed logins")
+	ErrLoginIPLocked         = errors.New("client ip is temporarily locked due to too many failed logins")
+	ErrLoginThrottled        = errors.New("login attempts are too frequent, retry later")
+	ErrPasswordPolicy        = errors.New("password does not satisfy the password policy")
+	ErrPasswordTicketInvalid = errors.New("password change ticket is invalid or expired")
+	ErrApiKeyInvalid         = errors.New("api key is invalid or revoked")
+	ErrApiKeyExpired         = errors.New("api key has expired")
+	ErrApiKeyNotFound        = errors.New("api key does not exist")
+	ErrApiKeyRoleNotAllowed  = errors.New("api key roles must be a subset of the owner roles")
+	ErrApiKeyCaller          = errors.New("api keys cannot be managed with api key authentication")
+	ErrServiceAccountLogin   = errors.New("service account cannot login with password")
+	ErrSessionNotFound       = errors.New("session does not exist")
+	ErrRoleCycle             = errors.New("role inheritance cannot contain a cycle")
+	ErrRoleHasChildren       = errors.New("role is inherited by other roles")
+	ErrTenantNotFound        = errors.New("tenant does not exist")
+	ErrTenantExists          = errors.New("tenant already exists")
+	ErrTenantNotEmpty        = errors.New("tenant still has members or roles")
+	ErrTenantMemberNotFound  = errors.New("user is not a member of the tenant")
+	ErrTenantRoleNotAllowed  = errors.New("role does not belong to the tenant")
+	ErrTenantPolicyNotAllow  = errors.New("tenant role can only use tenant scoped policies")
+	ErrPolicyCondition       = errors.New("policy condition is invalid")
+	ErrRouteCatalog          = errors.New("route catalog is not loaded")
+	ErrRevisionNotFound      = errors.New("rbac revision does not exist")
+	ErrManifestInvalid       = errors.New("rbac manifest is invalid")
+	ErrRoleDuration          = errors.New("role duration must be a positive duration such as 4h")
+	ErrAccessRequestNotFound = errors.New("access request does not exist")
+	ErrAccessRequestPending  = errors.New("a pending access request for this role already exists")
+	ErrAccessRequestDecided  = errors.New("access request is not pending")
+	ErrNotApprover           = errors.New("user is not an approver of the role")
+	ErrSelfApprove           = errors.New("cannot approve your own access request")
+	ErrRoleHasRole           = errors.New("user already has the role")
 )
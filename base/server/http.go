@@ -5,12 +5,18 @@ import (
 	"errors"
 	"net/http"
 	"qqlx/base/conf"
+	"qqlx/base/constant"
+	"qqlx/base/health"
+	"qqlx/base/metrics"
 	"qqlx/base/middleware"
+	"qqlx/pkg/jwt"
 	"qqlx/router"
+	"qqlx/service"
 	"time"
 
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 const DefaultShutdownTimeout = time.Second * 30
@@ -45,6 +51,25 @@ func NewServer(e *gin.Engine, options ...Options) *Server {
 	return &ser
 }
 
+// NewMetricsServer 在 server.metricsBind 上单独提供 /metrics, 不通过业务端口对外暴露
+func NewMetricsServer(options ...Options) *Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	ser := Server{
+		ShutdownTimeout: DefaultShutdownTimeout,
+		srv: &http.Server{
+			Addr:    conf.GetMetricsBind(),
+			Handler: mux,
+		},
+	}
+
+	for _, option := range options {
+		option(&ser)
+	}
+
+	return &ser
+}
+
 // WithShutdownTimeout duration of graceful shutdown
 func WithShutdownTimeout(duration time.Duration) Options {
 	return func(server *Server) {
@@ -70,6 +95,9 @@ func (s *Server) Shutdown() error {
 
 func NewHttpServer(
 	apiRouter *router.ApiRoute,
+	catalog *router.RouteCatalog,
+	policySvc *service.PolicySVC,
+	authentication *middleware.AuthenticationMiddleware,
 	authorization *middleware.AuthorizationMiddleware,
 ) *gin.Engine {
 	if conf.GetLogLevel() == "debug" {
@@ -79,16 +107,58 @@ func NewHttpServer(
 	}
 
 	r := gin.New()
+	// 客户端 IP 用于登录限制和审计, 不信任未配置的代理设置的请求头
+	if err := r.SetTrustedProxies(conf.GetTrustedProxies()); err != nil {
+		zap.S().Fatalf("set trusted proxies failed: %v", err)
+	}
+	// 处理函数和 service 使用 *gin.Context 作为 ctx, 需要从 c.Request 的 ctx 中获取 span
+	r.ContextWithFallback = true
 	if conf.GetResponseCompress() {
 		r.Use(gzip.Gzip(gzip.DefaultCompression, gzip.WithExcludedPaths([]string{"/api/v1/healthz"})))
 	}
 
 	r.GET("/healthz", func(ctx *gin.Context) { ctx.String(200, "OK") })
-	r.Use(middleware.ZapMiddleware(), middleware.RequestIDMiddleware(), middleware.CorssDomainMiddleware(), gin.Recovery())
+	r.GET("/livez", health.LivezHandler)
+	r.GET("/readyz", health.ReadyzHandler)
+	// 下游服务通过 JWKS 获取公钥验签, HS256 模式下返回空列表
+	r.GET("/.well-known/jwks.json", func(ctx *gin.Context) { ctx.JSON(200, jwt.GetJWKS()) })
+	r.Use(middleware.MetricsMiddleware(), middleware.TracingMiddleware(), middleware.ZapMiddleware(), middleware.RequestIDMiddleware(), middleware.ClientMiddleware(), middleware.CorssDomainMiddleware(), gin.Recovery())
 
 	baseGroup := r.Group("/api/v1")
-	apiRouter.RegisterApiUserRoute(baseGroup, authorization)
-	apiRouter.RegisterApiRoleRoute(baseGroup, authorization)
-	apiRouter.RegisterApiPolicyRoute(baseGroup, authorization)
+	apiRouter.RegisterApiUserRoute(baseGroup, authentication, authorization)
+	apiRouter.RegisterApiRoleRoute(baseGroup, authentication, authorization)
+	apiRouter.RegisterApiPolicyRoute(baseGroup, authentication, authorization)
+	apiRouter.RegisterApiServiceAccountRoute(baseGroup, authentication, authorization)
+	apiRouter.RegisterApiTenantRoute(baseGroup, authentication, authorization)
+	apiRouter.RegisterApiAuthzRoute(baseGroup, authentication, authorization)
+	apiRouter.RegisterApiRbacRoute(baseGroup, authentication, authorization)
+	apiRouter.RegisterApiAccessRequestRoute(baseGroup, authentication, authorization)
+	apiRouter.RegisterApiAuditRoute(baseGroup, authentication, authorization)
+	apiRouter.RegisterApiAuthRoute(baseGroup)
+	catalog.Load(r)
+	reconcileCatalog(policySvc)
 	return r
 }
+
+// reconcileCatalog 按 casbin.catalog 配置在启动时对比路由和策略
+func reconcileCatalog(policySvc *service.PolicySVC) {
+	mode := conf.GetCasbinCatalog()
+	if mode != constant.CatalogReport && mode != constant.CatalogApply {
+		return
+	}
+	ctx := context.WithValue(context.Background(), constant.TraceID, "catalog")
+	res, err := policySvc.Catalog(ctx, mode == constant.CatalogApply)
+	if err != nil {
+		zap.S().Errorf("reconcile policy catalog failed: %v", err)
+		return
+	}
+	for _, route := range res.Missing {
+		zap.S().Warnf("route %s %s has no policy", route.Method, route.Path)
+	}
+	for _, policy := range res.Orphaned {
+		zap.S().Warnf("policy %s (%s %s) does not match any route", policy.Name, policy.Method, policy.Path)
+	}
+	for _, policy := range res.Created {
+		zap.S().Infof("policy %s created for route %s %s", policy.Name, policy.Method, policy.Path)
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"qqlx/base/conf"
+	"qqlx/base/constant"
+	"qqlx/service"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Worker 周期执行的后台任务, 作为 ServerInterface 随应用启动和停止
+type Worker struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func NewWorker(name string, interval time.Duration, run func(ctx context.Context) error) *Worker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Worker{
+		name:     name,
+		interval: interval,
+		run:      run,
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+}
+
+// Start 按间隔执行任务直到 Shutdown, 任务失败只记录日志
+func (w *Worker) Start() error {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return nil
+		case <-ticker.C:
+			ctx := context.WithValue(w.ctx, constant.TraceID, w.name)
+			if err := w.run(ctx); err != nil {
+				zap.S().Errorf("worker %s failed, err: %s", w.name, err)
+			}
+		}
+	}
+}
+
+// Shutdown 停止任务并等待正在执行的任务结束
+func (w *Worker) Shutdown() error {
+	w.cancel()
+	<-w.done
+	return nil
+}
+
+// RoleExpiryWorker 删除过期的临时角色授权
+type RoleExpiryWorker struct {
+	*Worker
+}
+
+func NewRoleExpiryWorker(userSvc *service.UserSVC) *RoleExpiryWorker {
+	return &RoleExpiryWorker{
+		Worker: NewWorker("role-expiry", conf.GetRoleExpiryInterval(), userSvc.ExpireRoles),
+	}
+}
+
+// AccessRequestExpiryWorker 将超过有效期的待审批角色申请设置为过期
+type AccessRequestExpiryWorker struct {
+	*Worker
+}
+
+func NewAccessRequestExpiryWorker(accessRequestSvc *service.AccessRequestSVC) *AccessRequestExpiryWorker {
+	return &AccessRequestExpiryWorker{
+		Worker: NewWorker("access-request-expiry", conf.GetRoleExpiryInterval(), accessRequestSvc.ExpireRequests),
+	}
+}
+
+// SessionPurgeWorker 删除过期和已结束的登录会话
+type SessionPurgeWorker struct {
+	*Worker
+}
+
+func NewSessionPurgeWorker(tokenSvc *service.TokenSVC) *SessionPurgeWorker {
+	return &SessionPurgeWorker{
+		Worker: NewWorker("session-purge", conf.GetSessionPurgeInterval(), tokenSvc.PurgeSessions),
+	}
+}
+
+// AuditCheckpointWorker 定期将审计哈希链的链头签名写入检查点文件
+type AuditCheckpointWorker struct {
+	*Worker
+}
+
+func NewAuditCheckpointWorker(auditSvc *service.AuditSVC) *AuditCheckpointWorker {
+	return &AuditCheckpointWorker{
+		Worker: NewWorker("audit-checkpoint", conf.GetAuditCheckpointInterval(), auditSvc.Checkpoint),
+	}
+}
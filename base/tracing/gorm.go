@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const gormSpanKey = "tracing:span"
+
+// GormPlugin 为 gorm 的 create/query/update/delete/row/raw 创建 span, 需要使用 db.WithContext 传递 ctx
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	for _, err := range []error{
+		callback.Create().Before("gorm:create").Register("tracing:before_create", gormBefore("create")),
+		callback.Create().After("gorm:create").Register("tracing:after_create", gormAfter),
+		callback.Query().Before("gorm:query").Register("tracing:before_query", gormBefore("query")),
+		callback.Query().After("gorm:query").Register("tracing:after_query", gormAfter),
+		callback.Update().Before("gorm:update").Register("tracing:before_update", gormBefore("update")),
+		callback.Update().After("gorm:update").Register("tracing:after_update", gormAfter),
+		callback.Delete().Before("gorm:delete").Register("tracing:before_delete", gormBefore("delete")),
+		callback.Delete().After("gorm:delete").Register("tracing:after_delete", gormAfter),
+		callback.Row().Before("gorm:row").Register("tracing:before_row", gormBefore("row")),
+		callback.Row().After("gorm:row").Register("tracing:after_row", gormAfter),
+		callback.Raw().Before("gorm:raw").Register("tracing:before_raw", gormBefore("raw")),
+		callback.Raw().After("gorm:raw").Register("tracing:after_raw", gormAfter),
+	} {
+		if err != nil {
+			return fmt.Errorf("register gorm tracing callback failed, %w", err)
+		}
+	}
+	return nil
+}
+
+func gormBefore(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Statement.Context == nil {
+			return
+		}
+		ctx, span := Start(db.Statement.Context, "gorm."+operation, trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemMySQL, semconv.DBOperationName(operation)))
+		db.Statement.Context = ctx
+		db.InstanceSet(gormSpanKey, span)
+	}
+}
+
+func gormAfter(db *gorm.DB) {
+	value, ok := db.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+	span.SetAttributes(
+		semconv.DBCollectionName(db.Statement.Table),
+		semconv.DBQueryText(db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.RowsAffected),
+	)
+	err := db.Error
+	// 查询不到记录是正常的业务结果
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	End(span, err)
+}
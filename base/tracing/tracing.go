@@ -0,0 +1,94 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"qqlx/base/conf"
+	"qqlx/base/constant"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const tracerName = "qqlx"
+
+// 链路追踪导出方式
+const (
+	ExporterOtlp   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// InitTracer 设置 W3C traceparent 传播和导出器, 没有配置导出器时只传播上游的 trace
+func InitTracer(ctx context.Context) (shutdown func(), err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	switch conf.GetTraceExporter() {
+	case "":
+		return func() {}, nil
+	case ExporterOtlp:
+		options := make([]otlptracehttp.Option, 0, 2)
+		if endpoint := conf.GetTraceEndpoint(); endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(endpoint))
+		}
+		if conf.GetTraceInsecure() {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, options...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New()
+	default:
+		return nil, fmt.Errorf("trace.exporter is not supported: %s", conf.GetTraceExporter())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("init trace exporter failed, %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(conf.GetProjectName()),
+			semconv.ServiceVersion(constant.ServerVersion),
+		)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(conf.GetTraceSampleRatio()))),
+	)
+	otel.SetTracerProvider(provider)
+	zap.S().Infof("tracer init success, exporter: %s", conf.GetTraceExporter())
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			zap.S().Errorf("shutdown tracer failed: %v", err)
+		}
+	}, nil
+}
+
+// Start 创建 span, ctx 中已有 span 时作为子 span
+func Start(ctx context.Context, name string, options ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, options...)
+}
+
+// Detach 返回只保留 traceID 和 span 的 ctx, 不随请求取消, 也不引用请求结束后会被复用的 *gin.Context, 用于请求结束后仍在执行的 goroutine
+func Detach(ctx context.Context) context.Context {
+	detached := trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx))
+	traceID, _ := ctx.Value(constant.TraceID).(string)
+	return context.WithValue(detached, constant.TraceID, traceID)
+}
+
+// End 结束 span, err 不为空时记录错误
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
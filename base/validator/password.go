@@ -0,0 +1,240 @@
+package validator
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"qqlx/base/conf"
+	"qqlx/base/reason"
+	"qqlx/schema"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+)
+
+// 密码策略校验标签, 每个标签对应一条可翻译的提示
+const (
+	TagPasswordMin      = "password_min"
+	TagPasswordUpper    = "password_upper"
+	TagPasswordLower    = "password_lower"
+	TagPasswordDigit    = "password_digit"
+	TagPasswordSymbol   = "password_symbol"
+	TagPasswordBanned   = "password_banned"
+	TagPasswordIdentity = "password_identity"
+	TagPasswordReused   = "password_reused"
+)
+
+var passwordTranslations = map[string]string{
+	TagPasswordMin:      "{0}长度不能少于{1}个字符",
+	TagPasswordUpper:    "{0}必须包含大写字母",
+	TagPasswordLower:    "{0}必须包含小写字母",
+	TagPasswordDigit:    "{0}必须包含数字",
+	TagPasswordSymbol:   "{0}必须包含特殊字符",
+	TagPasswordBanned:   "{0}过于常见, 请更换",
+	TagPasswordIdentity: "{0}不能包含用户名或邮箱",
+	TagPasswordReused:   "{0}不能与最近{1}次使用的密码相同",
+}
+
+// PasswordViolation 违反的密码策略
+type PasswordViolation struct {
+	Tag   string
+	Param string
+}
+
+// PasswordPolicy 密码策略, 从配置文件加载
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// BanIdentity 禁止密码包含用户名或邮箱前缀
+	BanIdentity bool
+	// History 不能重复使用最近 N 次的密码, 0 表示不限制
+	History int
+	// MaxAge 密码最长使用时间, 超过后登录时要求修改, 0 表示不限制
+	MaxAge time.Duration
+	banned map[string]struct{}
+}
+
+func NewPasswordPolicy() (*PasswordPolicy, error) {
+	maxAge, err := conf.GetPasswordMaxAge()
+	if err != nil {
+		return nil, err
+	}
+	policy := &PasswordPolicy{
+		MinLength:     conf.GetPasswordMinLength(),
+		RequireUpper:  conf.GetPasswordRequireUpper(),
+		RequireLower:  conf.GetPasswordRequireLower(),
+		RequireDigit:  conf.GetPasswordRequireDigit(),
+		RequireSymbol: conf.GetPasswordRequireSymbol(),
+		BanIdentity:   conf.GetPasswordBanIdentity(),
+		History:       conf.GetPasswordHistory(),
+		MaxAge:        maxAge,
+		banned:        map[string]struct{}{},
+	}
+	if file := conf.GetPasswordBannedListFile(); file != "" {
+		if err = policy.loadBanned(file); err != nil {
+			return nil, err
+		}
+	}
+	return policy, nil
+}
+
+// loadBanned 加载禁用密码列表, 每行一个, 忽略空行和 # 开头的注释, 不区分大小写
+func (p *PasswordPolicy) loadBanned(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("open password.bannedListFile %s failed: %w", file, err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.banned[strings.ToLower(line)] = struct{}{}
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("read password.bannedListFile %s failed: %w", file, err)
+	}
+	return nil
+}
+
+// Check 校验密码, identities 为用户名, 邮箱等不允许出现在密码中的内容
+func (p *PasswordPolicy) Check(password string, identities ...string) []PasswordViolation {
+	var violations []PasswordViolation
+	if len([]rune(password)) < p.MinLength {
+		violations = append(violations, PasswordViolation{Tag: TagPasswordMin, Param: strconv.Itoa(p.MinLength)})
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		violations = append(violations, PasswordViolation{Tag: TagPasswordUpper})
+	}
+	if p.RequireLower && !lower {
+		violations = append(violations, PasswordViolation{Tag: TagPasswordLower})
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, PasswordViolation{Tag: TagPasswordDigit})
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, PasswordViolation{Tag: TagPasswordSymbol})
+	}
+
+	lowerPassword := strings.ToLower(password)
+	if _, ok := p.banned[lowerPassword]; ok {
+		violations = append(violations, PasswordViolation{Tag: TagPasswordBanned})
+	}
+	if p.BanIdentity {
+		for _, identity := range identities {
+			// 邮箱只比较 @ 之前的部分
+			identity, _, _ = strings.Cut(strings.ToLower(identity), "@")
+			if len(identity) >= 3 && strings.Contains(lowerPassword, identity) {
+				violations = append(violations, PasswordViolation{Tag: TagPasswordIdentity})
+				break
+			}
+		}
+	}
+	return violations
+}
+
+// Reused 密码与历史密码重复时的违规信息
+func (p *PasswordPolicy) Reused() PasswordViolation {
+	return PasswordViolation{Tag: TagPasswordReused, Param: strconv.Itoa(p.History)}
+}
+
+// Expired 判断密码是否超过最长使用时间, changedAt 为 0 表示未知, 不视为过期
+func (p *PasswordPolicy) Expired(changedAt int) bool {
+	if p.MaxAge <= 0 || changedAt == 0 {
+		return false
+	}
+	return time.Since(time.Unix(int64(changedAt), 0)) > p.MaxAge
+}
+
+// Translate 将违规信息翻译为提示, 与请求参数校验的提示格式一致
+func (p *PasswordPolicy) Translate(field string, violations []PasswordViolation) string {
+	msgArr := make([]string, 0, len(violations))
+	for _, v := range violations {
+		var (
+			msg string
+			err = reason.ErrParams
+		)
+		if trans != nil {
+			msg, err = trans.T(v.Tag, field, v.Param)
+		}
+		if err != nil {
+			// 未创建 Validator 时翻译尚未注册
+			msg = strings.NewReplacer("{0}", field, "{1}", v.Param).Replace(passwordTranslations[v.Tag])
+		}
+		msgArr = append(msgArr, msg)
+	}
+	return strings.Join(msgArr, "; ")
+}
+
+// registerPassword 注册密码策略翻译, 以及包含密码的请求的结构体校验
+func (p *PasswordPolicy) registerPassword(v *validator.Validate) {
+	for tag, text := range passwordTranslations {
+		_ = v.RegisterTranslation(tag, trans, func(ut ut.Translator) error {
+			return ut.Add(tag, text, true)
+		}, func(ut ut.Translator, fe validator.FieldError) string {
+			msg, err := ut.T(fe.Tag(), fe.Field(), fe.Param())
+			if err != nil {
+				return text
+			}
+			return msg
+		})
+	}
+	v.RegisterStructValidation(p.structLevel,
+		schema.UserRegistryRequest{},
+		schema.UserUpdatePasswordRequest{},
+		schema.UserEnableRequest{},
+		schema.UserResetPasswordRequest{},
+		schema.UserChangeExpiredPasswordRequest{},
+	)
+}
+
+func (p *PasswordPolicy) structLevel(sl validator.StructLevel) {
+	var (
+		password, field string
+		identities      []string
+	)
+	switch req := sl.Current().Interface().(type) {
+	case schema.UserRegistryRequest:
+		password, field, identities = req.Password, "Password", []string{req.Name, req.Email}
+	case schema.UserUpdatePasswordRequest:
+		password, field = req.NewPassword, "NewPassword"
+	case schema.UserEnableRequest:
+		password, field = req.Password, "Password"
+	case schema.UserResetPasswordRequest:
+		password, field = req.Password, "Password"
+	case schema.UserChangeExpiredPasswordRequest:
+		password, field = req.Password, "Password"
+	default:
+		return
+	}
+	// 为空时由 required 校验
+	if password == "" {
+		return
+	}
+	for _, v := range p.Check(password, identities...) {
+		sl.ReportError(password, field, field, v.Tag, v.Param)
+	}
+}
@@ -21,12 +21,13 @@ type Validator struct {
 	validate *validator.Validate
 }
 
-func NewValidator() *Validator {
+func NewValidator(password *PasswordPolicy) *Validator {
 	v := validator.New()
 	zhTrans := zh.New()
 	uni := ut.New(zhTrans, zhTrans)
 	trans, _ = uni.GetTranslator("zh")
 	_ = translations.RegisterDefaultTranslations(v, trans)
+	password.registerPassword(v)
 
 	return &Validator{
 		validate: v,
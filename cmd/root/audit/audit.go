@@ -0,0 +1,101 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"qqlx/base/conf"
+	"qqlx/base/constant"
+	"qqlx/base/data"
+	"qqlx/base/logger"
+	"qqlx/service"
+	auditstore "qqlx/store/audit"
+
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "audit",
+	Short: "audit log tools",
+	Long:  "audit log tools",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if !cmd.Flags().Changed(constant.FlagConfigPath) {
+			envConfigPath := os.Getenv(constant.ConfigEnv)
+			if envConfigPath != "" {
+				err := cmd.Flags().Set(constant.FlagConfigPath, envConfigPath)
+				if err != nil {
+					log.Fatalf("set config file path from env %s faild: %v", envConfigPath, err)
+				}
+			}
+		}
+	},
+}
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "walk the audit hash chain and report the first break",
+	Long:  "walk the audit hash chain and report the first break, signed checkpoints are verified when the checkpoint file exists",
+	Run: func(cmd *cobra.Command, args []string) {
+		cf, err := cmd.Flags().GetString(constant.FlagConfigPath)
+		if err != nil {
+			log.Fatalf("get config file path faild: %v", err)
+		}
+		checkpoints, _ := cmd.Flags().GetString("checkpoints")
+		verify(cf, checkpoints)
+	},
+}
+
+func init() {
+	verifyCmd.Flags().String("checkpoints", "", "checkpoint file, default audit.checkpointFile in config")
+	Cmd.AddCommand(verifyCmd)
+}
+
+func verify(cf, checkpointFile string) {
+	if err := conf.LoadConfig(cf); err != nil {
+		log.Fatalf("load config file %s failed: %v", cf, err)
+	}
+	logger.InitLogger()
+	db, closeDB, err := data.InitMySQL()
+	if err != nil {
+		log.Fatalf("init mysql failed: %v", err)
+	}
+	defer closeDB()
+
+	var checkpoints io.Reader
+	if checkpointFile == "" {
+		checkpointFile = conf.GetAuditCheckpointFile()
+	}
+	if checkpointFile != "" {
+		file, err := os.Open(checkpointFile)
+		switch {
+		case err == nil:
+			defer file.Close()
+			checkpoints = file
+		case os.IsNotExist(err):
+			log.Printf("checkpoint file %s does not exist, skip checkpoints", checkpointFile)
+		default:
+			log.Fatalf("open %s failed: %v", checkpointFile, err)
+		}
+	}
+
+	ctx := context.WithValue(context.Background(), constant.TraceID, "audit-verify")
+	auditSvc, err := service.NewAuditSVC(auditstore.NewAuditStore(db))
+	if err != nil {
+		log.Fatalf("init audit service failed: %v", err)
+	}
+	res, err := auditSvc.Verify(ctx, checkpoints)
+	if err != nil {
+		log.Fatalf("verify audit log failed: %v", err)
+	}
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		log.Fatalf("marshal result failed: %v", err)
+	}
+	fmt.Println(string(out))
+	if !res.Valid {
+		os.Exit(1)
+	}
+}
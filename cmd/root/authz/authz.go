@@ -0,0 +1,99 @@
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"qqlx/base/conf"
+	"qqlx/base/constant"
+	"qqlx/base/data"
+	"qqlx/base/logger"
+	"qqlx/schema"
+	"qqlx/service"
+	"qqlx/store/rbac"
+	"qqlx/store/userstore"
+
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "authz",
+	Short: "authorization tools",
+	Long:  "authorization tools",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if !cmd.Flags().Changed(constant.FlagConfigPath) {
+			envConfigPath := os.Getenv(constant.ConfigEnv)
+			if envConfigPath != "" {
+				err := cmd.Flags().Set(constant.FlagConfigPath, envConfigPath)
+				if err != nil {
+					log.Fatalf("set config file path from env %s faild: %v", envConfigPath, err)
+				}
+			}
+		}
+	},
+}
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "check and explain an authorization decision against the database",
+	Long:  "check and explain an authorization decision against the database",
+	Run: func(cmd *cobra.Command, args []string) {
+		cf, err := cmd.Flags().GetString(constant.FlagConfigPath)
+		if err != nil {
+			log.Fatalf("get config file path faild: %v", err)
+		}
+		req := &schema.AuthzCheckRequest{}
+		req.User, _ = cmd.Flags().GetString("user")
+		req.Role, _ = cmd.Flags().GetString("role")
+		req.Path, _ = cmd.Flags().GetString("path")
+		req.Method, _ = cmd.Flags().GetString("method")
+		req.Tenant, _ = cmd.Flags().GetInt("tenant")
+		req.ResourceID, _ = cmd.Flags().GetInt("resource-id")
+		if (req.User == "") == (req.Role == "") {
+			log.Fatal("one of --user or --role is required")
+		}
+		if req.Path == "" {
+			log.Fatal("--path is required")
+		}
+		check(cf, req)
+	},
+}
+
+func init() {
+	checkCmd.Flags().String("user", "", "user name, use the user's global and tenant roles")
+	checkCmd.Flags().String("role", "", "role name, check only this role")
+	checkCmd.Flags().String("path", "", "request path, e.g. /api/v1/users")
+	checkCmd.Flags().String("method", "GET", "request method")
+	checkCmd.Flags().Int("tenant", 0, "tenant id, 0 means no tenant")
+	checkCmd.Flags().Int("resource-id", 0, "value of :id used by conditional policies")
+	Cmd.AddCommand(checkCmd)
+}
+
+func check(cf string, req *schema.AuthzCheckRequest) {
+	if err := conf.LoadConfig(cf); err != nil {
+		log.Fatalf("load config file %s failed: %v", cf, err)
+	}
+	logger.InitLogger()
+	db, closeFunc, err := data.InitMySQL()
+	if err != nil {
+		log.Fatalf("init mysql failed: %v", err)
+	}
+	defer closeFunc()
+	enforcer, err := data.InitCasbin()
+	if err != nil {
+		log.Fatalf("init casbin failed: %v", err)
+	}
+	authzSvc := service.NewAuthzSVC(userstore.NewUserStore(db), userstore.NewUserAssociationStore(db), rbac.NewRoleStore(db), rbac.NewTenantMemberStore(db), rbac.NewAuthentication(enforcer))
+	ctx := context.WithValue(context.Background(), constant.TraceID, "authz-check")
+	res, err := authzSvc.Check(ctx, req)
+	if err != nil {
+		log.Fatalf("authz check failed: %v", err)
+	}
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		log.Fatalf("marshal result failed: %v", err)
+	}
+	fmt.Println(string(out))
+}
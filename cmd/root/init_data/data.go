@@ -1,21 +1,27 @@
 package init_data
 
 import (
+	"qqlx/base/constant"
 	"qqlx/schema"
 )
 
+// selfPolices 只能操作自己的资源的条件策略, 属于 self 角色
+var selfPolices = []string{"getOwnUserInfo", "listOwnUserSessions", "killOwnUserSession"}
+
 var polices = []schema.PolicyCreateRequest{
 	{
 		Name:     "admin",
 		Path:     "*",
 		Method:   "*",
 		Describe: "超级管理员",
+		Effect:   constant.PolicyEffectAllow,
 	},
 	{
 		Name:     "view",
 		Path:     "*",
 		Method:   "GET",
 		Describe: "查看",
+		Effect:   constant.PolicyEffectAllow,
 	},
 	{
 		Name:     "listUser",
@@ -53,6 +59,54 @@ var polices = []schema.PolicyCreateRequest{
 		Method:   "POST",
 		Describe: "删除用户角色",
 	},
+	{
+		Name:     "resetUserMfa",
+		Path:     "/api/v1/users/:id/mfa",
+		Method:   "DELETE",
+		Describe: "重置用户MFA",
+	},
+	{
+		Name:     "unlockUser",
+		Path:     "/api/v1/users/:id/unlock",
+		Method:   "PUT",
+		Describe: "解除用户登录锁定",
+	},
+	{
+		Name:     "listUserSessions",
+		Path:     "/api/v1/users/:id/sessions",
+		Method:   "GET",
+		Describe: "获取用户会话列表",
+	},
+	{
+		Name:     "killUserSession",
+		Path:     "/api/v1/users/:id/sessions/:sid",
+		Method:   "DELETE",
+		Describe: "结束用户会话",
+	},
+	{
+		Name:     "createServiceAccount",
+		Path:     "/api/v1/service-accounts",
+		Method:   "POST",
+		Describe: "创建服务账号",
+	},
+	{
+		Name:     "listServiceAccountKeys",
+		Path:     "/api/v1/service-accounts/:id/keys",
+		Method:   "GET",
+		Describe: "获取服务账号API key列表",
+	},
+	{
+		Name:     "createServiceAccountKey",
+		Path:     "/api/v1/service-accounts/:id/keys",
+		Method:   "POST",
+		Describe: "创建服务账号API key",
+	},
+	{
+		Name:     "revokeServiceAccountKey",
+		Path:     "/api/v1/service-accounts/:id/keys/:kid",
+		Method:   "DELETE",
+		Describe: "吊销服务账号API key",
+	},
 	{
 		Name:     "listRoles",
 		Path:     "/api/v1/roles",
@@ -95,6 +149,18 @@ var polices = []schema.PolicyCreateRequest{
 		Method:   "POST",
 		Describe: "删除角色权限",
 	},
+	{
+		Name:     "getRolePolices",
+		Path:     "/api/v1/roles/:id/polices",
+		Method:   "GET",
+		Describe: "获取角色的直接权限和有效权限",
+	},
+	{
+		Name:     "updateRoleParents",
+		Path:     "/api/v1/roles/:id/parents",
+		Method:   "PUT",
+		Describe: "设置角色继承的父角色",
+	},
 	{
 		Name:     "policesList",
 		Path:     "/api/v1/polices",
@@ -125,4 +191,181 @@ var polices = []schema.PolicyCreateRequest{
 		Method:   "DELETE",
 		Describe: "删除策略",
 	},
+	{
+		Name:     "policyCatalog",
+		Path:     "/api/v1/polices/catalog",
+		Method:   "GET",
+		Describe: "对比路由目录和策略",
+	},
+	{
+		Name:     "policyCatalogApply",
+		Path:     "/api/v1/polices/catalog",
+		Method:   "POST",
+		Describe: "为缺少策略的鉴权路由创建策略",
+	},
+	{
+		Name:     "rbacRevisionList",
+		Path:     "/api/v1/rbac/revisions",
+		Method:   "GET",
+		Describe: "获取角色策略变更记录",
+	},
+	{
+		Name:     "rbacRevisionDiff",
+		Path:     "/api/v1/rbac/revisions/diff",
+		Method:   "GET",
+		Describe: "对比两个版本的角色策略",
+	},
+	{
+		Name:     "rbacRevisionInfo",
+		Path:     "/api/v1/rbac/revisions/:id",
+		Method:   "GET",
+		Describe: "获取角色策略变更记录详情",
+	},
+	{
+		Name:     "rbacRevisionRollback",
+		Path:     "/api/v1/rbac/revisions/:id/rollback",
+		Method:   "POST",
+		Describe: "回滚角色策略到指定版本",
+	},
+	{
+		Name:     "rbacExport",
+		Path:     "/api/v1/rbac/export",
+		Method:   "GET",
+		Describe: "导出策略、角色和用户角色配置",
+	},
+	{
+		Name:     "rbacApply",
+		Path:     "/api/v1/rbac/apply",
+		Method:   "POST",
+		Describe: "应用策略、角色和用户角色配置",
+	},
+	{
+		Name:     "updateRoleApprovers",
+		Path:     "/api/v1/roles/:id/approvers",
+		Method:   "PUT",
+		Describe: "设置角色访问申请的审批人",
+	},
+	{
+		Name:     "accessRequestListAll",
+		Path:     "/api/v1/access-requests/all",
+		Method:   "GET",
+		Describe: "查看所有用户的角色访问申请",
+	},
+	{
+		Name:     "auditList",
+		Path:     "/api/v1/audit",
+		Method:   "GET",
+		Describe: "查询审计记录",
+	},
+	{
+		Name:     "auditExport",
+		Path:     "/api/v1/audit/export",
+		Method:   "GET",
+		Describe: "导出审计记录",
+	},
+	{
+		Name:     "authzCheck",
+		Path:     "/api/v1/authz/check",
+		Method:   "POST",
+		Describe: "检查用户或角色的权限并解释结果",
+	},
+	{
+		Name:     "tenantList",
+		Path:     "/api/v1/tenants",
+		Method:   "GET",
+		Describe: "获取租户列表",
+	},
+	{
+		Name:     "createTenant",
+		Path:     "/api/v1/tenants",
+		Method:   "POST",
+		Describe: "创建租户",
+	},
+	{
+		Name:     "deleteTenant",
+		Path:     "/api/v1/tenants/:tenant",
+		Method:   "DELETE",
+		Describe: "删除租户",
+	},
+	{
+		Name:     "tenantMemberList",
+		Path:     "/api/v1/tenants/:tenant/members",
+		Method:   "GET",
+		Describe: "获取租户成员列表",
+	},
+	{
+		Name:     "setTenantMember",
+		Path:     "/api/v1/tenants/:tenant/members",
+		Method:   "PUT",
+		Describe: "添加租户成员或设置租户管理员",
+	},
+	{
+		Name:     "removeTenantMember",
+		Path:     "/api/v1/tenants/:tenant/members/:uid",
+		Method:   "DELETE",
+		Describe: "移除租户成员",
+	},
+	{
+		Name:     "setTenantMemberRoles",
+		Path:     "/api/v1/tenants/:tenant/members/:uid/roles",
+		Method:   "PUT",
+		Describe: "设置成员在租户内的角色",
+	},
+	{
+		Name:     "tenantRoleList",
+		Path:     "/api/v1/tenants/:tenant/roles",
+		Method:   "GET",
+		Describe: "获取租户角色列表",
+	},
+	{
+		Name:     "createTenantRole",
+		Path:     "/api/v1/tenants/:tenant/roles",
+		Method:   "POST",
+		Describe: "创建租户角色",
+	},
+	{
+		Name:     "deleteTenantRole",
+		Path:     "/api/v1/tenants/:tenant/roles/:id",
+		Method:   "DELETE",
+		Describe: "删除租户角色",
+	},
+	{
+		Name:     "addTenantRolePolices",
+		Path:     "/api/v1/tenants/:tenant/roles/:id/polices",
+		Method:   "PUT",
+		Describe: "租户角色添加权限",
+	},
+	{
+		Name:     "deleteTenantRolePolices",
+		Path:     "/api/v1/tenants/:tenant/roles/:id/polices",
+		Method:   "POST",
+		Describe: "租户角色删除权限",
+	},
+	{
+		Name:     "tenantPolicyList",
+		Path:     "/api/v1/tenants/:tenant/polices",
+		Method:   "GET",
+		Describe: "获取租户角色可用的策略",
+	},
+	{
+		Name:      "getOwnUserInfo",
+		Path:      "/api/v1/users/:id",
+		Method:    "GET",
+		Describe:  "获取自己的用户信息",
+		Condition: "r.env.Owner",
+	},
+	{
+		Name:      "listOwnUserSessions",
+		Path:      "/api/v1/users/:id/sessions",
+		Method:    "GET",
+		Describe:  "获取自己的会话列表",
+		Condition: "r.env.Owner",
+	},
+	{
+		Name:      "killOwnUserSession",
+		Path:      "/api/v1/users/:id/sessions/:sid",
+		Method:    "DELETE",
+		Describe:  "结束自己的会话",
+		Condition: "r.env.Owner",
+	},
 }
@@ -10,7 +10,9 @@ import (
 	"qqlx/base/data"
 	"qqlx/base/helpers"
 	"qqlx/base/logger"
+	"qqlx/base/validator"
 	"qqlx/model"
+	"qqlx/pkg/mailer"
 	"qqlx/pkg/sonyflake"
 	"qqlx/schema"
 	"qqlx/service"
@@ -92,7 +94,11 @@ func initData(cf string) {
 		_ = zap.S().Sync()
 		closeFunc()
 	}()
-	if err = db.AutoMigrate(&model.User{}, &model.Role{}, &model.Policy{}); err != nil {
+	if err = db.AutoMigrate(&model.User{}, &model.Role{}, &model.Policy{}, &model.PasswordHistory{}, &model.ApiKey{}, &model.Session{}, &model.Tenant{}, &model.TenantMember{}, &model.RbacRevision{}, &model.UserRole{}, &model.AccessRequest{}, &model.AuditEvent{}, &model.AuditChain{}); err != nil {
+		panic(err)
+	}
+	// 增加 effect 之前创建的策略按 allow 处理
+	if err = db.Model(&model.Policy{}).Where("effect = '' OR effect IS NULL").Update("effect", constant.PolicyEffectAllow).Error; err != nil {
 		panic(err)
 	}
 	casbinStore := rbac.NewCasbinStore(enforcer)
@@ -114,8 +120,8 @@ func initData(cf string) {
 	roleStore := rbac.NewRoleStore(db)
 	policyStore := rbac.NewPolicyStore(db)
 	appendStore := rbac.NewRoleAssociationStore(db)
-	roleSvc := service.NewRoleSVC(generateIDStruct, roleStore, policyStore, appendStore, casbinStore, ldapStore)
-	policySvc := service.NewPolicySVC(generateIDStruct, policyStore)
+	roleSvc := service.NewRoleSVC(generateIDStruct, roleStore, policyStore, appendStore, casbinStore, ldapStore, nil, nil)
+	policySvc := service.NewPolicySVC(generateIDStruct, policyStore, casbinStore, nil, nil, nil)
 	// Create Polices
 	for _, police := range polices {
 		_ = policySvc.CreatePolicy(ctxValue, &police)
@@ -142,6 +148,20 @@ func initData(cf string) {
 	if err != nil {
 		logger.Caller().Error(err)
 	}
+	err = roleSvc.CreateRole(ctxValue, &schema.RoleCreateRequest{
+		Name:     "self",
+		Describe: "只能查看自己的用户信息和管理自己的会话",
+	})
+	if err != nil {
+		logger.Caller().Error(err)
+	}
+	err = roleSvc.CreateRole(ctxValue, &schema.RoleCreateRequest{
+		Name:     constant.TenantAdminRole,
+		Describe: "租户管理员, 管理所在租户的成员和角色",
+	})
+	if err != nil {
+		logger.Caller().Error(err)
+	}
 
 	// Create Role Polices
 	adminRole, err := roleRepo.Query(ctxValue, rbac.RoleName("admin"))
@@ -157,7 +177,7 @@ func initData(cf string) {
 	if err != nil {
 		logger.Caller().Error(err)
 	}
-	err = casbinStore.CreateRolePolices(ctxValue, [][]string{{adminRole.Name, adminPolicy.Path, adminPolicy.Method}})
+	err = casbinStore.CreateRolePolices(ctxValue, helpers.GetCasbinRole(adminRole, []model.Policy{*adminPolicy}))
 	if err != nil {
 		zap.S().Error(err)
 	}
@@ -175,7 +195,7 @@ func initData(cf string) {
 	if err != nil {
 		logger.Caller().Error(err)
 	}
-	err = casbinStore.CreateRolePolices(ctxValue, [][]string{{viewRole.Name, viewPolicy.Path, viewPolicy.Method}})
+	err = casbinStore.CreateRolePolices(ctxValue, helpers.GetCasbinRole(viewRole, []model.Policy{*viewPolicy}))
 	if err != nil {
 		zap.S().Error(err)
 	}
@@ -185,7 +205,7 @@ func initData(cf string) {
 	if err != nil {
 		logger.Caller().Error(err)
 	}
-	_, rbacPolicy, err := policyStore.List(ctxValue, -1, -1, rbac.NotInPolicyNames([]string{"admin", "view"}))
+	_, rbacPolicy, err := policyStore.List(ctxValue, -1, -1, rbac.NotInPolicyNames(append([]string{"admin", "view"}, selfPolices...)))
 	if err != nil {
 		logger.Caller().Error(err)
 	}
@@ -193,13 +213,71 @@ func initData(cf string) {
 	if err != nil {
 		logger.Caller().Error(err)
 	}
-	casbinPolicy := helpers.GetCasbinRole("rbac", rbacPolicy)
+	casbinPolicy := helpers.GetCasbinRole(rbacRole, rbacPolicy)
 	err = casbinStore.CreateRolePolices(ctxValue, casbinPolicy)
 	if err != nil {
 		logger.Caller().Error(err)
 	}
 
-	userSvc, err := service.NewUserSVC(generateIDStruct, userRepo, userRoleStore, roleRepo, cacheStore, casbinStore, ldapStore)
+	// self role 添加条件策略
+	selfRole, err := roleRepo.Query(ctxValue, rbac.RoleName("self"))
+	if err != nil {
+		logger.Caller().Error(err)
+	}
+	_, selfPolicy, err := policyStore.List(ctxValue, -1, -1, rbac.InPolicyNames(selfPolices))
+	if err != nil {
+		logger.Caller().Error(err)
+	}
+	err = appendStore.AppendPolicy(ctxValue, selfRole, selfPolicy)
+	if err != nil {
+		logger.Caller().Error(err)
+	}
+	err = casbinStore.CreateRolePolices(ctxValue, helpers.GetCasbinRole(selfRole, selfPolicy))
+	if err != nil {
+		logger.Caller().Error(err)
+	}
+
+	// tenant-admin role 添加租户路由的权限, 租户成员设置为管理员时在该租户内生效
+	tenantAdminRole, err := roleRepo.Query(ctxValue, rbac.RoleName(constant.TenantAdminRole))
+	if err != nil {
+		logger.Caller().Error(err)
+	}
+	_, tenantPolicy, err := policyStore.List(ctxValue, -1, -1, rbac.PolicyPathPrefix(constant.TenantPathPrefix))
+	if err != nil {
+		logger.Caller().Error(err)
+	}
+	err = appendStore.AppendPolicy(ctxValue, tenantAdminRole, tenantPolicy)
+	if err != nil {
+		logger.Caller().Error(err)
+	}
+	err = casbinStore.CreateRolePolices(ctxValue, helpers.GetCasbinRole(tenantAdminRole, tenantPolicy))
+	if err != nil {
+		logger.Caller().Error(err)
+	}
+
+	mailSvc, err := service.NewMailSVC(cacheStore, mailer.NewLogMailer())
+	if err != nil {
+		logger.Caller().Error(err)
+		return
+	}
+	passwordPolicy, err := validator.NewPasswordPolicy()
+	if err != nil {
+		logger.Caller().Error(err)
+		return
+	}
+	loginGuardSvc, err := service.NewLoginGuardSVC(cacheStore)
+	if err != nil {
+		logger.Caller().Error(err)
+		return
+	}
+	userSvc, err := service.NewUserSVC(generateIDStruct, userRepo, userRoleStore, roleRepo, cacheStore, casbinStore, ldapStore, service.UserAuth{
+		Token:    service.NewTokenSVC(cacheStore, userstore.NewSessionStore(db), nil),
+		Mfa:      service.NewMfaSVC(userRepo, cacheStore, loginGuardSvc, nil),
+		Mail:     mailSvc,
+		Guard:    loginGuardSvc,
+		Password: passwordPolicy,
+		History:  userstore.NewPasswordHistoryStore(db),
+	}, nil)
 	if err != nil {
 		logger.Caller().Error(err)
 		return
@@ -211,6 +289,7 @@ func initData(cf string) {
 		Email:    "admin@qq.com",
 		Avatar:   "https://wpimg.wallstcn.com/f778738c-e4f8-4870-b634-56703b4acafe.gif",
 		Mobile:   "13800000000",
+		Verified: true,
 	}
 	if err = userSvc.RegistryUser(ctxValue, adminReq); err != nil {
 		logger.Caller().Errorf("init admin user failed: %v", err)
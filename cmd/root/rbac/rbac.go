@@ -0,0 +1,206 @@
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"qqlx/base/conf"
+	"qqlx/base/constant"
+	"qqlx/base/data"
+	"qqlx/base/logger"
+	"qqlx/base/validator"
+	"qqlx/pkg/mailer"
+	"qqlx/pkg/sonyflake"
+	"qqlx/schema"
+	"qqlx/service"
+	"qqlx/store/audit"
+	"qqlx/store/cache"
+	ldapstore "qqlx/store/ldap"
+	"qqlx/store/rbac"
+	"qqlx/store/userstore"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "rbac",
+	Short: "export and apply roles and policies as yaml",
+	Long:  "export and apply roles and policies as yaml",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if !cmd.Flags().Changed(constant.FlagConfigPath) {
+			envConfigPath := os.Getenv(constant.ConfigEnv)
+			if envConfigPath != "" {
+				err := cmd.Flags().Set(constant.FlagConfigPath, envConfigPath)
+				if err != nil {
+					log.Fatalf("set config file path from env %s faild: %v", envConfigPath, err)
+				}
+			}
+		}
+	},
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "export policies, roles, role policies and user roles",
+	Long:  "export policies, roles, role policies and user roles",
+	Run: func(cmd *cobra.Command, args []string) {
+		cf, err := cmd.Flags().GetString(constant.FlagConfigPath)
+		if err != nil {
+			log.Fatalf("get config file path faild: %v", err)
+		}
+		output, _ := cmd.Flags().GetString("output")
+		export(cf, output)
+	},
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "reconcile database, casbin and ldap groups to match the yaml file",
+	Long:  "reconcile database, casbin and ldap groups to match the yaml file",
+	Run: func(cmd *cobra.Command, args []string) {
+		cf, err := cmd.Flags().GetString(constant.FlagConfigPath)
+		if err != nil {
+			log.Fatalf("get config file path faild: %v", err)
+		}
+		file, _ := cmd.Flags().GetString("file")
+		if file == "" {
+			log.Fatal("--file is required")
+		}
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		prune, _ := cmd.Flags().GetBool("prune")
+		apply(cf, file, dryRun, prune)
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringP("output", "o", "", "output file, default stdout")
+	applyCmd.Flags().StringP("file", "f", "", "yaml file to apply")
+	applyCmd.Flags().Bool("dry-run", false, "only print the plan")
+	applyCmd.Flags().Bool("prune", false, "delete policies, roles and assignments not in the file")
+	Cmd.AddCommand(exportCmd, applyCmd)
+}
+
+func export(cf, output string) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "rbac-export")
+	manifestSvc, cleanup := newManifestSVC(ctx, cf)
+	defer cleanup()
+	res, err := manifestSvc.Export(ctx)
+	if err != nil {
+		log.Fatalf("export rbac failed: %v", err)
+	}
+	out, err := yaml.Marshal(res)
+	if err != nil {
+		log.Fatalf("marshal rbac failed: %v", err)
+	}
+	if output == "" {
+		fmt.Print(string(out))
+		return
+	}
+	if err = os.WriteFile(output, out, 0o644); err != nil {
+		log.Fatalf("write %s failed: %v", output, err)
+	}
+}
+
+func apply(cf, file string, dryRun, prune bool) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		log.Fatalf("read %s failed: %v", file, err)
+	}
+	manifest := new(schema.RbacManifest)
+	if err = yaml.Unmarshal(content, manifest); err != nil {
+		log.Fatalf("parse %s failed: %v", file, err)
+	}
+	ctx := context.WithValue(context.Background(), constant.TraceID, "rbac-apply")
+	manifestSvc, cleanup := newManifestSVC(ctx, cf)
+	defer cleanup()
+	res, err := manifestSvc.Apply(ctx, manifest, dryRun, prune)
+	if err != nil {
+		log.Fatalf("apply rbac failed: %v", err)
+	}
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		log.Fatalf("marshal plan failed: %v", err)
+	}
+	fmt.Println(string(out))
+}
+
+// newManifestSVC 按配置文件初始化 mysql、redis、casbin 和 ldap
+func newManifestSVC(ctx context.Context, cf string) (*service.ManifestSVC, func()) {
+	if err := conf.LoadConfig(cf); err != nil {
+		log.Fatalf("load config file %s failed: %v", cf, err)
+	}
+	logger.InitLogger()
+	db, closeDB, err := data.InitMySQL()
+	if err != nil {
+		log.Fatalf("init mysql failed: %v", err)
+	}
+	redisCli, err := data.CreateRDB(ctx)
+	if err != nil {
+		log.Fatalf("init redis failed: %v", err)
+	}
+	cacheStore, closeCache, err := cache.NewStore(redisCli)
+	if err != nil {
+		log.Fatalf("init cache store failed: %v", err)
+	}
+	enforcer, err := data.InitCasbin()
+	if err != nil {
+		log.Fatalf("init casbin failed: %v", err)
+	}
+	var ldapStore *ldapstore.Store
+	closeLdap := func() {}
+	if conf.GetLdapEnable() {
+		ldapCon, f, err := data.InitLdap()
+		if err != nil {
+			log.Fatalf("init ldap failed: %v", err)
+		}
+		closeLdap = f
+		if ldapStore, err = ldapstore.NewLdapStore(ldapCon); err != nil {
+			log.Fatalf("init ldap store failed: %v", err)
+		}
+	}
+	cleanup := func() {
+		closeLdap()
+		closeCache()
+		closeDB()
+	}
+
+	generateID := sonyflake.NewGenerateID(ctx, cacheStore)
+	userStore := userstore.NewUserStore(db)
+	roleStore := rbac.NewRoleStore(db)
+	policyStore := rbac.NewPolicyStore(db)
+	casbinStore := rbac.NewCasbinStore(enforcer)
+	auditSvc, err := service.NewAuditSVC(audit.NewAuditStore(db))
+	if err != nil {
+		log.Fatalf("init audit service failed: %v", err)
+	}
+	revisionSvc := service.NewRevisionSVC(generateID, rbac.NewRevisionStore(db), roleStore, policyStore, casbinStore, auditSvc)
+	roleSvc := service.NewRoleSVC(generateID, roleStore, policyStore, rbac.NewRoleAssociationStore(db), casbinStore, ldapStore, revisionSvc, auditSvc)
+	policySvc := service.NewPolicySVC(generateID, policyStore, casbinStore, nil, revisionSvc, auditSvc)
+	mailSvc, err := service.NewMailSVC(cacheStore, mailer.NewLogMailer())
+	if err != nil {
+		log.Fatalf("init mail service failed: %v", err)
+	}
+	passwordPolicy, err := validator.NewPasswordPolicy()
+	if err != nil {
+		log.Fatalf("init password policy failed: %v", err)
+	}
+	loginGuardSvc, err := service.NewLoginGuardSVC(cacheStore)
+	if err != nil {
+		log.Fatalf("init login guard failed: %v", err)
+	}
+	userSvc, err := service.NewUserSVC(generateID, userStore, userstore.NewUserAssociationStore(db), roleStore, cacheStore, casbinStore, ldapStore, service.UserAuth{
+		Token:    service.NewTokenSVC(cacheStore, userstore.NewSessionStore(db), auditSvc),
+		Mfa:      service.NewMfaSVC(userStore, cacheStore, loginGuardSvc, auditSvc),
+		Mail:     mailSvc,
+		Guard:    loginGuardSvc,
+		Password: passwordPolicy,
+		History:  userstore.NewPasswordHistoryStore(db),
+	}, auditSvc)
+	if err != nil {
+		log.Fatalf("init user service failed: %v", err)
+	}
+	return service.NewManifestSVC(userStore, roleStore, policyStore, policySvc, roleSvc, userSvc), cleanup
+}
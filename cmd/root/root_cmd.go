@@ -4,7 +4,10 @@ import (
 	"log"
 	"qqlx/base/conf"
 	"qqlx/base/constant"
+	"qqlx/cmd/root/audit"
+	"qqlx/cmd/root/authz"
 	"qqlx/cmd/root/init_data"
+	"qqlx/cmd/root/rbac"
 	"qqlx/cmd/root/run"
 
 	"github.com/spf13/cobra"
@@ -19,7 +22,7 @@ var rootCmd = &cobra.Command{
 func init() {
 	// 添加全局标志
 	rootCmd.PersistentFlags().StringP(constant.FlagConfigPath, "C", "./config.yaml", "config file path")
-	rootCmd.AddCommand(run.Cmd, init_data.InitCmd)
+	rootCmd.AddCommand(run.Cmd, init_data.InitCmd, authz.Cmd, rbac.Cmd, audit.Cmd)
 }
 
 func Execute() {
@@ -7,6 +7,7 @@ import (
 	"qqlx/base/conf"
 	"qqlx/base/constant"
 	"qqlx/base/logger"
+	"qqlx/base/tracing"
 	"qqlx/cmd"
 	"qqlx/pkg/jwt"
 
@@ -57,10 +58,15 @@ func runApp(configPath string) {
 		zap.S().Fatal(err)
 	}
 	ctx := context.Background()
+	shutdownTracer, err := tracing.InitTracer(ctx)
+	if err != nil {
+		zap.S().Fatal(err)
+	}
 	application, cleanup, err := cmd.InitApplication(ctx)
 	defer func() {
 		_ = zap.S().Sync()
 		cleanup()
+		shutdownTracer()
 	}()
 	if err != nil {
 		zap.S().Fatal(err)
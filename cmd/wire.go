@@ -32,6 +32,10 @@ func InitApplication(ctx context.Context) (*app.Application, func(), error) {
 	//))
 	wire.Build(
 		server.NewHttpServer,
+		server.NewRoleExpiryWorker,
+		server.NewAccessRequestExpiryWorker,
+		server.NewSessionPurgeWorker,
+		server.NewAuditCheckpointWorker,
 		store.ProviderStore,
 		service.ProviderService,
 		validator.ProviderValidator,
@@ -18,6 +18,7 @@ import (
 	"qqlx/pkg/sonyflake"
 	"qqlx/router"
 	"qqlx/service"
+	"qqlx/store/audit"
 	"qqlx/store/cache"
 	"qqlx/store/ldap"
 	"qqlx/store/rbac"
@@ -64,27 +65,116 @@ func InitApplication(ctx context.Context) (*app.Application, func(), error) {
 		cleanup()
 		return nil, nil, err
 	}
-	userSVC, err := service.NewUserSVC(generateIDStruct, userstoreStore, userAssociationStore, roleStore, store, casbinStore, ldapStore)
+	sessionStore := userstore.NewSessionStore(db)
+	auditStore := audit.NewAuditStore(db)
+	auditSVC, err := service.NewAuditSVC(auditStore)
 	if err != nil {
 		cleanup3()
 		cleanup2()
 		cleanup()
 		return nil, nil, err
 	}
-	validatorValidator := validator.NewValidator()
+	tokenSVC := service.NewTokenSVC(store, sessionStore, auditSVC)
+	loginGuardSVC, err := service.NewLoginGuardSVC(store)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	mfaSVC := service.NewMfaSVC(userstoreStore, store, loginGuardSVC, auditSVC)
+	mailer, err := data.InitMailer()
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	mailSVC, err := service.NewMailSVC(store, mailer)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	passwordPolicy, err := validator.NewPasswordPolicy()
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	passwordHistoryStore := userstore.NewPasswordHistoryStore(db)
+	userAuth := service.UserAuth{
+		Token:    tokenSVC,
+		Mfa:      mfaSVC,
+		Mail:     mailSVC,
+		Guard:    loginGuardSVC,
+		Password: passwordPolicy,
+		History:  passwordHistoryStore,
+	}
+	userSVC, err := service.NewUserSVC(generateIDStruct, userstoreStore, userAssociationStore, roleStore, store, casbinStore, ldapStore, userAuth, auditSVC)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	validatorValidator := validator.NewValidator(passwordPolicy)
 	bindRequest := handler.NewResponse(validatorValidator)
 	userCtrl := controller.NewUserCtrl(userSVC, bindRequest)
 	policyStore := rbac.NewPolicyStore(db)
 	roleAssociationStore := rbac.NewRoleAssociationStore(db)
-	roleSVC := service.NewRoleSVC(generateIDStruct, roleStore, policyStore, roleAssociationStore, casbinStore, ldapStore)
+	revisionStore := rbac.NewRevisionStore(db)
+	revisionSVC := service.NewRevisionSVC(generateIDStruct, revisionStore, roleStore, policyStore, casbinStore, auditSVC)
+	roleSVC := service.NewRoleSVC(generateIDStruct, roleStore, policyStore, roleAssociationStore, casbinStore, ldapStore, revisionSVC, auditSVC)
 	roleCtrl := controller.NewRoleCtrl(roleSVC, bindRequest)
-	policySVC := service.NewPolicySVC(generateIDStruct, policyStore)
+	routeCatalog := router.NewRouteCatalog()
+	policySVC := service.NewPolicySVC(generateIDStruct, policyStore, casbinStore, routeCatalog, revisionSVC, auditSVC)
 	policyCtrl := controller.NewPolicyCtrl(policySVC, bindRequest)
-	apiRoute := router.NewApiRoute(userCtrl, roleCtrl, policyCtrl)
+	oidcClient, err := data.InitOIDC(ctx)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	oidcSVC, err := service.NewOidcSVC(oidcClient, store, userstoreStore, userSVC, tokenSVC, mfaSVC, auditSVC)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	oidcCtrl := controller.NewOidcCtrl(oidcSVC, bindRequest)
+	mfaCtrl := controller.NewMfaCtrl(mfaSVC, userSVC, bindRequest)
+	apiKeyStore := userstore.NewApiKeyStore(db)
+	apiKeySVC := service.NewApiKeySVC(generateIDStruct, userstoreStore, apiKeyStore, auditSVC)
+	apiKeyCtrl := controller.NewApiKeyCtrl(apiKeySVC, bindRequest)
+	sessionCtrl := controller.NewSessionCtrl(tokenSVC, bindRequest)
+	tenantStore := rbac.NewTenantStore(db)
+	tenantMemberStore := rbac.NewTenantMemberStore(db)
+	tenantSVC := service.NewTenantSVC(generateIDStruct, tenantStore, tenantMemberStore, userstoreStore, roleStore, policyStore, roleSVC, store, auditSVC)
+	tenantCtrl := controller.NewTenantCtrl(tenantSVC, bindRequest)
 	authentication := rbac.NewAuthentication(enforcer)
-	authorizationMiddleware := middleware.NewAuthorization(store, authentication, userstoreStore)
-	engine := server.NewHttpServer(apiRoute, authorizationMiddleware)
-	application := app.NewApplication(engine)
+	authzSVC := service.NewAuthzSVC(userstoreStore, userAssociationStore, roleStore, tenantMemberStore, authentication)
+	authzCtrl := controller.NewAuthzCtrl(authzSVC, bindRequest)
+	revisionCtrl := controller.NewRevisionCtrl(revisionSVC, bindRequest)
+	manifestSVC := service.NewManifestSVC(userstoreStore, roleStore, policyStore, policySVC, roleSVC, userSVC)
+	manifestCtrl := controller.NewManifestCtrl(manifestSVC, bindRequest)
+	accessRequestStore := rbac.NewAccessRequestStore(db)
+	accessRequestSVC := service.NewAccessRequestSVC(generateIDStruct, accessRequestStore, roleStore, roleAssociationStore, userstoreStore, userSVC, auditSVC)
+	accessRequestCtrl := controller.NewAccessRequestCtrl(accessRequestSVC, bindRequest)
+	auditCtrl := controller.NewAuditCtrl(auditSVC, bindRequest)
+	apiRoute := router.NewApiRoute(userCtrl, roleCtrl, policyCtrl, oidcCtrl, mfaCtrl, apiKeyCtrl, sessionCtrl, tenantCtrl, authzCtrl, revisionCtrl, manifestCtrl, accessRequestCtrl, auditCtrl, routeCatalog)
+	authenticationMiddleware := middleware.NewAuthentication(tokenSVC, apiKeySVC, tokenSVC)
+	authorizationMiddleware := middleware.NewAuthorization(store, authentication, userstoreStore, userAssociationStore, tenantMemberStore)
+	engine := server.NewHttpServer(apiRoute, routeCatalog, policySVC, authenticationMiddleware, authorizationMiddleware)
+	roleExpiryWorker := server.NewRoleExpiryWorker(userSVC)
+	accessRequestExpiryWorker := server.NewAccessRequestExpiryWorker(accessRequestSVC)
+	sessionPurgeWorker := server.NewSessionPurgeWorker(tokenSVC)
+	auditCheckpointWorker := server.NewAuditCheckpointWorker(auditSVC)
+	application := app.NewApplication(engine, roleExpiryWorker, accessRequestExpiryWorker, sessionPurgeWorker, auditCheckpointWorker)
 	return application, func() {
 		cleanup3()
 		cleanup2()
@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"context"
+	"qqlx/base/handler"
+	"qqlx/pkg/jwt"
+	"qqlx/schema"
+	"qqlx/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccessRequestCtrl struct {
+	accessRequestSvc *service.AccessRequestSVC
+	res              handler.BindResponseInterface
+}
+
+func NewAccessRequestCtrl(accessRequestSvc *service.AccessRequestSVC, res *handler.BindRequest) *AccessRequestCtrl {
+	return &AccessRequestCtrl{
+		accessRequestSvc: accessRequestSvc,
+		res:              res,
+	}
+}
+
+// CreateHandler 当前用户申请角色
+func (receive *AccessRequestCtrl) CreateHandler(c *gin.Context) {
+	req := new(schema.AccessRequestCreateRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckJson()) {
+		return
+	}
+	claims, err := jwt.GetMyClaims(c)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	req.UserID = claims.UserID
+	res, err := receive.accessRequestSvc.Create(c, req)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+// ListHandler 当前用户的申请
+func (receive *AccessRequestCtrl) ListHandler(c *gin.Context) {
+	receive.list(c, receive.accessRequestSvc.ListMine)
+}
+
+// QueueHandler 当前用户待审批的申请
+func (receive *AccessRequestCtrl) QueueHandler(c *gin.Context) {
+	receive.list(c, receive.accessRequestSvc.Queue)
+}
+
+// ListAllHandler 所有用户的申请
+func (receive *AccessRequestCtrl) ListAllHandler(c *gin.Context) {
+	receive.list(c, receive.accessRequestSvc.ListAll)
+}
+
+func (receive *AccessRequestCtrl) list(c *gin.Context, list func(context.Context, *schema.AccessRequestListRequest) (*schema.AccessRequestListResponse, error)) {
+	req := new(schema.AccessRequestListRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckQuery()) {
+		return
+	}
+	claims, err := jwt.GetMyClaims(c)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	req.UserID = claims.UserID
+	res, err := list(c, req)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+// ApproveHandler 审批人通过申请
+func (receive *AccessRequestCtrl) ApproveHandler(c *gin.Context) {
+	receive.decide(c, receive.accessRequestSvc.Approve)
+}
+
+// RejectHandler 审批人拒绝申请
+func (receive *AccessRequestCtrl) RejectHandler(c *gin.Context) {
+	receive.decide(c, receive.accessRequestSvc.Reject)
+}
+
+func (receive *AccessRequestCtrl) decide(c *gin.Context, decide func(context.Context, *schema.AccessRequestDecideRequest) (*schema.AccessRequestResponse, error)) {
+	req := new(schema.AccessRequestDecideRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri(), handler.WithCheckJson()) {
+		return
+	}
+	claims, err := jwt.GetMyClaims(c)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	req.UserID = claims.UserID
+	res, err := decide(c, req)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+// UpdateApproversHandler 设置角色的审批人
+func (receive *AccessRequestCtrl) UpdateApproversHandler(c *gin.Context) {
+	req := new(schema.RoleApproverRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri(), handler.WithCheckJson()) {
+		return
+	}
+	if err := receive.accessRequestSvc.UpdateApprovers(c, req); err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, nil)
+}
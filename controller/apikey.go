@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"qqlx/base/apierr"
+	"qqlx/base/handler"
+	"qqlx/base/reason"
+	"qqlx/pkg/jwt"
+	"qqlx/schema"
+	"qqlx/service"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ApiKeyCtrl struct {
+	apiKeySvc *service.ApiKeySVC
+	res       handler.BindResponseInterface
+}
+
+func NewApiKeyCtrl(apiKeySvc *service.ApiKeySVC, res *handler.BindRequest) *ApiKeyCtrl {
+	return &ApiKeyCtrl{
+		apiKeySvc: apiKeySvc,
+		res:       res,
+	}
+}
+
+// ListHandler 当前用户的 API key 列表
+func (receive *ApiKeyCtrl) ListHandler(c *gin.Context) {
+	claims, err := jwt.GetMyClaims(c)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	res, err := receive.apiKeySvc.List(c, claims.UserID)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+// CreateHandler 当前用户创建 API key
+func (receive *ApiKeyCtrl) CreateHandler(c *gin.Context) {
+	req := new(schema.ApiKeyCreateRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckJson()) {
+		return
+	}
+	claims, err := jwt.GetMyClaims(c)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	req.UserID = claims.UserID
+	res, err := receive.apiKeySvc.Create(c, req)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+// RevokeHandler 当前用户吊销 API key
+func (receive *ApiKeyCtrl) RevokeHandler(c *gin.Context) {
+	req := new(schema.ApiKeyQueryRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri()) {
+		return
+	}
+	claims, err := jwt.GetMyClaims(c)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	req.UserID = claims.UserID
+	if err = receive.apiKeySvc.Revoke(c, req); err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, nil)
+}
+
+// CreateServiceAccountHandler 创建服务账号
+func (receive *ApiKeyCtrl) CreateServiceAccountHandler(c *gin.Context) {
+	req := new(schema.ServiceAccountCreateRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckJson()) {
+		return
+	}
+	if !regexp.MustCompile(`^[a-zA-Z0-9]+$`).MatchString(req.Name) {
+		receive.res.ResponseFailure(c, apierr.BadRequest().Set(apierr.ParamsErrCode, reason.ErrNameInvalid.Error(), reason.ErrNameInvalid))
+		return
+	}
+	res, err := receive.apiKeySvc.CreateServiceAccount(c, req)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+// ListServiceAccountKeyHandler 服务账号的 API key 列表
+func (receive *ApiKeyCtrl) ListServiceAccountKeyHandler(c *gin.Context) {
+	req := new(schema.ServiceAccountRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri()) {
+		return
+	}
+	if err := receive.apiKeySvc.ServiceAccount(c, req.ID); err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	res, err := receive.apiKeySvc.List(c, req.ID)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+// CreateServiceAccountKeyHandler 为服务账号创建 API key
+func (receive *ApiKeyCtrl) CreateServiceAccountKeyHandler(c *gin.Context) {
+	uri := new(schema.ServiceAccountRequest)
+	if receive.res.BindAndCheck(c, uri, handler.WithCheckUri()) {
+		return
+	}
+	req := new(schema.ApiKeyCreateRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckJson()) {
+		return
+	}
+	if err := receive.apiKeySvc.ServiceAccount(c, uri.ID); err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	req.UserID = uri.ID
+	res, err := receive.apiKeySvc.Create(c, req)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+// RevokeServiceAccountKeyHandler 吊销服务账号的 API key
+func (receive *ApiKeyCtrl) RevokeServiceAccountKeyHandler(c *gin.Context) {
+	req := new(schema.ApiKeyQueryRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri()) {
+		return
+	}
+	if err := receive.apiKeySvc.ServiceAccount(c, req.UserID); err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	if err := receive.apiKeySvc.Revoke(c, req); err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, nil)
+}
@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"qqlx/base/handler"
+	"qqlx/base/logger"
+	"qqlx/schema"
+	"qqlx/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditCtrl struct {
+	auditSvc *service.AuditSVC
+	res      handler.BindResponseInterface
+}
+
+func NewAuditCtrl(auditSvc *service.AuditSVC, res *handler.BindRequest) *AuditCtrl {
+	return &AuditCtrl{
+		auditSvc: auditSvc,
+		res:      res,
+	}
+}
+
+// ListHandler 游标分页查询审计记录
+func (receive *AuditCtrl) ListHandler(c *gin.Context) {
+	req := new(schema.AuditListRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckQuery()) {
+		return
+	}
+	res, err := receive.auditSvc.List(c, req)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+// ExportHandler 以 JSON Lines 格式导出审计记录
+func (receive *AuditCtrl) ExportHandler(c *gin.Context) {
+	req := new(schema.AuditListRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckQuery()) {
+		return
+	}
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", `attachment; filename="audit.jsonl"`)
+	if err := receive.auditSvc.Export(c, req, c.Writer); err != nil {
+		// 已经开始输出时无法再返回错误响应
+		if c.Writer.Written() {
+			logger.WithContext(c, true).Errorf("export audit events failed: %v", err)
+			return
+		}
+		receive.res.ResponseFailure(c, err)
+	}
+}
@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"qqlx/base/handler"
+	"qqlx/schema"
+	"qqlx/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuthzCtrl struct {
+	authzSvc *service.AuthzSVC
+	res      handler.BindResponseInterface
+}
+
+func NewAuthzCtrl(authzSvc *service.AuthzSVC, res *handler.BindRequest) *AuthzCtrl {
+	return &AuthzCtrl{
+		authzSvc: authzSvc,
+		res:      res,
+	}
+}
+
+// CheckHandler 检查用户或角色的权限并解释结果
+func (receive *AuthzCtrl) CheckHandler(c *gin.Context) {
+	req := new(schema.AuthzCheckRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckJson()) {
+		return
+	}
+	res, err := receive.authzSvc.Check(c, req)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"qqlx/base/apierr"
+	"qqlx/base/handler"
+	"qqlx/base/reason"
+	"qqlx/schema"
+	"qqlx/service"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+type ManifestCtrl struct {
+	manifestSvc *service.ManifestSVC
+	res         handler.BindResponseInterface
+}
+
+func NewManifestCtrl(manifestSvc *service.ManifestSVC, res *handler.BindRequest) *ManifestCtrl {
+	return &ManifestCtrl{
+		manifestSvc: manifestSvc,
+		res:         res,
+	}
+}
+
+// ExportHandler 导出角色策略配置
+func (receive *ManifestCtrl) ExportHandler(c *gin.Context) {
+	res, err := receive.manifestSvc.Export(c)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+// ApplyHandler 应用角色策略配置, 请求体为 YAML 或 JSON
+func (receive *ManifestCtrl) ApplyHandler(c *gin.Context) {
+	req := new(schema.RbacApplyRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckQuery()) {
+		return
+	}
+	body, err := c.GetRawData()
+	if err != nil {
+		receive.res.ResponseFailure(c, apierr.BadRequest().Set(apierr.ServiceErrCode, "failed to read request body", err))
+		return
+	}
+	manifest := new(schema.RbacManifest)
+	if err = yaml.Unmarshal(body, manifest); err != nil {
+		receive.res.ResponseFailure(c, apierr.BadRequest().Set(apierr.ServiceErrCode, reason.ErrManifestInvalid.Error(), err))
+		return
+	}
+	res, err := receive.manifestSvc.Apply(c, manifest, req.DryRun, req.Prune)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"qqlx/base/handler"
+	"qqlx/pkg/jwt"
+	"qqlx/schema"
+	"qqlx/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MfaCtrl struct {
+	mfaSvc  *service.MfaSVC
+	userSvc *service.UserSVC
+	res     handler.BindResponseInterface
+}
+
+func NewMfaCtrl(mfaSvc *service.MfaSVC, userSvc *service.UserSVC, res *handler.BindRequest) *MfaCtrl {
+	return &MfaCtrl{
+		mfaSvc:  mfaSvc,
+		userSvc: userSvc,
+		res:     res,
+	}
+}
+
+// LoginHandler 使用 mfa 登录票据和验证码完成登录
+func (receive *MfaCtrl) LoginHandler(c *gin.Context) {
+	req := new(schema.MfaLoginRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckJson()) {
+		return
+	}
+	req.IP = c.ClientIP()
+	res, err := receive.userSvc.LoginMfa(c, req)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+// LoginEnrollHandler 使用 mfa 登录票据绑定 MFA
+func (receive *MfaCtrl) LoginEnrollHandler(c *gin.Context) {
+	req := new(schema.MfaTicketRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckJson()) {
+		return
+	}
+	res, err := receive.mfaSvc.EnrollWithTicket(c, req)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+// EnrollHandler 当前用户生成 TOTP 密钥
+func (receive *MfaCtrl) EnrollHandler(c *gin.Context) {
+	claims, err := jwt.GetMyClaims(c)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	res, err := receive.mfaSvc.Enroll(c, claims.UserID)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+// ConfirmHandler 当前用户确认验证码并启用 MFA
+func (receive *MfaCtrl) ConfirmHandler(c *gin.Context) {
+	req := new(schema.MfaConfirmRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckJson()) {
+		return
+	}
+	claims, err := jwt.GetMyClaims(c)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	req.ID = claims.UserID
+	res, err := receive.mfaSvc.Confirm(c, req)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+// ResetHandler 管理员重置用户 MFA
+func (receive *MfaCtrl) ResetHandler(c *gin.Context) {
+	req := new(schema.UserQueryRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri()) {
+		return
+	}
+	if err := receive.mfaSvc.Reset(c, req); err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, nil)
+}
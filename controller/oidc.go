@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"net/http"
+	"qqlx/base/conf"
+	"qqlx/base/constant"
+	"qqlx/base/handler"
+	"qqlx/pkg/jwt"
+	"qqlx/schema"
+	"qqlx/service"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OidcCtrl struct {
+	oidcSvc *service.OidcSVC
+	res     handler.BindResponseInterface
+}
+
+func NewOidcCtrl(oidcSvc *service.OidcSVC, res *handler.BindRequest) *OidcCtrl {
+	return &OidcCtrl{
+		oidcSvc: oidcSvc,
+		res:     res,
+	}
+}
+
+// LoginHandler 获取 OIDC 授权地址
+func (receive *OidcCtrl) LoginHandler(c *gin.Context) {
+	res, err := receive.oidcSvc.Login(c)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	setOidcStateCookie(c, res.State)
+	receive.res.ResponseSuccess(c, res)
+}
+
+// LinkHandler 当前用户获取绑定 OIDC 身份的授权地址
+func (receive *OidcCtrl) LinkHandler(c *gin.Context) {
+	claims, err := jwt.GetMyClaims(c)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	res, err := receive.oidcSvc.Link(c, claims.UserID)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	setOidcStateCookie(c, res.State)
+	receive.res.ResponseSuccess(c, res)
+}
+
+// CallbackHandler OIDC 授权码回调, 返回 token
+func (receive *OidcCtrl) CallbackHandler(c *gin.Context) {
+	req := new(schema.OidcCallbackRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckQuery()) {
+		return
+	}
+	req.Cookie, _ = c.Cookie(constant.OidcStateCookie)
+	// state 只能使用一次, 回调后清除 cookie
+	setOidcStateCookie(c, "")
+	res, err := receive.oidcSvc.Callback(c, req)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+// setOidcStateCookie 把 state 写入 HttpOnly cookie, 回调时要求与 state 一致, value 为空时清除
+func setOidcStateCookie(c *gin.Context, value string) {
+	maxAge := -1
+	if value != "" {
+		expire, _ := time.ParseDuration(constant.OidcStateExpireTime)
+		maxAge = int(expire.Seconds())
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(constant.OidcStateCookie, value, maxAge, constant.OidcStateCookiePath, "", conf.GetOidcCookieSecure(), true)
+}
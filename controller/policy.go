@@ -81,3 +81,23 @@ func (receive *PolicyCtrl) ListHandler(c *gin.Context) {
 	}
 	receive.res.ResponseSuccess(c, res)
 }
+
+// CatalogHandler 对比路由目录和策略
+func (receive *PolicyCtrl) CatalogHandler(c *gin.Context) {
+	res, err := receive.policySvc.Catalog(c, false)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+// ReconcileHandler 为缺少策略的鉴权路由创建策略
+func (receive *PolicyCtrl) ReconcileHandler(c *gin.Context) {
+	res, err := receive.policySvc.Catalog(c, true)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
@@ -6,4 +6,14 @@ var ProviderContr = wire.NewSet(
 	NewUserCtrl,
 	NewRoleCtrl,
 	NewPolicyCtrl,
+	NewOidcCtrl,
+	NewMfaCtrl,
+	NewApiKeyCtrl,
+	NewSessionCtrl,
+	NewTenantCtrl,
+	NewAuthzCtrl,
+	NewRevisionCtrl,
+	NewManifestCtrl,
+	NewAccessRequestCtrl,
+	NewAuditCtrl,
 )
@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"qqlx/base/handler"
+	"qqlx/schema"
+	"qqlx/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RevisionCtrl struct {
+	revisionSvc *service.RevisionSVC
+	res         handler.BindResponseInterface
+}
+
+func NewRevisionCtrl(revisionSvc *service.RevisionSVC, res *handler.BindRequest) *RevisionCtrl {
+	return &RevisionCtrl{
+		revisionSvc: revisionSvc,
+		res:         res,
+	}
+}
+
+func (receive *RevisionCtrl) ListHandler(c *gin.Context) {
+	req := new(schema.RevisionListRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckQuery()) {
+		return
+	}
+	res, err := receive.revisionSvc.List(c, req)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+func (receive *RevisionCtrl) GetHandler(c *gin.Context) {
+	req := new(schema.RevisionIDRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri()) {
+		return
+	}
+	res, err := receive.revisionSvc.Get(c, req)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+// DiffHandler 对比两个版本的角色策略
+func (receive *RevisionCtrl) DiffHandler(c *gin.Context) {
+	req := new(schema.RevisionDiffRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckQuery()) {
+		return
+	}
+	res, err := receive.revisionSvc.Diff(c, req)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+// RollbackHandler 回滚角色策略到指定版本
+func (receive *RevisionCtrl) RollbackHandler(c *gin.Context) {
+	req := new(schema.RevisionIDRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri()) {
+		return
+	}
+	res, err := receive.revisionSvc.Rollback(c, req)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
@@ -105,3 +105,30 @@ func (receive *RoleCtrl) ListHandler(c *gin.Context) {
 	}
 	receive.res.ResponseSuccess(c, res)
 }
+
+// UpdateParentsHandler 设置角色继承的父角色
+func (receive *RoleCtrl) UpdateParentsHandler(c *gin.Context) {
+	req := new(schema.RoleParentRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri(), handler.WithCheckJson()) {
+		return
+	}
+	if err := receive.roleSvc.UpdateParents(c, req); err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, nil)
+}
+
+// GetPolicesHandler 获取角色直接分配的策略和有效策略
+func (receive *RoleCtrl) GetPolicesHandler(c *gin.Context) {
+	req := new(schema.RoleIDRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri()) {
+		return
+	}
+	res, err := receive.roleSvc.GetRolePolices(c, req)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
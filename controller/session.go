@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"qqlx/base/handler"
+	"qqlx/pkg/jwt"
+	"qqlx/schema"
+	"qqlx/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionCtrl struct {
+	tokenSvc *service.TokenSVC
+	res      handler.BindResponseInterface
+}
+
+func NewSessionCtrl(tokenSvc *service.TokenSVC, res *handler.BindRequest) *SessionCtrl {
+	return &SessionCtrl{
+		tokenSvc: tokenSvc,
+		res:      res,
+	}
+}
+
+// ListHandler 当前用户的会话列表
+func (receive *SessionCtrl) ListHandler(c *gin.Context) {
+	claims, err := jwt.GetMyClaims(c)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	res, err := receive.tokenSvc.ListSessions(c, claims.UserID, claims.FamilyID)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+// KillHandler 当前用户结束会话
+func (receive *SessionCtrl) KillHandler(c *gin.Context) {
+	req := new(schema.SessionQueryRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri()) {
+		return
+	}
+	claims, err := jwt.GetMyClaims(c)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	req.UserID = claims.UserID
+	if err = receive.tokenSvc.KillSession(c, req); err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, nil)
+}
+
+// UserListHandler 管理员查询用户的会话列表
+func (receive *SessionCtrl) UserListHandler(c *gin.Context) {
+	req := new(schema.SessionListRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri()) {
+		return
+	}
+	res, err := receive.tokenSvc.ListSessions(c, req.UserID, "")
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+// UserKillHandler 管理员结束用户的会话
+func (receive *SessionCtrl) UserKillHandler(c *gin.Context) {
+	req := new(schema.SessionQueryRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri()) {
+		return
+	}
+	if err := receive.tokenSvc.KillSession(c, req); err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, nil)
+}
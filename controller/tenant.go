@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"qqlx/base/handler"
+	"qqlx/schema"
+	"qqlx/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TenantCtrl struct {
+	tenantSvc *service.TenantSVC
+	res       handler.BindResponseInterface
+}
+
+func NewTenantCtrl(tenantSvc *service.TenantSVC, res *handler.BindRequest) *TenantCtrl {
+	return &TenantCtrl{
+		tenantSvc: tenantSvc,
+		res:       res,
+	}
+}
+
+func (receive *TenantCtrl) ListHandler(c *gin.Context) {
+	res, err := receive.tenantSvc.ListTenant(c)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+func (receive *TenantCtrl) CreateHandler(c *gin.Context) {
+	req := new(schema.TenantCreateRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckJson()) {
+		return
+	}
+	if err := receive.tenantSvc.CreateTenant(c, req); err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, nil)
+}
+
+func (receive *TenantCtrl) DeleteHandler(c *gin.Context) {
+	req := new(schema.TenantIDRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri()) {
+		return
+	}
+	if err := receive.tenantSvc.DeleteTenant(c, req); err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, nil)
+}
+
+// ListMemberHandler 租户成员列表
+func (receive *TenantCtrl) ListMemberHandler(c *gin.Context) {
+	req := new(schema.TenantIDRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri()) {
+		return
+	}
+	res, err := receive.tenantSvc.ListMember(c, req)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+// SetMemberHandler 添加租户成员或设置管理员
+func (receive *TenantCtrl) SetMemberHandler(c *gin.Context) {
+	req := new(schema.TenantMemberRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri(), handler.WithCheckJson()) {
+		return
+	}
+	if err := receive.tenantSvc.SetMember(c, req); err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, nil)
+}
+
+func (receive *TenantCtrl) RemoveMemberHandler(c *gin.Context) {
+	req := new(schema.TenantMemberIDRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri()) {
+		return
+	}
+	if err := receive.tenantSvc.RemoveMember(c, req); err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, nil)
+}
+
+// SetMemberRolesHandler 设置成员在租户内的角色
+func (receive *TenantCtrl) SetMemberRolesHandler(c *gin.Context) {
+	req := new(schema.TenantMemberRoleRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri(), handler.WithCheckJson()) {
+		return
+	}
+	if err := receive.tenantSvc.SetMemberRoles(c, req); err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, nil)
+}
+
+func (receive *TenantCtrl) ListRoleHandler(c *gin.Context) {
+	req := new(schema.TenantIDRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri()) {
+		return
+	}
+	res, err := receive.tenantSvc.ListRole(c, req)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+func (receive *TenantCtrl) CreateRoleHandler(c *gin.Context) {
+	req := new(schema.TenantRoleCreateRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri(), handler.WithCheckJson()) {
+		return
+	}
+	if err := receive.tenantSvc.CreateRole(c, req); err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, nil)
+}
+
+func (receive *TenantCtrl) DeleteRoleHandler(c *gin.Context) {
+	req := new(schema.TenantRoleRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri()) {
+		return
+	}
+	if err := receive.tenantSvc.DeleteRole(c, req); err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, nil)
+}
+
+func (receive *TenantCtrl) AddRolePolicyHandler(c *gin.Context) {
+	req := new(schema.TenantRolePolicyRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri(), handler.WithCheckJson()) {
+		return
+	}
+	if err := receive.tenantSvc.AddRolePolicy(c, req); err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, nil)
+}
+
+func (receive *TenantCtrl) DeleteRolePolicyHandler(c *gin.Context) {
+	req := new(schema.TenantRolePolicyRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri(), handler.WithCheckJson()) {
+		return
+	}
+	if err := receive.tenantSvc.DeleteRolePolicy(c, req); err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, nil)
+}
+
+// ListPolicyHandler 租户角色可以使用的策略
+func (receive *TenantCtrl) ListPolicyHandler(c *gin.Context) {
+	req := new(schema.TenantIDRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri()) {
+		return
+	}
+	res, err := receive.tenantSvc.ListPolicy(c, req)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
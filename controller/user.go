@@ -47,6 +47,7 @@ func (receive *UserCtrl) LoginHandler(c *gin.Context) {
 	if receive.res.BindAndCheck(c, req, handler.WithCheckJson()) {
 		return
 	}
+	req.IP = c.ClientIP()
 	res, err := receive.userSvc.Login(c, req)
 	if err != nil {
 		receive.res.ResponseFailure(c, err)
@@ -55,13 +56,106 @@ func (receive *UserCtrl) LoginHandler(c *gin.Context) {
 	receive.res.ResponseSuccess(c, res)
 }
 
+// RefreshHandler 使用 refresh token 换取新的 token
+func (receive *UserCtrl) RefreshHandler(c *gin.Context) {
+	req := new(schema.UserRefreshRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckJson()) {
+		return
+	}
+	res, err := receive.userSvc.RefreshToken(c, req)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+// UnlockHandler 管理员解除用户登录锁定
+func (receive *UserCtrl) UnlockHandler(c *gin.Context) {
+	req := new(schema.UserUnlockRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckUri(), handler.WithCheckQuery()) {
+		return
+	}
+	if err := receive.userSvc.UnlockUser(c, req); err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, nil)
+}
+
+// ChangeExpiredPasswordHandler 密码过期后使用票据修改密码并完成登录
+func (receive *UserCtrl) ChangeExpiredPasswordHandler(c *gin.Context) {
+	req := new(schema.UserChangeExpiredPasswordRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckJson()) {
+		return
+	}
+	res, err := receive.userSvc.ChangeExpiredPassword(c, req)
+	if err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, res)
+}
+
+// ForgotPasswordHandler 发送重置密码邮件
+func (receive *UserCtrl) ForgotPasswordHandler(c *gin.Context) {
+	req := new(schema.UserEmailRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckJson()) {
+		return
+	}
+	if err := receive.userSvc.ForgotPassword(c, req); err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, nil)
+}
+
+// ResetPasswordHandler 使用邮件 token 重置密码
+func (receive *UserCtrl) ResetPasswordHandler(c *gin.Context) {
+	req := new(schema.UserResetPasswordRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckJson()) {
+		return
+	}
+	if err := receive.userSvc.ResetPassword(c, req); err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, nil)
+}
+
+// VerifyEmailHandler 使用邮件 token 验证邮箱
+func (receive *UserCtrl) VerifyEmailHandler(c *gin.Context) {
+	req := new(schema.UserVerifyEmailRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckJson()) {
+		return
+	}
+	if err := receive.userSvc.VerifyEmail(c, req); err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, nil)
+}
+
+// ResendVerifyHandler 重新发送验证邮件
+func (receive *UserCtrl) ResendVerifyHandler(c *gin.Context) {
+	req := new(schema.UserEmailRequest)
+	if receive.res.BindAndCheck(c, req, handler.WithCheckJson()) {
+		return
+	}
+	if err := receive.userSvc.ResendVerifyMail(c, req); err != nil {
+		receive.res.ResponseFailure(c, err)
+		return
+	}
+	receive.res.ResponseSuccess(c, nil)
+}
+
 func (receive *UserCtrl) LogoutHandler(c *gin.Context) {
 	claims, err := jwt.GetMyClaims(c)
 	if err != nil {
 		receive.res.ResponseFailure(c, err)
 		return
 	}
-	if err = receive.userSvc.Logout(c, claims.UserID); err != nil {
+	if err = receive.userSvc.Logout(c, claims); err != nil {
 		receive.res.ResponseFailure(c, err)
 		return
 	}
@@ -0,0 +1,34 @@
+package model
+
+// 访问申请状态
+const (
+	AccessRequestPending  = "pending"
+	AccessRequestApproved = "approved"
+	AccessRequestRejected = "rejected"
+	AccessRequestExpired  = "expired"
+)
+
+// AccessRequest 用户申请全局角色, 由角色的审批人审批, 通过后授予角色
+type AccessRequest struct {
+	ID            int    `gorm:"primarykey" json:"id"`
+	CreatedAt     int    `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt     int    `gorm:"autoUpdateTime" json:"updatedAt"`
+	UserID        int    `gorm:"comment:申请人;index" json:"userId"`
+	RoleID        int    `gorm:"comment:申请的角色;index" json:"roleId"`
+	Justification string `gorm:"comment:申请理由;size:1024" json:"justification"`
+	// Duration 申请的授权时长, 为空时永久授权
+	Duration string `gorm:"comment:申请的授权时长;size:20" json:"duration"`
+	Status   string `gorm:"comment:状态,pending,approved,rejected,expired;size:10;index;default:pending" json:"status"`
+	// ApproverID 审批人, 过期时为 0
+	ApproverID int    `gorm:"comment:审批人" json:"approverId"`
+	Comment    string `gorm:"comment:审批意见;size:1024" json:"comment"`
+	DecidedAt  int    `gorm:"comment:审批时间" json:"decidedAt"`
+	// ExpiresAt 未审批的申请在该时间后过期
+	ExpiresAt int   `gorm:"comment:申请过期时间;index" json:"expiresAt"`
+	User      *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Role      *Role `gorm:"foreignKey:RoleID" json:"role,omitempty"`
+}
+
+func (receiver *AccessRequest) TableName() string {
+	return "access_requests"
+}
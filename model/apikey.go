@@ -0,0 +1,25 @@
+package model
+
+import (
+	"gorm.io/plugin/soft_delete"
+)
+
+// ApiKey 用户或服务账号的 API key, 只保存摘要
+type ApiKey struct {
+	ID         int                   `gorm:"primarykey"`
+	CreatedAt  int                   `gorm:"autoCreateTime"`
+	UpdatedAt  int                   `gorm:"autoUpdateTime"`
+	DeletedAt  soft_delete.DeletedAt `gorm:"softDelete:;index"`
+	Name       string                `gorm:"comment:名称;size:50"`
+	Prefix     string                `gorm:"comment:key前缀,用于识别;size:20"`
+	Hash       string                `gorm:"comment:key sha256摘要;uniqueIndex;size:64"`
+	UserID     int                   `gorm:"comment:所属用户ID;index"`
+	Roles      string                `gorm:"comment:限制使用的角色,逗号分隔,为空时使用用户全部角色;size:1024"`
+	ExpiresAt  int                   `gorm:"comment:过期时间,0不过期"`
+	LastUsedAt int                   `gorm:"comment:最近使用时间"`
+	User       *User                 `gorm:"foreignKey:UserID"`
+}
+
+func (receiver *ApiKey) TableName() string {
+	return "api_keys"
+}
@@ -0,0 +1,47 @@
+package model
+
+import "encoding/json"
+
+// 审计事件结果
+const (
+	AuditResultSuccess = "success"
+	AuditResultFailure = "failure"
+)
+
+// AuditEvent 管理操作和登录的审计记录, 只追加不修改
+//
+// Hash 为 PrevHash 和记录内容的 HMAC, 按 ID 顺序组成哈希链, 修改或删除记录后校验失败
+type AuditEvent struct {
+	ID        int `gorm:"primarykey" json:"id"`
+	CreatedAt int `gorm:"autoCreateTime;index" json:"createdAt"`
+	// ActorID 操作人, 登录时为登录的用户, 后台任务和命令行为 0
+	ActorID    int    `gorm:"comment:操作人;index" json:"actorId"`
+	ActorName  string `gorm:"comment:操作人名称;size:100" json:"actorName"`
+	Action     string `gorm:"comment:操作;size:50;index" json:"action"`
+	TargetType string `gorm:"comment:操作对象类型;size:20;index:idx_audit_target" json:"targetType"`
+	TargetID   string `gorm:"comment:操作对象;size:100;index:idx_audit_target" json:"targetId"`
+	// Before After 为写入时的 JSON 原文, 保证校验时的内容和计算哈希时一致
+	Before   json.RawMessage `gorm:"comment:修改前;type:text" json:"before,omitempty"`
+	After    json.RawMessage `gorm:"comment:修改后;type:text" json:"after,omitempty"`
+	ClientIP string          `gorm:"comment:客户端IP;size:64" json:"clientIp"`
+	TraceID  string          `gorm:"comment:请求ID;size:64;index" json:"traceId"`
+	Result   string          `gorm:"comment:结果,success或failure;size:10" json:"result"`
+	Error    string          `gorm:"comment:失败原因;size:512" json:"error,omitempty"`
+	PrevHash string          `gorm:"comment:上一条记录的哈希;size:64" json:"prevHash"`
+	Hash     string          `gorm:"comment:记录哈希;size:64" json:"hash"`
+}
+
+func (receiver *AuditEvent) TableName() string {
+	return "audit_events"
+}
+
+// AuditChain 哈希链的最后一条记录, 只有一行, 追加记录时加锁保证顺序, 用于发现末尾记录被删除
+type AuditChain struct {
+	ID     int    `gorm:"primarykey" json:"id"`
+	LastID int    `gorm:"comment:最后一条记录" json:"lastId"`
+	Hash   string `gorm:"comment:最后一条记录的哈希;size:64" json:"hash"`
+}
+
+func (receiver *AuditChain) TableName() string {
+	return "audit_chain"
+}
@@ -0,0 +1,13 @@
+package model
+
+// PasswordHistory 用户历史密码, 用于禁止重复使用最近的密码
+type PasswordHistory struct {
+	ID        int    `gorm:"primarykey"`
+	CreatedAt int    `gorm:"autoCreateTime"`
+	UserID    int    `gorm:"comment:用户ID;index"`
+	Password  string `gorm:"comment:密码哈希;size:255"`
+}
+
+func (receiver *PasswordHistory) TableName() string {
+	return "password_histories"
+}
@@ -13,6 +13,8 @@ type Policy struct {
 	Path      string                `gorm:"comment:路径;size:128;uniqueIndex:idx_policy_name_path_method" json:"path"`
 	Method    string                `gorm:"comment:方法;size:10;uniqueIndex:idx_policy_name_path_method" json:"method"`
 	Describe  string                `gorm:"comment:描述;size:1024" json:"describe"`
+	Effect    string                `gorm:"comment:效果,allow或deny;size:10;default:allow" json:"effect"`
+	Condition string                `gorm:"comment:生效条件,为空时无条件;size:512" json:"condition"`
 	Roles     []Role                `gorm:"many2many:role_policy;" json:"roles,omitempty"`
 }
 
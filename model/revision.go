@@ -0,0 +1,29 @@
+package model
+
+// RbacRevision 角色策略变更记录, Snapshot、Parents 和 Polices 为变更后的状态, 用于对比和回滚
+type RbacRevision struct {
+	ID        int    `gorm:"primarykey" json:"id"`
+	CreatedAt int    `gorm:"autoCreateTime" json:"createdAt"`
+	Operator  string `gorm:"comment:操作人;size:50" json:"operator"`
+	Action    string `gorm:"comment:操作;size:50;index" json:"action"`
+	Role      string `gorm:"comment:变更的角色,回滚和修改策略时为空;size:50;index" json:"role"`
+	// RollbackID 回滚时为目标版本
+	RollbackID int              `gorm:"comment:回滚的目标版本" json:"rollbackId,omitempty"`
+	Before     []int            `gorm:"comment:变更前角色的策略ID;serializer:json;type:text" json:"before"`
+	After      []int            `gorm:"comment:变更后角色的策略ID;serializer:json;type:text" json:"after"`
+	Snapshot   map[string][]int `gorm:"comment:变更后所有角色的策略ID;serializer:json;type:mediumtext" json:"-"`
+	// Parents 角色对应的父角色, key 和 value 为角色的 casbin subject, 旧版本为空时回滚不恢复继承关系
+	Parents map[string][]string `gorm:"comment:变更后所有角色的父角色;serializer:json;type:mediumtext" json:"-"`
+	// Polices 策略ID对应的效果和条件, 旧版本为空时回滚不恢复
+	Polices map[int]RevisionPolicy `gorm:"comment:变更后所有策略的效果和条件;serializer:json;type:mediumtext" json:"-"`
+}
+
+func (receiver *RbacRevision) TableName() string {
+	return "rbac_revisions"
+}
+
+// RevisionPolicy 版本中策略的效果和条件
+type RevisionPolicy struct {
+	Effect    string `json:"effect"`
+	Condition string `json:"condition,omitempty"`
+}
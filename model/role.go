@@ -9,10 +9,14 @@ type Role struct {
 	CreatedAt   int                   `gorm:"autoCreateTime" json:"createdAt"`
 	UpdatedAt   int                   `gorm:"autoUpdateTime" json:"updatedAt"`
 	DeletedAt   soft_delete.DeletedAt `gorm:"softDelete:;index" json:"deletedAt"`
-	Name        string                `gorm:"comment:角色名称;uniqueIndex;size:50" json:"name"`
+	Name        string                `gorm:"comment:角色名称;uniqueIndex:idx_role_tenant_name,priority:2;size:50" json:"name"`
 	Description string                `gorm:"comment:角色描述;size:1024" json:"description"`
+	TenantID    int                   `gorm:"comment:所属租户,0为全局角色;uniqueIndex:idx_role_tenant_name,priority:1;default:0" json:"tenantId"`
 	Policys     []Policy              `gorm:"many2many:role_policy;" json:"policys,omitempty"`
+	Parents     []Role                `gorm:"many2many:role_parent;joinForeignKey:RoleID;joinReferences:ParentID" json:"parents,omitempty"`
 	Users       []User                `gorm:"many2many:user_role;" json:"users,omitempty"`
+	// Approvers 审批该角色访问申请的用户
+	Approvers []User `gorm:"many2many:role_approver;" json:"approvers,omitempty"`
 }
 
 func (receiver *Role) TableName() string {
@@ -22,3 +26,7 @@ func (receiver *Role) TableName() string {
 func (receiver Role) GetName() string {
 	return receiver.Name
 }
+
+func (receiver Role) GetID() int {
+	return receiver.ID
+}
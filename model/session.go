@@ -0,0 +1,23 @@
+package model
+
+import (
+	"gorm.io/plugin/soft_delete"
+)
+
+// Session 登录会话, ID 与 refresh token 家族一致, 刷新 token 时保持不变
+type Session struct {
+	ID         string                `gorm:"primarykey;size:36"`
+	CreatedAt  int                   `gorm:"autoCreateTime"`
+	UpdatedAt  int                   `gorm:"autoUpdateTime"`
+	DeletedAt  soft_delete.DeletedAt `gorm:"softDelete:;index"`
+	UserID     int                   `gorm:"comment:用户ID;index"`
+	TokenID    string                `gorm:"comment:当前 access token jti;size:36"`
+	IP         string                `gorm:"comment:登录IP;size:64"`
+	UserAgent  string                `gorm:"comment:登录客户端;size:512"`
+	LastSeenAt int                   `gorm:"comment:最近访问时间"`
+	ExpiresAt  int                   `gorm:"comment:过期时间,与 refresh token 一致"`
+}
+
+func (receiver *Session) TableName() string {
+	return "sessions"
+}
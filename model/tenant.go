@@ -0,0 +1,34 @@
+package model
+
+import (
+	"gorm.io/plugin/soft_delete"
+)
+
+type Tenant struct {
+	ID          int                   `gorm:"primarykey" json:"id"`
+	CreatedAt   int                   `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt   int                   `gorm:"autoUpdateTime" json:"updatedAt"`
+	DeletedAt   soft_delete.DeletedAt `gorm:"softDelete:;index" json:"deletedAt"`
+	Name        string                `gorm:"comment:租户名称;uniqueIndex;size:50" json:"name"`
+	Description string                `gorm:"comment:租户描述;size:1024" json:"description"`
+}
+
+func (receiver *Tenant) TableName() string {
+	return "tenants"
+}
+
+// TenantMember 租户成员, 成员在租户内的角色只在该租户内生效
+type TenantMember struct {
+	ID        int    `gorm:"primarykey" json:"id"`
+	CreatedAt int    `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt int    `gorm:"autoUpdateTime" json:"updatedAt"`
+	TenantID  int    `gorm:"comment:租户ID;uniqueIndex:idx_tenant_member" json:"tenantId"`
+	UserID    int    `gorm:"comment:用户ID;uniqueIndex:idx_tenant_member;index" json:"userId"`
+	Admin     bool   `gorm:"comment:是否为租户管理员;default:false" json:"admin"`
+	Roles     []Role `gorm:"many2many:tenant_member_role;" json:"roles,omitempty"`
+	User      *User  `json:"user,omitempty"`
+}
+
+func (receiver *TenantMember) TableName() string {
+	return "tenant_members"
+}
@@ -10,20 +10,31 @@ var (
 )
 
 type User struct {
-	ID        int                   `gorm:"primarykey"`
-	CreatedAt int                   `gorm:"autoCreateTime"`
-	UpdatedAt int                   `gorm:"autoUpdateTime"`
-	DeletedAt soft_delete.DeletedAt `gorm:"softDelete:;index"`
-	Name      string                `gorm:"comment:用户名称;uniqueIndex;size:50"`
-	NickName  string                `gorm:"comment:用户昵称;size:50"`
-	Email     string                `gorm:"comment:邮箱;uniqueIndex;size:100"`
-	Password  string                `gorm:"comment:用户密码;size:255"`
-	Avatar    string                `gorm:"comment:用户头像;size:1024"`
-	Mobile    string                `gorm:"comment:用户手机号;size:20"`
-	Status    *int                  `gorm:"comment:用户状态,1可用,2删除;size:1;default:1"`
-	Roles     []Role                `gorm:"many2many:user_role;" json:"role,omitempty"`
+	ID               int                   `gorm:"primarykey"`
+	CreatedAt        int                   `gorm:"autoCreateTime"`
+	UpdatedAt        int                   `gorm:"autoUpdateTime"`
+	DeletedAt        soft_delete.DeletedAt `gorm:"softDelete:;index"`
+	Name             string                `gorm:"comment:用户名称;uniqueIndex;size:50"`
+	NickName         string                `gorm:"comment:用户昵称;size:50"`
+	Email            string                `gorm:"comment:邮箱;uniqueIndex;size:100"`
+	Password         string                `gorm:"comment:用户密码;size:255"`
+	Avatar           string                `gorm:"comment:用户头像;size:1024"`
+	Mobile           string                `gorm:"comment:用户手机号;size:20"`
+	Status           *int                  `gorm:"comment:用户状态,1可用,2删除;size:1;default:1"`
+	OidcSub          string                `gorm:"comment:OIDC subject;size:255;index"`
+	PasswordChangeAt int                   `gorm:"comment:密码修改时间"`
+	ServiceAccount   bool                  `gorm:"comment:是否为服务账号;default:false"`
+	Verified         bool                  `gorm:"comment:邮箱是否已验证;default:false"`
+	MfaEnable        bool                  `gorm:"comment:是否启用MFA;default:false"`
+	MfaSecret        string                `gorm:"comment:TOTP密钥;size:64" json:"-"`
+	MfaRecoveryCodes string                `gorm:"comment:MFA恢复码sha256摘要,逗号分隔;size:1024" json:"-"`
+	Roles            []Role                `gorm:"many2many:user_role;" json:"role,omitempty"`
 }
 
 func (receiver *User) TableName() string {
 	return "users"
 }
+
+func (receiver User) GetID() int {
+	return receiver.ID
+}
@@ -0,0 +1,14 @@
+package model
+
+// UserRole user_role 关联表, 由 User.Roles 维护关联, 记录临时授权的过期时间和原因
+type UserRole struct {
+	UserID    int    `gorm:"primaryKey" json:"userId"`
+	RoleID    int    `gorm:"primaryKey" json:"roleId"`
+	ExpiresAt int    `gorm:"comment:过期时间,0为永久;index;default:0" json:"expiresAt"`
+	Reason    string `gorm:"comment:授权原因;size:255" json:"reason"`
+	Role      *Role  `gorm:"foreignKey:RoleID" json:"role,omitempty"`
+}
+
+func (receiver *UserRole) TableName() string {
+	return "user_role"
+}
@@ -0,0 +1,212 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"os"
+	"qqlx/base/conf"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// signingKey 非对称签名密钥, private 为空时只用于验签
+type signingKey struct {
+	kid     string
+	private crypto.PrivateKey
+	public  crypto.PublicKey
+}
+
+// keySet 非对称签名密钥集合
+//
+// active 为当前签名使用的密钥, verify 包含所有可用于验签的密钥, 用于密钥轮换
+type keySet struct {
+	method jwt.SigningMethod
+	active *signingKey
+	verify map[string]*signingKey
+}
+
+// JWK JSON Web Key, 只包含公钥信息
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func signingMethod(algorithm string) (jwt.SigningMethod, error) {
+	switch algorithm {
+	case AlgorithmHS256:
+		return jwt.SigningMethodHS256, nil
+	case AlgorithmRS256:
+		return jwt.SigningMethodRS256, nil
+	case AlgorithmES256:
+		return jwt.SigningMethodES256, nil
+	case AlgorithmEdDSA:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("jwt.algorithm is not supported: %s", algorithm)
+	}
+}
+
+// loadKeySet 从配置的密钥文件加载非对称密钥
+func loadKeySet(method jwt.SigningMethod, activeKid string, keys []conf.JwtKey) (*keySet, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("jwt.keys is empty, algorithm %s requires key files", method.Alg())
+	}
+	set := &keySet{
+		method: method,
+		verify: make(map[string]*signingKey, len(keys)),
+	}
+	for _, k := range keys {
+		if k.Kid == "" {
+			return nil, fmt.Errorf("jwt.keys kid is empty")
+		}
+		if _, exist := set.verify[k.Kid]; exist {
+			return nil, fmt.Errorf("jwt.keys kid %s is duplicated", k.Kid)
+		}
+		key, err := loadKey(method, k)
+		if err != nil {
+			return nil, err
+		}
+		set.verify[k.Kid] = key
+	}
+
+	active, ok := set.verify[activeKid]
+	if !ok {
+		return nil, fmt.Errorf("jwt.activeKid %s not found in jwt.keys", activeKid)
+	}
+	if active.private == nil {
+		return nil, fmt.Errorf("jwt.keys kid %s has no privateKey, can not be used to sign", activeKid)
+	}
+	set.active = active
+	return set, nil
+}
+
+func loadKey(method jwt.SigningMethod, k conf.JwtKey) (*signingKey, error) {
+	key := &signingKey{kid: k.Kid}
+	if k.PrivateKey != "" {
+		data, err := os.ReadFile(k.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("read jwt private key %s failed: %w", k.PrivateKey, err)
+		}
+		switch method {
+		case jwt.SigningMethodRS256:
+			private, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, fmt.Errorf("parse jwt rsa private key %s failed: %w", k.PrivateKey, err)
+			}
+			key.private, key.public = private, &private.PublicKey
+		case jwt.SigningMethodES256:
+			private, err := jwt.ParseECPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, fmt.Errorf("parse jwt ec private key %s failed: %w", k.PrivateKey, err)
+			}
+			key.private, key.public = private, &private.PublicKey
+		case jwt.SigningMethodEdDSA:
+			private, err := jwt.ParseEdPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, fmt.Errorf("parse jwt ed25519 private key %s failed: %w", k.PrivateKey, err)
+			}
+			key.private, key.public = private, private.(ed25519.PrivateKey).Public()
+		}
+	}
+
+	// 只配置公钥时用于验签(已轮换下线的密钥)
+	if k.PublicKey != "" {
+		data, err := os.ReadFile(k.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("read jwt public key %s failed: %w", k.PublicKey, err)
+		}
+		var public crypto.PublicKey
+		switch method {
+		case jwt.SigningMethodRS256:
+			public, err = jwt.ParseRSAPublicKeyFromPEM(data)
+		case jwt.SigningMethodES256:
+			public, err = jwt.ParseECPublicKeyFromPEM(data)
+		case jwt.SigningMethodEdDSA:
+			public, err = jwt.ParseEdPublicKeyFromPEM(data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse jwt public key %s failed: %w", k.PublicKey, err)
+		}
+		// 同时配置私钥时公钥必须与私钥匹配, 否则签发的 token 无法通过验签
+		if key.public != nil && !publicKeyEqual(key.public, public) {
+			return nil, fmt.Errorf("jwt.keys kid %s: publicKey does not match privateKey", k.Kid)
+		}
+		key.public = public
+	}
+
+	if key.public == nil {
+		return nil, fmt.Errorf("jwt.keys kid %s requires privateKey or publicKey", k.Kid)
+	}
+	if ec, ok := key.public.(*ecdsa.PublicKey); ok && ec.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("jwt.keys kid %s: ES256 requires a P-256 key", k.Kid)
+	}
+	return key, nil
+}
+
+func publicKeyEqual(a, b crypto.PublicKey) bool {
+	key, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && key.Equal(b)
+}
+
+// jwk 将公钥转换为 JWK
+func (k *signingKey) jwk(alg string) JWK {
+	res := JWK{Kid: k.kid, Use: "sig", Alg: alg}
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		res.Kty = "RSA"
+		res.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		res.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		res.Kty = "EC"
+		res.Crv = pub.Curve.Params().Name
+		res.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+		res.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		res.Kty = "OKP"
+		res.Crv = "Ed25519"
+		res.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+	return res
+}
+
+// GetJWKS 返回所有验签公钥, HS256 模式下为空
+func GetJWKS() *JWKS {
+	res := &JWKS{Keys: make([]JWK, 0)}
+	if jwtConf.keys == nil {
+		return res
+	}
+	for _, key := range jwtConf.keys.verify {
+		res.Keys = append(res.Keys, key.jwk(jwtConf.keys.method.Alg()))
+	}
+	sort.Slice(res.Keys, func(i, j int) bool {
+		return res.Keys[i].Kid < res.Keys[j].Kid
+	})
+	return res
+}
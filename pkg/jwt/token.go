@@ -1,6 +1,10 @@
 package jwt
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"qqlx/base/apierr"
 	"qqlx/base/conf"
 	"qqlx/base/constant"
@@ -9,36 +13,85 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 var jwtConf = &Conf{}
 
 type Conf struct {
-	Secret string
-	Expire time.Duration
-	Issuer string
+	Secret        string
+	Expire        time.Duration
+	RefreshExpire time.Duration
+	Issuer        string
+	method        jwt.SigningMethod
+	// keys 非对称签名密钥, HS256 时为 nil
+	keys *keySet
 }
 
 func InitConf() error {
-	secret, err := conf.GetJwtSecret()
+	var (
+		secret string
+		keys   *keySet
+	)
+	method, err := signingMethod(conf.GetJwtAlgorithm())
 	if err != nil {
 		return err
 	}
+	if method == jwt.SigningMethodHS256 {
+		secret, err = conf.GetJwtSecret()
+		if err != nil {
+			return err
+		}
+	} else {
+		jwtKeys, err := conf.GetJwtKeys()
+		if err != nil {
+			return err
+		}
+		keys, err = loadKeySet(method, conf.GetJwtActiveKid(), jwtKeys)
+		if err != nil {
+			return err
+		}
+	}
 	expirationTime, err := conf.GetJwtExpirationTime()
 	if err != nil {
 		return err
 	}
+	refreshExpirationTime, err := conf.GetJwtRefreshExpirationTime()
+	if err != nil {
+		return err
+	}
 	jwtConf = &Conf{
-		Secret: secret,
-		Expire: expirationTime,
-		Issuer: conf.GetJwtIssuer(),
+		Secret:        secret,
+		Expire:        expirationTime,
+		RefreshExpire: refreshExpirationTime,
+		Issuer:        conf.GetJwtIssuer(),
+		method:        method,
+		keys:          keys,
 	}
 	return nil
 }
 
+// GetExpire access token 有效期
+func GetExpire() time.Duration {
+	return jwtConf.Expire
+}
+
+// GetRefreshExpire refresh token 有效期
+func GetRefreshExpire() time.Duration {
+	return jwtConf.RefreshExpire
+}
+
 type MyClaims struct {
 	UserID   int    `json:"userId"`
 	UserName string `json:"userName"`
+	// FamilyID 签发时对应的 refresh token 家族, 注销时一并吊销
+	FamilyID string `json:"fid,omitempty"`
+	// Roles 限制可使用的角色, 为空时使用用户的全部角色, API key 认证时设置
+	Roles []string `json:"roles,omitempty"`
+	// IssuedAtMicro 微秒精度的签发时间, 与吊销时间比较, iat 只有秒级精度
+	IssuedAtMicro int64 `json:"iatUs,omitempty"`
+	// ApiKey 通过 API key 认证, 只在服务端设置, 不会出现在 token 中
+	ApiKey bool `json:"-"`
 	*jwt.RegisteredClaims
 }
 
@@ -46,9 +99,11 @@ type MyClaims struct {
 func NewClaims(userID int, userName string) *MyClaims {
 	now := time.Now()
 	return &MyClaims{
-		UserID:   userID,
-		UserName: userName,
+		UserID:        userID,
+		UserName:      userName,
+		IssuedAtMicro: now.UnixMicro(),
 		RegisteredClaims: &jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			Issuer:    conf.GetJwtIssuer(),
 			ExpiresAt: jwt.NewNumericDate(now.Add(jwtConf.Expire)),
 			IssuedAt:  jwt.NewNumericDate(now),
@@ -57,22 +112,62 @@ func NewClaims(userID int, userName string) *MyClaims {
 	}
 }
 
-func (c *MyClaims) GenerateToken() (token string, err error) {
-	claims := jwt.NewWithClaims(jwt.SigningMethodHS256, c)
+// NewApiKeyClaims API key 认证后使用的声明, 不会被签名, 有效期由 API key 决定
+func NewApiKeyClaims(userID int, userName string, roles []string) *MyClaims {
+	return &MyClaims{
+		UserID:   userID,
+		UserName: userName,
+		Roles:    roles,
+		ApiKey:   true,
+		RegisteredClaims: &jwt.RegisteredClaims{
+			Issuer:   conf.GetJwtIssuer(),
+			IssuedAt: jwt.NewNumericDate(time.Now()),
+		},
+	}
+}
 
-	token, err = claims.SignedString([]byte(jwtConf.Secret))
+// WithFamily 设置 refresh token 家族
+func (c *MyClaims) WithFamily(familyID string) *MyClaims {
+	c.FamilyID = familyID
+	return c
+}
+
+func (c *MyClaims) GenerateToken() (token string, err error) {
+	if jwtConf.keys == nil {
+		claims := jwt.NewWithClaims(jwt.SigningMethodHS256, c)
+		token, err = claims.SignedString([]byte(jwtConf.Secret))
+	} else {
+		claims := jwt.NewWithClaims(jwtConf.keys.method, c)
+		claims.Header["kid"] = jwtConf.keys.active.kid
+		token, err = claims.SignedString(jwtConf.keys.active.private)
+	}
 	if err != nil {
 		return "", apierr.InternalServer().Set(apierr.JwtErrCode, "failed to generate token", err)
 	}
 	return token, nil
 }
 
+// keyFunc 根据 token 头部的 kid 选择验签密钥
+func keyFunc(token *jwt.Token) (interface{}, error) {
+	if jwtConf.keys == nil {
+		return []byte(jwtConf.Secret), nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := jwtConf.keys.verify[kid]
+	if !ok {
+		return nil, reason.ErrTokenKidUnknown
+	}
+	return key.public, nil
+}
+
 // ParseToken 解析token
 func ParseToken(tokenString string) (*MyClaims, error) {
 	var myCustomClaims MyClaims
-	token, err := jwt.ParseWithClaims(tokenString, &myCustomClaims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(jwtConf.Secret), nil
-	})
+	method := jwtConf.method
+	if method == nil {
+		method = jwt.SigningMethodHS256
+	}
+	token, err := jwt.ParseWithClaims(tokenString, &myCustomClaims, keyFunc, jwt.WithValidMethods([]string{method.Alg()}))
 	if err != nil {
 		return nil, apierr.InternalServer().Set(apierr.JwtErrCode, "failed to parse token", err)
 	}
@@ -94,3 +189,19 @@ func GetMyClaims(c *gin.Context) (*MyClaims, error) {
 	}
 	return myCustomClaims, nil
 }
+
+// NewRefreshToken 生成不透明的 refresh token, 返回 token 以及用于服务端存储的哈希
+func NewRefreshToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", apierr.InternalServer().Set(apierr.JwtErrCode, "failed to generate refresh token", err)
+	}
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashRefreshToken(token), nil
+}
+
+// HashRefreshToken 计算 refresh token 的哈希, 服务端只保存哈希
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
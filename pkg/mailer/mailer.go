@@ -0,0 +1,81 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// SMTPConfig SMTP 服务配置
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPMailer 通过 SMTP 发送邮件, 服务端支持时自动使用 STARTTLS
+type SMTPMailer struct {
+	conf *SMTPConfig
+}
+
+func NewSMTPMailer(conf *SMTPConfig) *SMTPMailer {
+	return &SMTPMailer{conf: conf}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, to []string, subject, body string) error {
+	var auth smtp.Auth
+	if m.conf.Username != "" {
+		auth = smtp.PlainAuth("", m.conf.Username, m.conf.Password, m.conf.Host)
+	}
+	addr := net.JoinHostPort(m.conf.Host, strconv.Itoa(m.conf.Port))
+	msg := buildMessage(m.conf.From, to, subject, body)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- smtp.SendMail(addr, auth, m.conf.From, to, msg)
+	}()
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("send mail to %v canceled: %w", to, ctx.Err())
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("send mail to %v failed: %w", to, err)
+		}
+		return nil
+	}
+}
+
+// LogMailer 只输出日志, 不实际发送, 用于开发和测试环境
+type LogMailer struct{}
+
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+func (m *LogMailer) Send(_ context.Context, to []string, subject, body string) error {
+	zap.S().Infof("mail to: %v, subject: %s, body: %s", to, subject, body)
+	return nil
+}
+
+func buildMessage(from string, to []string, subject, body string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	buf.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return buf.Bytes()
+}
@@ -0,0 +1,156 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"qqlx/base/apierr"
+	"qqlx/base/reason"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// Config OIDC 客户端配置
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// 用户信息映射的 claim 名称
+	NameClaim     string
+	EmailClaim    string
+	NickNameClaim string
+	GroupsClaim   string
+}
+
+// Identity 从 id_token 中映射出的用户身份
+type Identity struct {
+	Subject string
+	Name    string
+	// Username 标准 claim preferred_username, 自动创建用户时作为用户名
+	Username string
+	Email    string
+	// EmailVerified 身份提供方是否已验证邮箱, 对应标准 claim email_verified
+	EmailVerified bool
+	NickName      string
+	Groups        []string
+}
+
+// Client OIDC 授权码 + PKCE 客户端
+type Client struct {
+	conf     *Config
+	oauth2   *oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+}
+
+// NewClient 通过 issuer 的 discovery 文档初始化客户端
+func NewClient(ctx context.Context, conf *Config) (*Client, error) {
+	provider, err := gooidc.NewProvider(ctx, conf.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery %s failed: %w", conf.Issuer, err)
+	}
+	scopes := conf.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{gooidc.ScopeOpenID, "profile", "email"}
+	}
+	return &Client{
+		conf: conf,
+		oauth2: &oauth2.Config{
+			ClientID:     conf.ClientID,
+			ClientSecret: conf.ClientSecret,
+			RedirectURL:  conf.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       scopes,
+		},
+		verifier: provider.Verifier(&gooidc.Config{ClientID: conf.ClientID}),
+	}, nil
+}
+
+// NewState 生成随机的 state 和 nonce, 以及 PKCE verifier
+func NewState() (state, nonce, verifier string, err error) {
+	if state, err = randomString(); err != nil {
+		return "", "", "", err
+	}
+	if nonce, err = randomString(); err != nil {
+		return "", "", "", err
+	}
+	return state, nonce, oauth2.GenerateVerifier(), nil
+}
+
+// AuthCodeURL 生成跳转到身份提供方的授权地址
+func (c *Client) AuthCodeURL(state, nonce, verifier string) string {
+	return c.oauth2.AuthCodeURL(state, gooidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+}
+
+// Exchange 使用授权码换取 token, 校验 id_token 后映射为用户身份
+func (c *Client) Exchange(ctx context.Context, code, nonce, verifier string) (*Identity, error) {
+	token, err := c.oauth2.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, apierr.Unauthorized().Set(apierr.AuthErrCode, "oidc exchange code failed", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, apierr.Unauthorized().Set(apierr.AuthErrCode, "oidc exchange code failed", reason.ErrOidcIDTokenMissing)
+	}
+	idToken, err := c.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, apierr.Unauthorized().Set(apierr.AuthErrCode, "oidc verify id_token failed", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, apierr.Unauthorized().Set(apierr.AuthErrCode, "oidc verify id_token failed", reason.ErrOidcNonce)
+	}
+
+	claims := map[string]any{}
+	if err = idToken.Claims(&claims); err != nil {
+		return nil, apierr.Unauthorized().Set(apierr.AuthErrCode, "oidc parse claims failed", err)
+	}
+	return c.mapClaims(idToken.Subject, claims), nil
+}
+
+// mapClaims 根据配置的 claim 名称映射用户信息
+func (c *Client) mapClaims(subject string, claims map[string]any) *Identity {
+	identity := &Identity{
+		Subject:  subject,
+		Name:     stringClaim(claims, c.conf.NameClaim),
+		Username: stringClaim(claims, "preferred_username"),
+		Email:    stringClaim(claims, c.conf.EmailClaim),
+		NickName: stringClaim(claims, c.conf.NickNameClaim),
+	}
+	// 部分身份提供方以字符串形式返回 email_verified
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+	switch groups := claims[c.conf.GroupsClaim].(type) {
+	case []any:
+		for _, group := range groups {
+			if g, ok := group.(string); ok {
+				identity.Groups = append(identity.Groups, g)
+			}
+		}
+	case string:
+		identity.Groups = []string{groups}
+	}
+	return identity
+}
+
+func stringClaim(claims map[string]any, name string) string {
+	if name == "" {
+		return ""
+	}
+	v, _ := claims[name].(string)
+	return v
+}
+
+func randomString() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", apierr.InternalServer().Set(apierr.AuthErrCode, "failed to generate oidc state", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/sony/sonyflake"
 	"qqlx/base/apierr"
 	"qqlx/base/constant"
+	"qqlx/base/metrics"
 	"qqlx/store/cache"
 	"time"
 )
@@ -39,6 +40,7 @@ func NewGenerateID(ctx context.Context, redis *cache.Store) *GenerateIDStruct {
 func (g *GenerateIDStruct) NextID() (int, error) {
 	id, err := g.sonyflake.NextID()
 	if err != nil {
+		metrics.IDGenerateErrors.Inc()
 		return 0, apierr.InternalServer().Set(apierr.SonyflakeErrCode, "sonyflake next id failed", err)
 	}
 	return int(id), nil
@@ -0,0 +1,98 @@
+package router
+
+import (
+	"net/http"
+	"qqlx/base/middleware"
+	"qqlx/schema"
+	"sort"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RouteCatalog 路由目录, 由 gin 路由表和注册时记录的鉴权路由生成, 用于和策略对账
+type RouteCatalog struct {
+	mu        sync.RWMutex
+	protected map[string]struct{}
+	routes    []schema.CatalogRoute
+}
+
+func NewRouteCatalog() *RouteCatalog {
+	return &RouteCatalog{
+		protected: make(map[string]struct{}),
+	}
+}
+
+func (receive *RouteCatalog) protect(method, path string) {
+	receive.mu.Lock()
+	defer receive.mu.Unlock()
+	receive.protected[method+" "+path] = struct{}{}
+}
+
+// Load 读取 gin 的路由表, 在所有路由注册完成后调用
+func (receive *RouteCatalog) Load(engine *gin.Engine) {
+	receive.mu.Lock()
+	defer receive.mu.Unlock()
+	infos := engine.Routes()
+	routes := make([]schema.CatalogRoute, 0, len(infos))
+	for _, info := range infos {
+		_, protected := receive.protected[info.Method+" "+info.Path]
+		routes = append(routes, schema.CatalogRoute{
+			Method:    info.Method,
+			Path:      info.Path,
+			Protected: protected,
+		})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path == routes[j].Path {
+			return routes[i].Method < routes[j].Method
+		}
+		return routes[i].Path < routes[j].Path
+	})
+	receive.routes = routes
+}
+
+// Routes 路由目录, Load 之前为空
+func (receive *RouteCatalog) Routes() []schema.CatalogRoute {
+	receive.mu.RLock()
+	defer receive.mu.RUnlock()
+	return append([]schema.CatalogRoute(nil), receive.routes...)
+}
+
+// authorizedGroup 需要鉴权的路由组, 注册的路由记录到路由目录
+type authorizedGroup struct {
+	group   *gin.RouterGroup
+	catalog *RouteCatalog
+}
+
+func newAuthorizedGroup(group *gin.RouterGroup, authorization *middleware.AuthorizationMiddleware, catalog *RouteCatalog) authorizedGroup {
+	return authorizedGroup{
+		group:   group.Group("", authorization.Authorization()),
+		catalog: catalog,
+	}
+}
+
+func (receive authorizedGroup) handle(method, path string, handler gin.HandlerFunc) {
+	receive.group.Handle(method, path, handler)
+	receive.catalog.protect(method, receive.group.Group(path).BasePath())
+}
+
+func (receive authorizedGroup) GET(path string, handler gin.HandlerFunc) {
+	receive.handle(http.MethodGet, path, handler)
+}
+
+func (receive authorizedGroup) POST(path string, handler gin.HandlerFunc) {
+	receive.handle(http.MethodPost, path, handler)
+}
+
+func (receive authorizedGroup) PUT(path string, handler gin.HandlerFunc) {
+	receive.handle(http.MethodPut, path, handler)
+}
+
+func (receive authorizedGroup) PATCH(path string, handler gin.HandlerFunc) {
+	receive.handle(http.MethodPatch, path, handler)
+}
+
+func (receive authorizedGroup) DELETE(path string, handler gin.HandlerFunc) {
+	receive.handle(http.MethodDelete, path, handler)
+}
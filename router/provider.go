@@ -1,7 +1,13 @@
 package router
 
-import "github.com/google/wire"
+import (
+	"qqlx/base/interfaces"
+
+	"github.com/google/wire"
+)
 
 var ProviderRouter = wire.NewSet(
 	NewApiRoute,
+	NewRouteCatalog,
+	wire.Bind(new(interfaces.RouteCatalogInterface), new(*RouteCatalog)),
 )
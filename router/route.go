@@ -8,47 +8,108 @@ import (
 )
 
 type ApiRoute struct {
-	userCtrl   *controller.UserCtrl
-	roleCtrl   *controller.RoleCtrl
-	policyCtrl *controller.PolicyCtrl
+	userCtrl     *controller.UserCtrl
+	roleCtrl     *controller.RoleCtrl
+	policyCtrl   *controller.PolicyCtrl
+	oidcCtrl     *controller.OidcCtrl
+	mfaCtrl      *controller.MfaCtrl
+	apiKeyCtrl   *controller.ApiKeyCtrl
+	sessionCtrl  *controller.SessionCtrl
+	tenantCtrl   *controller.TenantCtrl
+	authzCtrl    *controller.AuthzCtrl
+	revisionCtrl *controller.RevisionCtrl
+	manifestCtrl *controller.ManifestCtrl
+	accessCtrl   *controller.AccessRequestCtrl
+	auditCtrl    *controller.AuditCtrl
+	catalog      *RouteCatalog
 }
 
 func NewApiRoute(
 	userContr *controller.UserCtrl,
 	roleContr *controller.RoleCtrl,
 	policyController *controller.PolicyCtrl,
+	oidcController *controller.OidcCtrl,
+	mfaController *controller.MfaCtrl,
+	apiKeyController *controller.ApiKeyCtrl,
+	sessionController *controller.SessionCtrl,
+	tenantController *controller.TenantCtrl,
+	authzController *controller.AuthzCtrl,
+	revisionController *controller.RevisionCtrl,
+	manifestController *controller.ManifestCtrl,
+	accessController *controller.AccessRequestCtrl,
+	auditController *controller.AuditCtrl,
+	catalog *RouteCatalog,
 ) *ApiRoute {
 	return &ApiRoute{
-		userCtrl:   userContr,
-		roleCtrl:   roleContr,
-		policyCtrl: policyController,
+		userCtrl:     userContr,
+		roleCtrl:     roleContr,
+		policyCtrl:   policyController,
+		oidcCtrl:     oidcController,
+		mfaCtrl:      mfaController,
+		apiKeyCtrl:   apiKeyController,
+		sessionCtrl:  sessionController,
+		tenantCtrl:   tenantController,
+		authzCtrl:    authzController,
+		revisionCtrl: revisionController,
+		manifestCtrl: manifestController,
+		accessCtrl:   accessController,
+		auditCtrl:    auditController,
+		catalog:      catalog,
 	}
 }
 
-func (a *ApiRoute) RegisterApiUserRoute(r *gin.RouterGroup, authorization *middleware.AuthorizationMiddleware) {
+func (a *ApiRoute) RegisterApiUserRoute(r *gin.RouterGroup, authentication *middleware.AuthenticationMiddleware, authorization *middleware.AuthorizationMiddleware) {
 	userGroup := r.Group("/users")
 	{
 		userGroup.POST("create", a.userCtrl.RegisterHandler)
 		userGroup.POST("/login", a.userCtrl.LoginHandler)
-		userGroup.Use(middleware.Authentication())
+		userGroup.POST("/refresh", a.userCtrl.RefreshHandler)
+		userGroup.POST("/login/mfa", a.mfaCtrl.LoginHandler)
+		userGroup.POST("/login/mfa/enroll", a.mfaCtrl.LoginEnrollHandler)
+		userGroup.POST("/login/password", a.userCtrl.ChangeExpiredPasswordHandler)
+		userGroup.POST("/password/forgot", a.userCtrl.ForgotPasswordHandler)
+		userGroup.POST("/password/reset", a.userCtrl.ResetPasswordHandler)
+		userGroup.POST("/verify", a.userCtrl.VerifyEmailHandler)
+		userGroup.POST("/verify/resend", a.userCtrl.ResendVerifyHandler)
+		userGroup.Use(authentication.Authentication())
 		{
 			userGroup.POST("/logout", a.userCtrl.LogoutHandler)
-			userGroup.GET("", authorization.Authorization(), a.userCtrl.ListHandler)
+			authorized := newAuthorizedGroup(userGroup, authorization, a.catalog)
+			authorized.GET("", a.userCtrl.ListHandler)
 			userGroup.PATCH("", a.userCtrl.UpdatePasswordHandler)
 			userGroup.PUT("", a.userCtrl.UpdateHandler)
 			userGroup.GET("/info", a.userCtrl.InfoHandler)
-			userGroup.GET("/:id", authorization.Authorization(), a.userCtrl.GetUserInfoHandler)
-			userGroup.DELETE("/:id", authorization.Authorization(), a.userCtrl.DisableHandler)
-			userGroup.PUT("/enable/:id", a.userCtrl.EnableHandler)
-			userGroup.PUT("/:id/roles", authorization.Authorization(), a.userCtrl.AddRoleHandler)
-			userGroup.POST("/:id/roles", authorization.Authorization(), a.userCtrl.RemoveRoleHandler)
+			userGroup.POST("/mfa/enroll", a.mfaCtrl.EnrollHandler)
+			userGroup.POST("/mfa/confirm", a.mfaCtrl.ConfirmHandler)
+			userGroup.GET("/oidc/link", a.oidcCtrl.LinkHandler)
+			userGroup.GET("/keys", a.apiKeyCtrl.ListHandler)
+			userGroup.POST("/keys", a.apiKeyCtrl.CreateHandler)
+			userGroup.DELETE("/keys/:kid", a.apiKeyCtrl.RevokeHandler)
+			userGroup.GET("/sessions", a.sessionCtrl.ListHandler)
+			userGroup.DELETE("/sessions/:sid", a.sessionCtrl.KillHandler)
+			authorized.DELETE("/:id/mfa", a.mfaCtrl.ResetHandler)
+			authorized.PUT("/:id/unlock", a.userCtrl.UnlockHandler)
+			authorized.GET("/:id/sessions", a.sessionCtrl.UserListHandler)
+			authorized.DELETE("/:id/sessions/:sid", a.sessionCtrl.UserKillHandler)
+			authorized.GET("/:id", a.userCtrl.GetUserInfoHandler)
+			authorized.DELETE("/:id", a.userCtrl.DisableHandler)
+			authorized.PUT("/enable/:id", a.userCtrl.EnableHandler)
+			authorized.PUT("/:id/roles", a.userCtrl.AddRoleHandler)
+			authorized.POST("/:id/roles", a.userCtrl.RemoveRoleHandler)
 		}
 	}
 }
 
-func (a *ApiRoute) RegisterApiRoleRoute(r *gin.RouterGroup, authorization *middleware.AuthorizationMiddleware) {
-	roleGroup := r.Group("/roles")
-	roleGroup.Use(middleware.Authentication(), authorization.Authorization())
+func (a *ApiRoute) RegisterApiServiceAccountRoute(r *gin.RouterGroup, authentication *middleware.AuthenticationMiddleware, authorization *middleware.AuthorizationMiddleware) {
+	serviceAccountGroup := newAuthorizedGroup(r.Group("/service-accounts", authentication.Authentication()), authorization, a.catalog)
+	serviceAccountGroup.POST("", a.apiKeyCtrl.CreateServiceAccountHandler)
+	serviceAccountGroup.GET("/:id/keys", a.apiKeyCtrl.ListServiceAccountKeyHandler)
+	serviceAccountGroup.POST("/:id/keys", a.apiKeyCtrl.CreateServiceAccountKeyHandler)
+	serviceAccountGroup.DELETE("/:id/keys/:kid", a.apiKeyCtrl.RevokeServiceAccountKeyHandler)
+}
+
+func (a *ApiRoute) RegisterApiRoleRoute(r *gin.RouterGroup, authentication *middleware.AuthenticationMiddleware, authorization *middleware.AuthorizationMiddleware) {
+	roleGroup := newAuthorizedGroup(r.Group("/roles", authentication.Authentication()), authorization, a.catalog)
 	roleGroup.GET("", a.roleCtrl.ListHandler)
 	roleGroup.POST("", a.roleCtrl.CreateHandler)
 	roleGroup.PUT("/:id", a.roleCtrl.UpdateInfoHandler)
@@ -56,14 +117,77 @@ func (a *ApiRoute) RegisterApiRoleRoute(r *gin.RouterGroup, authorization *middl
 	roleGroup.DELETE("/:id", a.roleCtrl.DeleteHandler)
 	roleGroup.PUT("/:id/polices", a.roleCtrl.AddRoleByPolicyHandler)
 	roleGroup.POST("/:id/polices", a.roleCtrl.DeleteRoleByPolicyHandler)
+	roleGroup.GET("/:id/polices", a.roleCtrl.GetPolicesHandler)
+	roleGroup.PUT("/:id/parents", a.roleCtrl.UpdateParentsHandler)
+	roleGroup.PUT("/:id/approvers", a.accessCtrl.UpdateApproversHandler)
+}
+
+// RegisterApiAccessRequestRoute 角色访问申请, 申请和审批只需登录, 审批权限由角色的审批人决定
+func (a *ApiRoute) RegisterApiAccessRequestRoute(r *gin.RouterGroup, authentication *middleware.AuthenticationMiddleware, authorization *middleware.AuthorizationMiddleware) {
+	accessGroup := r.Group("/access-requests", authentication.Authentication())
+	accessGroup.POST("", a.accessCtrl.CreateHandler)
+	accessGroup.GET("", a.accessCtrl.ListHandler)
+	accessGroup.GET("/queue", a.accessCtrl.QueueHandler)
+	accessGroup.POST("/:id/approve", a.accessCtrl.ApproveHandler)
+	accessGroup.POST("/:id/reject", a.accessCtrl.RejectHandler)
+	authorized := newAuthorizedGroup(accessGroup, authorization, a.catalog)
+	authorized.GET("/all", a.accessCtrl.ListAllHandler)
 }
 
-func (a *ApiRoute) RegisterApiPolicyRoute(r *gin.RouterGroup, authorization *middleware.AuthorizationMiddleware) {
-	poliyGroup := r.Group("/polices")
-	poliyGroup.Use(middleware.Authentication(), authorization.Authorization())
+func (a *ApiRoute) RegisterApiPolicyRoute(r *gin.RouterGroup, authentication *middleware.AuthenticationMiddleware, authorization *middleware.AuthorizationMiddleware) {
+	poliyGroup := newAuthorizedGroup(r.Group("/polices", authentication.Authentication()), authorization, a.catalog)
 	poliyGroup.GET("", a.policyCtrl.ListHandler)
 	poliyGroup.POST("", a.policyCtrl.CreateHandler)
+	poliyGroup.GET("/catalog", a.policyCtrl.CatalogHandler)
+	poliyGroup.POST("/catalog", a.policyCtrl.ReconcileHandler)
 	poliyGroup.GET("/:id", a.policyCtrl.GetHandler)
 	poliyGroup.PUT("/:id", a.policyCtrl.UpdateHandler)
 	poliyGroup.DELETE("/:id", a.policyCtrl.DeleteHandler)
 }
+
+// RegisterApiTenantRoute 租户路由, :tenant 作为 casbin domain
+func (a *ApiRoute) RegisterApiTenantRoute(r *gin.RouterGroup, authentication *middleware.AuthenticationMiddleware, authorization *middleware.AuthorizationMiddleware) {
+	tenantGroup := newAuthorizedGroup(r.Group("/tenants", authentication.Authentication()), authorization, a.catalog)
+	tenantGroup.GET("", a.tenantCtrl.ListHandler)
+	tenantGroup.POST("", a.tenantCtrl.CreateHandler)
+	tenantGroup.DELETE("/:tenant", a.tenantCtrl.DeleteHandler)
+	tenantGroup.GET("/:tenant/members", a.tenantCtrl.ListMemberHandler)
+	tenantGroup.PUT("/:tenant/members", a.tenantCtrl.SetMemberHandler)
+	tenantGroup.DELETE("/:tenant/members/:uid", a.tenantCtrl.RemoveMemberHandler)
+	tenantGroup.PUT("/:tenant/members/:uid/roles", a.tenantCtrl.SetMemberRolesHandler)
+	tenantGroup.GET("/:tenant/roles", a.tenantCtrl.ListRoleHandler)
+	tenantGroup.POST("/:tenant/roles", a.tenantCtrl.CreateRoleHandler)
+	tenantGroup.DELETE("/:tenant/roles/:id", a.tenantCtrl.DeleteRoleHandler)
+	tenantGroup.PUT("/:tenant/roles/:id/polices", a.tenantCtrl.AddRolePolicyHandler)
+	tenantGroup.POST("/:tenant/roles/:id/polices", a.tenantCtrl.DeleteRolePolicyHandler)
+	tenantGroup.GET("/:tenant/polices", a.tenantCtrl.ListPolicyHandler)
+}
+
+func (a *ApiRoute) RegisterApiAuthzRoute(r *gin.RouterGroup, authentication *middleware.AuthenticationMiddleware, authorization *middleware.AuthorizationMiddleware) {
+	authzGroup := newAuthorizedGroup(r.Group("/authz", authentication.Authentication()), authorization, a.catalog)
+	authzGroup.POST("/check", a.authzCtrl.CheckHandler)
+}
+
+// RegisterApiRbacRoute 角色策略变更记录、回滚以及声明式配置的导出和应用
+func (a *ApiRoute) RegisterApiRbacRoute(r *gin.RouterGroup, authentication *middleware.AuthenticationMiddleware, authorization *middleware.AuthorizationMiddleware) {
+	rbacGroup := newAuthorizedGroup(r.Group("/rbac", authentication.Authentication()), authorization, a.catalog)
+	rbacGroup.GET("/revisions", a.revisionCtrl.ListHandler)
+	rbacGroup.GET("/revisions/diff", a.revisionCtrl.DiffHandler)
+	rbacGroup.GET("/revisions/:id", a.revisionCtrl.GetHandler)
+	rbacGroup.POST("/revisions/:id/rollback", a.revisionCtrl.RollbackHandler)
+	rbacGroup.GET("/export", a.manifestCtrl.ExportHandler)
+	rbacGroup.POST("/apply", a.manifestCtrl.ApplyHandler)
+}
+
+// RegisterApiAuditRoute 审计记录查询和导出
+func (a *ApiRoute) RegisterApiAuditRoute(r *gin.RouterGroup, authentication *middleware.AuthenticationMiddleware, authorization *middleware.AuthorizationMiddleware) {
+	auditGroup := newAuthorizedGroup(r.Group("/audit", authentication.Authentication()), authorization, a.catalog)
+	auditGroup.GET("", a.auditCtrl.ListHandler)
+	auditGroup.GET("/export", a.auditCtrl.ExportHandler)
+}
+
+func (a *ApiRoute) RegisterApiAuthRoute(r *gin.RouterGroup) {
+	oidcGroup := r.Group("/auth/oidc")
+	oidcGroup.GET("/login", a.oidcCtrl.LoginHandler)
+	oidcGroup.GET("/callback", a.oidcCtrl.CallbackHandler)
+}
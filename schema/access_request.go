@@ -0,0 +1,79 @@
+package schema
+
+import "qqlx/model"
+
+type AccessRequestIDRequest struct {
+	ID int `uri:"id" validate:"required,gte=1"`
+}
+
+type AccessRequestCreateRequest struct {
+	RoleName      string `json:"roleName" validate:"required"`
+	Justification string `json:"justification" validate:"required,max=1024"`
+	// Duration 申请的授权时长, 如 4h, 为空时申请永久授权
+	Duration string `json:"duration"`
+	// UserID 申请人, 由登录信息设置
+	UserID int `json:"-"`
+}
+
+type AccessRequestListRequest struct {
+	Page     int    `form:"page" validate:"required,gt=0|eq=-1" json:"page"`
+	PageSize int    `form:"pageSize" validate:"required,gt=0|eq=-1" json:"pageSize"`
+	Status   string `form:"status" validate:"omitempty,oneof=pending approved rejected expired" json:"status"`
+	// UserID 当前用户, 由登录信息设置
+	UserID int `json:"-"`
+}
+
+type AccessRequestListResponse struct {
+	Total    int64                   `json:"total"`
+	Page     int                     `json:"page"`
+	PageSize int                     `json:"pageSize"`
+	Items    []AccessRequestResponse `json:"items"`
+}
+
+// AccessRequestResponse 访问申请, 申请人和角色只返回 ID 和名称
+type AccessRequestResponse struct {
+	ID            int    `json:"id"`
+	CreatedAt     int    `json:"createdAt"`
+	UpdatedAt     int    `json:"updatedAt"`
+	UserID        int    `json:"userId"`
+	UserName      string `json:"userName"`
+	RoleID        int    `json:"roleId"`
+	RoleName      string `json:"roleName"`
+	Justification string `json:"justification"`
+	Duration      string `json:"duration"`
+	Status        string `json:"status"`
+	ApproverID    int    `json:"approverId"`
+	Comment       string `json:"comment"`
+	DecidedAt     int    `json:"decidedAt"`
+	ExpiresAt     int    `json:"expiresAt"`
+}
+
+func (receive *AccessRequestResponse) ConvertToAccessRequestResponse(in *model.AccessRequest) {
+	receive.ID = in.ID
+	receive.CreatedAt = in.CreatedAt
+	receive.UpdatedAt = in.UpdatedAt
+	receive.UserID = in.UserID
+	receive.RoleID = in.RoleID
+	receive.Justification = in.Justification
+	receive.Duration = in.Duration
+	receive.Status = in.Status
+	receive.ApproverID = in.ApproverID
+	receive.Comment = in.Comment
+	receive.DecidedAt = in.DecidedAt
+	receive.ExpiresAt = in.ExpiresAt
+	if in.User != nil {
+		receive.UserName = in.User.Name
+	}
+	if in.Role != nil {
+		receive.RoleName = in.Role.Name
+	}
+}
+
+type AccessRequestDecideRequest struct {
+	ID int `uri:"id" validate:"required,gte=1"`
+	// Duration 审批时覆盖申请的授权时长, 为空时使用申请的时长, 拒绝时忽略
+	Duration string `json:"duration"`
+	Comment  string `json:"comment" validate:"max=1024"`
+	// UserID 审批人, 由登录信息设置
+	UserID int `json:"-"`
+}
@@ -0,0 +1,60 @@
+package schema
+
+import (
+	"qqlx/model"
+	"strings"
+)
+
+type ApiKeyCreateRequest struct {
+	// UserID 所属用户, 当前用户或服务账号
+	UserID int    `json:"-"`
+	Name   string `json:"name" validate:"required,max=50"`
+	// ExpiresIn 有效期, 例如 720h, 为空时不过期
+	ExpiresIn string `json:"expiresIn"`
+	// Roles 限制可使用的角色, 必须是所属用户角色的子集, 为空时使用全部角色
+	Roles []string `json:"roles"`
+}
+
+type ApiKeyQueryRequest struct {
+	// UserID 所属用户, 当前用户或服务账号
+	UserID int `uri:"id"`
+	ID     int `uri:"kid" validate:"required,gte=1"`
+}
+
+type ServiceAccountRequest struct {
+	ID int `uri:"id" validate:"required,gte=1"`
+}
+
+type ServiceAccountCreateRequest struct {
+	Name     string `json:"name" validate:"required,max=50"`
+	NickName string `json:"nickName"`
+}
+
+type ApiKeyResponse struct {
+	ID         int      `json:"id"`
+	CreatedAt  int      `json:"createdAt"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Roles      []string `json:"roles"`
+	ExpiresAt  int      `json:"expiresAt"`
+	LastUsedAt int      `json:"lastUsedAt"`
+}
+
+func (receive *ApiKeyResponse) ConvertToApiKeyResponse(in *model.ApiKey) {
+	receive.ID = in.ID
+	receive.CreatedAt = in.CreatedAt
+	receive.Name = in.Name
+	receive.Prefix = in.Prefix
+	receive.Roles = []string{}
+	if in.Roles != "" {
+		receive.Roles = strings.Split(in.Roles, ",")
+	}
+	receive.ExpiresAt = in.ExpiresAt
+	receive.LastUsedAt = in.LastUsedAt
+}
+
+type ApiKeyCreateResponse struct {
+	ApiKeyResponse
+	// Key 完整的 API key, 只在创建时返回一次
+	Key string `json:"key"`
+}
@@ -0,0 +1,47 @@
+package schema
+
+import "qqlx/model"
+
+type AuditListRequest struct {
+	// Cursor 上一页返回的 nextCursor, 为 0 时从最新的记录开始
+	Cursor int `form:"cursor" validate:"gte=0"`
+	// Limit 每页数量, 默认 50, 导出时忽略
+	Limit   int `form:"limit" validate:"omitempty,gt=0,lte=500"`
+	ActorID int `form:"actorId" validate:"gte=0"`
+	// Action 操作前缀, 如 user. 或 role.create
+	Action     string `form:"action"`
+	TargetType string `form:"targetType"`
+	TargetID   string `form:"targetId"`
+	Result     string `form:"result" validate:"omitempty,oneof=success failure"`
+	// From 开始时间, unix 秒, 包含
+	From int `form:"from" validate:"gte=0"`
+	// To 结束时间, unix 秒, 不包含
+	To int `form:"to" validate:"gte=0"`
+}
+
+type AuditListResponse struct {
+	Items []model.AuditEvent `json:"items"`
+	// NextCursor 下一页的游标, 为 0 时没有更多记录
+	NextCursor int `json:"nextCursor"`
+}
+
+// AuditCheckpoint 签名检查点, 记录导出时哈希链的链头, 每行一个
+type AuditCheckpoint struct {
+	CreatedAt int    `json:"createdAt"`
+	LastID    int    `json:"lastId"`
+	Hash      string `json:"hash"`
+	Signature string `json:"signature"`
+}
+
+type AuditVerifyResponse struct {
+	Valid bool `json:"valid"`
+	// Checked 校验通过的记录数
+	Checked  int    `json:"checked"`
+	LastID   int    `json:"lastId"`
+	LastHash string `json:"lastHash"`
+	// BrokenID 第一处断裂的记录
+	BrokenID int    `json:"brokenId,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	// Checkpoints 校验通过的检查点数
+	Checkpoints int `json:"checkpoints"`
+}
@@ -0,0 +1,36 @@
+package schema
+
+type AuthzCheckRequest struct {
+	// User 用户名, 使用用户的全局角色和租户内的角色, 与 Role 二选一
+	User string `json:"user" validate:"required_without=Role"`
+	// Role 只检查该角色
+	Role   string `json:"role" validate:"required_without=User"`
+	Path   string `json:"path" validate:"required"`
+	Method string `json:"method" validate:"required"`
+	// Tenant 请求所在的租户, 0 表示不在租户内
+	Tenant int `json:"tenant" validate:"gte=0"`
+	// ResourceID 条件策略中的 :id, 没有时为 0
+	ResourceID int `json:"resourceId" validate:"gte=0"`
+}
+
+type AuthzCheckResponse struct {
+	Allowed bool `json:"allowed"`
+	// Decision allow 或 deny
+	Decision string `json:"decision"`
+	// Roles 参与鉴权的角色
+	Roles []string `json:"roles"`
+	// PolicyID, PolicyName 决定结果的策略, 没有匹配的策略时为空
+	PolicyID   int               `json:"policyId,omitempty"`
+	PolicyName string            `json:"policyName,omitempty"`
+	Results    []AuthzRoleResult `json:"results"`
+}
+
+// AuthzRoleResult 单个角色的鉴权结果
+type AuthzRoleResult struct {
+	Role    string `json:"role"`
+	Allowed bool   `json:"allowed"`
+	// Explain casbin 匹配的策略 {role, path, method, eft, dom, cond}, 来源角色与 Role 不同时为继承的策略
+	Explain    []string `json:"explain"`
+	PolicyID   int      `json:"policyId,omitempty"`
+	PolicyName string   `json:"policyName,omitempty"`
+}
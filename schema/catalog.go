@@ -0,0 +1,21 @@
+package schema
+
+import "qqlx/model"
+
+// CatalogRoute 路由目录中的路由
+type CatalogRoute struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Protected 是否经过鉴权中间件
+	Protected bool `json:"protected"`
+}
+
+type PolicyCatalogResponse struct {
+	Routes []CatalogRoute `json:"routes"`
+	// Missing 没有策略的鉴权路由
+	Missing []CatalogRoute `json:"missing"`
+	// Orphaned 没有对应路由的策略
+	Orphaned []model.Policy `json:"orphaned"`
+	// Created 为缺失的路由创建的策略
+	Created []model.Policy `json:"created"`
+}
@@ -0,0 +1,54 @@
+package schema
+
+// RbacManifest 声明式的角色策略配置, 只包含全局角色
+type RbacManifest struct {
+	Policies []ManifestPolicy `yaml:"policies" json:"policies"`
+	Roles    []ManifestRole   `yaml:"roles" json:"roles"`
+	Users    []ManifestUser   `yaml:"users" json:"users"`
+}
+
+// ManifestPolicy 策略, 以名称作为标识
+type ManifestPolicy struct {
+	Name      string `yaml:"name" json:"name"`
+	Path      string `yaml:"path" json:"path"`
+	Method    string `yaml:"method" json:"method"`
+	Describe  string `yaml:"describe" json:"describe"`
+	Effect    string `yaml:"effect,omitempty" json:"effect,omitempty"`
+	Condition string `yaml:"condition,omitempty" json:"condition,omitempty"`
+}
+
+type ManifestRole struct {
+	Name        string   `yaml:"name" json:"name"`
+	Description string   `yaml:"description" json:"description"`
+	Parents     []string `yaml:"parents,omitempty" json:"parents,omitempty"`
+	// Policies 策略名称
+	Policies []string `yaml:"policies,omitempty" json:"policies,omitempty"`
+}
+
+// ManifestUser 用户的全局角色, 用户需要已存在
+type ManifestUser struct {
+	Name  string   `yaml:"name" json:"name"`
+	Roles []string `yaml:"roles" json:"roles"`
+}
+
+type RbacApplyRequest struct {
+	DryRun bool `form:"dryRun"`
+	// Prune 删除配置中不存在的策略、角色和关联
+	Prune bool `form:"prune"`
+}
+
+// RbacChange 应用配置时的一项变更
+type RbacChange struct {
+	// Action create, update 或 delete
+	Action string `json:"action"`
+	// Kind policy, role, rolePolicy, roleParent 或 userRole
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type RbacPlanResponse struct {
+	DryRun  bool         `json:"dryRun"`
+	Prune   bool         `json:"prune"`
+	Changes []RbacChange `json:"changes"`
+}
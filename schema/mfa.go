@@ -0,0 +1,28 @@
+package schema
+
+type MfaLoginRequest struct {
+	Ticket string `json:"ticket" validate:"required"`
+	// TOTP 验证码或恢复码
+	Code string `json:"code" validate:"required"`
+	// IP 来源 IP, 由请求设置
+	IP string `json:"-"`
+}
+
+type MfaTicketRequest struct {
+	Ticket string `json:"ticket" validate:"required"`
+}
+
+type MfaEnrollResponse struct {
+	Secret string `json:"secret"`
+	// otpauth URI, 可直接生成二维码
+	URI string `json:"uri"`
+}
+
+type MfaConfirmRequest struct {
+	ID   int
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+type MfaConfirmResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
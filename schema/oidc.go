@@ -0,0 +1,13 @@
+package schema
+
+type OidcLoginResponse struct {
+	AuthURL string `json:"authUrl"`
+	State   string `json:"state"`
+}
+
+type OidcCallbackRequest struct {
+	Code  string `form:"code" validate:"required"`
+	State string `form:"state" validate:"required"`
+	// Cookie 登录或绑定时写入浏览器的 state, 由请求设置
+	Cookie string `form:"-"`
+}
@@ -11,6 +11,10 @@ type PolicyCreateRequest struct {
 	Describe string `json:"describe" validate:"required"`
 	Path     string `json:"path" validate:"required"`
 	Method   string `json:"method" validate:"required"`
+	// Effect allow 或 deny, 默认 allow
+	Effect string `json:"effect" validate:"omitempty,oneof=allow deny"`
+	// Condition 生效条件, 例如 r.env.Owner, 为空时无条件
+	Condition string `json:"condition" validate:"max=512"`
 }
 
 type PolicyIDRequest struct {
@@ -20,6 +24,10 @@ type PolicyIDRequest struct {
 type PolicyUpdateRequest struct {
 	ID       int    `uri:"id" validate:"required"`
 	Describe string `json:"describe" validate:"required"`
+	// Effect 为空时不修改, 修改后同步到使用该策略的角色
+	Effect string `json:"effect" validate:"omitempty,oneof=allow deny"`
+	// Condition 为 nil 时不修改, 空字符串清除条件
+	Condition *string `json:"condition" validate:"omitempty,max=512"`
 }
 
 type PolicyListRequest struct {
@@ -44,6 +52,7 @@ type PolicyResponse struct {
 	Name        string                `json:"name"`
 	Path        string                `json:"path"`
 	Method      string                `json:"method"`
+	Effect      string                `json:"effect"`
 	Description string                `json:"description"`
 	Roles       []model.Role          `json:"roles"`
 }
@@ -0,0 +1,43 @@
+package schema
+
+import "qqlx/model"
+
+type RevisionIDRequest struct {
+	ID int `uri:"id" validate:"required,gte=1"`
+}
+
+type RevisionListRequest struct {
+	Page     int `form:"page" validate:"required,gt=0|eq=-1" json:"page"`
+	PageSize int `form:"pageSize" validate:"required,gt=0|eq=-1" json:"pageSize"`
+}
+
+type RevisionListResponse struct {
+	Total    int64                `json:"total"`
+	Page     int                  `json:"page"`
+	PageSize int                  `json:"pageSize"`
+	Items    []model.RbacRevision `json:"items"`
+}
+
+type RevisionDiffRequest struct {
+	From int `form:"from" validate:"required,gte=1"`
+	To   int `form:"to" validate:"required,gte=1"`
+}
+
+// RevisionRoleDiff 角色在两个版本之间的策略变化, 已删除的策略只有 ID
+type RevisionRoleDiff struct {
+	Role    string         `json:"role"`
+	Added   []model.Policy `json:"added"`
+	Removed []model.Policy `json:"removed"`
+}
+
+type RevisionDiffResponse struct {
+	From  int                `json:"from"`
+	To    int                `json:"to"`
+	Roles []RevisionRoleDiff `json:"roles"`
+}
+
+type RevisionRollbackResponse struct {
+	Revision *model.RbacRevision `json:"revision"`
+	// Skipped 版本中存在但已删除的角色, 无法恢复
+	Skipped []string `json:"skipped"`
+}
@@ -12,6 +12,9 @@ type RoleCreateRequest struct {
 	Name      string `json:"name" validate:"required"`
 	Describe  string `json:"describe" validate:"required"`
 	PolicyIds []int  `json:"policyIds"`
+	ParentIDs []int  `json:"parentIds"`
+	// TenantID 所属租户, 由租户路由设置, 0 为全局角色
+	TenantID int `json:"-"`
 }
 
 type RoleUpdateRequest struct {
@@ -23,6 +26,27 @@ type RolePolicyRequest struct {
 	PolicyIds []int `json:"policyIds" validate:"required"`
 }
 
+type RoleParentRequest struct {
+	ID int `uri:"id" validate:"required"`
+	// ParentIDs 父角色, 替换原有的父角色, 为空时清空
+	ParentIDs []int `json:"parentIds"`
+}
+
+// RolePolicyResponse 角色的直接策略和有效策略
+type RolePolicyResponse struct {
+	Direct    []model.Policy    `json:"direct"`
+	Effective []EffectivePolicy `json:"effective"`
+}
+
+type EffectivePolicy struct {
+	Path   string `json:"path"`
+	Method string `json:"method"`
+	Effect string `json:"effect"`
+	// Role 策略来源角色, 与当前角色不同时为继承的策略
+	Role      string `json:"role"`
+	Inherited bool   `json:"inherited"`
+}
+
 type RoleListRequest struct {
 	Page     int    `form:"page" validate:"required,gt=0|eq=-1"`
 	PageSize int    `form:"pageSize" validate:"required,gt=0|eq=-1"`
@@ -36,3 +60,9 @@ type RoleListResponse struct {
 	PageSize int          `json:"pageSize"`
 	Items    []model.Role `json:"items"`
 }
+
+type RoleApproverRequest struct {
+	ID int `uri:"id" validate:"required"`
+	// UserIDs 审批人, 替换原有的审批人, 为空时清空
+	UserIDs []int `json:"userIds"`
+}
@@ -0,0 +1,33 @@
+package schema
+
+import "qqlx/model"
+
+type SessionListRequest struct {
+	UserID int `uri:"id" validate:"required,gte=1"`
+}
+
+type SessionQueryRequest struct {
+	// UserID 会话所属用户, 当前用户或管理员指定的用户
+	UserID int    `uri:"id"`
+	ID     string `uri:"sid" validate:"required"`
+}
+
+type SessionResponse struct {
+	ID         string `json:"id"`
+	IP         string `json:"ip"`
+	UserAgent  string `json:"userAgent"`
+	CreatedAt  int    `json:"createdAt"`
+	LastSeenAt int    `json:"lastSeenAt"`
+	ExpiresAt  int    `json:"expiresAt"`
+	// Current 是否为当前请求所在的会话
+	Current bool `json:"current"`
+}
+
+func (receive *SessionResponse) ConvertToSessionResponse(in *model.Session) {
+	receive.ID = in.ID
+	receive.IP = in.IP
+	receive.UserAgent = in.UserAgent
+	receive.CreatedAt = in.CreatedAt
+	receive.LastSeenAt = in.LastSeenAt
+	receive.ExpiresAt = in.ExpiresAt
+}
@@ -0,0 +1,69 @@
+package schema
+
+import "qqlx/model"
+
+type TenantIDRequest struct {
+	Tenant int `uri:"tenant" validate:"required,gte=1"`
+}
+
+type TenantCreateRequest struct {
+	Name     string `json:"name" validate:"required,max=50"`
+	Describe string `json:"describe"`
+}
+
+type TenantMemberRequest struct {
+	Tenant int  `uri:"tenant" validate:"required,gte=1"`
+	UserID int  `json:"userId" validate:"required,gte=1"`
+	Admin  bool `json:"admin"`
+}
+
+type TenantMemberIDRequest struct {
+	Tenant int `uri:"tenant" validate:"required,gte=1"`
+	UserID int `uri:"uid" validate:"required,gte=1"`
+}
+
+type TenantMemberRoleRequest struct {
+	Tenant int `uri:"tenant" validate:"required,gte=1"`
+	UserID int `uri:"uid" validate:"required,gte=1"`
+	// RoleIDs 成员在租户内的角色, 替换原有的角色, 为空时清空
+	RoleIDs []int `json:"roleIds"`
+}
+
+type TenantRoleCreateRequest struct {
+	Tenant    int    `uri:"tenant" validate:"required,gte=1"`
+	Name      string `json:"name" validate:"required"`
+	Describe  string `json:"describe" validate:"required"`
+	PolicyIds []int  `json:"policyIds"`
+}
+
+type TenantRoleRequest struct {
+	Tenant int `uri:"tenant" validate:"required,gte=1"`
+	ID     int `uri:"id" validate:"required"`
+}
+
+type TenantRolePolicyRequest struct {
+	Tenant    int   `uri:"tenant" validate:"required,gte=1"`
+	ID        int   `uri:"id" validate:"required"`
+	PolicyIds []int `json:"policyIds" validate:"required"`
+}
+
+type TenantMemberResponse struct {
+	UserID int      `json:"userId"`
+	Name   string   `json:"name"`
+	Email  string   `json:"email"`
+	Admin  bool     `json:"admin"`
+	Roles  []string `json:"roles"`
+}
+
+func (receive *TenantMemberResponse) ConvertToTenantMemberResponse(in *model.TenantMember) {
+	receive.UserID = in.UserID
+	receive.Admin = in.Admin
+	if in.User != nil {
+		receive.Name = in.User.Name
+		receive.Email = in.User.Email
+	}
+	receive.Roles = make([]string, 0, len(in.Roles))
+	for _, role := range in.Roles {
+		receive.Roles = append(receive.Roles, role.Name)
+	}
+}
@@ -13,7 +13,7 @@ type UserQueryRequest struct {
 
 type UserEnableRequest struct {
 	ID       int    `uri:"id" validate:"required,gte=1"`
-	Password string `json:"password" validate:"required,min=8"`
+	Password string `json:"password" validate:"required"`
 }
 
 type UserListRequest struct {
@@ -22,6 +22,8 @@ type UserListRequest struct {
 	Status   int    `form:"status" validate:"required,oneof=-1 1 2"`       // -1:全部 1:启用 2:禁用
 	Keyword  string `form:"keyword" validate:"omitempty,oneof=name email"` // 支持 name 或 email 前缀模糊搜索
 	Value    string `form:"value" validate:"required_with=Keyword"`        // keyword存在的时候Value一定要存在
+	// ServiceAccount 为空时不过滤, true 只查询服务账号
+	ServiceAccount *bool `form:"serviceAccount"`
 }
 
 type UserNameRequest struct {
@@ -31,25 +33,70 @@ type UserNameRequest struct {
 type UserRegistryRequest struct {
 	Name     string `json:"name" validate:"required"`
 	NickName string `json:"nickName"`
-	Password string `json:"password" validate:"required,min=8"`
+	Password string `json:"password" validate:"required"`
 	Avatar   string `json:"avatar"`
 	Email    string `json:"email" validate:"email"`
 	Mobile   string `json:"mobile"`
+	// Verified 内部创建用户时使用, 邮箱已验证时不发送验证邮件
+	Verified bool `json:"-"`
 }
 
 type UserLoginRequest struct {
 	Email    string `json:"email"`
-	Password string `json:"password" validate:"required,min=8"`
+	Password string `json:"password" validate:"required"`
+	// IP 客户端来源 IP, 用于登录失败计数
+	IP string `json:"-"`
+}
+
+type UserUnlockRequest struct {
+	ID int `uri:"id" validate:"required,gte=1"`
+	// IP 同时解除该来源 IP 的锁定
+	IP string `form:"ip" validate:"omitempty,ip"`
 }
 
 type UserLoginResponse struct {
-	Token string `json:"token"`
+	Token            string `json:"token"`
+	ExpiresIn        int64  `json:"expiresIn"`
+	RefreshToken     string `json:"refreshToken"`
+	RefreshExpiresIn int64  `json:"refreshExpiresIn"`
+	// 启用 MFA 时不返回 token, 需要使用 MfaTicket 调用 /users/login/mfa 完成登录
+	MfaRequired bool   `json:"mfaRequired,omitempty"`
+	MfaTicket   string `json:"mfaTicket,omitempty"`
+	// 要求启用 MFA 但尚未绑定, 需要先调用 /users/login/mfa/enroll 绑定
+	MfaEnrollRequired bool `json:"mfaEnrollRequired,omitempty"`
+	// 通过登录票据完成绑定时返回的恢复码, 只返回一次
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"`
+	// 密码已过期时不返回 token, 需要使用 PasswordTicket 调用 /users/login/password 修改密码
+	PasswordExpired bool   `json:"passwordExpired,omitempty"`
+	PasswordTicket  string `json:"passwordTicket,omitempty"`
+}
+
+type UserChangeExpiredPasswordRequest struct {
+	Ticket   string `json:"ticket" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type UserRefreshRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+type UserEmailRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type UserResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type UserVerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
 }
 
 type UserUpdatePasswordRequest struct {
 	ID          int
-	OldPassword string `json:"oldPassword" validate:"required,min=8"`
-	NewPassword string `json:"newPassword" validate:"required,min=8"`
+	OldPassword string `json:"oldPassword" validate:"required"`
+	NewPassword string `json:"newPassword" validate:"required"`
 }
 
 type UserUpdateRequest struct {
@@ -70,8 +117,13 @@ type UserResponse struct {
 	Email     string                `json:"email"`
 	Mobile    string                `json:"mobile"`
 	Status    int                   `json:"status"`
+	Verified  bool                  `json:"verified"`
+	MfaEnable bool                  `json:"mfaEnable"`
+	Service   bool                  `json:"serviceAccount"`
 	RoleName  []string              `json:"roleName,omitempty"`
 	Roles     []model.Role          `json:"roles,omitempty"`
+	// Assignments 全局角色的授权信息
+	Assignments []UserRoleAssignment `json:"assignments,omitempty"`
 }
 
 func (receive *UserResponse) ConvertToUserResponse(in *model.User) {
@@ -85,6 +137,9 @@ func (receive *UserResponse) ConvertToUserResponse(in *model.User) {
 	receive.Email = in.Email
 	receive.Mobile = in.Mobile
 	receive.Status = *in.Status
+	receive.Verified = in.Verified
+	receive.MfaEnable = in.MfaEnable
+	receive.Service = in.ServiceAccount
 	receive.Roles = in.Roles
 }
 
@@ -98,4 +153,17 @@ type UserListResponse struct {
 type UserUpdateRoleRequest struct {
 	ID        int      `uri:"id" validate:"required"`
 	RoleNames []string `json:"roleNames" validate:"required"`
+	// Duration 临时授权的时长, 如 4h, 为空时永久授权, 只在增加角色时使用
+	Duration string `json:"duration"`
+	Reason   string `json:"reason" validate:"max=255"`
+}
+
+// UserRoleAssignment 用户的角色授权
+type UserRoleAssignment struct {
+	Role string `json:"role"`
+	// ExpiresAt 过期时间, 0 为永久
+	ExpiresAt int `json:"expiresAt"`
+	// Remaining 剩余秒数, 永久授权时为空
+	Remaining int    `json:"remaining,omitempty"`
+	Reason    string `json:"reason,omitempty"`
 }
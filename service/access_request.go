@@ -0,0 +1,352 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"qqlx/base/apierr"
+	"qqlx/base/conf"
+	"qqlx/base/constant"
+	"qqlx/base/helpers"
+	"qqlx/base/interfaces"
+	"qqlx/base/logger"
+	"qqlx/base/reason"
+	"qqlx/base/tracing"
+	"qqlx/model"
+	"qqlx/pkg/sonyflake"
+	"qqlx/schema"
+	"qqlx/store/rbac"
+	"qqlx/store/userstore"
+	"slices"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AccessRequestSVC 用户申请全局角色, 由角色的审批人审批, 通过后授予角色
+type AccessRequestSVC struct {
+	generateID   *sonyflake.GenerateIDStruct
+	requestStore interfaces.AccessRequestStoreInterface
+	roleStore    interfaces.RoleStoreInterface
+	appendStore  interfaces.RolePolicyStoreInterface
+	userStore    interfaces.UserStoreInterface
+	userSvc      *UserSVC
+	audit        *AuditSVC
+}
+
+func NewAccessRequestSVC(
+	generateID *sonyflake.GenerateIDStruct,
+	requestStore interfaces.AccessRequestStoreInterface,
+	roleStore interfaces.RoleStoreInterface,
+	appendStore interfaces.RolePolicyStoreInterface,
+	userStore interfaces.UserStoreInterface,
+	userSvc *UserSVC,
+	audit *AuditSVC,
+) *AccessRequestSVC {
+	return &AccessRequestSVC{
+		generateID:   generateID,
+		requestStore: requestStore,
+		roleStore:    roleStore,
+		appendStore:  appendStore,
+		userStore:    userStore,
+		userSvc:      userSvc,
+		audit:        audit,
+	}
+}
+
+// UpdateApprovers 设置角色的审批人, 只有全局角色可以申请
+func (receive *AccessRequestSVC) UpdateApprovers(ctx context.Context, req *schema.RoleApproverRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "AccessRequestSVC.UpdateApprovers")
+	defer span.End()
+	logger.WithContext(ctx, true).Debugf("role update approvers, request: %#v", req)
+	userIDs := helpers.Deduplicate(req.UserIDs)
+	event := newAuditEvent(constant.AuditRoleApprovers, constant.AuditTargetRole, req.ID)
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	role, err := receive.roleStore.Query(ctx, rbac.RoleID(req.ID), rbac.RoleTenantID(0), rbac.LoadApprovers())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apierr.InternalServer().Set(apierr.ServiceErrCode, "role not found", reason.ErrRoleNotFound)
+		}
+		return err
+	}
+	approvers := make([]model.User, 0, len(userIDs))
+	if len(userIDs) > 0 {
+		_, approvers, err = receive.userStore.List(ctx, 1, len(userIDs), userstore.IDs(userIDs), userstore.Status(model.UserStatusAvailable))
+		if err != nil {
+			return err
+		}
+	}
+	notFound := helpers.FindMissingByID(approvers, userIDs)
+	if len(notFound) > 0 {
+		return apierr.InternalServer().Set(apierr.ServiceErrCode, fmt.Sprintf("user not found: %v", notFound), reason.ErrUserNotFound)
+	}
+	event.Before = auditJSON(map[string]any{"approvers": helpers.GetIDs(role.Approvers)})
+	event.After = auditJSON(map[string]any{"approvers": userIDs})
+	role.Approvers = nil
+	return receive.appendStore.ReplaceApprovers(ctx, role, approvers)
+}
+
+// Create 当前用户申请全局角色, 同一角色只能有一个待审批的申请
+func (receive *AccessRequestSVC) Create(ctx context.Context, req *schema.AccessRequestCreateRequest) (res *schema.AccessRequestResponse, err error) {
+	ctx, span := tracing.Start(ctx, "AccessRequestSVC.Create")
+	defer span.End()
+	logger.WithContext(ctx, true).Debugf("create access request, request: %#v", req)
+	event := newAuditEvent(constant.AuditAccessRequested, constant.AuditTargetAccessRequest, "")
+	event.After = auditJSON(map[string]any{"role": req.RoleName, "duration": req.Duration, "justification": req.Justification})
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	if _, err = roleExpiresAt(req.Duration); err != nil {
+		return nil, err
+	}
+	role, err := receive.roleStore.Query(ctx, rbac.RoleName(req.RoleName), rbac.RoleTenantID(0))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierr.InternalServer().Set(apierr.ServiceErrCode, "role not found", reason.ErrRoleNotFound)
+		}
+		return nil, err
+	}
+	user, err := receive.userStore.Query(ctx, userstore.ID(req.UserID), userstore.LoadRoles())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierr.InternalServer().Set(apierr.ServiceErrCode, "user not found", reason.ErrUserNotFound)
+		}
+		return nil, err
+	}
+	if hasRole(user, role.ID) {
+		return nil, apierr.BadRequest().Set(apierr.ServiceErrCode, reason.ErrRoleHasRole.Error(), reason.ErrRoleHasRole)
+	}
+	total, _, err := receive.requestStore.List(ctx, 1, 1,
+		rbac.AccessRequestUserID(user.ID),
+		rbac.AccessRequestRoleID(role.ID),
+		rbac.AccessRequestStatus(model.AccessRequestPending),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if total > 0 {
+		return nil, apierr.BadRequest().Set(apierr.ServiceErrCode, reason.ErrAccessRequestPending.Error(), reason.ErrAccessRequestPending)
+	}
+
+	id, err := receive.generateID.NextID()
+	if err != nil {
+		return nil, err
+	}
+	request := &model.AccessRequest{
+		ID:            id,
+		UserID:        user.ID,
+		RoleID:        role.ID,
+		Justification: req.Justification,
+		Duration:      req.Duration,
+		Status:        model.AccessRequestPending,
+		ExpiresAt:     int(time.Now().Add(conf.GetAccessRequestTTL()).Unix()),
+	}
+	event.TargetID = strconv.Itoa(id)
+	if err = receive.requestStore.Create(ctx, request); err != nil {
+		return nil, err
+	}
+	request.User, request.Role = user, role
+	res = &schema.AccessRequestResponse{}
+	res.ConvertToAccessRequestResponse(request)
+	return res, nil
+}
+
+// hasRole 用户是否已有角色
+func hasRole(user *model.User, roleID int) bool {
+	return slices.ContainsFunc(user.Roles, func(role model.Role) bool {
+		return role.ID == roleID
+	})
+}
+
+// ListMine 当前用户的申请
+func (receive *AccessRequestSVC) ListMine(ctx context.Context, req *schema.AccessRequestListRequest) (res *schema.AccessRequestListResponse, err error) {
+	ctx, span := tracing.Start(ctx, "AccessRequestSVC.ListMine")
+	defer span.End()
+	return receive.list(ctx, req, rbac.AccessRequestUserID(req.UserID))
+}
+
+// Queue 当前用户可以审批的待审批申请
+func (receive *AccessRequestSVC) Queue(ctx context.Context, req *schema.AccessRequestListRequest) (res *schema.AccessRequestListResponse, err error) {
+	ctx, span := tracing.Start(ctx, "AccessRequestSVC.Queue")
+	defer span.End()
+	req.Status = model.AccessRequestPending
+	return receive.list(ctx, req, rbac.AccessRequestApprover(req.UserID))
+}
+
+// ListAll 所有用户的申请
+func (receive *AccessRequestSVC) ListAll(ctx context.Context, req *schema.AccessRequestListRequest) (res *schema.AccessRequestListResponse, err error) {
+	ctx, span := tracing.Start(ctx, "AccessRequestSVC.ListAll")
+	defer span.End()
+	return receive.list(ctx, req)
+}
+
+func (receive *AccessRequestSVC) list(ctx context.Context, req *schema.AccessRequestListRequest, options ...rbac.AccessRequestQueryOption) (res *schema.AccessRequestListResponse, err error) {
+	logger.WithContext(ctx, false).Debugf("access request list, request: %#v", req)
+	if req.Status != "" {
+		options = append(options, rbac.AccessRequestStatus(req.Status))
+	}
+	total, requests, err := receive.requestStore.List(ctx, req.Page, req.PageSize, options...)
+	if err != nil {
+		return nil, err
+	}
+	res = &schema.AccessRequestListResponse{
+		Total:    total,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+		Items:    make([]schema.AccessRequestResponse, len(requests)),
+	}
+	for i := range requests {
+		res.Items[i].ConvertToAccessRequestResponse(&requests[i])
+	}
+	return res, nil
+}
+
+// Approve 审批人通过申请, 通过 UserAddRole 授予角色, 可以覆盖申请的授权时长
+func (receive *AccessRequestSVC) Approve(ctx context.Context, req *schema.AccessRequestDecideRequest) (res *schema.AccessRequestResponse, err error) {
+	ctx, span := tracing.Start(ctx, "AccessRequestSVC.Approve")
+	defer span.End()
+	logger.WithContext(ctx, true).Debugf("approve access request, request: %#v", req)
+	event := newAuditEvent(constant.AuditAccessApproved, constant.AuditTargetAccessRequest, req.ID)
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	request, err := receive.pending(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	requested, duration := request.Duration, request.Duration
+	if req.Duration != "" {
+		duration = req.Duration
+	}
+	if _, err = roleExpiresAt(duration); err != nil {
+		return nil, err
+	}
+	event.Before = auditJSON(map[string]any{"status": request.Status})
+	event.After = auditJSON(map[string]any{"status": model.AccessRequestApproved, "user": request.User.Name, "role": request.Role.Name, "duration": duration, "comment": req.Comment})
+	// 先占用申请再授予角色, 并发审批时只有一个请求授予角色
+	request.Duration = duration
+	if err = receive.decide(ctx, request, model.AccessRequestApproved, req); err != nil {
+		return nil, err
+	}
+	err = receive.userSvc.UserAddRole(ctx, &schema.UserUpdateRoleRequest{
+		ID:        request.UserID,
+		RoleNames: []string{request.Role.Name},
+		Duration:  duration,
+		Reason:    fmt.Sprintf("access request %d", request.ID),
+	})
+	if err != nil {
+		// 授予失败时恢复为待审批, 可以重新审批
+		request.Status = model.AccessRequestPending
+		request.Duration = requested
+		request.ApproverID = 0
+		request.Comment = ""
+		request.DecidedAt = 0
+		if _, restoreErr := receive.requestStore.Decide(ctx, request, model.AccessRequestApproved); restoreErr != nil {
+			logger.WithContext(ctx, true).Errorf("restore access request %d to pending failed: %v", request.ID, restoreErr)
+		}
+		return nil, err
+	}
+	res = &schema.AccessRequestResponse{}
+	res.ConvertToAccessRequestResponse(request)
+	return res, nil
+}
+
+// Reject 审批人拒绝申请
+func (receive *AccessRequestSVC) Reject(ctx context.Context, req *schema.AccessRequestDecideRequest) (res *schema.AccessRequestResponse, err error) {
+	ctx, span := tracing.Start(ctx, "AccessRequestSVC.Reject")
+	defer span.End()
+	logger.WithContext(ctx, true).Debugf("reject access request, request: %#v", req)
+	event := newAuditEvent(constant.AuditAccessRejected, constant.AuditTargetAccessRequest, req.ID)
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	request, err := receive.pending(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	event.Before = auditJSON(map[string]any{"status": request.Status})
+	event.After = auditJSON(map[string]any{"status": model.AccessRequestRejected, "user": request.User.Name, "role": request.Role.Name, "comment": req.Comment})
+	if err = receive.decide(ctx, request, model.AccessRequestRejected, req); err != nil {
+		return nil, err
+	}
+	res = &schema.AccessRequestResponse{}
+	res.ConvertToAccessRequestResponse(request)
+	return res, nil
+}
+
+// pending 查询待审批的申请并检查 req.UserID 是否可以审批
+func (receive *AccessRequestSVC) pending(ctx context.Context, req *schema.AccessRequestDecideRequest) (*model.AccessRequest, error) {
+	request, err := receive.requestStore.Query(ctx, req.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierr.InternalServer().Set(apierr.ServiceErrCode, reason.ErrAccessRequestNotFound.Error(), reason.ErrAccessRequestNotFound)
+		}
+		return nil, err
+	}
+	if request.Role == nil || request.User == nil {
+		return nil, apierr.InternalServer().Set(apierr.ServiceErrCode, reason.ErrAccessRequestNotFound.Error(), reason.ErrAccessRequestNotFound)
+	}
+	if request.Status != model.AccessRequestPending {
+		return nil, apierr.BadRequest().Set(apierr.ServiceErrCode, reason.ErrAccessRequestDecided.Error(), reason.ErrAccessRequestDecided)
+	}
+	if request.UserID == req.UserID {
+		return nil, apierr.Forbidden().Set(apierr.ServiceErrCode, reason.ErrSelfApprove.Error(), reason.ErrSelfApprove)
+	}
+	if !slices.ContainsFunc(request.Role.Approvers, func(user model.User) bool { return user.ID == req.UserID }) {
+		return nil, apierr.Forbidden().Set(apierr.ServiceErrCode, reason.ErrNotApprover.Error(), reason.ErrNotApprover)
+	}
+	// 后台任务还未处理的过期申请
+	if request.ExpiresAt <= int(time.Now().Unix()) {
+		if err = receive.expire(ctx, request); err != nil {
+			return nil, err
+		}
+		return nil, apierr.BadRequest().Set(apierr.ServiceErrCode, reason.ErrAccessRequestDecided.Error(), reason.ErrAccessRequestDecided)
+	}
+	return request, nil
+}
+
+// decide 仅在申请仍待审批时更新状态, 已被其他请求处理时返回 ErrAccessRequestDecided
+func (receive *AccessRequestSVC) decide(ctx context.Context, request *model.AccessRequest, status string, req *schema.AccessRequestDecideRequest) error {
+	request.Status = status
+	request.ApproverID = req.UserID
+	request.Comment = req.Comment
+	request.DecidedAt = int(time.Now().Unix())
+	ok, err := receive.requestStore.Decide(ctx, request, model.AccessRequestPending)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return apierr.BadRequest().Set(apierr.ServiceErrCode, reason.ErrAccessRequestDecided.Error(), reason.ErrAccessRequestDecided)
+	}
+	return nil
+}
+
+// expire 将待审批的申请设置为过期, 已被审批时不处理
+func (receive *AccessRequestSVC) expire(ctx context.Context, request *model.AccessRequest) (err error) {
+	before := request.Status
+	request.Status = model.AccessRequestExpired
+	request.DecidedAt = int(time.Now().Unix())
+	ok, err := receive.requestStore.Decide(ctx, request, model.AccessRequestPending)
+	if err == nil && !ok {
+		return nil
+	}
+	event := newAuditEvent(constant.AuditAccessExpired, constant.AuditTargetAccessRequest, request.ID)
+	event.Before = auditJSON(map[string]any{"status": before})
+	event.After = auditJSON(map[string]any{"status": model.AccessRequestExpired})
+	receive.audit.Record(ctx, event, err)
+	return err
+}
+
+// ExpireRequests 将超过有效期的待审批申请设置为过期, 由后台任务定期调用
+func (receive *AccessRequestSVC) ExpireRequests(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "AccessRequestSVC.ExpireRequests")
+	defer span.End()
+	_, requests, err := receive.requestStore.List(ctx, -1, -1,
+		rbac.AccessRequestStatus(model.AccessRequestPending),
+		rbac.AccessRequestExpiredBefore(int(time.Now().Unix())),
+	)
+	if err != nil {
+		return err
+	}
+	for i := range requests {
+		if err = receive.expire(ctx, &requests[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"qqlx/base/apierr"
+	"qqlx/base/constant"
+	"qqlx/base/helpers"
+	"qqlx/base/interfaces"
+	"qqlx/base/logger"
+	"qqlx/base/reason"
+	"qqlx/base/tracing"
+	"qqlx/model"
+	"qqlx/pkg/jwt"
+	"qqlx/pkg/sonyflake"
+	"qqlx/schema"
+	"qqlx/store/userstore"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type ApiKeySVC struct {
+	generateID  *sonyflake.GenerateIDStruct
+	userStore   interfaces.UserStoreInterface
+	apiKeyStore interfaces.ApiKeyStoreInterface
+	audit       *AuditSVC
+}
+
+func NewApiKeySVC(generateID *sonyflake.GenerateIDStruct, userStore interfaces.UserStoreInterface, apiKeyStore interfaces.ApiKeyStoreInterface, audit *AuditSVC) *ApiKeySVC {
+	return &ApiKeySVC{
+		generateID:  generateID,
+		userStore:   userStore,
+		apiKeyStore: apiKeyStore,
+		audit:       audit,
+	}
+}
+
+// Create 为用户创建 API key, 完整的 key 只在创建时返回
+func (receive *ApiKeySVC) Create(ctx context.Context, req *schema.ApiKeyCreateRequest) (res *schema.ApiKeyCreateResponse, err error) {
+	ctx, span := tracing.Start(ctx, "ApiKeySVC.Create")
+	defer span.End()
+	logger.WithContext(ctx, true).Debugf("create api key, userID: %d, name: %s, roles: %v", req.UserID, req.Name, req.Roles)
+	event := newAuditEvent(constant.AuditApiKeyCreate, constant.AuditTargetApiKey, "")
+	event.After = auditJSON(map[string]any{"userId": req.UserID, "name": req.Name, "roles": req.Roles, "expiresIn": req.ExpiresIn})
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	if apiKeyCaller(ctx) {
+		return nil, apierr.Forbidden().Set(apierr.AuthErrCode, reason.ErrApiKeyCaller.Error(), reason.ErrApiKeyCaller)
+	}
+	var expiresAt int
+	if req.ExpiresIn != "" {
+		expire, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || expire <= 0 {
+			return nil, apierr.BadRequest().Set(apierr.ServiceErrCode, fmt.Sprintf("invalid expiresIn: %s", req.ExpiresIn), err)
+		}
+		expiresAt = int(time.Now().Add(expire).Unix())
+	}
+	user, err := receive.queryUser(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	roles := helpers.Deduplicate(req.Roles)
+	ownerRoles := make([]string, 0, len(user.Roles))
+	for _, role := range user.Roles {
+		ownerRoles = append(ownerRoles, role.Name)
+	}
+	if !ApiKeyRolesAllowed(ownerRoles, roles) {
+		return nil, apierr.BadRequest().Set(apierr.ServiceErrCode, reason.ErrApiKeyRoleNotAllowed.Error(), reason.ErrApiKeyRoleNotAllowed)
+	}
+	key, err := GenerateApiKey()
+	if err != nil {
+		return nil, err
+	}
+	id, err := receive.generateID.NextID()
+	if err != nil {
+		return nil, err
+	}
+	apiKey := &model.ApiKey{
+		ID:        id,
+		Name:      req.Name,
+		Prefix:    key[:len(constant.ApiKeyPrefix)+8],
+		Hash:      HashApiKey(key),
+		UserID:    user.ID,
+		Roles:     strings.Join(roles, ","),
+		ExpiresAt: expiresAt,
+	}
+	event.TargetID = strconv.Itoa(apiKey.ID)
+	if err = receive.apiKeyStore.Create(ctx, apiKey); err != nil {
+		return nil, err
+	}
+	res = &schema.ApiKeyCreateResponse{Key: key}
+	res.ConvertToApiKeyResponse(apiKey)
+	return res, nil
+}
+
+// List 查询用户的 API key
+func (receive *ApiKeySVC) List(ctx context.Context, userID int) (res []schema.ApiKeyResponse, err error) {
+	ctx, span := tracing.Start(ctx, "ApiKeySVC.List")
+	defer span.End()
+	keys, err := receive.apiKeyStore.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	res = make([]schema.ApiKeyResponse, 0, len(keys))
+	for i := range keys {
+		item := schema.ApiKeyResponse{}
+		item.ConvertToApiKeyResponse(&keys[i])
+		res = append(res, item)
+	}
+	return res, nil
+}
+
+// Revoke 吊销 API key, 立即生效
+func (receive *ApiKeySVC) Revoke(ctx context.Context, req *schema.ApiKeyQueryRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "ApiKeySVC.Revoke")
+	defer span.End()
+	logger.WithContext(ctx, true).Debugf("revoke api key, request: %#v", req)
+	event := newAuditEvent(constant.AuditApiKeyRevoke, constant.AuditTargetApiKey, req.ID)
+	event.Before = auditJSON(map[string]any{"userId": req.UserID})
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	if apiKeyCaller(ctx) {
+		return apierr.Forbidden().Set(apierr.AuthErrCode, reason.ErrApiKeyCaller.Error(), reason.ErrApiKeyCaller)
+	}
+	key, err := receive.apiKeyStore.Query(ctx, req.ID, req.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apierr.InternalServer().Set(apierr.ServiceErrCode, reason.ErrApiKeyNotFound.Error(), reason.ErrApiKeyNotFound)
+		}
+		return err
+	}
+	return receive.apiKeyStore.Delete(ctx, key)
+}
+
+// CreateServiceAccount 创建服务账号, 服务账号只能使用 API key 认证
+func (receive *ApiKeySVC) CreateServiceAccount(ctx context.Context, req *schema.ServiceAccountCreateRequest) (res *schema.UserResponse, err error) {
+	ctx, span := tracing.Start(ctx, "ApiKeySVC.CreateServiceAccount")
+	defer span.End()
+	logger.WithContext(ctx, true).Debugf("create service account, request: %#v", req)
+	event := newAuditEvent(constant.AuditServiceAccountCreate, constant.AuditTargetUser, req.Name)
+	event.After = auditJSON(req)
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	email := fmt.Sprintf("%s@%s", req.Name, constant.ServiceAccountEmailDomain)
+	_, err = receive.userStore.Query(ctx, userstore.Name(req.Name))
+	if err == nil {
+		return nil, apierr.InternalServer().Set(apierr.ServiceErrCode, "user already exists", reason.ErrUserExists)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	// 服务账号不能使用密码登录, 随机密码只用于占位
+	random, err := randomCode(32)
+	if err != nil {
+		return nil, err
+	}
+	password, err := bcrypt.GenerateFromPassword([]byte(random), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, apierr.InternalServer().Set(apierr.ServiceErrCode, "unknown error", reason.ErrEncryptPassword)
+	}
+	id, err := receive.generateID.NextID()
+	if err != nil {
+		return nil, err
+	}
+	user := &model.User{
+		ID:             id,
+		Name:           req.Name,
+		NickName:       req.NickName,
+		Email:          email,
+		Password:       string(password),
+		ServiceAccount: true,
+		Verified:       true,
+		Status:         &model.UserStatusAvailable,
+	}
+	event.TargetID = strconv.Itoa(id)
+	if err = receive.userStore.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	res = &schema.UserResponse{}
+	res.ConvertToUserResponse(user)
+	return res, nil
+}
+
+// ServiceAccount 校验用户是否为服务账号, 管理员只能通过服务账号接口管理服务账号的 API key
+func (receive *ApiKeySVC) ServiceAccount(ctx context.Context, id int) (err error) {
+	ctx, span := tracing.Start(ctx, "ApiKeySVC.ServiceAccount")
+	defer span.End()
+	user, err := receive.queryUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !user.ServiceAccount {
+		return apierr.InternalServer().Set(apierr.ServiceErrCode, "service account not found", reason.ErrUserNotFound)
+	}
+	return nil
+}
+
+// Authenticate 校验 API key, 返回与登录 token 相同的声明
+func (receive *ApiKeySVC) Authenticate(ctx context.Context, key string) (claims *jwt.MyClaims, err error) {
+	ctx, span := tracing.Start(ctx, "ApiKeySVC.Authenticate")
+	defer span.End()
+	if !strings.HasPrefix(key, constant.ApiKeyPrefix) {
+		return nil, reason.ErrApiKeyInvalid
+	}
+	apiKey, err := receive.apiKeyStore.QueryByHash(ctx, HashApiKey(key))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, reason.ErrApiKeyInvalid
+		}
+		return nil, err
+	}
+	now := time.Now().Unix()
+	if apiKey.ExpiresAt > 0 && int64(apiKey.ExpiresAt) <= now {
+		return nil, reason.ErrApiKeyExpired
+	}
+	if apiKey.User == nil {
+		return nil, reason.ErrUserNotFound
+	}
+	if apiKey.User.Status != nil && *apiKey.User.Status == model.UserStatusDisable {
+		return nil, reason.ErrUserIsDisable
+	}
+	// 降低写入频率, 最近使用时间精确到 ApiKeyTouchInterval
+	if now-int64(apiKey.LastUsedAt) >= constant.ApiKeyTouchInterval {
+		if err = receive.apiKeyStore.Touch(ctx, apiKey.ID, int(now)); err != nil {
+			logger.WithContext(ctx, true).Errorf("update api key last used failed, id: %d, err: %v", apiKey.ID, err)
+		}
+	}
+	var roles []string
+	if apiKey.Roles != "" {
+		roles = strings.Split(apiKey.Roles, ",")
+	}
+	return jwt.NewApiKeyClaims(apiKey.User.ID, apiKey.User.Name, roles), nil
+}
+
+func (receive *ApiKeySVC) queryUser(ctx context.Context, id int) (*model.User, error) {
+	user, err := receive.userStore.Query(ctx, userstore.ID(id), userstore.LoadRoles())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierr.InternalServer().Set(apierr.ServiceErrCode, "user not found", reason.ErrUserNotFound)
+		}
+		return nil, err
+	}
+	if *user.Status == model.UserStatusDisable {
+		return nil, apierr.InternalServer().Set(apierr.ServiceErrCode, "user not found", reason.ErrUserIsDisable)
+	}
+	return user, nil
+}
+
+// apiKeyCaller 当前请求是否通过 API key 认证, API key 不能用于创建或吊销 API key, 避免绕过角色限制
+func apiKeyCaller(ctx context.Context) bool {
+	claims, _ := ctx.Value(constant.AuthMidwareKey).(*jwt.MyClaims)
+	return claims != nil && claims.ApiKey
+}
+
+// GenerateApiKey 生成 API key, 格式为 ApiKeyPrefix + 随机字符串
+func GenerateApiKey() (string, error) {
+	random, err := randomCode(40)
+	if err != nil {
+		return "", err
+	}
+	return constant.ApiKeyPrefix + random, nil
+}
+
+// HashApiKey API key 只保存 sha256 摘要
+func HashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// ApiKeyRolesAllowed 判断 API key 限制的角色是否为所属用户角色的子集
+func ApiKeyRolesAllowed(ownerRoles, roles []string) bool {
+	for _, role := range roles {
+		if !slices.Contains(ownerRoles, role) {
+			return false
+		}
+	}
+	return true
+}
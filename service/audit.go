@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"qqlx/base/conf"
+	"qqlx/base/constant"
+	"qqlx/base/interfaces"
+	"qqlx/base/logger"
+	"qqlx/base/tracing"
+	"qqlx/model"
+	"qqlx/pkg/jwt"
+	"qqlx/schema"
+	"qqlx/store/audit"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// auditDefaultLimit 查询审计记录默认每页数量
+	auditDefaultLimit = 50
+	// auditExportBatch 导出时每次查询的数量
+	auditExportBatch = 500
+	// auditErrorSize 失败原因最大长度
+	auditErrorSize = 512
+)
+
+// AuditSVC 记录和查询审计事件, 记录按写入顺序组成哈希链
+//
+// 哈希链要求记录逐条追加: 进程内由 mu 串行, 多个实例之间由链头行的 FOR UPDATE 锁串行,
+// 每条记录占用一次数据库事务, 写入吞吐约为单个事务耗时的倒数, 所有实例共享这一上限.
+// 写入在业务操作完成后同步执行, 数据库变慢时会增加所有被审计接口的延迟
+type AuditSVC struct {
+	auditStore interfaces.AuditStoreInterface
+	key        []byte
+	// mu 同一进程内按顺序追加, 避免多个请求同时等待链头行锁占用数据库连接
+	mu             sync.Mutex
+	checkpointFile string
+	lastCheckpoint int
+}
+
+func NewAuditSVC(auditStore interfaces.AuditStoreInterface) (*AuditSVC, error) {
+	key, err := conf.GetAuditHmacKey()
+	if err != nil {
+		return nil, err
+	}
+	return &AuditSVC{
+		auditStore:     auditStore,
+		key:            []byte(key),
+		checkpointFile: conf.GetAuditCheckpointFile(),
+	}, nil
+}
+
+// Record 记录审计事件, err 不为空时记录为失败, receive 为 nil 时不记录
+//
+// 操作人为空时使用当前登录的用户, 写入失败只记录日志, 不影响操作结果
+func (receive *AuditSVC) Record(ctx context.Context, event *model.AuditEvent, err error) {
+	// 操作已经执行, 即使 ctx 已取消也要写入审计
+	ctx, span := tracing.Start(context.WithoutCancel(ctx), "AuditSVC.Record")
+	defer span.End()
+	if receive == nil {
+		return
+	}
+	if event.ActorID == 0 && event.ActorName == "" {
+		if claims, _ := ctx.Value(constant.AuthMidwareKey).(*jwt.MyClaims); claims != nil {
+			event.ActorID = claims.UserID
+			event.ActorName = claims.UserName
+		}
+	}
+	event.ClientIP, _ = ctx.Value(constant.ClientIPKey).(string)
+	event.TraceID, _ = ctx.Value(constant.TraceID).(string)
+	event.Result = model.AuditResultSuccess
+	if err != nil {
+		event.Result = model.AuditResultFailure
+		event.Error = err.Error()
+		if len(event.Error) > auditErrorSize {
+			// 截断后保证是合法的 UTF-8, 写入数据库后内容不变
+			event.Error = strings.ToValidUTF8(event.Error[:auditErrorSize], "")
+		}
+	}
+	if event.CreatedAt == 0 {
+		event.CreatedAt = int(time.Now().Unix())
+	}
+	receive.mu.Lock()
+	defer receive.mu.Unlock()
+	if createErr := receive.auditStore.Append(ctx, event, receive.seal); createErr != nil {
+		logger.WithContext(ctx, false).Errorw("record audit event failed", "action", event.Action,
+			"targetType", event.TargetType, "targetId", event.TargetID, "err", createErr)
+	}
+}
+
+// newAuditEvent 创建审计事件, 操作结束后通过 Record 记录
+func newAuditEvent(action, targetType string, targetID any) *model.AuditEvent {
+	return &model.AuditEvent{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   fmt.Sprint(targetID),
+	}
+}
+
+// auditJSON 序列化修改前后的值, 失败时不记录
+func auditJSON(v any) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// auditActor 操作人和操作对象都为 user, 用于登录等没有登录信息的操作
+func auditActor(event *model.AuditEvent, user *model.User) {
+	event.ActorID = user.ID
+	event.ActorName = user.Name
+	event.TargetID = strconv.Itoa(user.ID)
+}
+
+// List 按时间倒序游标分页查询审计记录
+func (receive *AuditSVC) List(ctx context.Context, req *schema.AuditListRequest) (res *schema.AuditListResponse, err error) {
+	ctx, span := tracing.Start(ctx, "AuditSVC.List")
+	defer span.End()
+	logger.WithContext(ctx, false).Debugf("audit list, request: %#v", req)
+	limit := req.Limit
+	if limit == 0 {
+		limit = auditDefaultLimit
+	}
+	options := auditOptions(req)
+	if req.Cursor > 0 {
+		options = append(options, audit.BeforeID(req.Cursor))
+	}
+	// 多查询一条判断是否有下一页
+	events, err := receive.auditStore.List(ctx, limit+1, options...)
+	if err != nil {
+		return nil, err
+	}
+	res = &schema.AuditListResponse{Items: events}
+	if len(events) > limit {
+		res.Items = events[:limit]
+		res.NextCursor = events[limit-1].ID
+	}
+	return res, nil
+}
+
+// Export 按时间倒序以 JSON Lines 格式导出所有符合条件的审计记录
+func (receive *AuditSVC) Export(ctx context.Context, req *schema.AuditListRequest, w io.Writer) (err error) {
+	ctx, span := tracing.Start(ctx, "AuditSVC.Export")
+	defer span.End()
+	logger.WithContext(ctx, false).Debugf("audit export, request: %#v", req)
+	encoder := json.NewEncoder(w)
+	cursor := req.Cursor
+	for {
+		options := auditOptions(req)
+		if cursor > 0 {
+			options = append(options, audit.BeforeID(cursor))
+		}
+		events, err := receive.auditStore.List(ctx, auditExportBatch, options...)
+		if err != nil {
+			return err
+		}
+		for i := range events {
+			if err = encoder.Encode(&events[i]); err != nil {
+				return err
+			}
+		}
+		if len(events) < auditExportBatch {
+			return nil
+		}
+		cursor = events[len(events)-1].ID
+	}
+}
+
+// auditOptions 查询条件, 不包含游标
+func auditOptions(req *schema.AuditListRequest) []audit.QueryOption {
+	options := make([]audit.QueryOption, 0)
+	if req.ActorID > 0 {
+		options = append(options, audit.ActorID(req.ActorID))
+	}
+	if req.Action != "" {
+		options = append(options, audit.Action(req.Action))
+	}
+	if req.TargetType != "" {
+		options = append(options, audit.TargetType(req.TargetType))
+	}
+	if req.TargetID != "" {
+		options = append(options, audit.TargetID(req.TargetID))
+	}
+	if req.Result != "" {
+		options = append(options, audit.Result(req.Result))
+	}
+	if req.From > 0 {
+		options = append(options, audit.CreatedFrom(req.From))
+	}
+	if req.To > 0 {
+		options = append(options, audit.CreatedTo(req.To))
+	}
+	return options
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"qqlx/base/logger"
+	"qqlx/base/tracing"
+	"qqlx/model"
+	"qqlx/schema"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// auditContent 计算哈希的记录内容, 字段顺序固定
+type auditContent struct {
+	PrevHash   string          `json:"prevHash"`
+	CreatedAt  int             `json:"createdAt"`
+	ActorID    int             `json:"actorId"`
+	ActorName  string          `json:"actorName"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"targetType"`
+	TargetID   string          `json:"targetId"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	ClientIP   string          `json:"clientIp"`
+	TraceID    string          `json:"traceId"`
+	Result     string          `json:"result"`
+	Error      string          `json:"error"`
+}
+
+// seal 计算记录的哈希, 包含上一条记录的哈希, 不包含自增的 ID
+func (receive *AuditSVC) seal(event *model.AuditEvent) string {
+	content, _ := json.Marshal(auditContent{
+		PrevHash:   event.PrevHash,
+		CreatedAt:  event.CreatedAt,
+		ActorID:    event.ActorID,
+		ActorName:  event.ActorName,
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		Before:     event.Before,
+		After:      event.After,
+		ClientIP:   event.ClientIP,
+		TraceID:    event.TraceID,
+		Result:     event.Result,
+		Error:      event.Error,
+	})
+	return receive.sign(content)
+}
+
+func (receive *AuditSVC) sign(content []byte) string {
+	mac := hmac.New(sha256.New, receive.key)
+	mac.Write(content)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// checkpointSignature 检查点的签名
+func (receive *AuditSVC) checkpointSignature(checkpoint *schema.AuditCheckpoint) string {
+	return receive.sign([]byte(fmt.Sprintf("checkpoint|%d|%d|%s", checkpoint.CreatedAt, checkpoint.LastID, checkpoint.Hash)))
+}
+
+// Verify 按顺序校验哈希链, 返回第一处断裂; checkpoints 不为空时同时校验签名检查点
+func (receive *AuditSVC) Verify(ctx context.Context, checkpoints io.Reader) (res *schema.AuditVerifyResponse, err error) {
+	ctx, span := tracing.Start(ctx, "AuditSVC.Verify")
+	defer span.End()
+	res = &schema.AuditVerifyResponse{Valid: true}
+	broken := func(id int, reason string) (*schema.AuditVerifyResponse, error) {
+		res.Valid = false
+		res.BrokenID = id
+		res.Reason = reason
+		return res, nil
+	}
+	cursor := 0
+	for {
+		events, err := receive.auditStore.ListAfter(ctx, cursor, auditExportBatch)
+		if err != nil {
+			return nil, err
+		}
+		for i := range events {
+			event := &events[i]
+			if event.PrevHash != res.LastHash {
+				return broken(event.ID, "previous hash mismatch, the previous record was deleted or modified")
+			}
+			if !hmac.Equal([]byte(event.Hash), []byte(receive.seal(event))) {
+				return broken(event.ID, "content hash mismatch, the record was modified")
+			}
+			res.Checked++
+			res.LastID = event.ID
+			res.LastHash = event.Hash
+		}
+		if len(events) < auditExportBatch {
+			break
+		}
+		cursor = events[len(events)-1].ID
+	}
+	chain, err := receive.auditStore.Chain(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if chain.LastID != res.LastID || chain.Hash != res.LastHash {
+		return broken(chain.LastID, "chain head mismatch, the latest records were deleted")
+	}
+	if checkpoints == nil {
+		return res, nil
+	}
+
+	scanner := bufio.NewScanner(checkpoints)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		checkpoint := new(schema.AuditCheckpoint)
+		if err = json.Unmarshal(scanner.Bytes(), checkpoint); err != nil {
+			return broken(0, fmt.Sprintf("checkpoint line %d is invalid: %v", line, err))
+		}
+		if !hmac.Equal([]byte(checkpoint.Signature), []byte(receive.checkpointSignature(checkpoint))) {
+			return broken(checkpoint.LastID, fmt.Sprintf("checkpoint line %d signature mismatch", line))
+		}
+		event, err := receive.auditStore.Query(ctx, checkpoint.LastID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return broken(checkpoint.LastID, fmt.Sprintf("checkpoint line %d record does not exist", line))
+			}
+			return nil, err
+		}
+		if event.Hash != checkpoint.Hash {
+			return broken(checkpoint.LastID, fmt.Sprintf("checkpoint line %d hash mismatch, the chain was rewritten", line))
+		}
+		res.Checkpoints++
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Checkpoint 将当前链头签名后追加到检查点文件, 没有新记录时不写入, 由后台任务定期调用
+func (receive *AuditSVC) Checkpoint(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "AuditSVC.Checkpoint")
+	defer span.End()
+	if receive.checkpointFile == "" {
+		return nil
+	}
+	chain, err := receive.auditStore.Chain(ctx)
+	if err != nil {
+		return err
+	}
+	if chain.LastID == 0 || chain.LastID == receive.lastCheckpoint {
+		return nil
+	}
+	checkpoint := &schema.AuditCheckpoint{
+		CreatedAt: int(time.Now().Unix()),
+		LastID:    chain.LastID,
+		Hash:      chain.Hash,
+	}
+	checkpoint.Signature = receive.checkpointSignature(checkpoint)
+	line, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(receive.checkpointFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err = file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	receive.lastCheckpoint = chain.LastID
+	logger.WithContext(ctx, false).Infof("audit checkpoint written, lastId: %d", chain.LastID)
+	return nil
+}
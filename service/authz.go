@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"qqlx/base/apierr"
+	"qqlx/base/constant"
+	"qqlx/base/helpers"
+	"qqlx/base/interfaces"
+	"qqlx/base/logger"
+	"qqlx/base/reason"
+	"qqlx/base/tracing"
+	"qqlx/model"
+	"qqlx/schema"
+	"qqlx/store/rbac"
+	"qqlx/store/userstore"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AuthzSVC 鉴权结果解释, 按鉴权中间件的规则计算并返回匹配的策略
+type AuthzSVC struct {
+	userStore     interfaces.UserStoreInterface
+	userRoleStore interfaces.UserRoleStoreInterface
+	roleStore     interfaces.RoleStoreInterface
+	memberStore   interfaces.TenantMemberStoreInterface
+	authorizer    interfaces.Authorizer
+}
+
+func NewAuthzSVC(
+	userStore interfaces.UserStoreInterface,
+	userRoleStore interfaces.UserRoleStoreInterface,
+	roleStore interfaces.RoleStoreInterface,
+	memberStore interfaces.TenantMemberStoreInterface,
+	authorizer interfaces.Authorizer,
+) *AuthzSVC {
+	return &AuthzSVC{
+		userStore:     userStore,
+		userRoleStore: userRoleStore,
+		roleStore:     roleStore,
+		memberStore:   memberStore,
+		authorizer:    authorizer,
+	}
+}
+
+// Check 检查用户或角色是否有权限访问 path, 任一角色允许即允许
+func (receive *AuthzSVC) Check(ctx context.Context, req *schema.AuthzCheckRequest) (res *schema.AuthzCheckResponse, err error) {
+	ctx, span := tracing.Start(ctx, "AuthzSVC.Check")
+	defer span.End()
+	logger.WithContext(ctx, true).Debugf("authz check, request: %#v", req)
+	var (
+		roles  []string
+		userID int
+	)
+	if req.Role != "" {
+		roles = []string{req.Role}
+	} else {
+		userID, roles, err = receive.userRoles(ctx, req.User, req.Tenant)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	method := strings.ToUpper(req.Method)
+	domain := helpers.GetCasbinDomain(req.Tenant)
+	env := rbac.NewConditionEnv(userID, req.ResourceID, req.Tenant, time.Now(), func(id int) (*model.User, error) {
+		return receive.userStore.Query(ctx, userstore.ID(id))
+	})
+	res = &schema.AuthzCheckResponse{
+		Decision: constant.PolicyEffectDeny,
+		Roles:    roles,
+		Results:  make([]schema.AuthzRoleResult, 0, len(roles)),
+	}
+	policies := make(map[string][]model.Policy)
+	for _, role := range roles {
+		result := schema.AuthzRoleResult{Role: role}
+		result.Allowed, result.Explain, err = receive.authorizer.ExplainWithCtx(ctx, role, domain, req.Path, method, env)
+		if err != nil {
+			return nil, err
+		}
+		if len(result.Explain) > 0 {
+			policy, err := receive.matchPolicy(ctx, policies, result.Explain)
+			if err != nil {
+				return nil, err
+			}
+			if policy != nil {
+				result.PolicyID, result.PolicyName = policy.ID, policy.Name
+			}
+		}
+		res.Results = append(res.Results, result)
+	}
+	// 与鉴权中间件一致, 任一角色匹配到 deny 策略时拒绝, 以第一个 deny 的角色为准
+	var denied, allowedBy, matched *schema.AuthzRoleResult
+	for i := range res.Results {
+		result := &res.Results[i]
+		if rbac.IsDenyExplain(result.Allowed, result.Explain) {
+			if denied == nil {
+				denied = result
+			}
+			continue
+		}
+		if result.Allowed && allowedBy == nil {
+			allowedBy = result
+		}
+		if len(result.Explain) > 0 && matched == nil {
+			matched = result
+		}
+	}
+	decided := denied
+	if denied == nil && allowedBy != nil {
+		res.Allowed = true
+		res.Decision = constant.PolicyEffectAllow
+		decided = allowedBy
+	}
+	if decided == nil {
+		decided = matched
+	}
+	if decided != nil {
+		res.PolicyID, res.PolicyName = decided.PolicyID, decided.PolicyName
+	}
+	return res, nil
+}
+
+// userRoles 用户未过期的全局角色和租户内的角色, 与鉴权中间件一致
+func (receive *AuthzSVC) userRoles(ctx context.Context, name string, tenantID int) (userID int, roles []string, err error) {
+	user, err := receive.userStore.Query(ctx, userstore.Name(name))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil, apierr.InternalServer().Set(apierr.ServiceErrCode, "user not found", reason.ErrUserNotFound)
+		}
+		return 0, nil, err
+	}
+	now := time.Now()
+	assignments, err := receive.userRoleStore.ListActive(ctx, user.ID, int(now.Unix()))
+	if err != nil {
+		return 0, nil, err
+	}
+	roles, _ = helpers.ActiveRoleNames(assignments, now)
+	if tenantID != 0 {
+		member, err := receive.memberStore.Query(ctx, tenantID, user.ID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil, err
+		}
+		if member != nil {
+			if member.Admin {
+				roles = append(roles, constant.TenantAdminRole)
+			}
+			for _, role := range member.Roles {
+				roles = append(roles, helpers.GetCasbinSubject(role.TenantID, role.Name))
+			}
+		}
+	}
+	return user.ID, roles, nil
+}
+
+// matchPolicy 根据 casbin 匹配的策略查找来源角色上对应的策略
+func (receive *AuthzSVC) matchPolicy(ctx context.Context, cache map[string][]model.Policy, explain []string) (*model.Policy, error) {
+	subject := explain[0]
+	policies, ok := cache[subject]
+	if !ok {
+		tenantID, name := helpers.ParseCasbinSubject(subject)
+		role, err := receive.roleStore.Query(ctx, rbac.RoleName(name), rbac.RoleTenantID(tenantID), rbac.LoadPolices())
+		if err != nil {
+			if errors.Is(err, reason.ErrRoleNotFound) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		policies = role.Policys
+		cache[subject] = policies
+	}
+	for i, policy := range policies {
+		if policy.Path == explain[1] && policy.Method == explain[2] &&
+			helpers.PolicyEffect(policy.Effect) == explain[3] &&
+			helpers.PolicyCondition(policy.Condition) == explain[5] {
+			return &policies[i], nil
+		}
+	}
+	return nil, nil
+}
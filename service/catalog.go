@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"qqlx/base/apierr"
+	"qqlx/base/constant"
+	"qqlx/base/helpers"
+	"qqlx/base/logger"
+	"qqlx/base/reason"
+	"qqlx/base/tracing"
+	"qqlx/model"
+	"qqlx/schema"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/casbin/casbin/v2/util"
+)
+
+// policyNameMaxLen 与 model.Policy.Name 的字段长度一致
+const policyNameMaxLen = 50
+
+// Catalog 对比路由目录和策略, apply 为 true 时为缺少策略的鉴权路由创建策略
+func (receive *PolicySVC) Catalog(ctx context.Context, apply bool) (res *schema.PolicyCatalogResponse, err error) {
+	ctx, span := tracing.Start(ctx, "PolicySVC.Catalog")
+	defer span.End()
+	logger.WithContext(ctx, false).Debugf("policy catalog, apply: %v", apply)
+	if receive.catalog == nil {
+		return nil, apierr.InternalServer().Set(apierr.ServiceErrCode, "route catalog is not loaded", reason.ErrRouteCatalog)
+	}
+	_, polices, err := receive.policyStore.List(ctx, -1, -1)
+	if err != nil {
+		return nil, err
+	}
+	routes := receive.catalog.Routes()
+	res = &schema.PolicyCatalogResponse{
+		Routes:   routes,
+		Missing:  make([]schema.CatalogRoute, 0),
+		Orphaned: make([]model.Policy, 0),
+		Created:  make([]model.Policy, 0),
+	}
+
+	names := make(map[string]struct{}, len(polices))
+	for _, policy := range polices {
+		names[policy.Name] = struct{}{}
+		if !policyMatchRoutes(policy, routes) {
+			res.Orphaned = append(res.Orphaned, policy)
+		}
+	}
+	for _, route := range routes {
+		if !route.Protected {
+			continue
+		}
+		if !routeHasPolicy(route, polices) {
+			res.Missing = append(res.Missing, route)
+		}
+	}
+	if !apply {
+		return res, nil
+	}
+
+	created := make([]string, 0, len(res.Missing))
+	event := newAuditEvent(constant.AuditPolicyCatalogApply, constant.AuditTargetPolicy, "")
+	defer func() {
+		event.After = auditJSON(map[string]any{"created": created})
+		receive.audit.Record(ctx, event, err)
+	}()
+	for _, route := range res.Missing {
+		id, err := receive.generateID.NextID()
+		if err != nil {
+			return nil, err
+		}
+		name := uniquePolicyName(catalogPolicyName(route.Method, route.Path), names)
+		names[name] = struct{}{}
+		policy := model.Policy{
+			ID:       id,
+			Name:     name,
+			Path:     route.Path,
+			Method:   route.Method,
+			Describe: fmt.Sprintf("自动生成: %s %s", route.Method, route.Path),
+			Effect:   helpers.PolicyEffect(""),
+		}
+		if err = receive.policyStore.Create(ctx, &policy); err != nil {
+			return nil, err
+		}
+		res.Created = append(res.Created, policy)
+		created = append(created, policy.Name)
+	}
+	return res, nil
+}
+
+// policyMatchRoutes 策略是否能匹配到路由, 路径或方法为 * 的策略总是有效
+func policyMatchRoutes(policy model.Policy, routes []schema.CatalogRoute) bool {
+	if policy.Path == "*" || policy.Method == "*" {
+		return true
+	}
+	for _, route := range routes {
+		if policyMatchRoute(policy, route) {
+			return true
+		}
+	}
+	return false
+}
+
+// routeHasPolicy 路由是否已有策略, 路径为 * 的策略不针对具体路由, 不算作路由的策略
+func routeHasPolicy(route schema.CatalogRoute, polices []model.Policy) bool {
+	for _, policy := range polices {
+		if policy.Path != "*" && policyMatchRoute(policy, route) {
+			return true
+		}
+	}
+	return false
+}
+
+// policyMatchRoute 与 casbin matcher 一致, 使用 keyMatch 和 keyMatch2 匹配路径
+func policyMatchRoute(policy model.Policy, route schema.CatalogRoute) bool {
+	if policy.Method != "*" && route.Method != policy.Method {
+		return false
+	}
+	return route.Path == policy.Path || util.KeyMatch(route.Path, policy.Path) || util.KeyMatch2(route.Path, policy.Path)
+}
+
+// uniquePolicyName 名称已存在时追加序号
+func uniquePolicyName(name string, names map[string]struct{}) string {
+	if _, ok := names[name]; !ok {
+		return name
+	}
+	for i := 2; ; i++ {
+		suffix := strconv.Itoa(i)
+		candidate := name
+		if len(candidate)+len(suffix) > policyNameMaxLen {
+			candidate = candidate[:policyNameMaxLen-len(suffix)]
+		}
+		candidate += suffix
+		if _, ok := names[candidate]; !ok {
+			return candidate
+		}
+	}
+}
+
+// catalogPolicyName 根据方法和路径生成策略名称, 路径参数以 By 开头, 如 GET /api/v1/tenants/:tenant/members 生成 getTenantsByTenantMembers
+func catalogPolicyName(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	path = strings.TrimPrefix(path, constant.ApiPrefix)
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" {
+			continue
+		}
+		// 区分 /users 和 /users/:id 这类只有参数不同的路由
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			b.WriteString("By")
+			segment = segment[1:]
+		}
+		upper := true
+		for _, r := range segment {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				upper = true
+				continue
+			}
+			if upper {
+				r = unicode.ToUpper(r)
+				upper = false
+			}
+			b.WriteRune(r)
+		}
+	}
+	name := b.String()
+	if len(name) > policyNameMaxLen {
+		name = name[:policyNameMaxLen]
+	}
+	return name
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"qqlx/base/apierr"
+	"qqlx/base/conf"
+	"qqlx/base/helpers"
+	"qqlx/base/interfaces"
+	"qqlx/base/logger"
+	"qqlx/base/reason"
+	"qqlx/base/tracing"
+	"strings"
+	"time"
+)
+
+const (
+	loginScopeAccount = "account"
+	loginScopeIP      = "ip"
+)
+
+// LoginGuardSVC 登录失败计数, 按账号和来源 IP 分别统计
+//
+// 账号连续失败后需要等待逐渐增加的时间才能再次尝试, 达到上限后账号或 IP 被临时锁定
+type LoginGuardSVC struct {
+	cache         interfaces.CacheInterface
+	maxFailures   int
+	maxIPFailures int
+	failureWindow time.Duration
+	lockoutTime   time.Duration
+	delayBase     time.Duration
+	maxDelay      time.Duration
+}
+
+func NewLoginGuardSVC(cache interfaces.CacheInterface) (*LoginGuardSVC, error) {
+	failureWindow, err := conf.GetLoginFailureWindow()
+	if err != nil {
+		return nil, err
+	}
+	lockoutTime, err := conf.GetLoginLockoutTime()
+	if err != nil {
+		return nil, err
+	}
+	delayBase, err := conf.GetLoginDelayBase()
+	if err != nil {
+		return nil, err
+	}
+	maxDelay, err := conf.GetLoginMaxDelay()
+	if err != nil {
+		return nil, err
+	}
+	return &LoginGuardSVC{
+		cache:         cache,
+		maxFailures:   conf.GetLoginMaxFailures(),
+		maxIPFailures: conf.GetLoginMaxIPFailures(),
+		failureWindow: failureWindow,
+		lockoutTime:   lockoutTime,
+		delayBase:     delayBase,
+		maxDelay:      maxDelay,
+	}, nil
+}
+
+// Check 登录前检查账号和 IP 是否被锁定或需要等待
+func (receive *LoginGuardSVC) Check(ctx context.Context, account, ip string) error {
+	ctx, span := tracing.Start(ctx, "LoginGuardSVC.Check")
+	defer span.End()
+	account = normalizeAccount(account)
+	locked, err := receive.cache.GetString(ctx, helpers.GetLoginLockedCacheKey(loginScopeAccount, account))
+	if err != nil {
+		return err
+	}
+	if locked != "" {
+		return apierr.TooManyRequests().Set(apierr.LockedErrCode, reason.ErrUserLocked.Error(), reason.ErrUserLocked)
+	}
+	if ip != "" {
+		locked, err = receive.cache.GetString(ctx, helpers.GetLoginLockedCacheKey(loginScopeIP, ip))
+		if err != nil {
+			return err
+		}
+		if locked != "" {
+			return apierr.TooManyRequests().Set(apierr.LockedErrCode, reason.ErrLoginIPLocked.Error(), reason.ErrLoginIPLocked)
+		}
+	}
+	delay, err := receive.cache.GetString(ctx, helpers.GetLoginDelayCacheKey(account))
+	if err != nil {
+		return err
+	}
+	if delay != "" {
+		return apierr.TooManyRequests().Set(apierr.AuthErrCode, reason.ErrLoginThrottled.Error(), reason.ErrLoginThrottled)
+	}
+	return nil
+}
+
+// Fail 记录一次登录失败, 达到上限时锁定
+func (receive *LoginGuardSVC) Fail(ctx context.Context, account, ip string) error {
+	ctx, span := tracing.Start(ctx, "LoginGuardSVC.Fail")
+	defer span.End()
+	account = normalizeAccount(account)
+	failures, err := receive.incr(ctx, helpers.GetLoginFailureCacheKey(loginScopeAccount, account))
+	if err != nil {
+		return err
+	}
+	if failures >= int64(receive.maxFailures) {
+		if err = receive.lock(ctx, loginScopeAccount, account); err != nil {
+			return err
+		}
+		logger.WithContext(ctx, true).Warnf("account locked after %d failed logins, account: %s, ip: %s, lockout: %s",
+			failures, account, ip, receive.lockoutTime)
+	} else if delay := receive.delay(failures); delay > 0 {
+		if err = receive.cache.SetString(ctx, helpers.GetLoginDelayCacheKey(account), "1", &delay); err != nil {
+			return err
+		}
+	}
+
+	if ip == "" {
+		return nil
+	}
+	failures, err = receive.incr(ctx, helpers.GetLoginFailureCacheKey(loginScopeIP, ip))
+	if err != nil {
+		return err
+	}
+	if failures >= int64(receive.maxIPFailures) {
+		if err = receive.lock(ctx, loginScopeIP, ip); err != nil {
+			return err
+		}
+		logger.WithContext(ctx, true).Warnf("ip locked after %d failed logins, ip: %s, lockout: %s", failures, ip, receive.lockoutTime)
+	}
+	return nil
+}
+
+// Succeed 登录成功后清空账号的失败计数, IP 计数在统计窗口结束后自动清空
+func (receive *LoginGuardSVC) Succeed(ctx context.Context, account string) error {
+	ctx, span := tracing.Start(ctx, "LoginGuardSVC.Succeed")
+	defer span.End()
+	account = normalizeAccount(account)
+	if err := receive.cache.Del(ctx, helpers.GetLoginFailureCacheKey(loginScopeAccount, account)); err != nil {
+		return err
+	}
+	return receive.cache.Del(ctx, helpers.GetLoginDelayCacheKey(account))
+}
+
+// Unlock 解除账号锁定, ip 不为空时同时解除该 IP 的锁定
+func (receive *LoginGuardSVC) Unlock(ctx context.Context, account, ip string) error {
+	ctx, span := tracing.Start(ctx, "LoginGuardSVC.Unlock")
+	defer span.End()
+	account = normalizeAccount(account)
+	keys := []string{
+		helpers.GetLoginLockedCacheKey(loginScopeAccount, account),
+		helpers.GetLoginFailureCacheKey(loginScopeAccount, account),
+		helpers.GetLoginDelayCacheKey(account),
+	}
+	if ip != "" {
+		keys = append(keys,
+			helpers.GetLoginLockedCacheKey(loginScopeIP, ip),
+			helpers.GetLoginFailureCacheKey(loginScopeIP, ip),
+		)
+	}
+	for _, key := range keys {
+		if err := receive.cache.Del(ctx, key); err != nil {
+			return err
+		}
+	}
+	logger.WithContext(ctx, true).Infof("login unlocked, account: %s, ip: %s", account, ip)
+	return nil
+}
+
+// incr 失败计数加一, 计数不存在时先带过期时间创建, 保证统计窗口一定生效
+func (receive *LoginGuardSVC) incr(ctx context.Context, key string) (int64, error) {
+	if _, err := receive.cache.SetNX(ctx, key, "0", &receive.failureWindow); err != nil {
+		return 0, err
+	}
+	n, err := receive.cache.Incr(ctx, key)
+	if err != nil {
+		return 0, apierr.InternalServer().Set(apierr.RedisErrCode, "redis incr failed", err)
+	}
+	return n, nil
+}
+
+func (receive *LoginGuardSVC) lock(ctx context.Context, scope, id string) error {
+	if err := receive.cache.SetString(ctx, helpers.GetLoginLockedCacheKey(scope, id), "1", &receive.lockoutTime); err != nil {
+		return err
+	}
+	return receive.cache.Del(ctx, helpers.GetLoginFailureCacheKey(scope, id))
+}
+
+// delay 第 n 次失败后需要等待的时间, 每次失败翻倍
+func (receive *LoginGuardSVC) delay(failures int64) time.Duration {
+	if receive.delayBase <= 0 || failures <= 0 {
+		return 0
+	}
+	delay := receive.delayBase
+	for i := int64(1); i < failures && delay < receive.maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, receive.maxDelay)
+}
+
+func normalizeAccount(account string) string {
+	return strings.ToLower(strings.TrimSpace(account))
+}
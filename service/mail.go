@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"qqlx/base/apierr"
+	"qqlx/base/conf"
+	"qqlx/base/helpers"
+	"qqlx/base/interfaces"
+	"qqlx/base/logger"
+	"qqlx/base/reason"
+	"qqlx/base/tracing"
+	"qqlx/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MailTokenPurposeVerify = "verify"
+	MailTokenPurposeReset  = "reset"
+)
+
+// MailSVC 发送邮箱验证和重置密码邮件, 管理邮件中的一次性 token
+//
+// token 格式为 id.signature, signature 为 id 的 HMAC 签名, 服务端只保存 id 的摘要
+type MailSVC struct {
+	cache        interfaces.CacheInterface
+	mailer       interfaces.Mailer
+	key          []byte
+	projectName  string
+	verifyURL    string
+	resetURL     string
+	verifyExpire time.Duration
+	resetExpire  time.Duration
+}
+
+func NewMailSVC(cache interfaces.CacheInterface, mailer interfaces.Mailer) (*MailSVC, error) {
+	key, err := conf.GetMailTokenKey()
+	if err != nil {
+		return nil, err
+	}
+	verifyExpire, err := conf.GetMailVerifyExpireTime()
+	if err != nil {
+		return nil, err
+	}
+	resetExpire, err := conf.GetMailResetExpireTime()
+	if err != nil {
+		return nil, err
+	}
+	return &MailSVC{
+		cache:        cache,
+		mailer:       mailer,
+		key:          []byte(key),
+		projectName:  conf.GetProjectName(),
+		verifyURL:    conf.GetMailVerifyURL(),
+		resetURL:     conf.GetMailResetURL(),
+		verifyExpire: verifyExpire,
+		resetExpire:  resetExpire,
+	}, nil
+}
+
+// SendVerifyMail 发送邮箱验证邮件
+func (receive *MailSVC) SendVerifyMail(ctx context.Context, user *model.User) error {
+	ctx, span := tracing.Start(ctx, "MailSVC.SendVerifyMail")
+	defer span.End()
+	token, err := receive.issueToken(ctx, MailTokenPurposeVerify, user.ID, receive.verifyExpire)
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf("%s 您好:\n\n请打开以下链接验证邮箱, 链接 %s 内有效:\n\n%s\n",
+		user.Name, receive.verifyExpire, receive.link(receive.verifyURL, token))
+	return receive.mailer.Send(ctx, []string{user.Email}, fmt.Sprintf("[%s] 验证邮箱", receive.projectName), body)
+}
+
+// SendResetMail 发送重置密码邮件
+func (receive *MailSVC) SendResetMail(ctx context.Context, user *model.User) error {
+	ctx, span := tracing.Start(ctx, "MailSVC.SendResetMail")
+	defer span.End()
+	token, err := receive.issueToken(ctx, MailTokenPurposeReset, user.ID, receive.resetExpire)
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf("%s 您好:\n\n请打开以下链接重置密码, 链接 %s 内有效, 如非本人操作请忽略:\n\n%s\n",
+		user.Name, receive.resetExpire, receive.link(receive.resetURL, token))
+	return receive.mailer.Send(ctx, []string{user.Email}, fmt.Sprintf("[%s] 重置密码", receive.projectName), body)
+}
+
+// VerifyToken 校验邮件 token, 返回对应的用户 ID, 不消费 token
+func (receive *MailSVC) VerifyToken(ctx context.Context, purpose, token string) (userID int, err error) {
+	ctx, span := tracing.Start(ctx, "MailSVC.VerifyToken")
+	defer span.End()
+	userID, _, err = receive.verifyToken(ctx, purpose, token)
+	return userID, err
+}
+
+// ConsumeToken 校验并消费邮件 token, 返回对应的用户 ID, 每个 token 只能使用一次
+func (receive *MailSVC) ConsumeToken(ctx context.Context, purpose, token string) (userID int, err error) {
+	ctx, span := tracing.Start(ctx, "MailSVC.ConsumeToken")
+	defer span.End()
+	userID, hash, err := receive.verifyToken(ctx, purpose, token)
+	if err != nil {
+		return 0, err
+	}
+
+	expire := receive.expire(purpose)
+	used, err := receive.cache.SetNX(ctx, helpers.GetMailTokenUsedCacheKey(purpose, hash), "1", &expire)
+	if err != nil {
+		return 0, err
+	}
+	if !used {
+		logger.WithContext(ctx, true).Warnf("mail token reused, purpose: %s, userID: %d", purpose, userID)
+		return 0, mailTokenInvalid()
+	}
+	_ = receive.cache.Del(ctx, helpers.GetMailTokenCacheKey(purpose, hash))
+	return userID, nil
+}
+
+// verifyToken 校验签名并查询 token 对应的用户 ID, hash 为 token 在缓存中的摘要
+func (receive *MailSVC) verifyToken(ctx context.Context, purpose, token string) (userID int, hash string, err error) {
+	id, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(receive.sign(purpose, id))) {
+		return 0, "", mailTokenInvalid()
+	}
+	hash = hashMailToken(id)
+	value, err := receive.cache.GetString(ctx, helpers.GetMailTokenCacheKey(purpose, hash))
+	if err != nil {
+		return 0, "", err
+	}
+	if value == "" {
+		return 0, "", mailTokenInvalid()
+	}
+	userID, err = strconv.Atoi(value)
+	if err != nil {
+		return 0, "", mailTokenInvalid()
+	}
+	return userID, hash, nil
+}
+
+func mailTokenInvalid() error {
+	return apierr.Unauthorized().Set(apierr.AuthErrCode, reason.ErrMailTokenInvalid.Error(), reason.ErrMailTokenInvalid)
+}
+
+func (receive *MailSVC) issueToken(ctx context.Context, purpose string, userID int, expire time.Duration) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", apierr.InternalServer().Set(apierr.ServiceErrCode, "failed to generate mail token", err)
+	}
+	id := base64.RawURLEncoding.EncodeToString(buf)
+	if err := receive.cache.SetString(ctx, helpers.GetMailTokenCacheKey(purpose, hashMailToken(id)), strconv.Itoa(userID), &expire); err != nil {
+		return "", err
+	}
+	return id + "." + receive.sign(purpose, id), nil
+}
+
+func (receive *MailSVC) sign(purpose, id string) string {
+	mac := hmac.New(sha256.New, receive.key)
+	mac.Write([]byte(purpose + ":" + id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (receive *MailSVC) expire(purpose string) time.Duration {
+	if purpose == MailTokenPurposeReset {
+		return receive.resetExpire
+	}
+	return receive.verifyExpire
+}
+
+// link 生成邮件中的链接, 模板中没有 %s 时将 token 追加到末尾
+func (receive *MailSVC) link(tpl, token string) string {
+	if !strings.Contains(tpl, "%s") {
+		return tpl + token
+	}
+	return fmt.Sprintf(tpl, token)
+}
+
+func hashMailToken(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
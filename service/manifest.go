@@ -0,0 +1,481 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"qqlx/base/apierr"
+	"qqlx/base/helpers"
+	"qqlx/base/interfaces"
+	"qqlx/base/logger"
+	"qqlx/base/reason"
+	"qqlx/base/tracing"
+	"qqlx/model"
+	"qqlx/schema"
+	"qqlx/store/rbac"
+	"qqlx/store/userstore"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// 配置变更的操作和对象
+const (
+	ManifestActionCreate = "create"
+	ManifestActionUpdate = "update"
+	ManifestActionDelete = "delete"
+
+	ManifestKindPolicy     = "policy"
+	ManifestKindRole       = "role"
+	ManifestKindRolePolicy = "rolePolicy"
+	ManifestKindRoleParent = "roleParent"
+	ManifestKindUserRole   = "userRole"
+)
+
+// ManifestSVC 导出和应用声明式的角色策略配置, 变更通过 PolicySVC、RoleSVC、UserSVC 执行,
+// 同步 casbin、ldap 并记录变更版本
+type ManifestSVC struct {
+	userStore   interfaces.UserStoreInterface
+	roleStore   interfaces.RoleStoreInterface
+	policyStore interfaces.PolicyStoreInterface
+	policy      *PolicySVC
+	role        *RoleSVC
+	user        *UserSVC
+}
+
+func NewManifestSVC(
+	userStore interfaces.UserStoreInterface,
+	roleStore interfaces.RoleStoreInterface,
+	policyStore interfaces.PolicyStoreInterface,
+	policy *PolicySVC,
+	role *RoleSVC,
+	user *UserSVC,
+) *ManifestSVC {
+	return &ManifestSVC{
+		userStore:   userStore,
+		roleStore:   roleStore,
+		policyStore: policyStore,
+		policy:      policy,
+		role:        role,
+		user:        user,
+	}
+}
+
+// manifestState 数据库中的当前配置
+type manifestState struct {
+	polices map[string]model.Policy
+	roles   map[string]model.Role
+	users   map[string]model.User
+}
+
+// load 查询当前配置, 配置按名称对应, 数据库中策略名称重复时无法确定对应关系, 直接拒绝
+func (receive *ManifestSVC) load(ctx context.Context) (state *manifestState, err error) {
+	_, polices, err := receive.policyStore.List(ctx, -1, -1, rbac.LoadRoles())
+	if err != nil {
+		return nil, err
+	}
+	_, roles, err := receive.roleStore.List(ctx, -1, -1, rbac.RoleTenantID(0), rbac.LoadPolices(), rbac.LoadParents())
+	if err != nil {
+		return nil, err
+	}
+	_, users, err := receive.userStore.List(ctx, -1, -1, userstore.LoadRoles())
+	if err != nil {
+		return nil, err
+	}
+	state = &manifestState{
+		polices: make(map[string]model.Policy, len(polices)),
+		roles:   make(map[string]model.Role, len(roles)),
+		users:   make(map[string]model.User, len(users)),
+	}
+	for _, policy := range polices {
+		if _, ok := state.polices[policy.Name]; ok {
+			msg := fmt.Sprintf("duplicate policy name in database: %s, rename it before export or apply", policy.Name)
+			return nil, apierr.BadRequest().Set(apierr.ServiceErrCode, msg, fmt.Errorf("%w: %s", reason.ErrManifestInvalid, msg))
+		}
+		state.polices[policy.Name] = policy
+	}
+	for _, role := range roles {
+		state.roles[role.Name] = role
+	}
+	for _, user := range users {
+		state.users[user.Name] = user
+	}
+	return state, nil
+}
+
+// Export 导出策略、全局角色、角色策略和用户角色
+func (receive *ManifestSVC) Export(ctx context.Context) (res *schema.RbacManifest, err error) {
+	ctx, span := tracing.Start(ctx, "ManifestSVC.Export")
+	defer span.End()
+	logger.WithContext(ctx, false).Debug("export rbac manifest")
+	state, err := receive.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res = &schema.RbacManifest{
+		Policies: make([]schema.ManifestPolicy, 0, len(state.polices)),
+		Roles:    make([]schema.ManifestRole, 0, len(state.roles)),
+		Users:    make([]schema.ManifestUser, 0),
+	}
+	for _, name := range sortedKeys(state.polices) {
+		policy := state.polices[name]
+		res.Policies = append(res.Policies, schema.ManifestPolicy{
+			Name:      policy.Name,
+			Path:      policy.Path,
+			Method:    policy.Method,
+			Describe:  policy.Describe,
+			Effect:    helpers.PolicyEffect(policy.Effect),
+			Condition: policy.Condition,
+		})
+	}
+	for _, name := range sortedKeys(state.roles) {
+		role := state.roles[name]
+		res.Roles = append(res.Roles, schema.ManifestRole{
+			Name:        role.Name,
+			Description: role.Description,
+			Parents:     roleNames(role.Parents),
+			Policies:    policyNames(role.Policys),
+		})
+	}
+	for _, name := range sortedKeys(state.users) {
+		roles := globalRoleNames(state.users[name].Roles)
+		if len(roles) == 0 {
+			continue
+		}
+		res.Users = append(res.Users, schema.ManifestUser{Name: name, Roles: roles})
+	}
+	return res, nil
+}
+
+// manifestStep 一项变更及其执行方法
+type manifestStep struct {
+	change schema.RbacChange
+	apply  func(ctx context.Context) error
+}
+
+// Apply 将数据库调整为与配置一致, dryRun 时只返回变更计划, prune 时删除配置中不存在的对象
+func (receive *ManifestSVC) Apply(ctx context.Context, manifest *schema.RbacManifest, dryRun, prune bool) (res *schema.RbacPlanResponse, err error) {
+	ctx, span := tracing.Start(ctx, "ManifestSVC.Apply")
+	defer span.End()
+	logger.WithContext(ctx, true).Debugf("apply rbac manifest, dryRun: %v, prune: %v", dryRun, prune)
+	if err = validManifest(manifest); err != nil {
+		return nil, err
+	}
+	state, err := receive.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	steps, err := receive.plan(state, manifest, prune)
+	if err != nil {
+		return nil, err
+	}
+	res = &schema.RbacPlanResponse{DryRun: dryRun, Prune: prune, Changes: make([]schema.RbacChange, 0, len(steps))}
+	for _, step := range steps {
+		res.Changes = append(res.Changes, step.change)
+	}
+	if dryRun {
+		return res, nil
+	}
+	for _, step := range steps {
+		if err = step.apply(ctx); err != nil {
+			logger.WithContext(ctx, true).Errorf("apply %s %s %s failed: %v", step.change.Action, step.change.Kind, step.change.Name, err)
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// validManifest 校验名称不能为空且不能重复, 引用的策略和角色需要在配置中
+func validManifest(manifest *schema.RbacManifest) error {
+	invalid := func(format string, args ...any) error {
+		msg := fmt.Sprintf(format, args...)
+		return apierr.BadRequest().Set(apierr.ServiceErrCode, msg, fmt.Errorf("%w: %s", reason.ErrManifestInvalid, msg))
+	}
+	polices := make(map[string]struct{}, len(manifest.Policies))
+	for _, policy := range manifest.Policies {
+		if policy.Name == "" || policy.Path == "" || policy.Method == "" {
+			return invalid("policy name, path and method are required")
+		}
+		if _, ok := polices[policy.Name]; ok {
+			return invalid("duplicate policy: %s", policy.Name)
+		}
+		polices[policy.Name] = struct{}{}
+	}
+	roles := make(map[string]struct{}, len(manifest.Roles))
+	for _, role := range manifest.Roles {
+		if role.Name == "" {
+			return invalid("role name is required")
+		}
+		if _, ok := roles[role.Name]; ok {
+			return invalid("duplicate role: %s", role.Name)
+		}
+		roles[role.Name] = struct{}{}
+	}
+	for _, role := range manifest.Roles {
+		for _, name := range role.Policies {
+			if _, ok := polices[name]; !ok {
+				return invalid("role %s references unknown policy: %s", role.Name, name)
+			}
+		}
+		for _, name := range role.Parents {
+			if _, ok := roles[name]; !ok {
+				return invalid("role %s references unknown parent: %s", role.Name, name)
+			}
+		}
+	}
+	users := make(map[string]struct{}, len(manifest.Users))
+	for _, user := range manifest.Users {
+		if _, ok := users[user.Name]; ok || user.Name == "" {
+			return invalid("user name is required and must be unique: %q", user.Name)
+		}
+		users[user.Name] = struct{}{}
+		for _, name := range user.Roles {
+			if _, ok := roles[name]; !ok {
+				return invalid("user %s references unknown role: %s", user.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// plan 按策略、角色、继承关系、角色策略、用户角色的顺序生成变更, 删除放在最后
+func (receive *ManifestSVC) plan(state *manifestState, manifest *schema.RbacManifest, prune bool) (steps []manifestStep, err error) {
+	add := func(action, kind, name, detail string, apply func(ctx context.Context) error) {
+		steps = append(steps, manifestStep{
+			change: schema.RbacChange{Action: action, Kind: kind, Name: name, Detail: detail},
+			apply:  apply,
+		})
+	}
+
+	for _, item := range manifest.Policies {
+		current, ok := state.polices[item.Name]
+		if !ok {
+			add(ManifestActionCreate, ManifestKindPolicy, item.Name, item.Method+" "+item.Path, func(ctx context.Context) error {
+				return receive.policy.CreatePolicy(ctx, &schema.PolicyCreateRequest{
+					Name:      item.Name,
+					Path:      item.Path,
+					Method:    item.Method,
+					Describe:  item.Describe,
+					Effect:    item.Effect,
+					Condition: item.Condition,
+				})
+			})
+			continue
+		}
+		if current.Path != item.Path || current.Method != item.Method {
+			msg := fmt.Sprintf("policy %s path or method cannot be changed, use a new name", item.Name)
+			return nil, apierr.BadRequest().Set(apierr.ServiceErrCode, msg, fmt.Errorf("%w: %s", reason.ErrManifestInvalid, msg))
+		}
+		effect := helpers.PolicyEffect(item.Effect)
+		if current.Describe != item.Describe || helpers.PolicyEffect(current.Effect) != effect || current.Condition != item.Condition {
+			add(ManifestActionUpdate, ManifestKindPolicy, item.Name, "describe, effect or condition", func(ctx context.Context) error {
+				condition := item.Condition
+				return receive.policy.UpdatePolicy(ctx, &schema.PolicyUpdateRequest{
+					ID:        current.ID,
+					Describe:  item.Describe,
+					Effect:    effect,
+					Condition: &condition,
+				})
+			})
+		}
+	}
+
+	for _, item := range manifest.Roles {
+		current, ok := state.roles[item.Name]
+		if !ok {
+			add(ManifestActionCreate, ManifestKindRole, item.Name, "", func(ctx context.Context) error {
+				return receive.role.CreateRole(ctx, &schema.RoleCreateRequest{Name: item.Name, Describe: item.Description})
+			})
+		} else if current.Description != item.Description {
+			add(ManifestActionUpdate, ManifestKindRole, item.Name, "description", func(ctx context.Context) error {
+				return receive.role.UpdateRoleDesc(ctx, &schema.RoleUpdateRequest{ID: current.ID, Describe: item.Description})
+			})
+		}
+	}
+
+	for _, item := range manifest.Roles {
+		current := state.roles[item.Name]
+		parents := roleNames(current.Parents)
+		added, removed := diffNames(parents, item.Parents)
+		if len(added) == 0 && (len(removed) == 0 || !prune) {
+			continue
+		}
+		// 父角色整体替换, 不删除时保留原有的父角色
+		want := slices.Clone(item.Parents)
+		if !prune {
+			want = append(want, removed...)
+		}
+		add(ManifestActionUpdate, ManifestKindRoleParent, item.Name, strings.Join(want, ","), func(ctx context.Context) error {
+			role, err := receive.roleStore.Query(ctx, rbac.RoleName(item.Name), rbac.RoleTenantID(0))
+			if err != nil {
+				return err
+			}
+			var parents []model.Role
+			if len(want) > 0 {
+				if _, parents, err = receive.roleStore.List(ctx, -1, -1, rbac.RoleNames(want), rbac.RoleTenantID(0)); err != nil {
+					return err
+				}
+			}
+			return receive.role.UpdateParents(ctx, &schema.RoleParentRequest{ID: role.ID, ParentIDs: helpers.GetIDs(parents)})
+		})
+	}
+
+	for _, item := range manifest.Roles {
+		added, removed := diffNames(policyNames(state.roles[item.Name].Policys), item.Policies)
+		if len(added) > 0 {
+			add(ManifestActionCreate, ManifestKindRolePolicy, item.Name, strings.Join(added, ","), func(ctx context.Context) error {
+				return receive.updateRolePolicy(ctx, item.Name, added, receive.role.AddByPolicy)
+			})
+		}
+		if len(removed) > 0 && prune {
+			add(ManifestActionDelete, ManifestKindRolePolicy, item.Name, strings.Join(removed, ","), func(ctx context.Context) error {
+				return receive.updateRolePolicy(ctx, item.Name, removed, receive.role.DeleteByPolicy)
+			})
+		}
+	}
+
+	for _, item := range manifest.Users {
+		current, ok := state.users[item.Name]
+		if !ok {
+			msg := fmt.Sprintf("user %s does not exist", item.Name)
+			return nil, apierr.BadRequest().Set(apierr.ServiceErrCode, msg, fmt.Errorf("%w: %s", reason.ErrManifestInvalid, msg))
+		}
+		added, removed := diffNames(globalRoleNames(current.Roles), item.Roles)
+		if len(added) > 0 {
+			add(ManifestActionCreate, ManifestKindUserRole, item.Name, strings.Join(added, ","), func(ctx context.Context) error {
+				return receive.user.UserAddRole(ctx, &schema.UserUpdateRoleRequest{ID: current.ID, RoleNames: added})
+			})
+		}
+		if len(removed) > 0 && prune {
+			add(ManifestActionDelete, ManifestKindUserRole, item.Name, strings.Join(removed, ","), func(ctx context.Context) error {
+				return receive.user.UserRemoveRole(ctx, &schema.UserUpdateRoleRequest{ID: current.ID, RoleNames: removed})
+			})
+		}
+	}
+	if !prune {
+		return steps, nil
+	}
+
+	wantRoles := make([]string, 0, len(manifest.Roles))
+	for _, item := range manifest.Roles {
+		wantRoles = append(wantRoles, item.Name)
+	}
+	for _, name := range pruneRoleOrder(state.roles, wantRoles) {
+		role := state.roles[name]
+		add(ManifestActionDelete, ManifestKindRole, name, "", func(ctx context.Context) error {
+			return receive.role.DeleteRole(ctx, &schema.RoleIDRequest{ID: role.ID})
+		})
+	}
+	wantPolices := make(map[string]struct{}, len(manifest.Policies))
+	for _, item := range manifest.Policies {
+		wantPolices[item.Name] = struct{}{}
+	}
+	for _, name := range sortedKeys(state.polices) {
+		if _, ok := wantPolices[name]; ok {
+			continue
+		}
+		policy := state.polices[name]
+		// 配置只包含全局角色, 租户角色使用的策略不删除
+		if slices.ContainsFunc(policy.Roles, func(role model.Role) bool { return role.TenantID != 0 }) {
+			continue
+		}
+		add(ManifestActionDelete, ManifestKindPolicy, name, policy.Method+" "+policy.Path, func(ctx context.Context) error {
+			return receive.policy.DeletePolicy(ctx, &schema.PolicyIDRequest{ID: policy.ID})
+		})
+	}
+	return steps, nil
+}
+
+// updateRolePolicy 执行时根据名称查询角色和策略, 角色可能在同一次应用中创建
+func (receive *ManifestSVC) updateRolePolicy(ctx context.Context, roleName string, polices []string, update func(context.Context, *schema.RolePolicyRequest) error) error {
+	role, err := receive.roleStore.Query(ctx, rbac.RoleName(roleName), rbac.RoleTenantID(0))
+	if err != nil {
+		return err
+	}
+	_, list, err := receive.policyStore.List(ctx, -1, -1, rbac.InPolicyNames(polices))
+	if err != nil {
+		return err
+	}
+	return update(ctx, &schema.RolePolicyRequest{ID: role.ID, PolicyIds: helpers.GetIDs(list)})
+}
+
+// pruneRoleOrder 需要删除的角色, 被继承的角色排在子角色之后
+func pruneRoleOrder(roles map[string]model.Role, want []string) []string {
+	remaining := make(map[string]struct{})
+	for name := range roles {
+		if !slices.Contains(want, name) {
+			remaining[name] = struct{}{}
+		}
+	}
+	order := make([]string, 0, len(remaining))
+	for len(remaining) > 0 {
+		next := make([]string, 0)
+		for name := range remaining {
+			inherited := false
+			for child := range remaining {
+				if slices.Contains(roleNames(roles[child].Parents), name) {
+					inherited = true
+					break
+				}
+			}
+			if !inherited {
+				next = append(next, name)
+			}
+		}
+		// 存在环时按名称顺序删除, 由 DeleteRole 返回错误
+		if len(next) == 0 {
+			for name := range remaining {
+				next = append(next, name)
+			}
+		}
+		sort.Strings(next)
+		for _, name := range next {
+			order = append(order, name)
+			delete(remaining, name)
+		}
+	}
+	return order
+}
+
+// diffNames 返回 want 中新增的和 current 中多余的名称
+func diffNames(current, want []string) (added, removed []string) {
+	return helpers.FindMissing(current, helpers.Deduplicate(want)), helpers.FindMissing(want, current)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func roleNames(roles []model.Role) []string {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// globalRoleNames 用户的全局角色, 租户角色通过租户成员分配
+func globalRoleNames(roles []model.Role) []string {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		if role.TenantID == 0 {
+			names = append(names, role.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func policyNames(polices []model.Policy) []string {
+	names := make([]string, 0, len(polices))
+	for _, policy := range polices {
+		names = append(names, policy.Name)
+	}
+	sort.Strings(names)
+	return names
+}
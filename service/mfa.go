@@ -0,0 +1,401 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"qqlx/base/apierr"
+	"qqlx/base/conf"
+	"qqlx/base/constant"
+	"qqlx/base/helpers"
+	"qqlx/base/interfaces"
+	"qqlx/base/logger"
+	"qqlx/base/reason"
+	"qqlx/base/tracing"
+	"qqlx/model"
+	"qqlx/pkg/totp"
+	"qqlx/schema"
+	"qqlx/store/cache"
+	"qqlx/store/userstore"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// mfaTicket 密码校验通过后签发的 mfa 登录票据
+type mfaTicket struct {
+	UserID int `json:"userId"`
+	// Account 登录失败计数使用的账号, 验证码错误计入账号的登录失败
+	Account string `json:"account"`
+	// Enroll 用户被要求启用 MFA 但尚未绑定, 票据可用于绑定
+	Enroll bool `json:"enroll"`
+	// Oidc 通过 OIDC 登录签发的票据, 完成登录时不检查本地密码
+	Oidc      bool  `json:"oidc,omitempty"`
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+type MfaSVC struct {
+	userStore    interfaces.UserStoreInterface
+	cache        interfaces.CacheInterface
+	issuer       string
+	requireAdmin bool
+	guard        *LoginGuardSVC
+	audit        *AuditSVC
+}
+
+func NewMfaSVC(userStore interfaces.UserStoreInterface, cache interfaces.CacheInterface, guard *LoginGuardSVC, audit *AuditSVC) *MfaSVC {
+	return &MfaSVC{
+		userStore:    userStore,
+		cache:        cache,
+		guard:        guard,
+		audit:        audit,
+		issuer:       conf.GetMfaIssuer(),
+		requireAdmin: conf.GetMfaRequireAdmin(),
+	}
+}
+
+// Required 判断用户登录是否需要 MFA, enroll 为 true 表示需要先绑定
+func (receive *MfaSVC) Required(user *model.User) (required, enroll bool) {
+	if user.MfaEnable {
+		return true, false
+	}
+	if receive.requireAdmin && user.Name == "admin" {
+		return true, true
+	}
+	return false, false
+}
+
+// NewTicket 签发 mfa 登录票据
+func (receive *MfaSVC) NewTicket(ctx context.Context, user *model.User, enroll bool) (*schema.UserLoginResponse, error) {
+	ctx, span := tracing.Start(ctx, "MfaSVC.NewTicket")
+	defer span.End()
+	return receive.newTicket(ctx, &mfaTicket{UserID: user.ID, Account: user.Email, Enroll: enroll})
+}
+
+// NewOidcTicket 签发 OIDC 登录的 mfa 票据
+func (receive *MfaSVC) NewOidcTicket(ctx context.Context, user *model.User, enroll bool) (*schema.UserLoginResponse, error) {
+	ctx, span := tracing.Start(ctx, "MfaSVC.NewOidcTicket")
+	defer span.End()
+	return receive.newTicket(ctx, &mfaTicket{UserID: user.ID, Account: user.Email, Enroll: enroll, Oidc: true})
+}
+
+// newTicket 保存票据, 错误次数单独计数, 与票据同时过期
+func (receive *MfaSVC) newTicket(ctx context.Context, value *mfaTicket) (*schema.UserLoginResponse, error) {
+	ticket, err := randomCode(24)
+	if err != nil {
+		return nil, err
+	}
+	expire, _ := time.ParseDuration(constant.MfaTicketExpireTime)
+	value.ExpiresAt = time.Now().Add(expire).Unix()
+	if err = receive.saveTicket(ctx, ticket, value); err != nil {
+		return nil, err
+	}
+	if err = receive.cache.SetString(ctx, helpers.GetMfaTicketAttemptsCacheKey(ticket), "0", &expire); err != nil {
+		return nil, err
+	}
+	return &schema.UserLoginResponse{
+		MfaRequired:       true,
+		MfaTicket:         ticket,
+		MfaEnrollRequired: value.Enroll,
+	}, nil
+}
+
+// Enroll 生成 TOTP 密钥, 确认验证码之前不会生效
+func (receive *MfaSVC) Enroll(ctx context.Context, userID int) (*schema.MfaEnrollResponse, error) {
+	ctx, span := tracing.Start(ctx, "MfaSVC.Enroll")
+	defer span.End()
+	user, err := receive.queryUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MfaEnable {
+		return nil, apierr.BadRequest().Set(apierr.ServiceErrCode, reason.ErrMfaAlreadyEnabled.Error(), reason.ErrMfaAlreadyEnabled)
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, apierr.InternalServer().Set(apierr.ServiceErrCode, "failed to enroll mfa", err)
+	}
+	expire, _ := time.ParseDuration(constant.MfaEnrollExpireTime)
+	if err = receive.cache.SetString(ctx, helpers.GetMfaEnrollCacheKey(user.ID), secret, &expire); err != nil {
+		return nil, err
+	}
+	return &schema.MfaEnrollResponse{
+		Secret: secret,
+		URI:    totp.URI(receive.issuer, user.Email, secret),
+	}, nil
+}
+
+// EnrollWithTicket 使用登录票据绑定 MFA, 用于被要求启用 MFA 但尚未绑定的用户
+func (receive *MfaSVC) EnrollWithTicket(ctx context.Context, req *schema.MfaTicketRequest) (*schema.MfaEnrollResponse, error) {
+	ctx, span := tracing.Start(ctx, "MfaSVC.EnrollWithTicket")
+	defer span.End()
+	ticket, err := receive.loadTicket(ctx, req.Ticket)
+	if err != nil {
+		return nil, err
+	}
+	if !ticket.Enroll {
+		return nil, apierr.Unauthorized().Set(apierr.AuthErrCode, reason.ErrMfaTicketInvalid.Error(), reason.ErrMfaTicketInvalid)
+	}
+	return receive.Enroll(ctx, ticket.UserID)
+}
+
+// Confirm 校验验证码后启用 MFA, 返回一次性恢复码
+func (receive *MfaSVC) Confirm(ctx context.Context, req *schema.MfaConfirmRequest) (*schema.MfaConfirmResponse, error) {
+	ctx, span := tracing.Start(ctx, "MfaSVC.Confirm")
+	defer span.End()
+	key := helpers.GetMfaEnrollCacheKey(req.ID)
+	secret, err := receive.cache.GetString(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if secret == "" {
+		return nil, apierr.BadRequest().Set(apierr.ServiceErrCode, reason.ErrMfaNotEnrolled.Error(), reason.ErrMfaNotEnrolled)
+	}
+	step, ok := totp.Validate(secret, req.Code, time.Now())
+	if !ok {
+		return nil, apierr.Unauthorized().Set(apierr.AuthErrCode, reason.ErrMfaCodeInvalid.Error(), reason.ErrMfaCodeInvalid)
+	}
+	user, err := receive.queryUser(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MfaEnable {
+		return nil, apierr.BadRequest().Set(apierr.ServiceErrCode, reason.ErrMfaAlreadyEnabled.Error(), reason.ErrMfaAlreadyEnabled)
+	}
+	if _, err = receive.markUsed(ctx, user.ID, step); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, constant.MfaRecoveryCodeCount)
+	hashes := make([]string, 0, constant.MfaRecoveryCodeCount)
+	for i := 0; i < constant.MfaRecoveryCodeCount; i++ {
+		code, err := randomCode(10)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	user.MfaEnable = true
+	user.MfaSecret = secret
+	user.MfaRecoveryCodes = strings.Join(hashes, ",")
+	if err = receive.userStore.Save(ctx, user); err != nil {
+		return nil, err
+	}
+	_ = receive.cache.Del(ctx, key)
+	logger.WithContext(ctx, true).Infof("user enable mfa, userName: %s", user.Name)
+	return &schema.MfaConfirmResponse{RecoveryCodes: codes}, nil
+}
+
+// VerifyTicket 校验登录票据和验证码, 成功后票据失效
+//
+// 绑定票据使用验证码确认绑定, 此时返回恢复码; oidcLogin 表示票据由 OIDC 登录签发
+func (receive *MfaSVC) VerifyTicket(ctx context.Context, req *schema.MfaLoginRequest) (user *model.User, recoveryCodes []string, oidcLogin bool, err error) {
+	ctx, span := tracing.Start(ctx, "MfaSVC.VerifyTicket")
+	defer span.End()
+	key := helpers.GetMfaTicketCacheKey(req.Ticket)
+	attemptsKey := helpers.GetMfaTicketAttemptsCacheKey(req.Ticket)
+	ticket, err := receive.loadTicket(ctx, req.Ticket)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	// 账号被锁定时不再校验验证码, 避免通过重新申请票据绕过错误次数上限
+	if receive.guard != nil {
+		if err = receive.guard.Check(ctx, ticket.Account, req.IP); err != nil {
+			return nil, nil, false, err
+		}
+	}
+	// 校验前原子计数, 并发请求也不会超过错误次数上限
+	attempts, err := receive.cache.Incr(ctx, attemptsKey)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if attempts > constant.MfaTicketMaxAttempts {
+		receive.dropTicket(ctx, req.Ticket)
+		return nil, nil, false, apierr.Unauthorized().Set(apierr.AuthErrCode, reason.ErrMfaTicketInvalid.Error(), reason.ErrMfaTicketInvalid)
+	}
+
+	var ok bool
+	if ticket.Enroll {
+		res, confirmErr := receive.Confirm(ctx, &schema.MfaConfirmRequest{ID: ticket.UserID, Code: req.Code})
+		if confirmErr != nil && !errors.Is(confirmErr, reason.ErrMfaCodeInvalid) {
+			return nil, nil, false, confirmErr
+		}
+		if ok = confirmErr == nil; ok {
+			recoveryCodes = res.RecoveryCodes
+		}
+	} else {
+		if user, err = receive.queryUser(ctx, ticket.UserID); err != nil {
+			return nil, nil, false, err
+		}
+		if ok, err = receive.verifyCode(ctx, user, req.Code); err != nil {
+			return nil, nil, false, err
+		}
+	}
+
+	if !ok {
+		// 错误次数达到上限后票据失效, 需要重新进行密码登录
+		if attempts >= constant.MfaTicketMaxAttempts {
+			logger.WithContext(ctx, true).Warnf("mfa ticket attempts exceeded, userID: %d", ticket.UserID)
+			receive.dropTicket(ctx, req.Ticket)
+		}
+		if receive.guard != nil {
+			if failErr := receive.guard.Fail(ctx, ticket.Account, req.IP); failErr != nil {
+				logger.WithContext(ctx, true).Errorf("record mfa failure failed, userID: %d, err: %v", ticket.UserID, failErr)
+			}
+		}
+		return nil, nil, false, apierr.Unauthorized().Set(apierr.AuthErrCode, reason.ErrMfaCodeInvalid.Error(), reason.ErrMfaCodeInvalid)
+	}
+	// 第二因素校验通过后才清空账号的失败计数
+	if receive.guard != nil {
+		if err = receive.guard.Succeed(ctx, ticket.Account); err != nil {
+			return nil, nil, false, err
+		}
+	}
+
+	if err = receive.cache.Del(ctx, key); err != nil {
+		return nil, nil, false, err
+	}
+	_ = receive.cache.Del(ctx, attemptsKey)
+	user, err = receive.userStore.Query(ctx, userstore.ID(ticket.UserID), userstore.LoadRoles())
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return user, recoveryCodes, ticket.Oidc, nil
+}
+
+// Reset 管理员重置用户 MFA, 用户下次登录时可重新绑定
+func (receive *MfaSVC) Reset(ctx context.Context, req *schema.UserQueryRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "MfaSVC.Reset")
+	defer span.End()
+	event := newAuditEvent(constant.AuditUserResetMfa, constant.AuditTargetUser, req.ID)
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	user, err := receive.queryUser(ctx, req.ID)
+	if err != nil {
+		return err
+	}
+	event.Before = auditJSON(map[string]any{"mfaEnable": user.MfaEnable})
+	event.After = auditJSON(map[string]any{"mfaEnable": false})
+	user.MfaEnable = false
+	user.MfaSecret = ""
+	user.MfaRecoveryCodes = ""
+	if err = receive.userStore.Save(ctx, user); err != nil {
+		return err
+	}
+	_ = receive.cache.Del(ctx, helpers.GetMfaEnrollCacheKey(user.ID))
+	logger.WithContext(ctx, true).Infof("reset user mfa, userName: %s", user.Name)
+	return nil
+}
+
+// verifyCode 校验 TOTP 验证码或恢复码, 恢复码使用后移除
+func (receive *MfaSVC) verifyCode(ctx context.Context, user *model.User, code string) (bool, error) {
+	if step, ok := totp.Validate(user.MfaSecret, code, time.Now()); ok {
+		return receive.markUsed(ctx, user.ID, step)
+	}
+
+	hash := hashRecoveryCode(code)
+	for _, h := range strings.Split(user.MfaRecoveryCodes, ",") {
+		if h == "" || subtle.ConstantTimeCompare([]byte(h), []byte(hash)) != 1 {
+			continue
+		}
+		// 并发请求使用同一恢复码时只有一个能标记成功
+		ok, err := receive.cache.SetNX(ctx, helpers.GetMfaRecoveryUsedCacheKey(user.ID, hash), "1", &cache.NeverExpires)
+		if err != nil || !ok {
+			return false, err
+		}
+		if err = receive.removeRecoveryCode(ctx, user.ID, hash); err != nil {
+			return false, err
+		}
+		logger.WithContext(ctx, true).Infof("user login with mfa recovery code, userName: %s", user.Name)
+		return true, nil
+	}
+	return false, nil
+}
+
+// removeRecoveryCode 重新查询用户后移除恢复码, 避免覆盖并发请求移除的其他恢复码
+func (receive *MfaSVC) removeRecoveryCode(ctx context.Context, userID int, hash string) error {
+	user, err := receive.queryUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	hashes := strings.Split(user.MfaRecoveryCodes, ",")
+	remain := make([]string, 0, len(hashes))
+	for _, h := range hashes {
+		if h != "" && h != hash {
+			remain = append(remain, h)
+		}
+	}
+	user.MfaRecoveryCodes = strings.Join(remain, ",")
+	return receive.userStore.Save(ctx, user)
+}
+
+// markUsed 标记 TOTP 周期已使用, 同一验证码不能重复使用
+func (receive *MfaSVC) markUsed(ctx context.Context, userID int, step int64) (bool, error) {
+	expire := time.Duration(totp.Period*(2*totp.Skew+1)) * time.Second
+	return receive.cache.SetNX(ctx, helpers.GetMfaUsedCacheKey(userID, step), "1", &expire)
+}
+
+// dropTicket 删除票据和错误计数
+func (receive *MfaSVC) dropTicket(ctx context.Context, ticket string) {
+	_ = receive.cache.Del(ctx, helpers.GetMfaTicketCacheKey(ticket))
+	_ = receive.cache.Del(ctx, helpers.GetMfaTicketAttemptsCacheKey(ticket))
+}
+
+func (receive *MfaSVC) loadTicket(ctx context.Context, ticket string) (*mfaTicket, error) {
+	value, err := receive.cache.GetString(ctx, helpers.GetMfaTicketCacheKey(ticket))
+	if err != nil {
+		return nil, err
+	}
+	if value == "" {
+		return nil, apierr.Unauthorized().Set(apierr.AuthErrCode, reason.ErrMfaTicketInvalid.Error(), reason.ErrMfaTicketInvalid)
+	}
+	res := &mfaTicket{}
+	if err = json.Unmarshal([]byte(value), res); err != nil {
+		return nil, apierr.Unauthorized().Set(apierr.AuthErrCode, reason.ErrMfaTicketInvalid.Error(), err)
+	}
+	return res, nil
+}
+
+func (receive *MfaSVC) saveTicket(ctx context.Context, ticket string, value *mfaTicket) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return apierr.InternalServer().Set(apierr.ServiceErrCode, "failed to save mfa ticket", err)
+	}
+	expire := time.Until(time.Unix(value.ExpiresAt, 0))
+	if expire <= 0 {
+		return apierr.Unauthorized().Set(apierr.AuthErrCode, reason.ErrMfaTicketInvalid.Error(), reason.ErrMfaTicketInvalid)
+	}
+	return receive.cache.SetString(ctx, helpers.GetMfaTicketCacheKey(ticket), string(data), &expire)
+}
+
+func (receive *MfaSVC) queryUser(ctx context.Context, userID int) (*model.User, error) {
+	user, err := receive.userStore.Query(ctx, userstore.ID(userID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierr.InternalServer().Set(apierr.ServiceErrCode, "user not found", reason.ErrUserNotFound)
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomCode 生成小写 base32 随机字符串
+func randomCode(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", apierr.InternalServer().Set(apierr.ServiceErrCode, "failed to generate random code", err)
+	}
+	code := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf)
+	return strings.ToLower(code[:size]), nil
+}
@@ -0,0 +1,364 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"qqlx/base/apierr"
+	"qqlx/base/conf"
+	"qqlx/base/constant"
+	"qqlx/base/helpers"
+	"qqlx/base/interfaces"
+	"qqlx/base/logger"
+	"qqlx/base/reason"
+	"qqlx/base/tracing"
+	"qqlx/model"
+	"qqlx/pkg/oidc"
+	"qqlx/schema"
+	"qqlx/store/userstore"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// oidcNameAttempts 自动创建用户时用户名冲突的最大尝试次数
+const oidcNameAttempts = 100
+
+// oidcState 登录时保存的 state 信息, 回调时校验
+type oidcState struct {
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	// LinkUserID 绑定流程发起的用户, 为 0 表示普通登录
+	LinkUserID int `json:"linkUserId,omitempty"`
+}
+
+type OidcSVC struct {
+	client        *oidc.Client
+	cache         interfaces.CacheInterface
+	userStore     interfaces.UserStoreInterface
+	userSvc       *UserSVC
+	token         *TokenSVC
+	mfa           *MfaSVC
+	audit         *AuditSVC
+	autoProvision bool
+	// groupRoles 组名到角色名的映射
+	groupRoles map[string][]string
+}
+
+func NewOidcSVC(client *oidc.Client, cache interfaces.CacheInterface, userStore interfaces.UserStoreInterface, userSvc *UserSVC, token *TokenSVC, mfa *MfaSVC, audit *AuditSVC) (*OidcSVC, error) {
+	groupRoles, err := conf.GetOidcGroupRoles()
+	if err != nil {
+		return nil, err
+	}
+	mapping := make(map[string][]string, len(groupRoles))
+	for _, gr := range groupRoles {
+		mapping[gr.Group] = append(mapping[gr.Group], gr.Role)
+	}
+	return &OidcSVC{
+		client:        client,
+		cache:         cache,
+		userStore:     userStore,
+		userSvc:       userSvc,
+		token:         token,
+		mfa:           mfa,
+		audit:         audit,
+		autoProvision: conf.GetOidcAutoProvision(),
+		groupRoles:    mapping,
+	}, nil
+}
+
+// Login 生成授权地址, state nonce 和 PKCE verifier 保存在缓存中
+func (receive *OidcSVC) Login(ctx context.Context) (res *schema.OidcLoginResponse, err error) {
+	ctx, span := tracing.Start(ctx, "OidcSVC.Login")
+	defer span.End()
+	return receive.authorize(ctx, 0)
+}
+
+// Link 已登录用户绑定 OIDC 身份, 回调时将 subject 绑定到该用户
+func (receive *OidcSVC) Link(ctx context.Context, userID int) (res *schema.OidcLoginResponse, err error) {
+	ctx, span := tracing.Start(ctx, "OidcSVC.Link")
+	defer span.End()
+	if apiKeyCaller(ctx) {
+		return nil, apierr.Forbidden().Set(apierr.AuthErrCode, reason.ErrApiKeyCaller.Error(), reason.ErrApiKeyCaller)
+	}
+	return receive.authorize(ctx, userID)
+}
+
+// authorize 保存 state 并生成授权地址, linkUserID 不为 0 时回调执行绑定
+func (receive *OidcSVC) authorize(ctx context.Context, linkUserID int) (*schema.OidcLoginResponse, error) {
+	if receive.client == nil {
+		return nil, apierr.BadRequest().Set(apierr.AuthErrCode, reason.ErrOidcDisabled.Error(), reason.ErrOidcDisabled)
+	}
+	state, nonce, verifier, err := oidc.NewState()
+	if err != nil {
+		return nil, err
+	}
+	value, err := json.Marshal(&oidcState{Nonce: nonce, Verifier: verifier, LinkUserID: linkUserID})
+	if err != nil {
+		return nil, apierr.InternalServer().Set(apierr.ServiceErrCode, "failed to save oidc state", err)
+	}
+	expire, _ := time.ParseDuration(constant.OidcStateExpireTime)
+	if err = receive.cache.SetString(ctx, helpers.GetOidcStateCacheKey(state), string(value), &expire); err != nil {
+		return nil, err
+	}
+	return &schema.OidcLoginResponse{
+		AuthURL: receive.client.AuthCodeURL(state, nonce, verifier),
+		State:   state,
+	}, nil
+}
+
+// Callback 授权码回调, 校验身份后签发 qqlx token
+func (receive *OidcSVC) Callback(ctx context.Context, req *schema.OidcCallbackRequest) (res *schema.UserLoginResponse, err error) {
+	ctx, span := tracing.Start(ctx, "OidcSVC.Callback")
+	defer span.End()
+	logger.WithContext(ctx, true).Debugf("oidc callback, state: %s", req.State)
+	var user *model.User
+	event := newAuditEvent(constant.AuditUserLoginOidc, constant.AuditTargetUser, "")
+	defer func() {
+		if user != nil {
+			auditActor(event, user)
+		}
+		receive.audit.Record(ctx, event, err)
+	}()
+	if receive.client == nil {
+		return nil, apierr.BadRequest().Set(apierr.AuthErrCode, reason.ErrOidcDisabled.Error(), reason.ErrOidcDisabled)
+	}
+	// state 必须与发起登录或绑定的浏览器 cookie 一致, 防止回调被用于其他浏览器
+	if req.Cookie == "" || subtle.ConstantTimeCompare([]byte(req.Cookie), []byte(req.State)) != 1 {
+		return nil, apierr.Unauthorized().Set(apierr.AuthErrCode, "invalid oidc state", reason.ErrOidcState)
+	}
+
+	// state 只能使用一次
+	key := helpers.GetOidcStateCacheKey(req.State)
+	value, err := receive.cache.GetString(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if value == "" {
+		return nil, apierr.Unauthorized().Set(apierr.AuthErrCode, "invalid oidc state", reason.ErrOidcState)
+	}
+	if err = receive.cache.Del(ctx, key); err != nil {
+		return nil, err
+	}
+	state := &oidcState{}
+	if err = json.Unmarshal([]byte(value), state); err != nil {
+		return nil, apierr.Unauthorized().Set(apierr.AuthErrCode, "invalid oidc state", err)
+	}
+
+	if state.LinkUserID != 0 {
+		event.Action = constant.AuditUserLinkOidc
+		event.TargetID = strconv.Itoa(state.LinkUserID)
+	}
+
+	identity, err := receive.client.Exchange(ctx, req.Code, state.Nonce, state.Verifier)
+	if err != nil {
+		return nil, err
+	}
+	event.ActorName = identity.Email
+	event.After = auditJSON(map[string]any{"subject": identity.Subject, "email": identity.Email})
+	if state.LinkUserID != 0 {
+		user, err = receive.link(ctx, state.LinkUserID, identity)
+	} else {
+		user, err = receive.resolveUser(ctx, identity)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if *user.Status == model.UserStatusDisable {
+		logger.WithContext(ctx, true).Errorf("users has been disabled, user email: %s", user.Email)
+		return nil, apierr.Unauthorized().Set(apierr.ServiceErrCode, "user not found", reason.ErrUserIsDisable)
+	}
+	changed, err := receive.syncRoles(ctx, user, identity.Groups)
+	if err != nil {
+		return nil, err
+	}
+	// 角色变更后重新加载, 避免缓存已移除的角色
+	if changed {
+		if user, err = receive.userStore.Query(ctx, userstore.ID(user.ID), userstore.LoadRoles()); err != nil {
+			return nil, err
+		}
+	}
+	// 与密码登录一致, 启用 MFA 的用户先返回登录票据
+	if required, enroll := receive.mfa.Required(user); required {
+		return receive.mfa.NewOidcTicket(ctx, user, enroll)
+	}
+	return receive.userSvc.IssueLogin(ctx, user)
+}
+
+// resolveUser 根据 subject 查找用户, 不存在时按配置自动创建
+//
+// 已存在的本地账号不会按 email 自动绑定, 需要用户登录后通过 Link 显式绑定
+func (receive *OidcSVC) resolveUser(ctx context.Context, identity *oidc.Identity) (*model.User, error) {
+	user, err := receive.userStore.Query(ctx, userstore.OidcSub(identity.Subject), userstore.LoadRoles())
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if identity.Email == "" {
+		return nil, apierr.Unauthorized().Set(apierr.AuthErrCode, "oidc user has no email", reason.ErrOidcEmailMissing)
+	}
+	if !identity.EmailVerified {
+		return nil, apierr.Unauthorized().Set(apierr.AuthErrCode, reason.ErrOidcEmailUnverified.Error(), reason.ErrOidcEmailUnverified)
+	}
+	_, err = receive.userStore.Query(ctx, userstore.Email(identity.Email))
+	if err == nil {
+		logger.WithContext(ctx, true).Warnf("oidc email matches local user, link required, email: %s", identity.Email)
+		return nil, apierr.Unauthorized().Set(apierr.AuthErrCode, reason.ErrOidcLinkRequired.Error(), reason.ErrOidcLinkRequired)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if !receive.autoProvision {
+		return nil, apierr.Unauthorized().Set(apierr.AuthErrCode, "oidc user not provisioned", reason.ErrOidcNotProvisioned)
+	}
+	if err = receive.provision(ctx, identity); err != nil {
+		return nil, err
+	}
+	user, err = receive.userStore.Query(ctx, userstore.Email(identity.Email), userstore.LoadRoles())
+	if err != nil {
+		return nil, err
+	}
+	// 自动创建的用户直接绑定 subject
+	user.OidcSub = identity.Subject
+	if err = receive.userStore.Save(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// link 将 OIDC subject 绑定到发起绑定的用户, subject 不能已绑定其他用户
+func (receive *OidcSVC) link(ctx context.Context, userID int, identity *oidc.Identity) (*model.User, error) {
+	linked, err := receive.userStore.Query(ctx, userstore.OidcSub(identity.Subject))
+	if err == nil && linked.ID != userID {
+		return nil, apierr.BadRequest().Set(apierr.AuthErrCode, reason.ErrOidcSubjectLinked.Error(), reason.ErrOidcSubjectLinked)
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	user, err := receive.userStore.Query(ctx, userstore.ID(userID), userstore.LoadRoles())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierr.BadRequest().Set(apierr.ServiceErrCode, "user not found", reason.ErrUserNotFound)
+		}
+		return nil, err
+	}
+	if user.OidcSub != identity.Subject {
+		user.OidcSub = identity.Subject
+		if err = receive.userStore.Save(ctx, user); err != nil {
+			return nil, err
+		}
+		logger.WithContext(ctx, true).Infof("user link oidc identity, userName: %s", user.Name)
+	}
+	return user, nil
+}
+
+// provision 自动创建用户, 使用随机密码, 用户只能通过 OIDC 登录
+func (receive *OidcSVC) provision(ctx context.Context, identity *oidc.Identity) error {
+	name, err := receive.provisionName(ctx, identity)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return apierr.InternalServer().Set(apierr.ServiceErrCode, "failed to provision oidc user", err)
+	}
+	logger.WithContext(ctx, true).Infof("oidc auto provision user, name: %s, email: %s", name, identity.Email)
+	return receive.userSvc.RegistryUser(ctx, &schema.UserRegistryRequest{
+		Name:     name,
+		NickName: identity.NickName,
+		Password: base64.RawURLEncoding.EncodeToString(buf),
+		Email:    identity.Email,
+		// 邮箱由身份提供方提供, 不再发送验证邮件
+		Verified: true,
+	})
+}
+
+// provisionName 自动创建用户的用户名, 使用 preferred_username, 没有时使用邮箱前缀, 已被使用时追加数字后缀
+func (receive *OidcSVC) provisionName(ctx context.Context, identity *oidc.Identity) (string, error) {
+	base := invalidNameChars.ReplaceAllString(identity.Username, "")
+	if base == "" {
+		base = invalidNameChars.ReplaceAllString(strings.Split(identity.Email, "@")[0], "")
+	}
+	if base == "" {
+		base = "user"
+	}
+	// 用户名最长 50, 留出后缀的长度
+	if len(base) > 45 {
+		base = base[:45]
+	}
+	for i := 1; i <= oidcNameAttempts; i++ {
+		name := base
+		if i > 1 {
+			name = base + strconv.Itoa(i)
+		}
+		// 已删除的用户仍然占用唯一索引
+		_, err := receive.userStore.Query(ctx, userstore.Name(name), userstore.WithDeleted())
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return name, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", apierr.InternalServer().Set(apierr.ServiceErrCode, "no available user name for oidc user", reason.ErrUserExists)
+}
+
+// syncRoles 根据组映射同步用户角色, 只处理映射中出现的角色, changed 表示角色有变更
+func (receive *OidcSVC) syncRoles(ctx context.Context, user *model.User, groups []string) (changed bool, err error) {
+	if len(receive.groupRoles) == 0 || user.Name == "admin" {
+		return false, nil
+	}
+	managed := make(map[string]struct{})
+	for _, roles := range receive.groupRoles {
+		for _, role := range roles {
+			managed[role] = struct{}{}
+		}
+	}
+	desired := make(map[string]struct{})
+	for _, group := range groups {
+		for _, role := range receive.groupRoles[group] {
+			desired[role] = struct{}{}
+		}
+	}
+	current := make(map[string]struct{}, len(user.Roles))
+	for _, role := range user.Roles {
+		current[role.Name] = struct{}{}
+	}
+
+	var toAdd, toRemove []string
+	for role := range desired {
+		if _, ok := current[role]; !ok {
+			toAdd = append(toAdd, role)
+		}
+	}
+	for role := range current {
+		_, isManaged := managed[role]
+		_, isDesired := desired[role]
+		if isManaged && !isDesired {
+			toRemove = append(toRemove, role)
+		}
+	}
+
+	if len(toAdd) > 0 {
+		if err = receive.userSvc.UserAddRole(ctx, &schema.UserUpdateRoleRequest{ID: user.ID, RoleNames: toAdd}); err != nil {
+			return false, err
+		}
+	}
+	if len(toRemove) > 0 {
+		if err = receive.userSvc.UserRemoveRole(ctx, &schema.UserUpdateRoleRequest{ID: user.ID, RoleNames: toRemove}); err != nil {
+			return false, err
+		}
+	}
+	return len(toAdd) > 0 || len(toRemove) > 0, nil
+}
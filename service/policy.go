@@ -5,13 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"qqlx/base/apierr"
+	"qqlx/base/constant"
+	"qqlx/base/helpers"
 	"qqlx/base/interfaces"
 	"qqlx/base/logger"
 	"qqlx/base/reason"
+	"qqlx/base/tracing"
 	"qqlx/model"
 	"qqlx/pkg/sonyflake"
 	"qqlx/schema"
 	"qqlx/store/rbac"
+	"strconv"
 
 	"gorm.io/gorm"
 )
@@ -19,16 +23,26 @@ import (
 type PolicySVC struct {
 	generateID  *sonyflake.GenerateIDStruct
 	policyStore interfaces.PolicyStoreInterface
+	casbinStore interfaces.CasbinInterface
+	catalog     interfaces.RouteCatalogInterface
+	revision    *RevisionSVC
+	audit       *AuditSVC
 }
 
-func NewPolicySVC(generateID *sonyflake.GenerateIDStruct, policyStore interfaces.PolicyStoreInterface) *PolicySVC {
+func NewPolicySVC(generateID *sonyflake.GenerateIDStruct, policyStore interfaces.PolicyStoreInterface, casbinStore interfaces.CasbinInterface, catalog interfaces.RouteCatalogInterface, revision *RevisionSVC, audit *AuditSVC) *PolicySVC {
 	return &PolicySVC{
 		generateID:  generateID,
 		policyStore: policyStore,
+		casbinStore: casbinStore,
+		catalog:     catalog,
+		revision:    revision,
+		audit:       audit,
 	}
 }
 
 func (receive *PolicySVC) GetPolicy(ctx context.Context, req *schema.PolicyIDRequest) (res *model.Policy, err error) {
+	ctx, span := tracing.Start(ctx, "PolicySVC.GetPolicy")
+	defer span.End()
 	logger.WithContext(ctx, true).Debugf("get policy, request: %#v", req)
 	res, err = receive.policyStore.Query(ctx, rbac.PolicyID(req.ID))
 	if err != nil {
@@ -41,23 +55,50 @@ func (receive *PolicySVC) GetPolicy(ctx context.Context, req *schema.PolicyIDReq
 }
 
 func (receive *PolicySVC) CreatePolicy(ctx context.Context, req *schema.PolicyCreateRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "PolicySVC.CreatePolicy")
+	defer span.End()
 	logger.WithContext(ctx, false).Debugf("create policy, request: %#v", req)
+	event := newAuditEvent(constant.AuditPolicyCreate, constant.AuditTargetPolicy, req.Name)
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	if err = validCondition(req.Condition); err != nil {
+		return err
+	}
 	id, err := receive.generateID.NextID()
 	if err != nil {
 		return err
 	}
-	return receive.policyStore.Create(ctx, &model.Policy{
-		ID:       id,
-		Name:     req.Name,
-		Path:     req.Path,
-		Method:   req.Method,
-		Describe: req.Describe,
-	})
+	policy := &model.Policy{
+		ID:        id,
+		Name:      req.Name,
+		Path:      req.Path,
+		Method:    req.Method,
+		Describe:  req.Describe,
+		Effect:    helpers.PolicyEffect(req.Effect),
+		Condition: req.Condition,
+	}
+	event.TargetID = strconv.Itoa(id)
+	event.After = auditJSON(policy)
+	return receive.policyStore.Create(ctx, policy)
+}
+
+// validCondition 校验策略条件
+func validCondition(condition string) error {
+	if condition == "" {
+		return nil
+	}
+	if err := rbac.ValidCondition(condition); err != nil {
+		return apierr.BadRequest().Set(apierr.ServiceErrCode, fmt.Sprintf("%s: %v", reason.ErrPolicyCondition, err), reason.ErrPolicyCondition)
+	}
+	return nil
 }
 
 // DeletePolicy 删除策略
 func (receive *PolicySVC) DeletePolicy(ctx context.Context, req *schema.PolicyIDRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "PolicySVC.DeletePolicy")
+	defer span.End()
 	logger.WithContext(ctx, false).Debugf("get policy, request: %#v", req)
+	event := newAuditEvent(constant.AuditPolicyDelete, constant.AuditTargetPolicy, req.ID)
+	defer func() { receive.audit.Record(ctx, event, err) }()
 	policy, err := receive.policyStore.Query(ctx, rbac.PolicyID(req.ID), rbac.LoadRoles())
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -73,26 +114,76 @@ func (receive *PolicySVC) DeletePolicy(ctx context.Context, req *schema.PolicyID
 		}
 		return apierr.InternalServer().Set(apierr.ServiceErrCode, fmt.Sprintf("policy has been used by role: %v", roleNames), reason.ErrPolicyUsedByRole)
 	}
+	event.Before = auditJSON(auditPolicy(policy))
 
 	return receive.policyStore.Delete(ctx, policy, rbac.PolicyUnscoped())
 }
 
-// UpdatePolicy 更新策略描述信息
+// UpdatePolicy 更新策略描述信息、效果和条件
 func (receive *PolicySVC) UpdatePolicy(ctx context.Context, req *schema.PolicyUpdateRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "PolicySVC.UpdatePolicy")
+	defer span.End()
 	logger.WithContext(ctx, false).Debugf("get policy, request: %#v", req)
-	policy, err := receive.policyStore.Query(ctx, rbac.PolicyID(req.ID))
+	event := newAuditEvent(constant.AuditPolicyUpdate, constant.AuditTargetPolicy, req.ID)
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	policy, err := receive.policyStore.Query(ctx, rbac.PolicyID(req.ID), rbac.LoadRoles())
 	if err != nil {
 		return err
 	}
-	if policy.Describe == req.Describe {
+	event.Before = auditJSON(auditPolicy(policy))
+	effect := helpers.PolicyEffect(policy.Effect)
+	if req.Effect == "" {
+		req.Effect = effect
+	}
+	condition := policy.Condition
+	if req.Condition != nil {
+		if err = validCondition(*req.Condition); err != nil {
+			return err
+		}
+		condition = *req.Condition
+	}
+	changed := effect != req.Effect || condition != policy.Condition
+	if policy.Describe == req.Describe && !changed {
 		return nil
 	}
 
+	// 效果或条件变化时同步使用该策略的角色
+	if changed {
+		for i := range policy.Roles {
+			if err = receive.casbinStore.DeleteRolePolices(ctx, helpers.GetCasbinRole(&policy.Roles[i], []model.Policy{*policy})); err != nil {
+				return err
+			}
+		}
+		policy.Effect = req.Effect
+		policy.Condition = condition
+		for i := range policy.Roles {
+			if err = receive.casbinStore.CreateRolePolices(ctx, helpers.GetCasbinRole(&policy.Roles[i], []model.Policy{*policy})); err != nil {
+				return err
+			}
+		}
+	}
 	policy.Describe = req.Describe
-	return receive.policyStore.Save(ctx, policy)
+	policy.Roles = nil
+	event.After = auditJSON(auditPolicy(policy))
+	if err = receive.policyStore.Save(ctx, policy); err != nil {
+		return err
+	}
+	if changed {
+		receive.revision.Record(ctx, RevisionActionUpdatePolicy, "", nil, nil)
+	}
+	return nil
+}
+
+// auditPolicy 审计记录中的策略, 不包含关联的角色
+func auditPolicy(policy *model.Policy) model.Policy {
+	res := *policy
+	res.Roles = nil
+	return res
 }
 
 func (receive *PolicySVC) List(ctx context.Context, req *schema.PolicyListRequest) (res *schema.PolicyListResponse, err error) {
+	ctx, span := tracing.Start(ctx, "PolicySVC.List")
+	defer span.End()
 	logger.WithContext(ctx, false).Debugf("policy list, request: %#v", req)
 	options := make([]rbac.PolicyQueryOption, 0, 2)
 	if req.Keyword != "" {
@@ -1,11 +1,29 @@
 package service
 
 import (
+	"qqlx/base/interfaces"
+
 	"github.com/google/wire"
 )
 
 var ProviderService = wire.NewSet(
+	wire.Bind(new(interfaces.TokenRevocationInterface), new(*TokenSVC)),
+	wire.Bind(new(interfaces.ApiKeyAuthenticatorInterface), new(*ApiKeySVC)),
+	wire.Bind(new(interfaces.SessionTouchInterface), new(*TokenSVC)),
+	wire.Struct(new(UserAuth), "*"),
 	NewUserSVC,
 	NewRoleSVC,
 	NewPolicySVC,
+	NewTokenSVC,
+	NewOidcSVC,
+	NewMfaSVC,
+	NewMailSVC,
+	NewLoginGuardSVC,
+	NewApiKeySVC,
+	NewTenantSVC,
+	NewAuthzSVC,
+	NewRevisionSVC,
+	NewManifestSVC,
+	NewAccessRequestSVC,
+	NewAuditSVC,
 )
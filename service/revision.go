@@ -0,0 +1,383 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"qqlx/base/apierr"
+	"qqlx/base/constant"
+	"qqlx/base/helpers"
+	"qqlx/base/interfaces"
+	"qqlx/base/logger"
+	"qqlx/base/reason"
+	"qqlx/base/tracing"
+	"qqlx/model"
+	"qqlx/pkg/jwt"
+	"qqlx/pkg/sonyflake"
+	"qqlx/schema"
+	"qqlx/store/rbac"
+	"slices"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// 角色策略变更操作
+const (
+	RevisionActionAddPolicy    = "role.addPolicy"
+	RevisionActionDeletePolicy = "role.deletePolicy"
+	RevisionActionDeleteRole   = "role.delete"
+	RevisionActionCreateRole   = "role.create"
+	RevisionActionParents      = "role.updateParents"
+	RevisionActionUpdatePolicy = "policy.update"
+	RevisionActionRollback     = "rollback"
+)
+
+type RevisionSVC struct {
+	generateID    *sonyflake.GenerateIDStruct
+	revisionStore interfaces.RevisionStoreInterface
+	roleStore     interfaces.RoleStoreInterface
+	policyStore   interfaces.PolicyStoreInterface
+	casbinStore   interfaces.CasbinInterface
+	audit         *AuditSVC
+}
+
+func NewRevisionSVC(
+	generateID *sonyflake.GenerateIDStruct,
+	revisionStore interfaces.RevisionStoreInterface,
+	roleStore interfaces.RoleStoreInterface,
+	policyStore interfaces.PolicyStoreInterface,
+	casbinStore interfaces.CasbinInterface,
+	audit *AuditSVC,
+) *RevisionSVC {
+	return &RevisionSVC{
+		generateID:    generateID,
+		revisionStore: revisionStore,
+		roleStore:     roleStore,
+		policyStore:   policyStore,
+		casbinStore:   casbinStore,
+		audit:         audit,
+	}
+}
+
+// Record 记录角色策略变更, receive 为 nil 时不记录, 变更已经生效, 记录失败只打印日志
+func (receive *RevisionSVC) Record(ctx context.Context, action, role string, before, after []int) {
+	// 修改已经提交, 即使 ctx 已取消也要保存版本
+	ctx, span := tracing.Start(context.WithoutCancel(ctx), "RevisionSVC.Record")
+	defer span.End()
+	if receive == nil {
+		return
+	}
+	_, err := receive.create(ctx, &model.RbacRevision{
+		Action: action,
+		Role:   role,
+		Before: before,
+		After:  after,
+	})
+	if err != nil {
+		logger.WithContext(ctx, true).Errorf("record rbac revision failed, action: %s, role: %s, err: %v", action, role, err)
+	}
+}
+
+// create 保存当前所有角色的策略、父角色以及策略效果和条件的快照
+func (receive *RevisionSVC) create(ctx context.Context, revision *model.RbacRevision) (*model.RbacRevision, error) {
+	snapshot, err := receive.revisionStore.Snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, roles, err := receive.roleStore.List(ctx, -1, -1, rbac.LoadParents())
+	if err != nil {
+		return nil, err
+	}
+	_, polices, err := receive.policyStore.List(ctx, -1, -1)
+	if err != nil {
+		return nil, err
+	}
+	id, err := receive.generateID.NextID()
+	if err != nil {
+		return nil, err
+	}
+	revision.ID = id
+	revision.Operator = revisionOperator(ctx)
+	revision.Snapshot = snapshot
+	revision.Parents = make(map[string][]string, len(roles))
+	for _, role := range roles {
+		if len(role.Parents) > 0 {
+			revision.Parents[helpers.GetCasbinSubject(role.TenantID, role.Name)] = sortedRoleNames(role.Parents)
+		}
+	}
+	revision.Polices = make(map[int]model.RevisionPolicy, len(polices))
+	for _, policy := range polices {
+		revision.Polices[policy.ID] = revisionPolicy(policy)
+	}
+	if err = receive.revisionStore.Create(ctx, revision); err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// sortedRoleNames 父角色的 casbin subject, 按名称排序
+func sortedRoleNames(parents []model.Role) []string {
+	names := make([]string, 0, len(parents))
+	for _, parent := range parents {
+		names = append(names, helpers.GetCasbinSubject(parent.TenantID, parent.Name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// revisionPolicy 策略的效果和条件
+func revisionPolicy(policy model.Policy) model.RevisionPolicy {
+	return model.RevisionPolicy{Effect: helpers.PolicyEffect(policy.Effect), Condition: policy.Condition}
+}
+
+// revisionOperator 当前登录的用户名, 命令行等没有登录信息时为空
+func revisionOperator(ctx context.Context) string {
+	claims, _ := ctx.Value(constant.AuthMidwareKey).(*jwt.MyClaims)
+	if claims == nil {
+		return ""
+	}
+	return claims.UserName
+}
+
+func (receive *RevisionSVC) List(ctx context.Context, req *schema.RevisionListRequest) (res *schema.RevisionListResponse, err error) {
+	ctx, span := tracing.Start(ctx, "RevisionSVC.List")
+	defer span.End()
+	logger.WithContext(ctx, false).Debugf("rbac revision list, request: %#v", req)
+	total, revisions, err := receive.revisionStore.List(ctx, req.Page, req.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	return &schema.RevisionListResponse{
+		Total:    total,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+		Items:    revisions,
+	}, nil
+}
+
+func (receive *RevisionSVC) Get(ctx context.Context, req *schema.RevisionIDRequest) (res *model.RbacRevision, err error) {
+	ctx, span := tracing.Start(ctx, "RevisionSVC.Get")
+	defer span.End()
+	logger.WithContext(ctx, false).Debugf("get rbac revision, request: %#v", req)
+	return receive.query(ctx, req.ID)
+}
+
+func (receive *RevisionSVC) query(ctx context.Context, id int) (*model.RbacRevision, error) {
+	revision, err := receive.revisionStore.Query(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierr.InternalServer().Set(apierr.ServiceErrCode, reason.ErrRevisionNotFound.Error(), reason.ErrRevisionNotFound)
+		}
+		return nil, err
+	}
+	return revision, nil
+}
+
+// Diff 对比两个版本的角色策略, 结果为从 from 到 to 的变化
+func (receive *RevisionSVC) Diff(ctx context.Context, req *schema.RevisionDiffRequest) (res *schema.RevisionDiffResponse, err error) {
+	ctx, span := tracing.Start(ctx, "RevisionSVC.Diff")
+	defer span.End()
+	logger.WithContext(ctx, false).Debugf("rbac revision diff, request: %#v", req)
+	from, err := receive.query(ctx, req.From)
+	if err != nil {
+		return nil, err
+	}
+	to, err := receive.query(ctx, req.To)
+	if err != nil {
+		return nil, err
+	}
+	_, polices, err := receive.policyStore.List(ctx, -1, -1)
+	if err != nil {
+		return nil, err
+	}
+	policyMap := make(map[int]model.Policy, len(polices))
+	for _, policy := range polices {
+		policyMap[policy.ID] = policy
+	}
+	toPolicy := func(ids []int) []model.Policy {
+		res := make([]model.Policy, 0, len(ids))
+		for _, id := range ids {
+			policy, ok := policyMap[id]
+			if !ok {
+				policy = model.Policy{ID: id}
+			}
+			res = append(res, policy)
+		}
+		return res
+	}
+
+	res = &schema.RevisionDiffResponse{From: from.ID, To: to.ID, Roles: make([]schema.RevisionRoleDiff, 0)}
+	for _, role := range snapshotRoles(from.Snapshot, to.Snapshot) {
+		added := helpers.FindMissing(from.Snapshot[role], to.Snapshot[role])
+		removed := helpers.FindMissing(to.Snapshot[role], from.Snapshot[role])
+		if len(added) == 0 && len(removed) == 0 {
+			continue
+		}
+		res.Roles = append(res.Roles, schema.RevisionRoleDiff{
+			Role:    role,
+			Added:   toPolicy(added),
+			Removed: toPolicy(removed),
+		})
+	}
+	return res, nil
+}
+
+// snapshotRoles 快照中的角色名, 按名称排序
+func snapshotRoles(snapshots ...map[string][]int) []string {
+	roles := make([]string, 0)
+	for _, snapshot := range snapshots {
+		for role := range snapshot {
+			if !slices.Contains(roles, role) {
+				roles = append(roles, role)
+			}
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// Rollback 将所有角色的策略、父角色以及策略的效果和条件恢复到指定版本, 数据库和 casbin 策略同时成功或同时回滚
+func (receive *RevisionSVC) Rollback(ctx context.Context, req *schema.RevisionIDRequest) (res *schema.RevisionRollbackResponse, err error) {
+	ctx, span := tracing.Start(ctx, "RevisionSVC.Rollback")
+	defer span.End()
+	logger.WithContext(ctx, true).Debugf("rbac revision rollback, request: %#v", req)
+	event := newAuditEvent(constant.AuditRevisionRollback, constant.AuditTargetRevision, req.ID)
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	target, err := receive.query(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	_, roles, err := receive.roleStore.List(ctx, -1, -1, rbac.LoadParents())
+	if err != nil {
+		return nil, err
+	}
+	_, polices, err := receive.policyStore.List(ctx, -1, -1)
+	if err != nil {
+		return nil, err
+	}
+	restore := &rbac.RevisionRestore{RolePolicy: make(map[int][]int, len(roles))}
+	policyMap := make(map[int]model.Policy, len(polices))
+	for _, policy := range polices {
+		// 旧版本没有记录策略的效果和条件时保持当前值
+		if state, ok := target.Polices[policy.ID]; ok && state != revisionPolicy(policy) {
+			policy.Effect = state.Effect
+			policy.Condition = state.Condition
+			restore.Polices = append(restore.Polices, policy)
+		}
+		policyMap[policy.ID] = policy
+	}
+	roleMap := make(map[string]model.Role, len(roles))
+	for _, role := range roles {
+		roleMap[helpers.GetCasbinSubject(role.TenantID, role.Name)] = role
+	}
+
+	res = &schema.RevisionRollbackResponse{Skipped: make([]string, 0)}
+	var (
+		oldCasbin  [][]string
+		newCasbin  [][]string
+		oldParents = make(map[string][]string)
+		newParents = make(map[string][]string)
+	)
+	if target.Parents != nil {
+		restore.RoleParents = make(map[int][]int, len(roles))
+	}
+	for i := range roles {
+		role := &roles[i]
+		subject := helpers.GetCasbinSubject(role.TenantID, role.Name)
+		// 已删除的策略无法恢复
+		rolePolices := make([]model.Policy, 0, len(target.Snapshot[subject]))
+		for _, id := range target.Snapshot[subject] {
+			if policy, ok := policyMap[id]; ok {
+				rolePolices = append(rolePolices, policy)
+			}
+		}
+		restore.RolePolicy[role.ID] = helpers.GetIDs(rolePolices)
+		old, err := receive.casbinStore.GetRolePolicyByName(ctx, subject)
+		if err != nil {
+			return nil, err
+		}
+		oldCasbin = append(oldCasbin, old...)
+		newCasbin = append(newCasbin, helpers.GetCasbinRole(role, rolePolices)...)
+
+		if restore.RoleParents == nil {
+			continue
+		}
+		// 已删除的父角色无法恢复
+		parents := make([]model.Role, 0, len(target.Parents[subject]))
+		for _, name := range target.Parents[subject] {
+			if parent, ok := roleMap[name]; ok {
+				parents = append(parents, parent)
+			}
+		}
+		restore.RoleParents[role.ID] = helpers.GetIDs(parents)
+		oldNames, newNames := sortedRoleNames(role.Parents), sortedRoleNames(parents)
+		if !slices.Equal(oldNames, newNames) {
+			oldParents[subject] = oldNames
+			newParents[subject] = newNames
+		}
+	}
+	for _, role := range snapshotRoles(target.Snapshot) {
+		if _, ok := roleMap[role]; !ok {
+			res.Skipped = append(res.Skipped, role)
+		}
+	}
+
+	event.After = auditJSON(map[string]any{"rolePolicy": restore.RolePolicy, "roleParents": restore.RoleParents, "polices": helpers.GetIDs(restore.Polices), "skipped": res.Skipped})
+	synced := false
+	err = receive.revisionStore.Restore(ctx, restore, func() error {
+		if err := receive.replaceCasbin(ctx, oldCasbin, newCasbin); err != nil {
+			return err
+		}
+		synced = true
+		return receive.setParents(ctx, newParents)
+	})
+	if err != nil {
+		// 事务提交失败时恢复 casbin 策略和 g 规则
+		if synced {
+			if restoreErr := receive.replaceCasbin(ctx, newCasbin, oldCasbin); restoreErr != nil {
+				logger.WithContext(ctx, true).Errorf("restore casbin policy failed: %v", restoreErr)
+			}
+			if restoreErr := receive.setParents(ctx, oldParents); restoreErr != nil {
+				logger.WithContext(ctx, true).Errorf("restore casbin role parents failed: %v", restoreErr)
+			}
+		}
+		return nil, err
+	}
+
+	// 回滚已经生效, 记录失败只打印日志
+	res.Revision, err = receive.create(ctx, &model.RbacRevision{
+		Action:     RevisionActionRollback,
+		RollbackID: target.ID,
+	})
+	if err != nil {
+		logger.WithContext(ctx, true).Errorf("record rbac rollback revision failed, target: %d, err: %v", target.ID, err)
+	}
+	return res, nil
+}
+
+// setParents 设置角色的 g 规则, key 为角色的 casbin subject
+func (receive *RevisionSVC) setParents(ctx context.Context, parents map[string][]string) error {
+	for role, names := range parents {
+		if err := receive.casbinStore.SetRoleParents(ctx, role, names); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceCasbin 用 newRules 替换 oldRules, 失败时恢复 oldRules
+func (receive *RevisionSVC) replaceCasbin(ctx context.Context, oldRules, newRules [][]string) error {
+	if len(oldRules) > 0 {
+		if err := receive.casbinStore.DeleteRolePolices(ctx, oldRules); err != nil {
+			return err
+		}
+	}
+	if len(newRules) > 0 {
+		if err := receive.casbinStore.CreateRolePolices(ctx, newRules); err != nil {
+			_ = receive.casbinStore.DeleteRolePolices(ctx, newRules)
+			_ = receive.casbinStore.CreateRolePolices(ctx, oldRules)
+			return err
+		}
+	}
+	return nil
+}
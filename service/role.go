@@ -6,14 +6,19 @@ import (
 	"fmt"
 	"qqlx/base/apierr"
 	"qqlx/base/conf"
+	"qqlx/base/constant"
 	"qqlx/base/helpers"
 	"qqlx/base/interfaces"
 	"qqlx/base/logger"
 	"qqlx/base/reason"
+	"qqlx/base/tracing"
 	"qqlx/model"
 	"qqlx/pkg/sonyflake"
 	"qqlx/schema"
 	"qqlx/store/rbac"
+	"slices"
+	"strconv"
+	"strings"
 
 	"gorm.io/gorm"
 )
@@ -26,6 +31,8 @@ type RoleSVC struct {
 	casbinStore       interfaces.CasbinInterface
 	ldapEnable        bool
 	ldap              interfaces.LdapInterface
+	revision          *RevisionSVC
+	audit             *AuditSVC
 }
 
 func NewRoleSVC(
@@ -35,6 +42,8 @@ func NewRoleSVC(
 	appendStore interfaces.RolePolicyStoreInterface,
 	casbinStore interfaces.CasbinInterface,
 	ldap interfaces.LdapInterface,
+	revision *RevisionSVC,
+	audit *AuditSVC,
 ) *RoleSVC {
 	ldapEnable := conf.GetLdapEnable()
 	return &RoleSVC{
@@ -45,12 +54,16 @@ func NewRoleSVC(
 		appendPolicyStore: appendStore,
 		ldapEnable:        ldapEnable,
 		ldap:              ldap,
+		revision:          revision,
+		audit:             audit,
 	}
 }
 
 func (receive *RoleSVC) GetRole(ctx context.Context, req *schema.RoleIDRequest) (role *model.Role, err error) {
+	ctx, span := tracing.Start(ctx, "RoleSVC.GetRole")
+	defer span.End()
 	logger.WithContext(ctx, true).Debugf("get role, request: %#v", req)
-	role, err = receive.roleStore.Query(ctx, rbac.RoleID(req.ID), rbac.LoadPolices())
+	role, err = receive.roleStore.Query(ctx, rbac.RoleID(req.ID), rbac.LoadPolices(), rbac.LoadParents())
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, apierr.InternalServer().Set(apierr.ServiceErrCode, "role not found", reason.ErrRoleNotFound)
@@ -62,12 +75,17 @@ func (receive *RoleSVC) GetRole(ctx context.Context, req *schema.RoleIDRequest)
 }
 
 func (receive *RoleSVC) CreateRole(ctx context.Context, req *schema.RoleCreateRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "RoleSVC.CreateRole")
+	defer span.End()
 	logger.WithContext(ctx, true).Debugf("create role, request: %#v", req)
 	var (
 		id    int
 		exits bool
 	)
-	query, err := receive.roleStore.Query(ctx, rbac.RoleName(req.Name))
+	event := newAuditEvent(constant.AuditRoleCreate, constant.AuditTargetRole, req.Name)
+	event.After = auditJSON(req)
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	query, err := receive.roleStore.Query(ctx, rbac.RoleName(req.Name), rbac.RoleTenantID(req.TenantID))
 	if err != nil {
 		if !errors.Is(err, reason.ErrRoleNotFound) {
 			return err
@@ -76,6 +94,21 @@ func (receive *RoleSVC) CreateRole(ctx context.Context, req *schema.RoleCreateRe
 	if query != nil {
 		return apierr.InternalServer().Set(apierr.ServiceErrCode, reason.ErrRoleExists.Error(), reason.ErrRoleExists)
 	}
+	// 创建前校验策略和父角色, 避免校验失败时留下没有策略的角色
+	if len(req.PolicyIds) > 0 {
+		if _, err = receive.queryPolicies(ctx, req.TenantID, helpers.Deduplicate(req.PolicyIds)); err != nil {
+			return err
+		}
+	}
+	if len(req.ParentIDs) > 0 {
+		_, roles, err := receive.roleStore.List(ctx, -1, -1)
+		if err != nil {
+			return err
+		}
+		if _, err = parentRoles(roles, req.TenantID, helpers.Deduplicate(req.ParentIDs)); err != nil {
+			return err
+		}
+	}
 
 	id, err = receive.generateID.NextID()
 	if err != nil {
@@ -85,8 +118,11 @@ func (receive *RoleSVC) CreateRole(ctx context.Context, req *schema.RoleCreateRe
 		ID:          id,
 		Name:        req.Name,
 		Description: req.Describe,
+		TenantID:    req.TenantID,
 	}
-	if receive.ldapEnable {
+	event.TargetID = strconv.Itoa(id)
+	// 租户角色只在租户内生效, 不同步到 ldap
+	if receive.ldapEnable && role.TenantID == 0 {
 		exits, err = receive.ldap.SearchGroup(ctx, role.Name)
 		if err != nil {
 			return err
@@ -111,16 +147,146 @@ func (receive *RoleSVC) CreateRole(ctx context.Context, req *schema.RoleCreateRe
 			return err
 		}
 	}
+	if len(req.ParentIDs) > 0 {
+		err = receive.UpdateParents(ctx, &schema.RoleParentRequest{
+			ID:        id,
+			ParentIDs: req.ParentIDs,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	receive.revision.Record(ctx, RevisionActionCreateRole, role.Name, nil, nil)
 	return nil
 }
 
+// UpdateParents 设置角色继承的父角色, 子角色拥有父角色的所有权限
+func (receive *RoleSVC) UpdateParents(ctx context.Context, req *schema.RoleParentRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "RoleSVC.UpdateParents")
+	defer span.End()
+	logger.WithContext(ctx, true).Debugf("role update parents, request: %#v", req)
+	event := newAuditEvent(constant.AuditRoleUpdateParents, constant.AuditTargetRole, req.ID)
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	parentIDs := helpers.Deduplicate(req.ParentIDs)
+	role, err := receive.roleStore.Query(ctx, rbac.RoleID(req.ID))
+	if err != nil {
+		return err
+	}
+	if role.TenantID == 0 && role.Name == "admin" {
+		return apierr.InternalServer().Set(apierr.ServiceErrCode, reason.ErrAdminUserNotAllow.Error(), reason.ErrAdminUserNotAllow)
+	}
+
+	_, roles, err := receive.roleStore.List(ctx, -1, -1, rbac.LoadParents())
+	if err != nil {
+		return err
+	}
+	graph := make(map[int][]int, len(roles))
+	for _, item := range roles {
+		for _, parent := range item.Parents {
+			graph[item.ID] = append(graph[item.ID], parent.ID)
+		}
+	}
+	event.Before = auditJSON(map[string]any{"parents": graph[role.ID]})
+	event.After = auditJSON(map[string]any{"parents": parentIDs})
+	parents, err := parentRoles(roles, role.TenantID, parentIDs)
+	if err != nil {
+		return err
+	}
+	if helpers.RoleHasCycle(graph, role.ID, parentIDs) {
+		return apierr.BadRequest().Set(apierr.ServiceErrCode, reason.ErrRoleCycle.Error(), reason.ErrRoleCycle)
+	}
+
+	parentNames := make([]string, 0, len(parents))
+	for _, parent := range parents {
+		parentNames = append(parentNames, helpers.GetCasbinSubject(parent.TenantID, parent.Name))
+	}
+	oldParentNames := make([]string, 0, len(graph[role.ID]))
+	for _, item := range roles {
+		if slices.Contains(graph[role.ID], item.ID) {
+			oldParentNames = append(oldParentNames, helpers.GetCasbinSubject(item.TenantID, item.Name))
+		}
+	}
+	subject := helpers.GetCasbinSubject(role.TenantID, role.Name)
+	casbinChanged := false
+	err = receive.appendPolicyStore.ReplaceParents(ctx, role, parents, func() error {
+		casbinChanged = true
+		return receive.casbinStore.SetRoleParents(ctx, subject, parentNames)
+	})
+	// 事务回滚后恢复原有的 g 规则, 保证数据库和 casbin 一致
+	if err != nil && casbinChanged {
+		if restoreErr := receive.casbinStore.SetRoleParents(ctx, subject, oldParentNames); restoreErr != nil {
+			logger.WithContext(ctx, true).Errorf("restore casbin role parents failed, role: %s, err: %v", role.Name, restoreErr)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	receive.revision.Record(ctx, RevisionActionParents, role.Name, nil, nil)
+	return nil
+}
+
+// parentRoles 从角色列表中找出父角色, 只允许继承同一租户的角色
+func parentRoles(roles []model.Role, tenantID int, parentIDs []int) ([]model.Role, error) {
+	parents := make([]model.Role, 0, len(parentIDs))
+	for _, item := range roles {
+		if slices.Contains(parentIDs, item.ID) {
+			parents = append(parents, item)
+		}
+	}
+	notFound := helpers.FindMissingByID(parents, parentIDs)
+	if len(notFound) > 0 {
+		return nil, apierr.InternalServer().Set(apierr.ServiceErrCode, fmt.Sprintf("role not found: %v", notFound), reason.ErrRoleNotFound)
+	}
+	for _, parent := range parents {
+		if parent.TenantID != tenantID {
+			return nil, apierr.BadRequest().Set(apierr.ServiceErrCode, fmt.Sprintf("role %s belongs to another tenant", parent.Name), reason.ErrTenantRoleNotAllowed)
+		}
+	}
+	return parents, nil
+}
+
+// GetRolePolices 获取角色直接分配的策略和包含继承的有效策略
+func (receive *RoleSVC) GetRolePolices(ctx context.Context, req *schema.RoleIDRequest) (res *schema.RolePolicyResponse, err error) {
+	ctx, span := tracing.Start(ctx, "RoleSVC.GetRolePolices")
+	defer span.End()
+	logger.WithContext(ctx, true).Debugf("get role polices, request: %#v", req)
+	role, err := receive.roleStore.Query(ctx, rbac.RoleID(req.ID), rbac.LoadPolices())
+	if err != nil {
+		return nil, err
+	}
+	subject := helpers.GetCasbinSubject(role.TenantID, role.Name)
+	polices, err := receive.casbinStore.GetImplicitRolePolices(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+	res = &schema.RolePolicyResponse{
+		Direct:    role.Policys,
+		Effective: make([]schema.EffectivePolicy, 0, len(polices)),
+	}
+	for _, policy := range polices {
+		res.Effective = append(res.Effective, schema.EffectivePolicy{
+			Path:      policy[1],
+			Method:    policy[2],
+			Effect:    policy[3],
+			Role:      policy[0],
+			Inherited: policy[0] != subject,
+		})
+	}
+	return res, nil
+}
+
 // DeleteRole 删除角色
 func (receive *RoleSVC) DeleteRole(ctx context.Context, req *schema.RoleIDRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "RoleSVC.DeleteRole")
+	defer span.End()
 	logger.WithContext(ctx, true).Debugf("delete role, request: %#v", req)
+	event := newAuditEvent(constant.AuditRoleDelete, constant.AuditTargetRole, req.ID)
+	defer func() { receive.audit.Record(ctx, event, err) }()
 	role, err := receive.roleStore.Query(ctx, rbac.RoleID(req.ID), rbac.LoadUsers(), rbac.LoadPolices())
 	if err != nil {
 		return err
 	}
+	event.Before = auditJSON(map[string]any{"name": role.Name, "description": role.Description, "tenantId": role.TenantID, "polices": helpers.GetIDs(role.Policys)})
 	if len(role.Users) > 0 {
 		var userNames []string
 		for _, user := range role.Users {
@@ -128,15 +294,29 @@ func (receive *RoleSVC) DeleteRole(ctx context.Context, req *schema.RoleIDReques
 		}
 		return apierr.InternalServer().Set(apierr.ServiceErrCode, fmt.Sprintf("role has user: %v", userNames), reason.ErrRoleHasUser)
 	}
+	// 被其他角色继承时不允许删除
+	_, roles, err := receive.roleStore.List(ctx, -1, -1, rbac.LoadParents())
+	if err != nil {
+		return err
+	}
+	var children []string
+	for _, item := range roles {
+		if slices.ContainsFunc(item.Parents, func(parent model.Role) bool { return parent.ID == role.ID }) {
+			children = append(children, item.Name)
+		}
+	}
+	if len(children) > 0 {
+		return apierr.InternalServer().Set(apierr.ServiceErrCode, fmt.Sprintf("role is inherited by: %v", children), reason.ErrRoleHasChildren)
+	}
 
-	if receive.ldapEnable {
+	if receive.ldapEnable && role.TenantID == 0 {
 		err = receive.ldap.DeleteGroup(ctx, role.Name)
 		if err != nil {
 			return err
 		}
 	}
 
-	deleteCasbin := helpers.GetCasbinRole(role.Name, role.Policys)
+	deleteCasbin := helpers.GetCasbinRole(role, role.Policys)
 	err = receive.casbinStore.DeleteRolePolices(ctx, deleteCasbin)
 	if err != nil {
 		return err
@@ -145,12 +325,26 @@ func (receive *RoleSVC) DeleteRole(ctx context.Context, req *schema.RoleIDReques
 	if err != nil {
 		return err
 	}
-	return receive.roleStore.Delete(ctx, role, rbac.RoleUnscoped())
+	err = receive.appendPolicyStore.ReplaceParents(ctx, role, nil, func() error {
+		return receive.casbinStore.SetRoleParents(ctx, helpers.GetCasbinSubject(role.TenantID, role.Name), nil)
+	})
+	if err != nil {
+		return err
+	}
+	if err = receive.roleStore.Delete(ctx, role, rbac.RoleUnscoped()); err != nil {
+		return err
+	}
+	receive.revision.Record(ctx, RevisionActionDeleteRole, role.Name, helpers.GetIDs(role.Policys), nil)
+	return nil
 }
 
 // UpdateRoleDesc 更新角色描述信息
 func (receive *RoleSVC) UpdateRoleDesc(ctx context.Context, req *schema.RoleUpdateRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "RoleSVC.UpdateRoleDesc")
+	defer span.End()
 	logger.WithContext(ctx, true).Debugf("get role, request: %#v", req)
+	event := newAuditEvent(constant.AuditRoleUpdate, constant.AuditTargetRole, req.ID)
+	defer func() { receive.audit.Record(ctx, event, err) }()
 	role, err := receive.roleStore.Query(ctx, rbac.RoleID(req.ID))
 	if err != nil {
 		return err
@@ -160,36 +354,36 @@ func (receive *RoleSVC) UpdateRoleDesc(ctx context.Context, req *schema.RoleUpda
 		return nil
 	}
 
+	event.Before = auditJSON(map[string]any{"description": role.Description})
+	event.After = auditJSON(map[string]any{"description": req.Describe})
 	role.Description = req.Describe
 	return receive.roleStore.Save(ctx, role)
 }
 
 // AddByPolicy 增加角色权限
 func (receive *RoleSVC) AddByPolicy(ctx context.Context, req *schema.RolePolicyRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "RoleSVC.AddByPolicy")
+	defer span.End()
 	logger.WithContext(ctx, false).Debugf("role add policy, request: %#v", req)
+	event := newAuditEvent(constant.AuditRoleAddPolicy, constant.AuditTargetRole, req.ID)
+	defer func() { receive.audit.Record(ctx, event, err) }()
 	// 去重
 	reqPolicesIDs := helpers.Deduplicate(req.PolicyIds)
 	// 获取角色
-	role, err := receive.roleStore.Query(ctx, rbac.RoleID(req.ID))
+	role, err := receive.roleStore.Query(ctx, rbac.RoleID(req.ID), rbac.LoadPolices())
 	if err != nil {
 		return err
 	}
-	if role.Name == "admin" {
+	if role.TenantID == 0 && role.Name == "admin" {
 		return apierr.InternalServer().Set(apierr.ServiceErrCode, reason.ErrAdminUserNotAllow.Error(), reason.ErrAdminUserNotAllow)
 	}
+	before := helpers.GetIDs(role.Policys)
+	role.Policys = nil
 
-	_, list, err := receive.policyStore.List(ctx, 1, len(reqPolicesIDs), rbac.InPolicy(reqPolicesIDs))
+	list, err := receive.queryPolicies(ctx, role.TenantID, reqPolicesIDs)
 	if err != nil {
 		return err
 	}
-	if len(list) == 0 {
-		return apierr.InternalServer().Set(apierr.ServiceErrCode, reason.ErrPolicyNotFound.Error(), reason.ErrPolicyNotFound)
-	}
-
-	notFound := helpers.FindMissingByID(list, req.PolicyIds)
-	if len(notFound) > 0 {
-		return apierr.InternalServer().Set(apierr.ServiceErrCode, fmt.Sprintf("policy not found: %v", notFound), reason.ErrPolicyNotFound)
-	}
 
 	// role 追加策略
 	err = receive.appendPolicyStore.AppendPolicy(ctx, role, list)
@@ -197,13 +391,47 @@ func (receive *RoleSVC) AddByPolicy(ctx context.Context, req *schema.RolePolicyR
 		return err
 	}
 	// 更新 casbin 策略
-	saveCasbin := helpers.GetCasbinRole(role.Name, list)
-	return receive.casbinStore.CreateRolePolices(ctx, saveCasbin)
+	saveCasbin := helpers.GetCasbinRole(role, list)
+	if err = receive.casbinStore.CreateRolePolices(ctx, saveCasbin); err != nil {
+		return err
+	}
+	after := helpers.Deduplicate(append(slices.Clone(before), helpers.GetIDs(list)...))
+	event.Before = auditJSON(map[string]any{"polices": before})
+	event.After = auditJSON(map[string]any{"polices": after})
+	receive.revision.Record(ctx, RevisionActionAddPolicy, role.Name, before, after)
+	return nil
+}
+
+// queryPolicies 查询要分配给角色的策略, 租户角色只能使用租户路由下的策略, 防止租户管理员越权
+func (receive *RoleSVC) queryPolicies(ctx context.Context, tenantID int, policyIDs []int) ([]model.Policy, error) {
+	_, list, err := receive.policyStore.List(ctx, 1, len(policyIDs), rbac.InPolicy(policyIDs))
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, apierr.InternalServer().Set(apierr.ServiceErrCode, reason.ErrPolicyNotFound.Error(), reason.ErrPolicyNotFound)
+	}
+	notFound := helpers.FindMissingByID(list, policyIDs)
+	if len(notFound) > 0 {
+		return nil, apierr.InternalServer().Set(apierr.ServiceErrCode, fmt.Sprintf("policy not found: %v", notFound), reason.ErrPolicyNotFound)
+	}
+	if tenantID != 0 {
+		for _, policy := range list {
+			if !strings.HasPrefix(policy.Path, constant.TenantPathPrefix) {
+				return nil, apierr.BadRequest().Set(apierr.ServiceErrCode, fmt.Sprintf("policy not allowed: %s", policy.Name), reason.ErrTenantPolicyNotAllow)
+			}
+		}
+	}
+	return list, nil
 }
 
 // DeleteByPolicy 删除角色权限
 func (receive *RoleSVC) DeleteByPolicy(ctx context.Context, req *schema.RolePolicyRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "RoleSVC.DeleteByPolicy")
+	defer span.End()
 	logger.WithContext(ctx, true).Debugf("create role, request: %#v", req)
+	event := newAuditEvent(constant.AuditRoleDeletePolicy, constant.AuditTargetRole, req.ID)
+	defer func() { receive.audit.Record(ctx, event, err) }()
 	// 去重
 	policesID := helpers.Deduplicate(req.PolicyIds)
 	if len(policesID) == 0 {
@@ -211,13 +439,15 @@ func (receive *RoleSVC) DeleteByPolicy(ctx context.Context, req *schema.RolePoli
 	}
 
 	// 获取角色
-	role, err := receive.roleStore.Query(ctx, rbac.RoleID(req.ID))
+	role, err := receive.roleStore.Query(ctx, rbac.RoleID(req.ID), rbac.LoadPolices())
 	if err != nil {
 		return err
 	}
-	if role.Name == "admin" {
+	if role.TenantID == 0 && role.Name == "admin" {
 		return apierr.InternalServer().Set(apierr.ServiceErrCode, reason.ErrAdminUserNotAllow.Error(), reason.ErrAdminUserNotAllow)
 	}
+	before := helpers.GetIDs(role.Policys)
+	role.Policys = nil
 
 	// 获取数据库中的策略
 	_, list, err := receive.policyStore.List(ctx, 1, len(policesID), rbac.InPolicy(policesID))
@@ -239,17 +469,26 @@ func (receive *RoleSVC) DeleteByPolicy(ctx context.Context, req *schema.RolePoli
 	}
 
 	// 删除 casbin 策略
-	deleteCasbin := helpers.GetCasbinRole(role.Name, list)
-	return receive.casbinStore.DeleteRolePolices(ctx, deleteCasbin)
+	deleteCasbin := helpers.GetCasbinRole(role, list)
+	if err = receive.casbinStore.DeleteRolePolices(ctx, deleteCasbin); err != nil {
+		return err
+	}
+	after := helpers.FindMissing(helpers.GetIDs(list), before)
+	event.Before = auditJSON(map[string]any{"polices": before})
+	event.After = auditJSON(map[string]any{"polices": after})
+	receive.revision.Record(ctx, RevisionActionDeletePolicy, role.Name, before, after)
+	return nil
 }
 
 func (receive *RoleSVC) ListRole(ctx context.Context, req *schema.RoleListRequest) (data *schema.RoleListResponse, err error) {
+	ctx, span := tracing.Start(ctx, "RoleSVC.ListRole")
+	defer span.End()
 	logger.WithContext(ctx, true).Debugf("role list, request: %#v", req)
 	options := make([]rbac.RoleQueryOption, 0)
 	if req.Keyword != "" {
 		options = append(options, rbac.RoleQueryByName(req.Keyword, req.Value))
 	}
-	options = append(options, rbac.RoleSortByCreatedDesc())
+	options = append(options, rbac.RoleTenantID(0), rbac.RoleSortByCreatedDesc())
 
 	total, roles, err := receive.roleStore.List(ctx, req.Page, req.PageSize, options...)
 	if err != nil {
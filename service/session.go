@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"qqlx/base/apierr"
+	"qqlx/base/constant"
+	"qqlx/base/helpers"
+	"qqlx/base/logger"
+	"qqlx/base/reason"
+	"qqlx/base/tracing"
+	"qqlx/model"
+	"qqlx/pkg/jwt"
+	"qqlx/schema"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// startSession 登录时记录会话, 会话ID与 refresh token 家族一致
+func (receive *TokenSVC) startSession(ctx context.Context, user *model.User, familyID, tokenID string) error {
+	now := time.Now()
+	ip, _ := ctx.Value(constant.ClientIPKey).(string)
+	userAgent, _ := ctx.Value(constant.UserAgentKey).(string)
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	return receive.sessions.Create(ctx, &model.Session{
+		ID:         familyID,
+		UserID:     user.ID,
+		TokenID:    tokenID,
+		IP:         ip,
+		UserAgent:  userAgent,
+		LastSeenAt: int(now.Unix()),
+		ExpiresAt:  int(now.Add(jwt.GetRefreshExpire()).Unix()),
+	})
+}
+
+// Touch 更新 token 所在会话的最近访问时间, 每个会话在 SessionTouchInterval 内只更新一次
+func (receive *TokenSVC) Touch(ctx context.Context, claims *jwt.MyClaims) error {
+	ctx, span := tracing.Start(ctx, "TokenSVC.Touch")
+	defer span.End()
+	if claims.FamilyID == "" {
+		return nil
+	}
+	interval, _ := time.ParseDuration(constant.SessionTouchInterval)
+	ok, err := receive.cache.SetNX(ctx, helpers.GetSessionSeenCacheKey(claims.FamilyID), "1", &interval)
+	if err != nil || !ok {
+		return err
+	}
+	return receive.sessions.Touch(ctx, claims.FamilyID, int(time.Now().Unix()))
+}
+
+// ListSessions 查询用户的会话, current 为当前请求所在的会话
+func (receive *TokenSVC) ListSessions(ctx context.Context, userID int, current string) (res []schema.SessionResponse, err error) {
+	ctx, span := tracing.Start(ctx, "TokenSVC.ListSessions")
+	defer span.End()
+	sessions, err := receive.sessions.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	res = make([]schema.SessionResponse, 0, len(sessions))
+	for i := range sessions {
+		item := schema.SessionResponse{}
+		item.ConvertToSessionResponse(&sessions[i])
+		item.Current = sessions[i].ID == current
+		res = append(res, item)
+	}
+	return res, nil
+}
+
+// KillSession 结束用户的会话, 会话内的 access token 和 refresh token 立即失效
+func (receive *TokenSVC) KillSession(ctx context.Context, req *schema.SessionQueryRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "TokenSVC.KillSession")
+	defer span.End()
+	logger.WithContext(ctx, true).Debugf("kill session, request: %#v", req)
+	event := newAuditEvent(constant.AuditSessionKill, constant.AuditTargetSession, req.ID)
+	event.Before = auditJSON(map[string]any{"userId": req.UserID})
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	if _, err = receive.sessions.Query(ctx, req.ID, req.UserID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apierr.InternalServer().Set(apierr.ServiceErrCode, reason.ErrSessionNotFound.Error(), reason.ErrSessionNotFound)
+		}
+		return err
+	}
+	return receive.RevokeFamily(ctx, req.ID)
+}
@@ -0,0 +1,326 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"qqlx/base/apierr"
+	"qqlx/base/constant"
+	"qqlx/base/helpers"
+	"qqlx/base/interfaces"
+	"qqlx/base/logger"
+	"qqlx/base/reason"
+	"qqlx/base/tracing"
+	"qqlx/model"
+	"qqlx/pkg/sonyflake"
+	"qqlx/schema"
+	"qqlx/store/rbac"
+	"qqlx/store/userstore"
+
+	"gorm.io/gorm"
+)
+
+// TenantSVC 租户及租户内的成员、角色管理
+//
+// 租户路由的租户ID作为 casbin domain, 租户管理员只能管理所在租户的成员和角色
+type TenantSVC struct {
+	generateID  *sonyflake.GenerateIDStruct
+	tenantStore interfaces.TenantStoreInterface
+	memberStore interfaces.TenantMemberStoreInterface
+	userStore   interfaces.UserStoreInterface
+	roleStore   interfaces.RoleStoreInterface
+	policyStore interfaces.PolicyStoreInterface
+	role        *RoleSVC
+	cache       interfaces.CacheInterface
+	audit       *AuditSVC
+}
+
+func NewTenantSVC(
+	generateID *sonyflake.GenerateIDStruct,
+	tenantStore interfaces.TenantStoreInterface,
+	memberStore interfaces.TenantMemberStoreInterface,
+	userStore interfaces.UserStoreInterface,
+	roleStore interfaces.RoleStoreInterface,
+	policyStore interfaces.PolicyStoreInterface,
+	role *RoleSVC,
+	cache interfaces.CacheInterface,
+	audit *AuditSVC,
+) *TenantSVC {
+	return &TenantSVC{
+		generateID:  generateID,
+		tenantStore: tenantStore,
+		memberStore: memberStore,
+		userStore:   userStore,
+		roleStore:   roleStore,
+		policyStore: policyStore,
+		role:        role,
+		cache:       cache,
+		audit:       audit,
+	}
+}
+
+func (receive *TenantSVC) CreateTenant(ctx context.Context, req *schema.TenantCreateRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "TenantSVC.CreateTenant")
+	defer span.End()
+	logger.WithContext(ctx, true).Debugf("create tenant, request: %#v", req)
+	query, err := receive.tenantStore.QueryByName(ctx, req.Name)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if query != nil {
+		return apierr.BadRequest().Set(apierr.ServiceErrCode, reason.ErrTenantExists.Error(), reason.ErrTenantExists)
+	}
+	id, err := receive.generateID.NextID()
+	if err != nil {
+		return err
+	}
+	return receive.tenantStore.Create(ctx, &model.Tenant{
+		ID:          id,
+		Name:        req.Name,
+		Description: req.Describe,
+	})
+}
+
+func (receive *TenantSVC) ListTenant(ctx context.Context) (tenants []model.Tenant, err error) {
+	ctx, span := tracing.Start(ctx, "TenantSVC.ListTenant")
+	defer span.End()
+	return receive.tenantStore.List(ctx)
+}
+
+// DeleteTenant 删除租户, 租户下仍有成员或角色时不允许删除
+func (receive *TenantSVC) DeleteTenant(ctx context.Context, req *schema.TenantIDRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "TenantSVC.DeleteTenant")
+	defer span.End()
+	logger.WithContext(ctx, true).Debugf("delete tenant, request: %#v", req)
+	tenant, err := receive.queryTenant(ctx, req.Tenant)
+	if err != nil {
+		return err
+	}
+	members, err := receive.memberStore.List(ctx, tenant.ID)
+	if err != nil {
+		return err
+	}
+	total, _, err := receive.roleStore.List(ctx, 1, 1, rbac.RoleTenantID(tenant.ID))
+	if err != nil {
+		return err
+	}
+	if len(members) > 0 || total > 0 {
+		return apierr.BadRequest().Set(apierr.ServiceErrCode, reason.ErrTenantNotEmpty.Error(), reason.ErrTenantNotEmpty)
+	}
+	return receive.tenantStore.Delete(ctx, tenant)
+}
+
+func (receive *TenantSVC) ListMember(ctx context.Context, req *schema.TenantIDRequest) (res []schema.TenantMemberResponse, err error) {
+	ctx, span := tracing.Start(ctx, "TenantSVC.ListMember")
+	defer span.End()
+	if _, err = receive.queryTenant(ctx, req.Tenant); err != nil {
+		return nil, err
+	}
+	members, err := receive.memberStore.List(ctx, req.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	res = make([]schema.TenantMemberResponse, len(members))
+	for i := range members {
+		res[i].ConvertToTenantMemberResponse(&members[i])
+	}
+	return res, nil
+}
+
+// SetMember 添加租户成员或更新成员的管理员标记
+func (receive *TenantSVC) SetMember(ctx context.Context, req *schema.TenantMemberRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "TenantSVC.SetMember")
+	defer span.End()
+	logger.WithContext(ctx, true).Debugf("set tenant member, request: %#v", req)
+	event := newAuditEvent(constant.AuditTenantSetMember, constant.AuditTargetTenant, req.Tenant)
+	event.After = auditJSON(map[string]any{"userId": req.UserID, "admin": req.Admin})
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	if _, err = receive.queryTenant(ctx, req.Tenant); err != nil {
+		return err
+	}
+	user, err := receive.userStore.Query(ctx, userstore.ID(req.UserID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apierr.InternalServer().Set(apierr.ServiceErrCode, "user not found", reason.ErrUserNotFound)
+		}
+		return err
+	}
+	member, err := receive.memberStore.Query(ctx, req.Tenant, user.ID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		member = &model.TenantMember{TenantID: req.Tenant, UserID: user.ID}
+	} else {
+		event.Before = auditJSON(map[string]any{"userId": user.ID, "admin": member.Admin})
+	}
+	member.Admin = req.Admin
+	if err = receive.memberStore.Save(ctx, member); err != nil {
+		return err
+	}
+	return receive.cache.Del(ctx, helpers.GetTenantRoleCacheKey(req.Tenant, user.Name))
+}
+
+func (receive *TenantSVC) RemoveMember(ctx context.Context, req *schema.TenantMemberIDRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "TenantSVC.RemoveMember")
+	defer span.End()
+	logger.WithContext(ctx, true).Debugf("remove tenant member, request: %#v", req)
+	event := newAuditEvent(constant.AuditTenantRemoveMember, constant.AuditTargetTenant, req.Tenant)
+	event.Before = auditJSON(map[string]any{"userId": req.UserID})
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	member, user, err := receive.queryMember(ctx, req.Tenant, req.UserID)
+	if err != nil {
+		return err
+	}
+	if err = receive.memberStore.Delete(ctx, member); err != nil {
+		return err
+	}
+	return receive.cache.Del(ctx, helpers.GetTenantRoleCacheKey(req.Tenant, user.Name))
+}
+
+// SetMemberRoles 设置成员在租户内的角色, 只能使用该租户的角色
+func (receive *TenantSVC) SetMemberRoles(ctx context.Context, req *schema.TenantMemberRoleRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "TenantSVC.SetMemberRoles")
+	defer span.End()
+	logger.WithContext(ctx, true).Debugf("set tenant member roles, request: %#v", req)
+	event := newAuditEvent(constant.AuditTenantSetMemberRoles, constant.AuditTargetTenant, req.Tenant)
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	member, user, err := receive.queryMember(ctx, req.Tenant, req.UserID)
+	if err != nil {
+		return err
+	}
+	roleIDs := helpers.Deduplicate(req.RoleIDs)
+	event.Before = auditJSON(map[string]any{"userId": user.ID, "roles": helpers.GetIDs(member.Roles)})
+	event.After = auditJSON(map[string]any{"userId": user.ID, "roles": roleIDs})
+	roles := make([]model.Role, 0, len(roleIDs))
+	if len(roleIDs) > 0 {
+		_, roles, err = receive.roleStore.List(ctx, -1, -1, rbac.RoleTenantID(req.Tenant), rbac.InRole(roleIDs))
+		if err != nil {
+			return err
+		}
+		notFound := helpers.FindMissingByID(roles, roleIDs)
+		if len(notFound) > 0 {
+			return apierr.BadRequest().Set(apierr.ServiceErrCode, fmt.Sprintf("role not in tenant: %v", notFound), reason.ErrTenantRoleNotAllowed)
+		}
+	}
+	if err = receive.memberStore.ReplaceRoles(ctx, member, roles); err != nil {
+		return err
+	}
+	return receive.cache.Del(ctx, helpers.GetTenantRoleCacheKey(req.Tenant, user.Name))
+}
+
+func (receive *TenantSVC) ListRole(ctx context.Context, req *schema.TenantIDRequest) (roles []model.Role, err error) {
+	ctx, span := tracing.Start(ctx, "TenantSVC.ListRole")
+	defer span.End()
+	if _, err = receive.queryTenant(ctx, req.Tenant); err != nil {
+		return nil, err
+	}
+	_, roles, err = receive.roleStore.List(ctx, -1, -1, rbac.RoleTenantID(req.Tenant), rbac.LoadPolices(), rbac.RoleSortByCreatedDesc())
+	return roles, err
+}
+
+func (receive *TenantSVC) CreateRole(ctx context.Context, req *schema.TenantRoleCreateRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "TenantSVC.CreateRole")
+	defer span.End()
+	event := newAuditEvent(constant.AuditTenantCreateRole, constant.AuditTargetTenant, req.Tenant)
+	event.After = auditJSON(map[string]any{"name": req.Name, "policyIds": req.PolicyIds})
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	if _, err = receive.queryTenant(ctx, req.Tenant); err != nil {
+		return err
+	}
+	return receive.role.CreateRole(ctx, &schema.RoleCreateRequest{
+		Name:      req.Name,
+		Describe:  req.Describe,
+		PolicyIds: req.PolicyIds,
+		TenantID:  req.Tenant,
+	})
+}
+
+// DeleteRole 删除租户角色, 仍有成员使用时不允许删除
+func (receive *TenantSVC) DeleteRole(ctx context.Context, req *schema.TenantRoleRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "TenantSVC.DeleteRole")
+	defer span.End()
+	event := newAuditEvent(constant.AuditTenantDeleteRole, constant.AuditTargetTenant, req.Tenant)
+	event.Before = auditJSON(map[string]any{"roleId": req.ID})
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	role, err := receive.queryRole(ctx, req.Tenant, req.ID)
+	if err != nil {
+		return err
+	}
+	event.Before = auditJSON(map[string]any{"roleId": role.ID, "name": role.Name})
+	count, err := receive.memberStore.CountByRole(ctx, role.ID)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return apierr.InternalServer().Set(apierr.ServiceErrCode, fmt.Sprintf("role has %d tenant members", count), reason.ErrRoleHasUser)
+	}
+	return receive.role.DeleteRole(ctx, &schema.RoleIDRequest{ID: role.ID})
+}
+
+func (receive *TenantSVC) AddRolePolicy(ctx context.Context, req *schema.TenantRolePolicyRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "TenantSVC.AddRolePolicy")
+	defer span.End()
+	if _, err = receive.queryRole(ctx, req.Tenant, req.ID); err != nil {
+		return err
+	}
+	return receive.role.AddByPolicy(ctx, &schema.RolePolicyRequest{ID: req.ID, PolicyIds: req.PolicyIds})
+}
+
+func (receive *TenantSVC) DeleteRolePolicy(ctx context.Context, req *schema.TenantRolePolicyRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "TenantSVC.DeleteRolePolicy")
+	defer span.End()
+	if _, err = receive.queryRole(ctx, req.Tenant, req.ID); err != nil {
+		return err
+	}
+	return receive.role.DeleteByPolicy(ctx, &schema.RolePolicyRequest{ID: req.ID, PolicyIds: req.PolicyIds})
+}
+
+// ListPolicy 租户角色可以使用的策略
+func (receive *TenantSVC) ListPolicy(ctx context.Context, req *schema.TenantIDRequest) (polices []model.Policy, err error) {
+	ctx, span := tracing.Start(ctx, "TenantSVC.ListPolicy")
+	defer span.End()
+	if _, err = receive.queryTenant(ctx, req.Tenant); err != nil {
+		return nil, err
+	}
+	_, polices, err = receive.policyStore.List(ctx, -1, -1, rbac.PolicyPathPrefix(constant.TenantPathPrefix))
+	return polices, err
+}
+
+func (receive *TenantSVC) queryTenant(ctx context.Context, id int) (tenant *model.Tenant, err error) {
+	tenant, err = receive.tenantStore.Query(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierr.InternalServer().Set(apierr.ServiceErrCode, reason.ErrTenantNotFound.Error(), reason.ErrTenantNotFound)
+		}
+		return nil, err
+	}
+	return tenant, nil
+}
+
+func (receive *TenantSVC) queryMember(ctx context.Context, tenantID, userID int) (member *model.TenantMember, user *model.User, err error) {
+	member, err = receive.memberStore.Query(ctx, tenantID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, apierr.InternalServer().Set(apierr.ServiceErrCode, reason.ErrTenantMemberNotFound.Error(), reason.ErrTenantMemberNotFound)
+		}
+		return nil, nil, err
+	}
+	user, err = receive.userStore.Query(ctx, userstore.ID(userID))
+	if err != nil {
+		return nil, nil, err
+	}
+	return member, user, nil
+}
+
+// queryRole 查询租户角色, 其他租户和全局的角色视为不存在
+func (receive *TenantSVC) queryRole(ctx context.Context, tenantID, id int) (role *model.Role, err error) {
+	role, err = receive.roleStore.Query(ctx, rbac.RoleID(id), rbac.RoleTenantID(tenantID))
+	if err != nil {
+		if errors.Is(err, reason.ErrRoleNotFound) {
+			return nil, apierr.InternalServer().Set(apierr.ServiceErrCode, reason.ErrTenantRoleNotAllowed.Error(), reason.ErrTenantRoleNotAllowed)
+		}
+		return nil, err
+	}
+	return role, nil
+}
@@ -0,0 +1,262 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"qqlx/base/apierr"
+	"qqlx/base/helpers"
+	"qqlx/base/interfaces"
+	"qqlx/base/logger"
+	"qqlx/base/reason"
+	"qqlx/base/tracing"
+	"qqlx/model"
+	"qqlx/pkg/jwt"
+	"qqlx/schema"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const refreshFamilyRevoked = "revoked"
+
+// RefreshTokenInfo refresh token 在服务端保存的信息
+type RefreshTokenInfo struct {
+	UserID   int    `json:"userId"`
+	UserName string `json:"userName"`
+	FamilyID string `json:"familyId"`
+	// IssuedAt 微秒精度的签发时间, 与吊销时间比较
+	IssuedAt int64 `json:"issuedAt"`
+}
+
+type TokenSVC struct {
+	cache    interfaces.CacheInterface
+	sessions interfaces.SessionStoreInterface
+	audit    *AuditSVC
+}
+
+func NewTokenSVC(cache interfaces.CacheInterface, sessions interfaces.SessionStoreInterface, audit *AuditSVC) *TokenSVC {
+	return &TokenSVC{
+		cache:    cache,
+		sessions: sessions,
+		audit:    audit,
+	}
+}
+
+// IssueToken 签发 access token, 创建新的 refresh token 家族并记录会话
+func (receive *TokenSVC) IssueToken(ctx context.Context, user *model.User) (*schema.UserLoginResponse, error) {
+	ctx, span := tracing.Start(ctx, "TokenSVC.IssueToken")
+	defer span.End()
+	familyID := uuid.NewString()
+	res, tokenID, err := receive.issue(ctx, user, familyID)
+	if err != nil {
+		return nil, err
+	}
+	if err = receive.startSession(ctx, user, familyID, tokenID); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// RotateToken 在原有家族内签发新的 access token 和 refresh token, 会话保持不变
+func (receive *TokenSVC) RotateToken(ctx context.Context, user *model.User, familyID string) (*schema.UserLoginResponse, error) {
+	ctx, span := tracing.Start(ctx, "TokenSVC.RotateToken")
+	defer span.End()
+	res, tokenID, err := receive.issue(ctx, user, familyID)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := int(time.Now().Add(jwt.GetRefreshExpire()).Unix())
+	if err = receive.sessions.Rotate(ctx, familyID, tokenID, expiresAt); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ConsumeRefreshToken 校验并消费 refresh token, 每个 refresh token 只能使用一次
+//
+// 已使用过的 refresh token 再次出现时视为被盗用, 吊销整个家族
+func (receive *TokenSVC) ConsumeRefreshToken(ctx context.Context, refreshToken string) (*RefreshTokenInfo, error) {
+	ctx, span := tracing.Start(ctx, "TokenSVC.ConsumeRefreshToken")
+	defer span.End()
+	hash := jwt.HashRefreshToken(refreshToken)
+	value, err := receive.cache.GetString(ctx, helpers.GetRefreshTokenCacheKey(hash))
+	if err != nil {
+		return nil, err
+	}
+	if value == "" {
+		return nil, apierr.Unauthorized().Set(apierr.AuthErrCode, "invalid refresh token", reason.ErrRefreshInvalid)
+	}
+	info := &RefreshTokenInfo{}
+	if err = json.Unmarshal([]byte(value), info); err != nil {
+		return nil, apierr.Unauthorized().Set(apierr.AuthErrCode, "invalid refresh token", err)
+	}
+
+	status, err := receive.cache.GetString(ctx, helpers.GetRefreshFamilyCacheKey(info.FamilyID))
+	if err != nil {
+		return nil, err
+	}
+	if status == refreshFamilyRevoked {
+		return nil, apierr.Unauthorized().Set(apierr.AuthErrCode, "invalid refresh token", reason.ErrRefreshInvalid)
+	}
+	revoked, err := receive.issuedBeforeValidAfter(ctx, info.UserID, info.IssuedAt)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, apierr.Unauthorized().Set(apierr.AuthErrCode, "invalid refresh token", reason.ErrTokenRevoked)
+	}
+
+	expire := receive.remaining(info)
+	ok, err := receive.cache.SetNX(ctx, helpers.GetRefreshUsedCacheKey(hash), "1", &expire)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		logger.WithContext(ctx, true).Warnf("refresh token reused, revoke token family, userName: %s, family: %s", info.UserName, info.FamilyID)
+		if err = receive.RevokeFamily(ctx, info.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, apierr.Unauthorized().Set(apierr.AuthErrCode, "invalid refresh token", reason.ErrRefreshReused)
+	}
+	return info, nil
+}
+
+// RevokeFamily 吊销 refresh token 家族并结束对应的会话, 家族内所有 token 失效
+func (receive *TokenSVC) RevokeFamily(ctx context.Context, familyID string) error {
+	ctx, span := tracing.Start(ctx, "TokenSVC.RevokeFamily")
+	defer span.End()
+	expire := jwt.GetRefreshExpire()
+	if err := receive.cache.SetString(ctx, helpers.GetRefreshFamilyCacheKey(familyID), refreshFamilyRevoked, &expire); err != nil {
+		return err
+	}
+	return receive.sessions.Delete(ctx, familyID)
+}
+
+// RevokeToken 吊销单个 access token, 以及该 token 所在的 refresh token 家族
+func (receive *TokenSVC) RevokeToken(ctx context.Context, claims *jwt.MyClaims) error {
+	ctx, span := tracing.Start(ctx, "TokenSVC.RevokeToken")
+	defer span.End()
+	if claims.ID != "" && claims.ExpiresAt != nil {
+		expire := time.Until(claims.ExpiresAt.Time)
+		if expire > 0 {
+			if err := receive.cache.SetString(ctx, helpers.GetTokenRevokedCacheKey(claims.ID), "1", &expire); err != nil {
+				return err
+			}
+		}
+	}
+	if claims.FamilyID != "" {
+		return receive.RevokeFamily(ctx, claims.FamilyID)
+	}
+	return nil
+}
+
+// RevokeUserTokens 吊销用户当前所有的 access token 和 refresh token
+//
+// 记录微秒精度的吊销时间, 早于该时间签发的 token 全部失效, 记录保留到最长的 token 有效期结束
+func (receive *TokenSVC) RevokeUserTokens(ctx context.Context, userID int) error {
+	ctx, span := tracing.Start(ctx, "TokenSVC.RevokeUserTokens")
+	defer span.End()
+	expire := max(jwt.GetExpire(), jwt.GetRefreshExpire())
+	if err := receive.cache.SetInt64(ctx, helpers.GetTokenValidAfterCacheKey(userID), time.Now().UnixMicro(), &expire); err != nil {
+		return err
+	}
+	return receive.sessions.DeleteByUser(ctx, userID)
+}
+
+// PurgeSessions 物理删除已过期和已结束的会话, 由后台任务定期调用
+func (receive *TokenSVC) PurgeSessions(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "TokenSVC.PurgeSessions")
+	defer span.End()
+	count, err := receive.sessions.Purge(ctx, int(time.Now().Unix()))
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		logger.WithContext(ctx, true).Infof("purge sessions, count: %d", count)
+	}
+	return nil
+}
+
+// IsRevoked 判断 access token 是否已被吊销
+func (receive *TokenSVC) IsRevoked(ctx context.Context, claims *jwt.MyClaims) (bool, error) {
+	ctx, span := tracing.Start(ctx, "TokenSVC.IsRevoked")
+	defer span.End()
+	if claims.ID != "" {
+		revoked, err := receive.cache.GetString(ctx, helpers.GetTokenRevokedCacheKey(claims.ID))
+		if err != nil {
+			return false, err
+		}
+		if revoked != "" {
+			return true, nil
+		}
+	}
+	// 会话被结束时整个 refresh token 家族被吊销
+	if claims.FamilyID != "" {
+		status, err := receive.cache.GetString(ctx, helpers.GetRefreshFamilyCacheKey(claims.FamilyID))
+		if err != nil {
+			return false, err
+		}
+		if status == refreshFamilyRevoked {
+			return true, nil
+		}
+	}
+	if claims.IssuedAtMicro == 0 {
+		return false, nil
+	}
+	return receive.issuedBeforeValidAfter(ctx, claims.UserID, claims.IssuedAtMicro)
+}
+
+// issuedBeforeValidAfter 判断 token 是否签发于用户最近一次吊销之前, issuedAt 和吊销时间都是微秒
+//
+// 秒级精度时同一秒内吊销前签发的 token 不会失效, refresh 轮换后整个家族一直有效
+func (receive *TokenSVC) issuedBeforeValidAfter(ctx context.Context, userID int, issuedAt int64) (bool, error) {
+	validAfter, err := receive.cache.GetInt64(ctx, helpers.GetTokenValidAfterCacheKey(userID))
+	if err != nil {
+		return false, err
+	}
+	if validAfter == nil {
+		return false, nil
+	}
+	return issuedAt < *validAfter, nil
+}
+
+// issue 签发 token, 同时返回 access token 的 jti
+func (receive *TokenSVC) issue(ctx context.Context, user *model.User, familyID string) (*schema.UserLoginResponse, string, error) {
+	claims := jwt.NewClaims(user.ID, user.Name).WithFamily(familyID)
+	token, err := claims.GenerateToken()
+	if err != nil {
+		return nil, "", err
+	}
+	refreshToken, hash, err := jwt.NewRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+	info, err := json.Marshal(&RefreshTokenInfo{
+		UserID:   user.ID,
+		UserName: user.Name,
+		FamilyID: familyID,
+		IssuedAt: claims.IssuedAtMicro,
+	})
+	if err != nil {
+		return nil, "", apierr.InternalServer().Set(apierr.ServiceErrCode, "failed to issue refresh token", err)
+	}
+	refreshExpire := jwt.GetRefreshExpire()
+	if err = receive.cache.SetString(ctx, helpers.GetRefreshTokenCacheKey(hash), string(info), &refreshExpire); err != nil {
+		return nil, "", err
+	}
+	return &schema.UserLoginResponse{
+		Token:            token,
+		ExpiresIn:        int64(jwt.GetExpire().Seconds()),
+		RefreshToken:     refreshToken,
+		RefreshExpiresIn: int64(refreshExpire.Seconds()),
+	}, claims.ID, nil
+}
+
+// remaining refresh token 剩余有效期, 用于设置已使用标记的过期时间
+func (receive *TokenSVC) remaining(info *RefreshTokenInfo) time.Duration {
+	remaining := time.Until(time.UnixMicro(info.IssuedAt).Add(jwt.GetRefreshExpire()))
+	if remaining <= 0 {
+		return time.Second
+	}
+	return remaining
+}
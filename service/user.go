@@ -8,10 +8,13 @@ import (
 	"fmt"
 	"qqlx/base/apierr"
 	"qqlx/base/conf"
+	"qqlx/base/constant"
 	"qqlx/base/helpers"
 	"qqlx/base/interfaces"
 	"qqlx/base/logger"
 	"qqlx/base/reason"
+	"qqlx/base/tracing"
+	"qqlx/base/validator"
 	"qqlx/model"
 	"qqlx/pkg/jwt"
 	"qqlx/pkg/sonyflake"
@@ -19,6 +22,8 @@ import (
 	"qqlx/store/cache"
 	"qqlx/store/rbac"
 	"qqlx/store/userstore"
+	"slices"
+	"strconv"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -35,10 +40,28 @@ type UserSVC struct {
 	salt          string
 	ldapEnable    bool
 	ldap          interfaces.LdapInterface
+	token         *TokenSVC
+	mfa           *MfaSVC
+	mail          *MailSVC
+	guard         *LoginGuardSVC
+	password      *validator.PasswordPolicy
+	history       interfaces.PasswordHistoryStoreInterface
+	requireVerify bool
+	audit         *AuditSVC
+}
+
+// UserAuth 用户登录、密码和邮箱验证使用的依赖, 不需要的依赖可以为空
+type UserAuth struct {
+	Token    *TokenSVC
+	Mfa      *MfaSVC
+	Mail     *MailSVC
+	Guard    *LoginGuardSVC
+	Password *validator.PasswordPolicy
+	History  interfaces.PasswordHistoryStoreInterface
 }
 
 func NewUserSVC(
-	generateID *sonyflake.GenerateIDStruct, userStore interfaces.UserStoreInterface, userRoleStore interfaces.UserRoleStoreInterface, roleStore interfaces.RoleStoreInterface, cache interfaces.CacheInterface, casbin interfaces.CasbinInterface, ldap interfaces.LdapInterface) (*UserSVC, error) {
+	generateID *sonyflake.GenerateIDStruct, userStore interfaces.UserStoreInterface, userRoleStore interfaces.UserRoleStoreInterface, roleStore interfaces.RoleStoreInterface, cache interfaces.CacheInterface, casbin interfaces.CasbinInterface, ldap interfaces.LdapInterface, auth UserAuth, audit *AuditSVC) (*UserSVC, error) {
 	ldapEnable := conf.GetLdapEnable()
 	salt, err := conf.GetSalt()
 	if err != nil {
@@ -54,17 +77,30 @@ func NewUserSVC(
 		salt:          salt,
 		ldap:          ldap,
 		ldapEnable:    ldapEnable,
+		token:         auth.Token,
+		mfa:           auth.Mfa,
+		mail:          auth.Mail,
+		guard:         auth.Guard,
+		password:      auth.Password,
+		history:       auth.History,
+		requireVerify: conf.GetMailRequireVerified(),
+		audit:         audit,
 	}
 	return userSvc, nil
 }
 
 func (receive *UserSVC) RegistryUser(ctx context.Context, req *schema.UserRegistryRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "UserSVC.RegistryUser")
+	defer span.End()
 	logger.WithContext(ctx, true).Debugf("users registry request: %#v", req)
 	var (
 		user            *model.User
 		encryptPassword string
 		id              int
 	)
+	event := newAuditEvent(constant.AuditUserRegister, constant.AuditTargetUser, req.Email)
+	event.After = auditJSON(map[string]any{"name": req.Name, "email": req.Email, "nickName": req.NickName, "verified": req.Verified})
+	defer func() { receive.audit.Record(ctx, event, err) }()
 
 	user, err = receive.userStore.Query(ctx, userstore.Email(req.Email))
 	if err != nil {
@@ -72,17 +108,6 @@ func (receive *UserSVC) RegistryUser(ctx context.Context, req *schema.UserRegist
 			return err
 		}
 
-		// 创建 ldap 用户
-		if receive.ldapEnable {
-			// 生成 ldap ssha 密码
-			ssha := receive.ldapEncryptSSHA(req.Password)
-			// 创建 ldap 用户
-			err = receive.ldap.CreateUser(ctx, req.Name, ssha, req.Email)
-			if err != nil {
-				return err
-			}
-		}
-
 		encryptPassword, err = receive.encryptPassword(ctx, req.Password)
 		if err != nil {
 			return err
@@ -91,19 +116,44 @@ func (receive *UserSVC) RegistryUser(ctx context.Context, req *schema.UserRegist
 		if err != nil {
 			return err
 		}
-		err = receive.userStore.Create(ctx, &model.User{
-			ID:       id,
-			Name:     req.Name,
-			NickName: req.NickName,
-			Password: encryptPassword,
-			Avatar:   req.Avatar,
-			Email:    req.Email,
-			Mobile:   req.Mobile,
-		})
+		event.TargetID = strconv.Itoa(id)
+		newUser := &model.User{
+			ID:               id,
+			Name:             req.Name,
+			NickName:         req.NickName,
+			Password:         encryptPassword,
+			PasswordChangeAt: int(time.Now().Unix()),
+			Avatar:           req.Avatar,
+			Email:            req.Email,
+			Mobile:           req.Mobile,
+			Verified:         req.Verified,
+		}
+		err = receive.userStore.Create(ctx, newUser)
 		if err != nil {
 			return err
 		}
+		// 数据库创建成功后再创建 ldap 用户, ldap 失败时删除数据库用户, 避免两边不一致
+		if receive.ldapEnable {
+			// 生成 ldap ssha 密码
+			ssha := receive.ldapEncryptSSHA(req.Password)
+			if err = receive.ldap.CreateUser(ctx, req.Name, ssha, req.Email); err != nil {
+				if deleteErr := receive.userStore.Delete(ctx, newUser, userstore.Unscoped()); deleteErr != nil {
+					logger.WithContext(ctx, true).Errorf("delete user after ldap create failed, userName: %s, err: %v", newUser.Name, deleteErr)
+				}
+				return err
+			}
+		}
+		if err = receive.savePasswordHistory(ctx, newUser); err != nil {
+			return err
+		}
 
+		// 验证邮件发送失败不影响注册, 用户可以重新发送
+		if !newUser.Verified {
+			if err = receive.mail.SendVerifyMail(ctx, newUser); err != nil {
+				logger.WithContext(ctx, true).Errorf("send verify mail failed, userName: %s, err: %v", newUser.Name, err)
+			}
+		}
+		return nil
 	}
 	if user != nil {
 		return apierr.InternalServer().Set(apierr.ServiceErrCode, "user already exists", reason.ErrUserExists)
@@ -112,12 +162,28 @@ func (receive *UserSVC) RegistryUser(ctx context.Context, req *schema.UserRegist
 }
 
 func (receive *UserSVC) Login(ctx context.Context, req *schema.UserLoginRequest) (res *schema.UserLoginResponse, err error) {
+	ctx, span := tracing.Start(ctx, "UserSVC.Login")
+	defer span.End()
 	logger.WithContext(ctx, true).Debugf("user login, request: %#v", req)
 	var user *model.User
+	event := newAuditEvent(constant.AuditUserLogin, constant.AuditTargetUser, req.Email)
+	event.ActorName = req.Email
+	defer func() {
+		if user != nil {
+			auditActor(event, user)
+		}
+		receive.audit.Record(ctx, event, err)
+	}()
+	// 账号或来源 IP 被锁定时直接拒绝
+	if err = receive.guard.Check(ctx, req.Email, req.IP); err != nil {
+		logger.WithContext(ctx, true).Warnf("login rejected, email: %s, ip: %s, err: %v", req.Email, req.IP, err)
+		return nil, err
+	}
 	if req.Email != "" {
 		user, err = receive.userStore.Query(ctx, userstore.Email(req.Email), userstore.LoadRoles())
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
+				receive.loginFailed(ctx, req)
 				return nil, apierr.Unauthorized().Set(apierr.ServiceErrCode, "user not found", reason.ErrUserNotFound)
 			}
 			return nil, err
@@ -128,42 +194,255 @@ func (receive *UserSVC) Login(ctx context.Context, req *schema.UserLoginRequest)
 		logger.WithContext(ctx, true).Errorf("users has been disabled, user email: %s", user.Email)
 		return nil, apierr.Unauthorized().Set(apierr.ServiceErrCode, "user not found", reason.ErrUserIsDisable)
 	}
+	if user.ServiceAccount {
+		receive.loginFailed(ctx, req)
+		return nil, apierr.Unauthorized().Set(apierr.ServiceErrCode, reason.ErrServiceAccountLogin.Error(), reason.ErrServiceAccountLogin)
+	}
 	if !receive.verifyPassword(ctx, req.Password, user.Password) {
+		receive.loginFailed(ctx, req)
 		return nil, apierr.Unauthorized().Set(apierr.ServiceErrCode, "invalid password", reason.ErrInvalidPassword)
 	}
-	roleCount := len(user.Roles)
-	if roleCount > 0 {
-		rolesName := make([]any, 0, roleCount)
-		for _, role := range user.Roles {
-			rolesName = append(rolesName, role.Name)
+	// admin 不受邮箱验证限制, 避免初始化的邮箱不可用时无法登录
+	if receive.requireVerify && !user.Verified && user.Name != "admin" {
+		return nil, apierr.Unauthorized().Set(apierr.ServiceErrCode, reason.ErrUserNotVerified.Error(), reason.ErrUserNotVerified)
+	}
+	// 启用 MFA 的用户先返回登录票据, 验证码校验通过后再签发 token 并清空失败计数
+	if required, enroll := receive.mfa.Required(user); required {
+		event.After = auditJSON(map[string]any{"mfaRequired": true})
+		return receive.mfa.NewTicket(ctx, user, enroll)
+	}
+	if err = receive.guard.Succeed(ctx, req.Email); err != nil {
+		return nil, err
+	}
+	return receive.completeLogin(ctx, user)
+}
+
+// completeLogin 身份校验通过后签发 token, 密码已过期时返回修改密码票据
+func (receive *UserSVC) completeLogin(ctx context.Context, user *model.User) (res *schema.UserLoginResponse, err error) {
+	// 未记录密码修改时间的用户从本次登录开始计算
+	if receive.password.MaxAge > 0 && user.PasswordChangeAt == 0 {
+		user.PasswordChangeAt = int(time.Now().Unix())
+		if err = receive.userStore.Save(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+	if receive.password.Expired(user.PasswordChangeAt) {
+		logger.WithContext(ctx, true).Infof("user password expired, userName: %s", user.Name)
+		return receive.newPasswordTicket(ctx, user)
+	}
+	return receive.IssueLogin(ctx, user)
+}
+
+// IssueLogin 缓存用户角色并签发 token
+func (receive *UserSVC) IssueLogin(ctx context.Context, user *model.User) (*schema.UserLoginResponse, error) {
+	if err := receive.cacheRoles(ctx, user); err != nil {
+		return nil, err
+	}
+	return receive.token.IssueToken(ctx, user)
+}
+
+// newPasswordTicket 签发修改过期密码的票据
+func (receive *UserSVC) newPasswordTicket(ctx context.Context, user *model.User) (*schema.UserLoginResponse, error) {
+	ticket, err := randomCode(24)
+	if err != nil {
+		return nil, err
+	}
+	expire, _ := time.ParseDuration(constant.PasswordTicketExpireTime)
+	if err = receive.cache.SetString(ctx, helpers.GetPasswordTicketCacheKey(ticket), strconv.Itoa(user.ID), &expire); err != nil {
+		return nil, err
+	}
+	return &schema.UserLoginResponse{
+		PasswordExpired: true,
+		PasswordTicket:  ticket,
+	}, nil
+}
+
+// ChangeExpiredPassword 使用修改密码票据设置新密码后完成登录
+func (receive *UserSVC) ChangeExpiredPassword(ctx context.Context, req *schema.UserChangeExpiredPasswordRequest) (res *schema.UserLoginResponse, err error) {
+	ctx, span := tracing.Start(ctx, "UserSVC.ChangeExpiredPassword")
+	defer span.End()
+	key := helpers.GetPasswordTicketCacheKey(req.Ticket)
+	value, err := receive.cache.GetString(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	userID, convErr := strconv.Atoi(value)
+	if value == "" || convErr != nil {
+		return nil, apierr.Unauthorized().Set(apierr.AuthErrCode, reason.ErrPasswordTicketInvalid.Error(), reason.ErrPasswordTicketInvalid)
+	}
+	user, err := receive.userStore.Query(ctx, userstore.ID(userID), userstore.LoadRoles())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierr.Unauthorized().Set(apierr.ServiceErrCode, "user not found", reason.ErrUserNotFound)
 		}
-		err = receive.cache.SetSet(ctx, helpers.GetRoleCacheKey(user.Name), rolesName, &cache.NeverExpires)
-		if err != nil {
+		return nil, err
+	}
+	if *user.Status == model.UserStatusDisable {
+		logger.WithContext(ctx, true).Errorf("users has been disabled, user email: %s", user.Email)
+		return nil, apierr.Unauthorized().Set(apierr.ServiceErrCode, "user not found", reason.ErrUserIsDisable)
+	}
+	event := newAuditEvent(constant.AuditUserUpdatePassword, constant.AuditTargetUser, user.ID)
+	auditActor(event, user)
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	if err = receive.changePassword(ctx, user, req.Password); err != nil {
+		return nil, err
+	}
+	// 更新 ldap 用户
+	if receive.ldapEnable {
+		if err = receive.ldap.UpdateUserPassword(ctx, user.Name, req.Password); err != nil {
 			return nil, err
 		}
 	}
+	if err = receive.userStore.Save(ctx, user); err != nil {
+		return nil, err
+	}
+	if err = receive.savePasswordHistory(ctx, user); err != nil {
+		return nil, err
+	}
+	_ = receive.cache.Del(ctx, key)
+	return receive.completeLogin(ctx, user)
+}
+
+// changePassword 按密码策略校验新密码, 通过后更新密码哈希和修改时间, 调用方负责保存
+func (receive *UserSVC) changePassword(ctx context.Context, user *model.User, password string) error {
+	if violations := receive.password.Check(password, user.Name, user.Email); len(violations) > 0 {
+		return apierr.BadRequest().Set(apierr.ParamsErrCode, receive.password.Translate("Password", violations), reason.ErrPasswordPolicy)
+	}
+	if receive.password.History > 0 {
+		histories, err := receive.history.ListRecent(ctx, user.ID, receive.password.History)
+		if err != nil {
+			return err
+		}
+		hashes := []string{user.Password}
+		for _, h := range histories {
+			hashes = append(hashes, h.Password)
+		}
+		for _, hash := range hashes {
+			if hash != "" && receive.verifyPassword(ctx, password, hash) {
+				violations := []validator.PasswordViolation{receive.password.Reused()}
+				return apierr.BadRequest().Set(apierr.ParamsErrCode, receive.password.Translate("Password", violations), reason.ErrPasswordPolicy)
+			}
+		}
+	}
+
+	encryptPassword, err := receive.encryptPassword(ctx, password)
+	if err != nil {
+		return err
+	}
+	user.Password = encryptPassword
+	user.PasswordChangeAt = int(time.Now().Unix())
+	return nil
+}
+
+// savePasswordHistory 记录当前密码, 只保留策略要求的数量
+func (receive *UserSVC) savePasswordHistory(ctx context.Context, user *model.User) error {
+	if receive.password.History <= 0 {
+		return nil
+	}
+	if err := receive.history.Create(ctx, &model.PasswordHistory{UserID: user.ID, Password: user.Password}); err != nil {
+		return err
+	}
+	return receive.history.Prune(ctx, user.ID, receive.password.History)
+}
+
+// loginFailed 记录登录失败, 计数失败不影响返回原始错误
+func (receive *UserSVC) loginFailed(ctx context.Context, req *schema.UserLoginRequest) {
+	if err := receive.guard.Fail(ctx, req.Email, req.IP); err != nil {
+		logger.WithContext(ctx, true).Errorf("record login failure failed, email: %s, ip: %s, err: %v", req.Email, req.IP, err)
+	}
+}
+
+// LoginMfa 使用 mfa 登录票据和验证码完成登录
+func (receive *UserSVC) LoginMfa(ctx context.Context, req *schema.MfaLoginRequest) (res *schema.UserLoginResponse, err error) {
+	ctx, span := tracing.Start(ctx, "UserSVC.LoginMfa")
+	defer span.End()
+	event := newAuditEvent(constant.AuditUserLoginMfa, constant.AuditTargetUser, "")
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	user, recoveryCodes, oidcLogin, err := receive.mfa.VerifyTicket(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	auditActor(event, user)
+	if *user.Status == model.UserStatusDisable {
+		logger.WithContext(ctx, true).Errorf("users has been disabled, user email: %s", user.Email)
+		return nil, apierr.Unauthorized().Set(apierr.ServiceErrCode, "user not found", reason.ErrUserIsDisable)
+	}
+	// OIDC 登录的用户不使用本地密码, 不检查密码过期
+	if oidcLogin {
+		res, err = receive.IssueLogin(ctx, user)
+	} else {
+		res, err = receive.completeLogin(ctx, user)
+	}
+	if err != nil {
+		return nil, err
+	}
+	res.RecoveryCodes = recoveryCodes
+	return res, nil
+}
+
+// cacheRoles 缓存用户未过期的角色, 供鉴权中间件使用, 有临时角色时缓存在最早的过期时间失效
+func (receive *UserSVC) cacheRoles(ctx context.Context, user *model.User) error {
+	now := time.Now()
+	assignments, err := receive.userRoleStore.ListActive(ctx, user.ID, int(now.Unix()))
+	if err != nil {
+		return err
+	}
+	names, ttl := helpers.ActiveRoleNames(assignments, now)
+	if len(names) == 0 {
+		return nil
+	}
+	rolesName := make([]any, 0, len(names))
+	for _, name := range names {
+		rolesName = append(rolesName, name)
+	}
+	return receive.cache.SetSet(ctx, helpers.GetRoleCacheKey(user.Name), rolesName, cache.ExpireIn(ttl))
+}
 
-	token, err := jwt.NewClaims(user.ID, user.Name).GenerateToken()
+// RefreshToken 使用 refresh token 换取新的 access token, 同时轮换 refresh token
+func (receive *UserSVC) RefreshToken(ctx context.Context, req *schema.UserRefreshRequest) (res *schema.UserLoginResponse, err error) {
+	ctx, span := tracing.Start(ctx, "UserSVC.RefreshToken")
+	defer span.End()
+	info, err := receive.token.ConsumeRefreshToken(ctx, req.RefreshToken)
 	if err != nil {
 		return nil, err
 	}
-	res = &schema.UserLoginResponse{
-		Token: token,
+	user, err := receive.userStore.Query(ctx, userstore.ID(info.UserID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			_ = receive.token.RevokeFamily(ctx, info.FamilyID)
+			return nil, apierr.Unauthorized().Set(apierr.ServiceErrCode, "user not found", reason.ErrUserNotFound)
+		}
+		return nil, err
+	}
+	if *user.Status == model.UserStatusDisable {
+		logger.WithContext(ctx, true).Errorf("users has been disabled, user email: %s", user.Email)
+		_ = receive.token.RevokeFamily(ctx, info.FamilyID)
+		return nil, apierr.Unauthorized().Set(apierr.ServiceErrCode, "user not found", reason.ErrUserIsDisable)
 	}
-	return res, err
+	return receive.token.RotateToken(ctx, user, info.FamilyID)
 }
 
-func (receive *UserSVC) Logout(ctx context.Context, id int) (err error) {
-	query, _ := receive.userStore.Query(ctx, userstore.ID(id))
-	if query.Name != "" {
+// Logout 注销, 吊销当前 access token 以及对应的 refresh token 家族
+func (receive *UserSVC) Logout(ctx context.Context, claims *jwt.MyClaims) (err error) {
+	ctx, span := tracing.Start(ctx, "UserSVC.Logout")
+	defer span.End()
+	if err = receive.token.RevokeToken(ctx, claims); err != nil {
+		return err
+	}
+	query, _ := receive.userStore.Query(ctx, userstore.ID(claims.UserID))
+	if query != nil && query.Name != "" {
 		_ = receive.cache.Del(ctx, helpers.GetRoleCacheKey(query.Name))
 	}
 	return nil
 }
 
 func (receive *UserSVC) DisableUser(ctx context.Context, req *schema.UserQueryRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "UserSVC.DisableUser")
+	defer span.End()
 	logger.WithContext(ctx, true).Debugf("user delete, request: %#v", req)
 	var user *model.User
+	event := newAuditEvent(constant.AuditUserDisable, constant.AuditTargetUser, req.ID)
+	defer func() { receive.audit.Record(ctx, event, err) }()
 	user, err = receive.userStore.Query(ctx, userstore.ID(req.ID))
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -175,8 +454,8 @@ func (receive *UserSVC) DisableUser(ctx context.Context, req *schema.UserQueryRe
 		return apierr.InternalServer().Set(apierr.ServiceErrCode, reason.ErrAdminUserNotAllow.Error(), reason.ErrAdminUserNotAllow)
 	}
 
-	// 删除 ldap 用户
-	if receive.ldapEnable {
+	// 删除 ldap 用户, 服务账号不在 ldap 中
+	if receive.ldapEnable && !user.ServiceAccount {
 		// 删除用户后，所在组中的记录也会被删除
 		err = receive.ldap.DeleteUser(ctx, user.Name)
 		if err != nil {
@@ -189,18 +468,28 @@ func (receive *UserSVC) DisableUser(ctx context.Context, req *schema.UserQueryRe
 		return apierr.InternalServer().Set(apierr.ServiceErrCode, "user not found", reason.ErrUserIsDisable)
 	}
 
+	event.Before = auditJSON(map[string]any{"status": *user.Status})
+	event.After = auditJSON(map[string]any{"status": model.UserStatusDisable})
 	user.Status = &model.UserStatusDisable
 	err = receive.userStore.Save(ctx, user)
 	if err != nil {
 		return err
 	}
 
+	// 禁用后吊销该用户所有 token
+	if err = receive.token.RevokeUserTokens(ctx, user.ID); err != nil {
+		return err
+	}
 	return receive.cache.Del(ctx, helpers.GetRoleCacheKey(user.Name))
 }
 
 func (receive *UserSVC) EnableUser(ctx context.Context, req *schema.UserEnableRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "UserSVC.EnableUser")
+	defer span.End()
 	logger.WithContext(ctx, true).Debugf("user enable, request: %#v", req)
 	var user *model.User
+	event := newAuditEvent(constant.AuditUserEnable, constant.AuditTargetUser, req.ID)
+	defer func() { receive.audit.Record(ctx, event, err) }()
 	user, err = receive.userStore.Query(ctx, userstore.ID(req.ID), userstore.LoadRoles())
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -212,9 +501,12 @@ func (receive *UserSVC) EnableUser(ctx context.Context, req *schema.UserEnableRe
 		logger.WithContext(ctx, true).Errorf("user has been enabled, userName: %s", user.Name)
 		return nil
 	}
+	if err = receive.changePassword(ctx, user, req.Password); err != nil {
+		return err
+	}
 
-	// 添加 ldap 用户
-	if receive.ldapEnable {
+	// 添加 ldap 用户, 服务账号不在 ldap 中
+	if receive.ldapEnable && !user.ServiceAccount {
 		ldapPassword := receive.ldapEncryptSSHA(req.Password)
 		err = receive.ldap.CreateUser(ctx, user.Name, ldapPassword, user.Email)
 		if err != nil {
@@ -232,19 +524,22 @@ func (receive *UserSVC) EnableUser(ctx context.Context, req *schema.UserEnableRe
 		}
 	}
 
+	event.Before = auditJSON(map[string]any{"status": *user.Status})
+	event.After = auditJSON(map[string]any{"status": model.UserStatusAvailable})
 	user.Status = &model.UserStatusAvailable
-	password, err := receive.encryptPassword(ctx, req.Password)
-	if err != nil {
+	if err = receive.userStore.Save(ctx, user); err != nil {
 		return err
 	}
-
-	user.Password = password
-	return receive.userStore.Save(ctx, user)
+	return receive.savePasswordHistory(ctx, user)
 }
 
 func (receive *UserSVC) UpdatePassword(ctx context.Context, req *schema.UserUpdatePasswordRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "UserSVC.UpdatePassword")
+	defer span.End()
 	logger.WithContext(ctx, true).Debugf("user update password, request: %#v", req)
 	var user *model.User
+	event := newAuditEvent(constant.AuditUserUpdatePassword, constant.AuditTargetUser, req.ID)
+	defer func() { receive.audit.Record(ctx, event, err) }()
 	user, err = receive.userStore.Query(ctx, userstore.ID(req.ID))
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -260,6 +555,9 @@ func (receive *UserSVC) UpdatePassword(ctx context.Context, req *schema.UserUpda
 	if !receive.verifyPassword(ctx, req.OldPassword, user.Password) {
 		return apierr.InternalServer().Set(apierr.ServiceErrCode, "invalid password", reason.ErrInvalidPassword)
 	}
+	if err = receive.changePassword(ctx, user, req.NewPassword); err != nil {
+		return err
+	}
 
 	// 更新 ldap 用户
 	if receive.ldapEnable {
@@ -269,18 +567,157 @@ func (receive *UserSVC) UpdatePassword(ctx context.Context, req *schema.UserUpda
 		}
 	}
 
-	encryptPassword, err := receive.encryptPassword(ctx, req.NewPassword)
+	if err = receive.userStore.Save(ctx, user); err != nil {
+		return err
+	}
+	if err = receive.savePasswordHistory(ctx, user); err != nil {
+		return err
+	}
+	// 修改密码后吊销该用户所有 token, 需要重新登录
+	return receive.token.RevokeUserTokens(ctx, user.ID)
+}
+
+// UnlockUser 解除用户登录锁定
+func (receive *UserSVC) UnlockUser(ctx context.Context, req *schema.UserUnlockRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "UserSVC.UnlockUser")
+	defer span.End()
+	logger.WithContext(ctx, true).Debugf("user unlock, request: %#v", req)
+	event := newAuditEvent(constant.AuditUserUnlock, constant.AuditTargetUser, req.ID)
+	event.After = auditJSON(map[string]any{"ip": req.IP})
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	user, err := receive.userStore.Query(ctx, userstore.ID(req.ID))
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apierr.InternalServer().Set(apierr.ServiceErrCode, "user not found", reason.ErrUserNotFound)
+		}
 		return err
 	}
-	user.Password = encryptPassword
+	return receive.guard.Unlock(ctx, user.Email, req.IP)
+}
+
+// ForgotPassword 发送重置密码邮件, 用户不存在时同样返回成功, 避免泄露用户是否存在
+func (receive *UserSVC) ForgotPassword(ctx context.Context, req *schema.UserEmailRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "UserSVC.ForgotPassword")
+	defer span.End()
+	logger.WithContext(ctx, true).Debugf("user forgot password, request: %#v", req)
+	user, err := receive.userStore.Query(ctx, userstore.Email(req.Email))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.WithContext(ctx, true).Warnf("forgot password user not found, email: %s", req.Email)
+			return nil
+		}
+		return err
+	}
+	if *user.Status == model.UserStatusDisable {
+		logger.WithContext(ctx, true).Warnf("forgot password user has been disabled, email: %s", req.Email)
+		return nil
+	}
+	return receive.mail.SendResetMail(ctx, user)
+}
+
+// ResetPassword 使用重置密码邮件中的 token 设置新密码, 并吊销该用户所有 token
+func (receive *UserSVC) ResetPassword(ctx context.Context, req *schema.UserResetPasswordRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "UserSVC.ResetPassword")
+	defer span.End()
+	event := newAuditEvent(constant.AuditUserResetPassword, constant.AuditTargetUser, "")
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	// 新密码通过策略检查后才消费 token, 被拒绝时可以使用同一个链接重试
+	userID, err := receive.mail.VerifyToken(ctx, MailTokenPurposeReset, req.Token)
+	if err != nil {
+		return err
+	}
+	event.TargetID = strconv.Itoa(userID)
+	user, err := receive.userStore.Query(ctx, userstore.ID(userID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apierr.InternalServer().Set(apierr.ServiceErrCode, "user not found", reason.ErrUserNotFound)
+		}
+		return err
+	}
+	if *user.Status == model.UserStatusDisable {
+		logger.WithContext(ctx, true).Errorf("user has been disabled, user email: %s", user.Email)
+		return apierr.InternalServer().Set(apierr.ServiceErrCode, "user not found", reason.ErrUserIsDisable)
+	}
+	auditActor(event, user)
+	if err = receive.changePassword(ctx, user, req.Password); err != nil {
+		return err
+	}
+	if _, err = receive.mail.ConsumeToken(ctx, MailTokenPurposeReset, req.Token); err != nil {
+		return err
+	}
+
+	// 更新 ldap 用户
+	if receive.ldapEnable {
+		err = receive.ldap.UpdateUserPassword(ctx, user.Name, req.Password)
+		if err != nil {
+			return err
+		}
+	}
 
+	// 能收到重置邮件说明邮箱可用
+	user.Verified = true
+	if err = receive.userStore.Save(ctx, user); err != nil {
+		return err
+	}
+	if err = receive.savePasswordHistory(ctx, user); err != nil {
+		return err
+	}
+	logger.WithContext(ctx, true).Infof("user reset password, userName: %s", user.Name)
+	return receive.token.RevokeUserTokens(ctx, user.ID)
+}
+
+// VerifyEmail 使用验证邮件中的 token 验证邮箱
+func (receive *UserSVC) VerifyEmail(ctx context.Context, req *schema.UserVerifyEmailRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "UserSVC.VerifyEmail")
+	defer span.End()
+	event := newAuditEvent(constant.AuditUserVerifyEmail, constant.AuditTargetUser, "")
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	userID, err := receive.mail.ConsumeToken(ctx, MailTokenPurposeVerify, req.Token)
+	if err != nil {
+		return err
+	}
+	event.TargetID = strconv.Itoa(userID)
+	user, err := receive.userStore.Query(ctx, userstore.ID(userID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apierr.InternalServer().Set(apierr.ServiceErrCode, "user not found", reason.ErrUserNotFound)
+		}
+		return err
+	}
+	if user.Verified {
+		return nil
+	}
+	auditActor(event, user)
+	event.After = auditJSON(map[string]any{"verified": true})
+	user.Verified = true
 	return receive.userStore.Save(ctx, user)
 }
 
+// ResendVerifyMail 重新发送验证邮件, 用户不存在或已验证时同样返回成功
+func (receive *UserSVC) ResendVerifyMail(ctx context.Context, req *schema.UserEmailRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "UserSVC.ResendVerifyMail")
+	defer span.End()
+	logger.WithContext(ctx, true).Debugf("user resend verify mail, request: %#v", req)
+	user, err := receive.userStore.Query(ctx, userstore.Email(req.Email))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.Verified || *user.Status == model.UserStatusDisable {
+		return nil
+	}
+	return receive.mail.SendVerifyMail(ctx, user)
+}
+
 func (receive *UserSVC) UpdateUser(ctx context.Context, req *schema.UserUpdateRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "UserSVC.UpdateUser")
+	defer span.End()
 	logger.WithContext(ctx, true).Debugf("user update, request: %#v", req)
 	var user *model.User
+	event := newAuditEvent(constant.AuditUserUpdate, constant.AuditTargetUser, req.ID)
+	defer func() { receive.audit.Record(ctx, event, err) }()
 	user, err = receive.userStore.Query(ctx, userstore.ID(req.ID))
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -289,6 +726,7 @@ func (receive *UserSVC) UpdateUser(ctx context.Context, req *schema.UserUpdateRe
 		return err
 	}
 
+	event.Before = auditJSON(map[string]any{"mobile": user.Mobile, "avatar": user.Avatar, "nickName": user.NickName})
 	isUpdated := false
 	if req.Mobile != "" {
 		user.Mobile = req.Mobile
@@ -306,13 +744,23 @@ func (receive *UserSVC) UpdateUser(ctx context.Context, req *schema.UserUpdateRe
 	if !isUpdated {
 		return nil
 	}
+	event.After = auditJSON(map[string]any{"mobile": user.Mobile, "avatar": user.Avatar, "nickName": user.NickName})
 	return receive.userStore.Save(ctx, user)
 }
 
 // UserAddRole 增加用户角色
 func (receive *UserSVC) UserAddRole(ctx context.Context, req *schema.UserUpdateRoleRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "UserSVC.UserAddRole")
+	defer span.End()
 	logger.WithContext(ctx, true).Debugf("user update role, request: %#v", req)
 	roleNames := helpers.Deduplicate(req.RoleNames)
+	event := newAuditEvent(constant.AuditUserAddRole, constant.AuditTargetUser, req.ID)
+	event.After = auditJSON(map[string]any{"roles": roleNames, "duration": req.Duration, "reason": req.Reason})
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	expiresAt, err := roleExpiresAt(req.Duration)
+	if err != nil {
+		return err
+	}
 	var user *model.User
 	user, err = receive.userStore.Query(ctx, userstore.ID(req.ID))
 	if err != nil {
@@ -331,7 +779,8 @@ func (receive *UserSVC) UserAddRole(ctx context.Context, req *schema.UserUpdateR
 	}
 
 	roleCount := len(roleNames)
-	_, list, err := receive.roleStore.List(ctx, 1, roleCount, rbac.RoleNames(roleNames))
+	// 租户角色只能通过租户成员分配
+	_, list, err := receive.roleStore.List(ctx, 1, roleCount, rbac.RoleNames(roleNames), rbac.RoleTenantID(0))
 	if err != nil {
 		return err
 	}
@@ -345,8 +794,8 @@ func (receive *UserSVC) UserAddRole(ctx context.Context, req *schema.UserUpdateR
 		return err
 	}
 
-	// 更新 ldap
-	if receive.ldapEnable {
+	// 更新 ldap, 服务账号不在 ldap 中
+	if receive.ldapEnable && !user.ServiceAccount {
 		for _, roleName := range roleNames {
 			var exist bool
 			exist, err = receive.ldap.SearchGroup(ctx, roleName)
@@ -366,30 +815,37 @@ func (receive *UserSVC) UserAddRole(ctx context.Context, req *schema.UserUpdateR
 		}
 	}
 
-	err = receive.userRoleStore.AppendRoles(ctx, user, list)
+	// 已持有的角色只在新的授权期限更长时更新, 永久授权优先, 避免临时授权缩短原有期限
+	assignments, err := receive.userRoleStore.ListAssignments(ctx, user.ID)
 	if err != nil {
 		return err
 	}
-
-	query, err := receive.userStore.Query(ctx, userstore.ID(req.ID), userstore.LoadRoles())
+	extended := make([]int, 0, len(list))
+	for _, role := range list {
+		index := slices.IndexFunc(assignments, func(assignment model.UserRole) bool { return assignment.RoleID == role.ID })
+		if index < 0 || extendsExpiry(assignments[index].ExpiresAt, expiresAt) {
+			extended = append(extended, role.ID)
+		}
+	}
+	err = receive.userRoleStore.AppendRoles(ctx, user, list)
 	if err != nil {
 		return err
 	}
-
-	if len(query.Roles) > 0 {
-		listNames := make([]any, 0, len(list))
-		for _, role := range query.Roles {
-			listNames = append(listNames, role.Name)
-		}
-		if err = receive.cache.SetSet(ctx, userCache, listNames, &cache.NeverExpires); err != nil {
+	if len(extended) > 0 {
+		if err = receive.userRoleStore.SetRoleExpiry(ctx, user.ID, extended, expiresAt, req.Reason); err != nil {
 			return err
 		}
 	}
 
+	if err = receive.cacheRoles(ctx, user); err != nil {
+		return err
+	}
+
+	detached := tracing.Detach(ctx)
 	go func() {
 		time.Sleep(time.Millisecond * 200)
-		if err = receive.cache.Del(ctx, userCache); err != nil {
-			logger.WithContext(ctx, true).Error(err)
+		if err := receive.cache.Del(detached, userCache); err != nil {
+			logger.WithContext(detached, true).Error(err)
 		}
 	}()
 
@@ -397,8 +853,13 @@ func (receive *UserSVC) UserAddRole(ctx context.Context, req *schema.UserUpdateR
 }
 
 func (receive *UserSVC) UserRemoveRole(ctx context.Context, req *schema.UserUpdateRoleRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "UserSVC.UserRemoveRole")
+	defer span.End()
 	logger.WithContext(ctx, true).Debugf("user remove role, request: %#v", req)
 	uniqRoleNames := helpers.Deduplicate(req.RoleNames)
+	event := newAuditEvent(constant.AuditUserRemoveRole, constant.AuditTargetUser, req.ID)
+	event.Before = auditJSON(map[string]any{"roles": uniqRoleNames})
+	defer func() { receive.audit.Record(ctx, event, err) }()
 	var user *model.User
 	user, err = receive.userStore.Query(ctx, userstore.ID(req.ID))
 	if err != nil {
@@ -418,7 +879,7 @@ func (receive *UserSVC) UserRemoveRole(ctx context.Context, req *schema.UserUpda
 	}
 
 	roleCount := len(uniqRoleNames)
-	_, list, err := receive.roleStore.List(ctx, 1, roleCount, rbac.RoleNames(uniqRoleNames))
+	_, list, err := receive.roleStore.List(ctx, 1, roleCount, rbac.RoleNames(uniqRoleNames), rbac.RoleTenantID(0))
 	if err != nil {
 		return err
 	}
@@ -427,11 +888,15 @@ func (receive *UserSVC) UserRemoveRole(ctx context.Context, req *schema.UserUpda
 	if len(notFound) > 0 {
 		return apierr.InternalServer().Set(apierr.ServiceErrCode, fmt.Sprintf("role not exist: %v", notFound), reason.ErrRoleNotFound)
 	}
+	return receive.removeRoles(ctx, user, list)
+}
 
-	// 删除 ldap 用户
-	if receive.ldapEnable {
-		for _, roleName := range uniqRoleNames {
-			err = receive.ldap.RemoveUserFromGroup(ctx, roleName, user.Name)
+// removeRoles 删除用户角色, 同步 ldap 和 redis 中的角色缓存
+func (receive *UserSVC) removeRoles(ctx context.Context, user *model.User, list []model.Role) (err error) {
+	// 删除 ldap 用户, 服务账号不在 ldap 中
+	if receive.ldapEnable && !user.ServiceAccount {
+		for _, role := range list {
+			err = receive.ldap.RemoveUserFromGroup(ctx, role.Name, user.Name)
 			if err != nil {
 				return err
 			}
@@ -448,33 +913,109 @@ func (receive *UserSVC) UserRemoveRole(ctx context.Context, req *schema.UserUpda
 		return err
 	}
 
-	query, err := receive.userStore.Query(ctx, userstore.ID(user.ID), userstore.LoadRoles())
-	if err != nil {
+	if err = receive.cacheRoles(ctx, user); err != nil {
 		return err
 	}
-	queryRoleCount := len(query.Roles)
-	if queryRoleCount > 0 {
-		roleNames := make([]any, 0, queryRoleCount)
-		for _, role := range query.Roles {
-			roleNames = append(roleNames, role.Name)
-		}
-		if err = receive.cache.SetSet(ctx, userCache, roleNames, &cache.NeverExpires); err != nil {
-			return err
-		}
-	}
 
+	detached := tracing.Detach(ctx)
 	go func() {
 		time.Sleep(time.Millisecond * 200)
-		if err = receive.cache.Del(ctx, userCache); err != nil {
-			logger.WithContext(ctx, true).Error(err)
+		if err := receive.cache.Del(detached, userCache); err != nil {
+			logger.WithContext(detached, true).Error(err)
 		}
 	}()
 
 	return nil
 }
 
+// extendsExpiry 新的授权期限是否比原有期限更长, 0 表示永久
+func extendsExpiry(current, expiresAt int) bool {
+	if current == 0 {
+		return false
+	}
+	return expiresAt == 0 || expiresAt > current
+}
+
+// roleExpiresAt 根据授权时长计算过期时间, 为空时永久授权返回 0
+func roleExpiresAt(duration string) (int, error) {
+	if duration == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(duration)
+	if err != nil || d <= 0 {
+		return 0, apierr.BadRequest().Set(apierr.ServiceErrCode, reason.ErrRoleDuration.Error(), reason.ErrRoleDuration)
+	}
+	return int(time.Now().Add(d).Unix()), nil
+}
+
+// ExpireRoles 删除已过期的临时角色授权, 由后台任务定期调用, 单个用户失败时记录日志并继续处理其他用户
+func (receive *UserSVC) ExpireRoles(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "UserSVC.ExpireRoles")
+	defer span.End()
+	assignments, err := receive.userRoleStore.ListExpired(ctx, int(time.Now().Unix()))
+	if err != nil {
+		return err
+	}
+	expired := make(map[int][]model.Role)
+	for _, assignment := range assignments {
+		if assignment.Role == nil {
+			continue
+		}
+		expired[assignment.UserID] = append(expired[assignment.UserID], *assignment.Role)
+	}
+	for userID, roles := range expired {
+		if err := receive.expireUserRoles(ctx, userID, roles); err != nil {
+			logger.WithContext(ctx, true).Errorf("expire user roles failed, userID: %d, roles: %v, err: %v", userID, helpers.GetNames(roles), err)
+		}
+	}
+	return nil
+}
+
+// expireUserRoles 删除一个用户已过期的角色
+func (receive *UserSVC) expireUserRoles(ctx context.Context, userID int, roles []model.Role) (err error) {
+	user, err := receive.userStore.Query(ctx, userstore.ID(userID))
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		// 用户已删除时只清理关联
+		return receive.userRoleStore.DeleteRoles(ctx, &model.User{ID: userID}, roles)
+	}
+	event := newAuditEvent(constant.AuditRoleExpired, constant.AuditTargetUser, user.ID)
+	event.Before = auditJSON(map[string]any{"roles": helpers.GetNames(roles)})
+	defer func() { receive.audit.Record(ctx, event, err) }()
+	return receive.removeRoles(ctx, user, roles)
+}
+
+// roleAssignments 用户全局角色的过期时间和剩余时长
+func (receive *UserSVC) roleAssignments(ctx context.Context, userID int) ([]schema.UserRoleAssignment, error) {
+	assignments, err := receive.userRoleStore.ListAssignments(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	now := int(time.Now().Unix())
+	res := make([]schema.UserRoleAssignment, 0, len(assignments))
+	for _, assignment := range assignments {
+		if assignment.Role == nil {
+			continue
+		}
+		item := schema.UserRoleAssignment{
+			Role:      assignment.Role.Name,
+			ExpiresAt: assignment.ExpiresAt,
+			Reason:    assignment.Reason,
+		}
+		if assignment.ExpiresAt > 0 {
+			item.Remaining = max(assignment.ExpiresAt-now, 0)
+		}
+		res = append(res, item)
+	}
+	return res, nil
+}
+
 // Info 获取用户信息
 func (receive *UserSVC) Info(ctx context.Context, req *schema.UserQueryRequest) (res *schema.UserResponse, err error) {
+	ctx, span := tracing.Start(ctx, "UserSVC.Info")
+	defer span.End()
 	logger.WithContext(ctx, true).Debugf("user info, request: %#v", req)
 	options := make([]userstore.QueryOption, 0, len(req.Query)+1)
 	if len(req.Query) > 0 {
@@ -500,6 +1041,9 @@ func (receive *UserSVC) Info(ctx context.Context, req *schema.UserQueryRequest)
 
 	res = &schema.UserResponse{}
 	res.ConvertToUserResponse(user)
+	if res.Assignments, err = receive.roleAssignments(ctx, user.ID); err != nil {
+		return nil, err
+	}
 	if len(req.Query) == 0 {
 		roleName, err := receive.cache.GetSet(ctx, helpers.GetRoleCacheKey(user.Name))
 		if err != nil {
@@ -511,6 +1055,8 @@ func (receive *UserSVC) Info(ctx context.Context, req *schema.UserQueryRequest)
 }
 
 func (receive *UserSVC) ListUser(ctx context.Context, req *schema.UserListRequest) (data *schema.UserListResponse, err error) {
+	ctx, span := tracing.Start(ctx, "UserSVC.ListUser")
+	defer span.End()
 	logger.WithContext(ctx, true).Debugf("user list, request: %#v", req)
 	options := make([]userstore.QueryOption, 0)
 	// 过滤关键字
@@ -518,6 +1064,9 @@ func (receive *UserSVC) ListUser(ctx context.Context, req *schema.UserListReques
 		options = append(options, userstore.QueryByNameOrEmail(req.Keyword, req.Value))
 	}
 	// 过滤状态
+	if req.ServiceAccount != nil {
+		options = append(options, userstore.ServiceAccount(*req.ServiceAccount))
+	}
 	options = append(options, userstore.Status(req.Status), userstore.SortByCreatedDesc())
 
 	total, users, err := receive.userStore.List(ctx, req.Page, req.PageSize, options...)
@@ -0,0 +1,138 @@
+package audit
+
+import (
+	"context"
+	"qqlx/base/apierr"
+	"qqlx/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type QueryOption func(query *gorm.DB) *gorm.DB
+
+// ActorID 根据操作人查询
+func ActorID(id int) QueryOption {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("actor_id = ?", id)
+	}
+}
+
+// Action 根据操作前缀查询, 如 user. 查询所有用户操作
+func Action(action string) QueryOption {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("action LIKE ?", action+"%")
+	}
+}
+
+// TargetType 根据操作对象类型查询
+func TargetType(targetType string) QueryOption {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("target_type = ?", targetType)
+	}
+}
+
+// TargetID 根据操作对象查询
+func TargetID(targetID string) QueryOption {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("target_id = ?", targetID)
+	}
+}
+
+// Result 根据结果查询
+func Result(result string) QueryOption {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("result = ?", result)
+	}
+}
+
+// CreatedFrom 查询 from 之后的记录, 包含 from
+func CreatedFrom(from int) QueryOption {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("created_at >= ?", from)
+	}
+}
+
+// CreatedTo 查询 to 之前的记录, 不包含 to
+func CreatedTo(to int) QueryOption {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("created_at < ?", to)
+	}
+}
+
+// BeforeID 游标分页, 查询 id 小于 cursor 的记录
+func BeforeID(cursor int) QueryOption {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("id < ?", cursor)
+	}
+}
+
+type Store struct {
+	store *gorm.DB
+}
+
+func NewAuditStore(store *gorm.DB) *Store {
+	return &Store{
+		store: store,
+	}
+}
+
+// Append 锁定链头后追加记录, seal 在设置 PrevHash 后计算记录的 Hash
+//
+// 链头行锁在事务提交前不会释放, 所有实例的审计写入在这里串行
+func (receive *Store) Append(ctx context.Context, event *model.AuditEvent, seal func(event *model.AuditEvent) string) (err error) {
+	err = receive.store.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		chain := model.AuditChain{ID: 1}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).FirstOrCreate(&chain).Error; err != nil {
+			return err
+		}
+		event.PrevHash = chain.Hash
+		event.Hash = seal(event)
+		if err := tx.Create(event).Error; err != nil {
+			return err
+		}
+		return tx.Model(&chain).Updates(map[string]any{"last_id": event.ID, "hash": event.Hash}).Error
+	})
+	if err != nil {
+		return apierr.InternalServer().Set(apierr.DBErrCode, "failed to append audit event", err)
+	}
+	return nil
+}
+
+// Query 根据 id 查询记录
+func (receive *Store) Query(ctx context.Context, id int) (event *model.AuditEvent, err error) {
+	if err = receive.store.WithContext(ctx).Where("id = ?", id).Take(&event).Error; err != nil {
+		return nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to query audit event", err)
+	}
+	return event, nil
+}
+
+// Chain 哈希链的链头, 没有记录时为空
+func (receive *Store) Chain(ctx context.Context) (chain *model.AuditChain, err error) {
+	chain = new(model.AuditChain)
+	if err = receive.store.WithContext(ctx).Where("id = ?", 1).Limit(1).Find(chain).Error; err != nil {
+		return nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to query audit chain", err)
+	}
+	return chain, nil
+}
+
+// ListAfter 按 id 正序查询 id 大于 afterID 的最多 limit 条记录, 用于校验哈希链
+func (receive *Store) ListAfter(ctx context.Context, afterID, limit int) (events []model.AuditEvent, err error) {
+	err = receive.store.WithContext(ctx).Where("id > ?", afterID).Order("id").Limit(limit).Find(&events).Error
+	if err != nil {
+		return nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to list audit events", err)
+	}
+	return events, nil
+}
+
+// List 按 id 倒序查询最多 limit 条
+func (receive *Store) List(ctx context.Context, limit int, options ...QueryOption) (events []model.AuditEvent, err error) {
+	query := receive.store.WithContext(ctx).Model(&model.AuditEvent{})
+	for _, option := range options {
+		query = option(query)
+	}
+	if err = query.Order("id desc").Limit(limit).Find(&events).Error; err != nil {
+		return nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to list audit events", err)
+	}
+	return events, nil
+}
@@ -67,9 +67,23 @@ func (c *Store) SetSet(ctx context.Context, key string, value []any, expireTime
 		}
 		return nil
 	}
+	pipe := c.client.TxPipeline()
+	pipe.SAdd(ctx, saveKey, value...)
+	pipe.Expire(ctx, saveKey, *expireTime)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return apierr.InternalServer().Set(apierr.RedisErrCode, "redis set set failed", err)
+	}
 	return nil
 }
 
+// ExpireIn 缓存 ttl 后过期, ttl 不大于 0 时永不过期
+func ExpireIn(ttl time.Duration) *time.Duration {
+	if ttl <= 0 {
+		return &NeverExpires
+	}
+	return &ttl
+}
+
 func (c *Store) GetString(ctx context.Context, key string) (string, error) {
 	saveKey := fmt.Sprintf("%s:%s", c.keyPrefix, key)
 	if v, err := c.client.Get(ctx, saveKey).Result(); err != nil {
@@ -108,6 +122,24 @@ func (c *Store) SetString(ctx context.Context, key string, value string, expireT
 	return nil
 }
 
+// SetNX 键不存在时设置字符串
+//
+// expireTime 过期时间, nil 使用默认过期时间; &data.NeverExpires 表示永不过期
+func (c *Store) SetNX(ctx context.Context, key string, value string, expireTime *time.Duration) (bool, error) {
+	saveKey := fmt.Sprintf("%s:%s", c.keyPrefix, key)
+	expire := c.expireTime
+	if expireTime == &NeverExpires {
+		expire = 0
+	} else if expireTime != nil {
+		expire = *expireTime
+	}
+	ok, err := c.client.SetNX(ctx, saveKey, value, expire).Result()
+	if err != nil {
+		return false, apierr.InternalServer().Set(apierr.RedisErrCode, "redis set string nx failed", err)
+	}
+	return ok, nil
+}
+
 func (c *Store) GetInt64(ctx context.Context, key string) (*int64, error) {
 	saveKey := fmt.Sprintf("%s:%s", c.keyPrefix, key)
 	v, err := c.client.Get(ctx, saveKey).Int64()
@@ -162,3 +194,11 @@ func (c *Store) Incr(ctx context.Context, key string) (int64, error) {
 	saveKey := fmt.Sprintf("%s:%s", c.keyPrefix, key)
 	return c.client.Incr(ctx, saveKey).Result()
 }
+
+func (c *Store) Expire(ctx context.Context, key string, expireTime time.Duration) error {
+	saveKey := fmt.Sprintf("%s:%s", c.keyPrefix, key)
+	if err := c.client.Expire(ctx, saveKey, expireTime).Err(); err != nil {
+		return apierr.InternalServer().Set(apierr.RedisErrCode, "redis expire key failed", err)
+	}
+	return nil
+}
@@ -7,11 +7,15 @@ import (
 	"qqlx/base/apierr"
 	"qqlx/base/conf"
 	"qqlx/base/logger"
+	"qqlx/base/metrics"
 	"qqlx/base/reason"
+	"qqlx/base/tracing"
 	"qqlx/model"
 	"regexp"
+	"time"
 
 	"github.com/go-ldap/ldap/v3"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Store struct {
@@ -55,7 +59,7 @@ func NewLdapStore(l *ldap.Conn) (*Store, error) {
 }
 
 // CreateUser 创建用户
-func (receive *Store) CreateUser(_ context.Context, name, password, email string) error {
+func (receive *Store) CreateUser(ctx context.Context, name, password, email string) error {
 	dn := fmt.Sprintf("uid=%s,%s", name, receive.userBase)
 	userReq := ldap.NewAddRequest(dn, nil)
 	userReq.Attribute("objectClass", []string{"inetOrgPerson", "organizationalPerson", "person", "top"})
@@ -65,17 +69,17 @@ func (receive *Store) CreateUser(_ context.Context, name, password, email string
 	userReq.Attribute("mail", []string{email})
 	userReq.Attribute("displayName", []string{name})
 	userReq.Attribute("userPassword", []string{password})
-	if err := receive.ldap.Add(userReq); err != nil {
+	if err := receive.add(ctx, userReq); err != nil {
 		return apierr.InternalServer().Set(apierr.LdapErrCode, "ldap create user failed", err)
 	}
 	return nil
 }
 
 // DeleteUser 删除用户
-func (receive *Store) DeleteUser(_ context.Context, username string) error {
+func (receive *Store) DeleteUser(ctx context.Context, username string) error {
 	dn := fmt.Sprintf("uid=%s,%s", username, receive.userBase)
 	userReq := ldap.NewDelRequest(dn, nil)
-	if err := receive.ldap.Del(userReq); err != nil {
+	if err := receive.del(ctx, userReq); err != nil {
 		var ldapErr *ldap.Error
 		if errors.As(err, &ldapErr) {
 			// 用户不存在，忽略错误
@@ -89,28 +93,28 @@ func (receive *Store) DeleteUser(_ context.Context, username string) error {
 }
 
 // UpdateUserPassword 修改用户
-func (receive *Store) UpdateUserPassword(_ context.Context, username, password string) error {
+func (receive *Store) UpdateUserPassword(ctx context.Context, username, password string) error {
 	dn := fmt.Sprintf("uid=%s,%s", username, receive.userBase)
 	userReq := ldap.NewModifyRequest(dn, nil)
 	if password != "" {
 		userReq.Replace("userPassword", []string{password})
 	}
 
-	if err := receive.ldap.Modify(userReq); err != nil {
+	if err := receive.modify(ctx, userReq); err != nil {
 		return apierr.InternalServer().Set(apierr.LdapErrCode, "ldap update user password failed", err)
 	}
 	return nil
 }
 
 // SearchUser 搜索用户
-func (receive *Store) SearchUser(_ context.Context, username string) (*model.User, error) {
+func (receive *Store) SearchUser(ctx context.Context, username string) (*model.User, error) {
 	dn := fmt.Sprintf("uid=%s,%s", username, receive.userBase)
 	searchReq := ldap.NewSearchRequest(
 		receive.userBase,
 		ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
 		dn,
 		[]string{"uid", "cn", "sn", "mail", "userPassword"}, nil)
-	searchResult, err := receive.ldap.Search(searchReq)
+	searchResult, err := receive.search(ctx, searchReq)
 	if err != nil {
 		return nil, apierr.InternalServer().Set(apierr.LdapErrCode, "ldap search user failed", err)
 	}
@@ -126,7 +130,7 @@ func (receive *Store) SearchUser(_ context.Context, username string) (*model.Use
 	return user, nil
 }
 
-func (receive *Store) SearchUserGroups(_ context.Context, username string) (groups []string, err error) {
+func (receive *Store) SearchUserGroups(ctx context.Context, username string) (groups []string, err error) {
 	// 过滤条件，查询所有包含该用户 DN 的组
 	userDN := fmt.Sprintf("uid=%s,%s", ldap.EscapeFilter(username), receive.userBase)
 	filter := fmt.Sprintf("(member=%s)", ldap.EscapeFilter(userDN))
@@ -143,7 +147,7 @@ func (receive *Store) SearchUserGroups(_ context.Context, username string) (grou
 		nil,
 	)
 
-	searchResult, err := receive.ldap.Search(searchReq)
+	searchResult, err := receive.search(ctx, searchReq)
 	if err != nil {
 		return nil, apierr.InternalServer().Set(apierr.LdapErrCode, "ldap search user groups failed", err)
 	}
@@ -162,23 +166,23 @@ func (receive *Store) SearchUserGroups(_ context.Context, username string) (grou
 }
 
 // CreateGroup 创建组
-func (receive *Store) CreateGroup(_ context.Context, groupName string) error {
+func (receive *Store) CreateGroup(ctx context.Context, groupName string) error {
 	groupDN := fmt.Sprintf("cn=%s,%s", groupName, receive.groupBase)
 	groupReq := ldap.NewAddRequest(groupDN, nil)
 	groupReq.Attribute("objectClass", []string{"groupOfNames", "top"})
 	groupReq.Attribute("cn", []string{groupName})
 	groupReq.Attribute("member", []string{receive.rootDN})
-	if err := receive.ldap.Add(groupReq); err != nil {
+	if err := receive.add(ctx, groupReq); err != nil {
 		return apierr.InternalServer().Set(apierr.LdapErrCode, "ldap create group failed", err)
 	}
 	return nil
 }
 
 // DeleteGroup 删除组 如果删除的用户组不存在，返回 nil
-func (receive *Store) DeleteGroup(_ context.Context, groupName string) error {
+func (receive *Store) DeleteGroup(ctx context.Context, groupName string) error {
 	groupDN := fmt.Sprintf("cn=%s,%s", groupName, receive.groupBase)
 	groupReq := ldap.NewDelRequest(groupDN, nil)
-	if err := receive.ldap.Del(groupReq); err != nil {
+	if err := receive.del(ctx, groupReq); err != nil {
 		var ldapErr *ldap.Error
 		if errors.As(err, &ldapErr) {
 			// 组不存在，忽略错误
@@ -205,7 +209,7 @@ func (receive *Store) SearchGroup(ctx context.Context, groupName string) (exist
 		[]string{"cn", "member"},
 		nil,
 	)
-	searchResult, err := receive.ldap.Search(searchReq)
+	searchResult, err := receive.search(ctx, searchReq)
 	if err != nil {
 		return false, apierr.InternalServer().Set(apierr.LdapErrCode, "ldap search group failed", err)
 	}
@@ -222,26 +226,26 @@ func (receive *Store) SearchGroup(ctx context.Context, groupName string) (exist
 }
 
 // AddUserToGroup 添加用户到组
-func (receive *Store) AddUserToGroup(_ context.Context, groupName, userName string) error {
+func (receive *Store) AddUserToGroup(ctx context.Context, groupName, userName string) error {
 	groupDN := fmt.Sprintf("cn=%s,%s", groupName, receive.groupBase)
 	userDN := fmt.Sprintf("uid=%s,%s", userName, receive.userBase)
 	groupReq := ldap.NewModifyRequest(groupDN, nil)
 	groupReq.Add("member", []string{userDN})
-	if err := receive.ldap.Modify(groupReq); err != nil {
+	if err := receive.modify(ctx, groupReq); err != nil {
 		return apierr.InternalServer().Set(apierr.LdapErrCode, "ldap add user to group failed", err)
 	}
 	return nil
 }
 
 // RemoveUserFromGroup 从组中删除用户
-func (receive *Store) RemoveUserFromGroup(_ context.Context, groupName, userName string) error {
+func (receive *Store) RemoveUserFromGroup(ctx context.Context, groupName, userName string) error {
 	groupDN := fmt.Sprintf("cn=%s,%s", groupName, receive.groupBase)
 	userDN := fmt.Sprintf("uid=%s,%s", userName, receive.userBase)
 
 	// 删除用户
 	groupReq := ldap.NewModifyRequest(groupDN, nil)
 	groupReq.Delete("member", []string{userDN})
-	if err := receive.ldap.Modify(groupReq); err != nil {
+	if err := receive.modify(ctx, groupReq); err != nil {
 		return apierr.InternalServer().Set(apierr.LdapErrCode, "ldap remove user from group failed", err)
 	}
 	return nil
@@ -249,7 +253,7 @@ func (receive *Store) RemoveUserFromGroup(_ context.Context, groupName, userName
 
 // SearchGroupMembers 搜索组中的成员
 // 返回的成员是用户名
-func (receive *Store) SearchGroupMembers(_ context.Context, groupName string) (group *model.LdapGroup, err error) {
+func (receive *Store) SearchGroupMembers(ctx context.Context, groupName string) (group *model.LdapGroup, err error) {
 	filter := fmt.Sprintf("(cn=%s)", groupName)
 	searchReq := ldap.NewSearchRequest(
 		receive.groupBase,
@@ -262,7 +266,7 @@ func (receive *Store) SearchGroupMembers(_ context.Context, groupName string) (g
 		[]string{"cn", "member"},
 		nil,
 	)
-	searchResult, err := receive.ldap.Search(searchReq)
+	searchResult, err := receive.search(ctx, searchReq)
 	if err != nil {
 		return nil, apierr.InternalServer().Set(apierr.LdapErrCode, "ldap search group failed", err)
 	}
@@ -284,3 +288,37 @@ func (receive *Store) SearchGroupMembers(_ context.Context, groupName string) (g
 	}
 	return group, nil
 }
+
+// observe 记录 ldap 操作的耗时和 span
+func observe(ctx context.Context, operation string) func(err error) {
+	start := time.Now()
+	_, span := tracing.Start(ctx, "ldap."+operation, trace.WithSpanKind(trace.SpanKindClient))
+	return func(err error) {
+		metrics.ObserveLdap(operation, start, err)
+		tracing.End(span, err)
+	}
+}
+
+func (receive *Store) add(ctx context.Context, req *ldap.AddRequest) (err error) {
+	done := observe(ctx, "add")
+	defer func() { done(err) }()
+	return receive.ldap.Add(req)
+}
+
+func (receive *Store) del(ctx context.Context, req *ldap.DelRequest) (err error) {
+	done := observe(ctx, "delete")
+	defer func() { done(err) }()
+	return receive.ldap.Del(req)
+}
+
+func (receive *Store) modify(ctx context.Context, req *ldap.ModifyRequest) (err error) {
+	done := observe(ctx, "modify")
+	defer func() { done(err) }()
+	return receive.ldap.Modify(req)
+}
+
+func (receive *Store) search(ctx context.Context, req *ldap.SearchRequest) (res *ldap.SearchResult, err error) {
+	done := observe(ctx, "search")
+	defer func() { done(err) }()
+	return receive.ldap.Search(req)
+}
@@ -4,6 +4,7 @@ import (
 	"qqlx/base/data"
 	"qqlx/base/interfaces"
 	"qqlx/pkg/sonyflake"
+	"qqlx/store/audit"
 	"qqlx/store/cache"
 	"qqlx/store/ldap"
 	"qqlx/store/rbac"
@@ -16,20 +17,38 @@ var ProviderStore = wire.NewSet(
 	wire.Bind(new(interfaces.CacheInterface), new(*cache.Store)),
 	wire.Bind(new(interfaces.UserStoreInterface), new(*userstore.Store)),
 	wire.Bind(new(interfaces.UserRoleStoreInterface), new(*userstore.UserAssociationStore)),
+	wire.Bind(new(interfaces.PasswordHistoryStoreInterface), new(*userstore.PasswordHistoryStore)),
+	wire.Bind(new(interfaces.ApiKeyStoreInterface), new(*userstore.ApiKeyStore)),
+	wire.Bind(new(interfaces.SessionStoreInterface), new(*userstore.SessionStore)),
 	wire.Bind(new(interfaces.RoleStoreInterface), new(*rbac.RoleStore)),
 	wire.Bind(new(interfaces.PolicyStoreInterface), new(*rbac.PolicyStore)),
 	wire.Bind(new(interfaces.RolePolicyStoreInterface), new(*rbac.RoleAssociationStore)),
 	wire.Bind(new(interfaces.CasbinInterface), new(*rbac.CasbinStore)),
+	wire.Bind(new(interfaces.TenantStoreInterface), new(*rbac.TenantStore)),
+	wire.Bind(new(interfaces.TenantMemberStoreInterface), new(*rbac.TenantMemberStore)),
+	wire.Bind(new(interfaces.RevisionStoreInterface), new(*rbac.RevisionStore)),
+	wire.Bind(new(interfaces.AccessRequestStoreInterface), new(*rbac.AccessRequestStore)),
+	wire.Bind(new(interfaces.AuditStoreInterface), new(*audit.Store)),
 	wire.Bind(new(interfaces.LdapInterface), new(*ldap.Store)),
 	data.CreateRDB,
 	data.InitMySQL,
 	data.InitLdap,
+	data.InitOIDC,
+	data.InitMailer,
 	cache.NewStore,
 	userstore.NewUserStore,
 	userstore.NewUserAssociationStore,
+	userstore.NewPasswordHistoryStore,
+	userstore.NewApiKeyStore,
+	userstore.NewSessionStore,
 	rbac.NewRoleStore,
 	rbac.NewPolicyStore,
 	rbac.NewRoleAssociationStore,
+	rbac.NewTenantStore,
+	rbac.NewTenantMemberStore,
+	rbac.NewRevisionStore,
+	rbac.NewAccessRequestStore,
+	audit.NewAuditStore,
 	ldap.NewLdapStore,
 	rbac.NewCasbinStore,
 	data.InitCasbin,
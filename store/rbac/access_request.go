@@ -0,0 +1,116 @@
+package rbac
+
+import (
+	"context"
+	"qqlx/base/apierr"
+	"qqlx/model"
+
+	"gorm.io/gorm"
+)
+
+type AccessRequestQueryOption func(query *gorm.DB) *gorm.DB
+
+// AccessRequestUserID 查询用户的申请
+func AccessRequestUserID(userID int) AccessRequestQueryOption {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("user_id = ?", userID)
+	}
+}
+
+// AccessRequestRoleID 查询角色的申请
+func AccessRequestRoleID(roleID int) AccessRequestQueryOption {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("role_id = ?", roleID)
+	}
+}
+
+// AccessRequestStatus 根据状态查询
+func AccessRequestStatus(status string) AccessRequestQueryOption {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("status = ?", status)
+	}
+}
+
+// AccessRequestApprover 查询用户可以审批的申请
+func AccessRequestApprover(userID int) AccessRequestQueryOption {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("role_id IN (?)", query.Session(&gorm.Session{NewDB: true}).
+			Table("role_approver").Select("role_id").Where("user_id = ?", userID))
+	}
+}
+
+// AccessRequestExpiredBefore 查询在 now 之前过期的申请
+func AccessRequestExpiredBefore(now int) AccessRequestQueryOption {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("expires_at <= ?", now)
+	}
+}
+
+type AccessRequestStore struct {
+	store *gorm.DB
+}
+
+func NewAccessRequestStore(store *gorm.DB) *AccessRequestStore {
+	return &AccessRequestStore{
+		store: store,
+	}
+}
+
+func (receive *AccessRequestStore) Create(ctx context.Context, request *model.AccessRequest) (err error) {
+	if err = receive.store.WithContext(ctx).Create(request).Error; err != nil {
+		return apierr.InternalServer().Set(apierr.DBErrCode, "failed to create access request", err)
+	}
+	return nil
+}
+
+func (receive *AccessRequestStore) Query(ctx context.Context, id int) (request *model.AccessRequest, err error) {
+	err = receive.store.WithContext(ctx).Preload("User").Preload("Role.Approvers").
+		Where("id = ?", id).Take(&request).Error
+	if err != nil {
+		return nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to query access request", err)
+	}
+	return request, nil
+}
+
+func (receive *AccessRequestStore) Save(ctx context.Context, request *model.AccessRequest) (err error) {
+	if err = receive.store.WithContext(ctx).Omit("User", "Role").Save(request).Error; err != nil {
+		return apierr.InternalServer().Set(apierr.DBErrCode, "failed to save access request", err)
+	}
+	return nil
+}
+
+// Decide 条件更新, 并发审批时只有一个请求更新成功
+func (receive *AccessRequestStore) Decide(ctx context.Context, request *model.AccessRequest, from string) (ok bool, err error) {
+	result := receive.store.WithContext(ctx).Model(&model.AccessRequest{}).
+		Where("id = ? AND status = ?", request.ID, from).
+		Updates(map[string]any{
+			"status":      request.Status,
+			"duration":    request.Duration,
+			"approver_id": request.ApproverID,
+			"comment":     request.Comment,
+			"decided_at":  request.DecidedAt,
+		})
+	if result.Error != nil {
+		return false, apierr.InternalServer().Set(apierr.DBErrCode, "failed to decide access request", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// List 按申请时间倒序查询, 加载申请人和角色
+func (receive *AccessRequestStore) List(ctx context.Context, page, pageSize int, options ...AccessRequestQueryOption) (total int64, requests []model.AccessRequest, err error) {
+	query := receive.store.WithContext(ctx).Model(&model.AccessRequest{})
+	for _, option := range options {
+		query = option(query)
+	}
+	if err = query.Count(&total).Error; err != nil {
+		return 0, nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to count access requests", err)
+	}
+	query = query.Preload("User").Preload("Role").Order("id desc")
+	if page != -1 || pageSize != -1 {
+		query = query.Offset((page - 1) * pageSize).Limit(pageSize)
+	}
+	if err = query.Find(&requests).Error; err != nil {
+		return 0, nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to list access requests", err)
+	}
+	return total, requests, nil
+}
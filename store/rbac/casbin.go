@@ -3,6 +3,9 @@ package rbac
 import (
 	"context"
 	"qqlx/base/apierr"
+	"qqlx/base/constant"
+	"qqlx/base/metrics"
+	"time"
 
 	"github.com/casbin/casbin/v2"
 )
@@ -28,10 +31,10 @@ func (receive *CasbinStore) GetRolePolicyByName(_ context.Context, role string)
 
 // CreateRolePolices CreateRolePolicy 创建role拥有的权限
 //
-// polices [][]string{role, path, method}
+// polices [][]string{role, path, method, eft, dom, cond}
 func (receive *CasbinStore) CreateRolePolices(_ context.Context, polices [][]string) (err error) {
 	for _, v := range polices {
-		_, err := receive.enforcer.AddPolicy(v[0], v[1], v[2])
+		_, err := receive.enforcer.AddPolicy(v)
 		if err != nil {
 			return apierr.InternalServer().Set(apierr.CasbinErrCode, "failed to create casbin policy", err)
 		}
@@ -47,7 +50,7 @@ func (receive *CasbinStore) CreateRolePolices(_ context.Context, polices [][]str
 
 // DeleteRolePolices 删除role拥有的权限
 //
-// polices [][]string{role, path, method}
+// polices [][]string{role, path, method, eft, dom, cond}
 func (receive *CasbinStore) DeleteRolePolices(_ context.Context, polices [][]string) (err error) {
 	_, err = receive.enforcer.RemovePolicies(polices)
 	if err != nil {
@@ -58,7 +61,7 @@ func (receive *CasbinStore) DeleteRolePolices(_ context.Context, polices [][]str
 
 // UpdateRolePolices  更新role拥有的权限
 //
-// polices [][]string{role, path, method}
+// polices [][]string{role, path, method, eft, dom, cond}
 func (receive *CasbinStore) UpdateRolePolices(ctx context.Context, roleName string, polices [][]string) (err error) {
 	oldPolicys, err := receive.GetRolePolicyByName(ctx, roleName)
 	if err != nil {
@@ -72,20 +75,101 @@ func (receive *CasbinStore) UpdateRolePolices(ctx context.Context, roleName stri
 	return nil
 }
 
+// GetImplicitRolePolices 获取角色的有效策略, 包含从父角色继承的策略
+//
+// polices [][]string{来源角色, path, method, eft, dom, cond}
+func (receive *CasbinStore) GetImplicitRolePolices(_ context.Context, role string) (polices [][]string, err error) {
+	polices, err = receive.enforcer.GetImplicitPermissionsForUser(role)
+	if err != nil {
+		return nil, apierr.InternalServer().Set(apierr.CasbinErrCode, "failed to get casbin implicit policy", err)
+	}
+	return polices, nil
+}
+
+// SetRoleParents 设置角色继承关系, 替换原有的 g 规则
+func (receive *CasbinStore) SetRoleParents(_ context.Context, role string, parents []string) (err error) {
+	if _, err = receive.enforcer.RemoveFilteredGroupingPolicy(0, role); err != nil {
+		return apierr.InternalServer().Set(apierr.CasbinErrCode, "failed to delete casbin grouping policy", err)
+	}
+	if len(parents) == 0 {
+		return nil
+	}
+	rules := make([][]string, 0, len(parents))
+	for _, parent := range parents {
+		rules = append(rules, []string{role, parent})
+	}
+	if _, err = receive.enforcer.AddGroupingPolicies(rules); err != nil {
+		return apierr.InternalServer().Set(apierr.CasbinErrCode, "failed to create casbin grouping policy", err)
+	}
+	return nil
+}
+
 type Authentication struct {
 	enforcer *casbin.Enforcer
 }
 
 func NewAuthentication(enforcer *casbin.Enforcer) *Authentication {
+	registerConditionFunctions(enforcer)
 	return &Authentication{
 		enforcer: enforcer,
 	}
 }
 
-func (a *Authentication) EnforceWithCtx(_ context.Context, sub, obj, act string) (ok bool, err error) {
-	ok, err = a.enforcer.Enforce(sub, obj, act)
+func (a *Authentication) EnforceWithCtx(_ context.Context, sub, dom, obj, act string, env *ConditionEnv) (ok bool, err error) {
+	start := time.Now()
+	ok, err = a.enforcer.Enforce(sub, dom, obj, act, env)
+	metrics.CasbinEnforceDuration.Observe(time.Since(start).Seconds())
 	if err != nil {
+		metrics.CasbinDecisions.WithLabelValues(metrics.ResultError).Inc()
 		return false, apierr.Forbidden().Set(apierr.CasbinErrCode, "failed to enforce casbin policy", err)
 	}
+	if ok {
+		metrics.CasbinDecisions.WithLabelValues(metrics.ResultAllow).Inc()
+	} else {
+		metrics.CasbinDecisions.WithLabelValues(metrics.ResultDeny).Inc()
+	}
+	return ok, nil
+}
+
+// EnforceRolesWithCtx 使用多个角色鉴权, 任一角色匹配到 deny 策略时拒绝, 否则任一角色允许即允许
+func (a *Authentication) EnforceRolesWithCtx(_ context.Context, roles []string, dom, obj, act string, env *ConditionEnv) (ok bool, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.CasbinEnforceDuration.Observe(time.Since(start).Seconds())
+		switch {
+		case err != nil:
+			metrics.CasbinDecisions.WithLabelValues(metrics.ResultError).Inc()
+		case ok:
+			metrics.CasbinDecisions.WithLabelValues(metrics.ResultAllow).Inc()
+		default:
+			metrics.CasbinDecisions.WithLabelValues(metrics.ResultDeny).Inc()
+		}
+	}()
+	for _, role := range roles {
+		allowed, explain, err := a.enforcer.EnforceEx(role, dom, obj, act, env)
+		if err != nil {
+			return false, apierr.Forbidden().Set(apierr.CasbinErrCode, "failed to enforce casbin policy", err)
+		}
+		if IsDenyExplain(allowed, explain) {
+			return false, nil
+		}
+		ok = ok || allowed
+	}
 	return ok, nil
 }
+
+// IsDenyExplain 鉴权结果是否由 deny 策略决定
+func IsDenyExplain(allowed bool, explain []string) bool {
+	return !allowed && len(explain) > 3 && explain[3] == constant.PolicyEffectDeny
+}
+
+// ExplainWithCtx 鉴权并返回决定结果的策略
+//
+// explain []string{role, path, method, eft, dom, cond}, 没有匹配的策略时为空
+func (a *Authentication) ExplainWithCtx(_ context.Context, sub, dom, obj, act string, env *ConditionEnv) (ok bool, explain []string, err error) {
+	ok, explain, err = a.enforcer.EnforceEx(sub, dom, obj, act, env)
+	if err != nil {
+		return false, nil, apierr.Forbidden().Set(apierr.CasbinErrCode, "failed to enforce casbin policy", err)
+	}
+	return ok, explain, nil
+}
@@ -0,0 +1,105 @@
+package rbac
+
+import (
+	"errors"
+	"fmt"
+	"qqlx/model"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/util"
+	"github.com/casbin/govaluate"
+)
+
+// ConditionEnv 条件策略可以使用的请求属性, 策略条件中通过 r.env.<字段> 访问
+//
+// 例如 "r.env.Owner" 只能操作自己, "r.env.Hour >= 9 && r.env.Hour < 18" 只在工作时间生效,
+// "userAvailable(r.env)" 路由 :id 对应的用户为可用状态
+type ConditionEnv struct {
+	// UserID 当前用户ID
+	UserID int
+	// ResourceID 路由参数 :id, 没有时为 0
+	ResourceID int
+	// Owner :id 是否为当前用户
+	Owner bool
+	// Tenant 请求所在的租户, 0 表示不在租户内
+	Tenant int
+	// Hour 服务器本地时间的小时, 0-23
+	Hour int
+	// Weekday 星期, 0 为星期日
+	Weekday int
+
+	loadUser func(id int) (*model.User, error)
+	user     *model.User
+}
+
+// NewConditionEnv 创建条件属性, loadUser 在条件使用 :id 对应的用户时才会调用
+func NewConditionEnv(userID, resourceID, tenant int, now time.Time, loadUser func(id int) (*model.User, error)) *ConditionEnv {
+	return &ConditionEnv{
+		UserID:     userID,
+		ResourceID: resourceID,
+		Owner:      resourceID != 0 && resourceID == userID,
+		Tenant:     tenant,
+		Hour:       now.Hour(),
+		Weekday:    int(now.Weekday()),
+		loadUser:   loadUser,
+	}
+}
+
+// UserAvailable :id 对应的用户是否为可用状态, 同一请求只查询一次
+func (receive *ConditionEnv) UserAvailable() (bool, error) {
+	if receive.ResourceID == 0 || receive.loadUser == nil {
+		return false, nil
+	}
+	if receive.user == nil {
+		user, err := receive.loadUser(receive.ResourceID)
+		if err != nil {
+			return false, err
+		}
+		receive.user = user
+	}
+	return receive.user.Status != nil && *receive.user.Status == model.UserStatusAvailable, nil
+}
+
+// conditionFunctions 策略条件中可以调用的函数
+func conditionFunctions() map[string]govaluate.ExpressionFunction {
+	return map[string]govaluate.ExpressionFunction{
+		"userAvailable": func(args ...interface{}) (interface{}, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("function userAvailable expected 1 argument, but got %d", len(args))
+			}
+			env, ok := args[0].(*ConditionEnv)
+			if !ok {
+				return nil, errors.New("argument of userAvailable must be r.env")
+			}
+			return env.UserAvailable()
+		},
+	}
+}
+
+// registerConditionFunctions 注册策略条件函数, 需要在鉴权之前调用
+func registerConditionFunctions(enforcer *casbin.Enforcer) {
+	for name, function := range conditionFunctions() {
+		enforcer.AddFunction(name, function)
+	}
+}
+
+// ValidCondition 校验策略条件, 条件只能使用 r.env 属性和条件函数, 结果必须为 bool
+func ValidCondition(condition string) error {
+	expression, err := govaluate.NewEvaluableExpressionWithFunctions(util.EscapeAssertion(condition), conditionFunctions())
+	if err != nil {
+		return err
+	}
+	available := model.UserStatusAvailable
+	env := NewConditionEnv(1, 1, 0, time.Now(), func(id int) (*model.User, error) {
+		return &model.User{ID: id, Status: &available}, nil
+	})
+	result, err := expression.Evaluate(map[string]interface{}{"r_env": env})
+	if err != nil {
+		return err
+	}
+	if _, ok := result.(bool); !ok {
+		return fmt.Errorf("condition result must be bool, got %v", result)
+	}
+	return nil
+}
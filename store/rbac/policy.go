@@ -45,6 +45,13 @@ func InPolicy(ids []int) PolicyQueryOption {
 	}
 }
 
+// InPolicyNames 根据 names 查询策略
+func InPolicyNames(names []string) PolicyQueryOption {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("name IN ?", names)
+	}
+}
+
 // NotInPolicyNames 过滤掉 names 的策略
 func NotInPolicyNames(names []string) PolicyQueryOption {
 	return func(query *gorm.DB) *gorm.DB {
@@ -64,6 +71,13 @@ func PolicyQueryByName(keyword string, value string) PolicyQueryOption {
 	}
 }
 
+// PolicyPathPrefix 根据路径前缀查询 policy
+func PolicyPathPrefix(prefix string) PolicyQueryOption {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("path LIKE ?", prefix+"%")
+	}
+}
+
 type PolicyDeleteOption func(query *gorm.DB) *gorm.DB
 
 // PolicyUnscoped 永久删除 policy
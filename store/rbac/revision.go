@@ -0,0 +1,153 @@
+package rbac
+
+import (
+	"context"
+	"qqlx/base/apierr"
+	"qqlx/base/helpers"
+	"qqlx/model"
+
+	"gorm.io/gorm"
+)
+
+// rolePolicy role_policy 关联表的一行
+type rolePolicy struct {
+	RoleID   int
+	PolicyID int
+}
+
+func (receiver *rolePolicy) TableName() string {
+	return "role_policy"
+}
+
+// roleParent role_parent 关联表的一行
+type roleParent struct {
+	RoleID   int
+	ParentID int
+}
+
+func (receiver *roleParent) TableName() string {
+	return "role_parent"
+}
+
+// RevisionRestore 回滚时在同一事务中恢复的数据
+type RevisionRestore struct {
+	// RolePolicy 角色ID对应的策略ID, 为空时清空该角色的策略
+	RolePolicy map[int][]int
+	// RoleParents 角色ID对应的父角色ID, 为 nil 时不恢复继承关系
+	RoleParents map[int][]int
+	// Polices 需要恢复效果和条件的策略
+	Polices []model.Policy
+}
+
+type RevisionStore struct {
+	store *gorm.DB
+}
+
+func NewRevisionStore(store *gorm.DB) *RevisionStore {
+	return &RevisionStore{
+		store: store,
+	}
+}
+
+func (receive *RevisionStore) Create(ctx context.Context, revision *model.RbacRevision) (err error) {
+	if err = receive.store.WithContext(ctx).Create(revision).Error; err != nil {
+		return apierr.InternalServer().Set(apierr.DBErrCode, "failed to create rbac revision", err)
+	}
+	return nil
+}
+
+func (receive *RevisionStore) Query(ctx context.Context, id int) (revision *model.RbacRevision, err error) {
+	if err = receive.store.WithContext(ctx).Where("id = ?", id).Take(&revision).Error; err != nil {
+		return nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to query rbac revision", err)
+	}
+	return revision, nil
+}
+
+// List 按版本倒序查询, 不返回快照
+func (receive *RevisionStore) List(ctx context.Context, page, pageSize int) (total int64, revisions []model.RbacRevision, err error) {
+	query := receive.store.WithContext(ctx).Model(&model.RbacRevision{})
+	if err = query.Count(&total).Error; err != nil {
+		return 0, nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to count rbac revisions", err)
+	}
+	query = query.Omit("snapshot", "parents", "polices").Order("id desc")
+	if page != -1 || pageSize != -1 {
+		query = query.Offset((page - 1) * pageSize).Limit(pageSize)
+	}
+	if err = query.Find(&revisions).Error; err != nil {
+		return 0, nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to list rbac revisions", err)
+	}
+	return total, revisions, nil
+}
+
+// Snapshot 当前所有角色的策略ID, key 为角色的 casbin subject
+func (receive *RevisionStore) Snapshot(ctx context.Context) (snapshot map[string][]int, err error) {
+	var rows []struct {
+		Name     string
+		TenantID int
+		PolicyID int
+	}
+	err = receive.store.WithContext(ctx).Table("role_policy").
+		Select("roles.name AS name, roles.tenant_id AS tenant_id, role_policy.policy_id AS policy_id").
+		Joins("JOIN roles ON roles.id = role_policy.role_id").
+		Order("role_policy.policy_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to query role policy snapshot", err)
+	}
+	snapshot = make(map[string][]int)
+	for _, row := range rows {
+		subject := helpers.GetCasbinSubject(row.TenantID, row.Name)
+		snapshot[subject] = append(snapshot[subject], row.PolicyID)
+	}
+	return snapshot, nil
+}
+
+// Restore 在事务中恢复角色的策略、父角色以及策略的效果和条件, sync 返回错误时回滚事务
+func (receive *RevisionStore) Restore(ctx context.Context, restore *RevisionRestore, sync func() error) (err error) {
+	return receive.store.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(restore.RolePolicy) > 0 {
+			roleIDs := make([]int, 0, len(restore.RolePolicy))
+			rows := make([]rolePolicy, 0)
+			for roleID, policyIDs := range restore.RolePolicy {
+				roleIDs = append(roleIDs, roleID)
+				for _, policyID := range policyIDs {
+					rows = append(rows, rolePolicy{RoleID: roleID, PolicyID: policyID})
+				}
+			}
+			if err := tx.Where("role_id IN ?", roleIDs).Delete(&rolePolicy{}).Error; err != nil {
+				return apierr.InternalServer().Set(apierr.DBErrCode, "failed to delete role policy", err)
+			}
+			if len(rows) > 0 {
+				if err := tx.Create(&rows).Error; err != nil {
+					return apierr.InternalServer().Set(apierr.DBErrCode, "failed to restore role policy", err)
+				}
+			}
+		}
+		if len(restore.RoleParents) > 0 {
+			roleIDs := make([]int, 0, len(restore.RoleParents))
+			rows := make([]roleParent, 0)
+			for roleID, parentIDs := range restore.RoleParents {
+				roleIDs = append(roleIDs, roleID)
+				for _, parentID := range parentIDs {
+					rows = append(rows, roleParent{RoleID: roleID, ParentID: parentID})
+				}
+			}
+			if err := tx.Where("role_id IN ?", roleIDs).Delete(&roleParent{}).Error; err != nil {
+				return apierr.InternalServer().Set(apierr.DBErrCode, "failed to delete role parent", err)
+			}
+			if len(rows) > 0 {
+				if err := tx.Create(&rows).Error; err != nil {
+					return apierr.InternalServer().Set(apierr.DBErrCode, "failed to restore role parent", err)
+				}
+			}
+		}
+		for _, policy := range restore.Polices {
+			err := tx.Model(&model.Policy{}).Where("id = ?", policy.ID).
+				Updates(map[string]any{"effect": policy.Effect, "condition": policy.Condition}).Error
+			if err != nil {
+				return apierr.InternalServer().Set(apierr.DBErrCode, "failed to restore policy", err)
+			}
+		}
+		return sync()
+	})
+}
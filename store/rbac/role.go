@@ -32,6 +32,20 @@ func RoleID(id int) RoleQueryOption {
 	}
 }
 
+// InRole 根据 role id 列表查询
+func InRole(ids []int) RoleQueryOption {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("id in (?)", ids)
+	}
+}
+
+// RoleTenantID 根据所属租户查询 role, 0 为全局角色
+func RoleTenantID(tenantID int) RoleQueryOption {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("tenant_id = ?", tenantID)
+	}
+}
+
 // RoleSortByCreatedDesc 按照创建时间倒序
 func RoleSortByCreatedDesc() RoleQueryOption {
 	return func(query *gorm.DB) *gorm.DB {
@@ -53,6 +67,20 @@ func LoadPolicies() RoleQueryOption {
 	}
 }
 
+// LoadParents role 设置预加载 Parents
+func LoadParents() RoleQueryOption {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Preload("Parents")
+	}
+}
+
+// LoadApprovers role 设置预加载 Approvers
+func LoadApprovers() RoleQueryOption {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Preload("Approvers")
+	}
+}
+
 // LoadUsers role 设置预加载 Users
 func LoadUsers() RoleQueryOption {
 	return func(query *gorm.DB) *gorm.DB {
@@ -200,3 +228,33 @@ func (r *RoleAssociationStore) DeletePolicy(ctx context.Context, role *model.Rol
 	}
 	return nil
 }
+
+// ReplaceParents 在事务中替换角色的父角色, parents 为空时清空, sync 返回错误时回滚事务
+func (r *RoleAssociationStore) ReplaceParents(ctx context.Context, role *model.Role, parents []model.Role, sync func() error) (err error) {
+	return r.store.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		association := tx.Model(&role).Association("Parents")
+		if len(parents) == 0 {
+			err = association.Clear()
+		} else {
+			err = association.Replace(&parents)
+		}
+		if err != nil {
+			return apierr.InternalServer().Set(apierr.DBErrCode, "failed replace parent roles", err)
+		}
+		return sync()
+	})
+}
+
+// ReplaceApprovers 替换角色的审批人, approvers 为空时清空
+func (r *RoleAssociationStore) ReplaceApprovers(ctx context.Context, role *model.Role, approvers []model.User) (err error) {
+	association := r.store.WithContext(ctx).Model(&role).Association("Approvers")
+	if len(approvers) == 0 {
+		err = association.Clear()
+	} else {
+		err = association.Replace(&approvers)
+	}
+	if err != nil {
+		return apierr.InternalServer().Set(apierr.DBErrCode, "failed replace role approvers", err)
+	}
+	return nil
+}
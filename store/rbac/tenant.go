@@ -0,0 +1,127 @@
+package rbac
+
+import (
+	"context"
+	"qqlx/base/apierr"
+	"qqlx/model"
+
+	"gorm.io/gorm"
+)
+
+type TenantStore struct {
+	store *gorm.DB
+}
+
+func NewTenantStore(store *gorm.DB) *TenantStore {
+	return &TenantStore{
+		store: store,
+	}
+}
+
+func (receive *TenantStore) Create(ctx context.Context, tenant *model.Tenant) (err error) {
+	if err = receive.store.WithContext(ctx).Create(tenant).Error; err != nil {
+		return apierr.InternalServer().Set(apierr.DBErrCode, "failed to create tenant", err)
+	}
+	return nil
+}
+
+func (receive *TenantStore) Query(ctx context.Context, id int) (tenant *model.Tenant, err error) {
+	if err = receive.store.WithContext(ctx).Where("id = ?", id).Take(&tenant).Error; err != nil {
+		return nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to query tenant", err)
+	}
+	return tenant, nil
+}
+
+func (receive *TenantStore) QueryByName(ctx context.Context, name string) (tenant *model.Tenant, err error) {
+	if err = receive.store.WithContext(ctx).Where("name = ?", name).Take(&tenant).Error; err != nil {
+		return nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to query tenant", err)
+	}
+	return tenant, nil
+}
+
+func (receive *TenantStore) List(ctx context.Context) (tenants []model.Tenant, err error) {
+	if err = receive.store.WithContext(ctx).Order("id desc").Find(&tenants).Error; err != nil {
+		return nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to list tenants", err)
+	}
+	return tenants, nil
+}
+
+func (receive *TenantStore) Delete(ctx context.Context, tenant *model.Tenant) (err error) {
+	if err = receive.store.WithContext(ctx).Unscoped().Delete(tenant).Error; err != nil {
+		return apierr.InternalServer().Set(apierr.DBErrCode, "failed to delete tenant", err)
+	}
+	return nil
+}
+
+type TenantMemberStore struct {
+	store *gorm.DB
+}
+
+func NewTenantMemberStore(store *gorm.DB) *TenantMemberStore {
+	return &TenantMemberStore{
+		store: store,
+	}
+}
+
+// Query 查询租户成员, 同时加载成员在租户内的角色
+func (receive *TenantMemberStore) Query(ctx context.Context, tenantID, userID int) (member *model.TenantMember, err error) {
+	err = receive.store.WithContext(ctx).Preload("Roles").
+		Where("tenant_id = ? AND user_id = ?", tenantID, userID).Take(&member).Error
+	if err != nil {
+		return nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to query tenant member", err)
+	}
+	return member, nil
+}
+
+func (receive *TenantMemberStore) List(ctx context.Context, tenantID int) (members []model.TenantMember, err error) {
+	err = receive.store.WithContext(ctx).Preload("Roles").Preload("User").
+		Where("tenant_id = ?", tenantID).Order("id").Find(&members).Error
+	if err != nil {
+		return nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to list tenant members", err)
+	}
+	return members, nil
+}
+
+func (receive *TenantMemberStore) Save(ctx context.Context, member *model.TenantMember) (err error) {
+	if err = receive.store.WithContext(ctx).Omit("Roles", "User").Save(member).Error; err != nil {
+		return apierr.InternalServer().Set(apierr.DBErrCode, "failed to save tenant member", err)
+	}
+	return nil
+}
+
+// ReplaceRoles 替换成员在租户内的角色, roles 为空时清空
+func (receive *TenantMemberStore) ReplaceRoles(ctx context.Context, member *model.TenantMember, roles []model.Role) (err error) {
+	association := receive.store.WithContext(ctx).Model(member).Association("Roles")
+	if len(roles) == 0 {
+		err = association.Clear()
+	} else {
+		err = association.Replace(&roles)
+	}
+	if err != nil {
+		return apierr.InternalServer().Set(apierr.DBErrCode, "failed to replace tenant member roles", err)
+	}
+	return nil
+}
+
+// CountByRole 统计拥有该租户角色的成员数量
+func (receive *TenantMemberStore) CountByRole(ctx context.Context, roleID int) (count int64, err error) {
+	err = receive.store.WithContext(ctx).Table("tenant_member_role").Where("role_id = ?", roleID).Count(&count).Error
+	if err != nil {
+		return 0, apierr.InternalServer().Set(apierr.DBErrCode, "failed to count tenant member roles", err)
+	}
+	return count, nil
+}
+
+// Delete 删除成员及其租户内的角色
+func (receive *TenantMemberStore) Delete(ctx context.Context, member *model.TenantMember) (err error) {
+	err = receive.store.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(member).Association("Roles").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(member).Error
+	})
+	if err != nil {
+		return apierr.InternalServer().Set(apierr.DBErrCode, "failed to delete tenant member", err)
+	}
+	return nil
+}
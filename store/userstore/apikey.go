@@ -0,0 +1,67 @@
+package userstore
+
+import (
+	"context"
+	"qqlx/base/apierr"
+	"qqlx/model"
+
+	"gorm.io/gorm"
+)
+
+type ApiKeyStore struct {
+	store *gorm.DB
+}
+
+func NewApiKeyStore(store *gorm.DB) *ApiKeyStore {
+	return &ApiKeyStore{
+		store: store,
+	}
+}
+
+func (receive *ApiKeyStore) Create(ctx context.Context, key *model.ApiKey) (err error) {
+	if err = receive.store.WithContext(ctx).Create(key).Error; err != nil {
+		return apierr.InternalServer().Set(apierr.DBErrCode, "failed to create api key", err)
+	}
+	return nil
+}
+
+// QueryByHash 根据摘要查询, 同时加载所属用户
+func (receive *ApiKeyStore) QueryByHash(ctx context.Context, hash string) (key *model.ApiKey, err error) {
+	err = receive.store.WithContext(ctx).Preload("User").Where("hash = ?", hash).Take(&key).Error
+	if err != nil {
+		return nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to query api key", err)
+	}
+	return key, nil
+}
+
+func (receive *ApiKeyStore) Query(ctx context.Context, id, userID int) (key *model.ApiKey, err error) {
+	err = receive.store.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Take(&key).Error
+	if err != nil {
+		return nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to query api key", err)
+	}
+	return key, nil
+}
+
+func (receive *ApiKeyStore) List(ctx context.Context, userID int) (keys []model.ApiKey, err error) {
+	err = receive.store.WithContext(ctx).Where("user_id = ?", userID).Order("id desc").Find(&keys).Error
+	if err != nil {
+		return nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to list api keys", err)
+	}
+	return keys, nil
+}
+
+func (receive *ApiKeyStore) Delete(ctx context.Context, key *model.ApiKey) (err error) {
+	if err = receive.store.WithContext(ctx).Delete(key).Error; err != nil {
+		return apierr.InternalServer().Set(apierr.DBErrCode, "failed to delete api key", err)
+	}
+	return nil
+}
+
+// Touch 更新最近使用时间
+func (receive *ApiKeyStore) Touch(ctx context.Context, id, lastUsedAt int) (err error) {
+	err = receive.store.WithContext(ctx).Model(&model.ApiKey{}).Where("id = ?", id).UpdateColumn("last_used_at", lastUsedAt).Error
+	if err != nil {
+		return apierr.InternalServer().Set(apierr.DBErrCode, "failed to update api key", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package userstore
+
+import (
+	"context"
+	"qqlx/base/apierr"
+	"qqlx/model"
+
+	"gorm.io/gorm"
+)
+
+type PasswordHistoryStore struct {
+	store *gorm.DB
+}
+
+func NewPasswordHistoryStore(store *gorm.DB) *PasswordHistoryStore {
+	return &PasswordHistoryStore{
+		store: store,
+	}
+}
+
+func (receive *PasswordHistoryStore) Create(ctx context.Context, history *model.PasswordHistory) (err error) {
+	if err = receive.store.WithContext(ctx).Create(history).Error; err != nil {
+		return apierr.InternalServer().Set(apierr.DBErrCode, "failed to create password history", err)
+	}
+	return nil
+}
+
+func (receive *PasswordHistoryStore) ListRecent(ctx context.Context, userID, limit int) (histories []model.PasswordHistory, err error) {
+	err = receive.store.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("id desc").
+		Limit(limit).
+		Find(&histories).Error
+	if err != nil {
+		return nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to list password history", err)
+	}
+	return histories, nil
+}
+
+func (receive *PasswordHistoryStore) Prune(ctx context.Context, userID, keep int) (err error) {
+	var ids []int
+	err = receive.store.WithContext(ctx).Model(&model.PasswordHistory{}).
+		Where("user_id = ?", userID).
+		Order("id desc").
+		Offset(keep).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return apierr.InternalServer().Set(apierr.DBErrCode, "failed to prune password history", err)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if err = receive.store.WithContext(ctx).Delete(&model.PasswordHistory{}, ids).Error; err != nil {
+		return apierr.InternalServer().Set(apierr.DBErrCode, "failed to prune password history", err)
+	}
+	return nil
+}
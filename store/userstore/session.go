@@ -0,0 +1,90 @@
+package userstore
+
+import (
+	"context"
+	"qqlx/base/apierr"
+	"qqlx/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SessionStore struct {
+	store *gorm.DB
+}
+
+func NewSessionStore(store *gorm.DB) *SessionStore {
+	return &SessionStore{
+		store: store,
+	}
+}
+
+func (receive *SessionStore) Create(ctx context.Context, session *model.Session) (err error) {
+	if err = receive.store.WithContext(ctx).Create(session).Error; err != nil {
+		return apierr.InternalServer().Set(apierr.DBErrCode, "failed to create session", err)
+	}
+	return nil
+}
+
+func (receive *SessionStore) Query(ctx context.Context, id string, userID int) (session *model.Session, err error) {
+	err = receive.store.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Take(&session).Error
+	if err != nil {
+		return nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to query session", err)
+	}
+	return session, nil
+}
+
+// List 查询用户未过期的会话
+func (receive *SessionStore) List(ctx context.Context, userID int) (sessions []model.Session, err error) {
+	err = receive.store.WithContext(ctx).
+		Where("user_id = ? AND expires_at > ?", userID, time.Now().Unix()).
+		Order("created_at desc").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to list sessions", err)
+	}
+	return sessions, nil
+}
+
+// Rotate 刷新 token 后更新会话对应的 access token
+func (receive *SessionStore) Rotate(ctx context.Context, id, tokenID string, expiresAt int) (err error) {
+	err = receive.store.WithContext(ctx).Model(&model.Session{}).Where("id = ?", id).
+		Updates(map[string]any{"token_id": tokenID, "expires_at": expiresAt}).Error
+	if err != nil {
+		return apierr.InternalServer().Set(apierr.DBErrCode, "failed to update session", err)
+	}
+	return nil
+}
+
+// Touch 更新最近访问时间
+func (receive *SessionStore) Touch(ctx context.Context, id string, lastSeenAt int) (err error) {
+	err = receive.store.WithContext(ctx).Model(&model.Session{}).Where("id = ?", id).UpdateColumn("last_seen_at", lastSeenAt).Error
+	if err != nil {
+		return apierr.InternalServer().Set(apierr.DBErrCode, "failed to update session", err)
+	}
+	return nil
+}
+
+func (receive *SessionStore) Delete(ctx context.Context, id string) (err error) {
+	if err = receive.store.WithContext(ctx).Where("id = ?", id).Delete(&model.Session{}).Error; err != nil {
+		return apierr.InternalServer().Set(apierr.DBErrCode, "failed to delete session", err)
+	}
+	return nil
+}
+
+// DeleteByUser 删除用户的所有会话
+func (receive *SessionStore) DeleteByUser(ctx context.Context, userID int) (err error) {
+	if err = receive.store.WithContext(ctx).Where("user_id = ?", userID).Delete(&model.Session{}).Error; err != nil {
+		return apierr.InternalServer().Set(apierr.DBErrCode, "failed to delete sessions", err)
+	}
+	return nil
+}
+
+// Purge 物理删除已过期和已软删除的会话
+func (receive *SessionStore) Purge(ctx context.Context, now int) (count int64, err error) {
+	result := receive.store.WithContext(ctx).Unscoped().Where("expires_at <= ? OR deleted_at != 0", now).Delete(&model.Session{})
+	if result.Error != nil {
+		return 0, apierr.InternalServer().Set(apierr.DBErrCode, "failed to purge sessions", result.Error)
+	}
+	return result.RowsAffected, nil
+}
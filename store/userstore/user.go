@@ -19,6 +19,13 @@ func ID(id int) QueryOption {
 	}
 }
 
+// IDs 根据多个 user id 查询
+func IDs(ids []int) QueryOption {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("id IN ?", ids)
+	}
+}
+
 // Name 根据 user name 查询
 func Name(name string) QueryOption {
 	return func(query *gorm.DB) *gorm.DB {
@@ -26,6 +33,13 @@ func Name(name string) QueryOption {
 	}
 }
 
+// WithDeleted 查询时包含已删除的 user, 用于检查唯一索引
+func WithDeleted() QueryOption {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Unscoped()
+	}
+}
+
 // Email 根据 user email 查询
 func Email(email string) QueryOption {
 	return func(query *gorm.DB) *gorm.DB {
@@ -33,6 +47,20 @@ func Email(email string) QueryOption {
 	}
 }
 
+// OidcSub 根据 OIDC subject 查询
+func OidcSub(sub string) QueryOption {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("oidc_sub = ?", sub)
+	}
+}
+
+// ServiceAccount 根据是否为服务账号过滤
+func ServiceAccount(serviceAccount bool) QueryOption {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("service_account = ?", serviceAccount)
+	}
+}
+
 // SortByCreatedDesc 按照创建时间倒序
 func SortByCreatedDesc() QueryOption {
 	return func(query *gorm.DB) *gorm.DB {
@@ -186,3 +214,38 @@ func (r *UserAssociationStore) DeleteRoles(ctx context.Context, user *model.User
 	}
 	return nil
 }
+
+func (r *UserAssociationStore) SetRoleExpiry(ctx context.Context, userID int, roleIDs []int, expiresAt int, reason string) (err error) {
+	err = r.store.WithContext(ctx).Model(&model.UserRole{}).
+		Where("user_id = ? AND role_id IN ?", userID, roleIDs).
+		Updates(map[string]any{"expires_at": expiresAt, "reason": reason}).Error
+	if err != nil {
+		return apierr.InternalServer().Set(apierr.DBErrCode, "failed to set role expiry", err)
+	}
+	return nil
+}
+
+func (r *UserAssociationStore) ListAssignments(ctx context.Context, userID int) (assignments []model.UserRole, err error) {
+	err = r.store.WithContext(ctx).Preload("Role").Where("user_id = ?", userID).Order("role_id").Find(&assignments).Error
+	if err != nil {
+		return nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to list role assignments", err)
+	}
+	return assignments, nil
+}
+
+func (r *UserAssociationStore) ListActive(ctx context.Context, userID int, now int) (assignments []model.UserRole, err error) {
+	err = r.store.WithContext(ctx).Preload("Role").Where("user_id = ? AND (expires_at = 0 OR expires_at > ?)", userID, now).
+		Order("role_id").Find(&assignments).Error
+	if err != nil {
+		return nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to list active role assignments", err)
+	}
+	return assignments, nil
+}
+
+func (r *UserAssociationStore) ListExpired(ctx context.Context, now int) (assignments []model.UserRole, err error) {
+	err = r.store.WithContext(ctx).Preload("Role").Where("expires_at > 0 AND expires_at <= ?", now).Find(&assignments).Error
+	if err != nil {
+		return nil, apierr.InternalServer().Set(apierr.DBErrCode, "failed to list expired role assignments", err)
+	}
+	return assignments, nil
+}
@@ -0,0 +1,295 @@
+package access_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"qqlx/base/constant"
+	"qqlx/base/reason"
+	"qqlx/model"
+	"qqlx/schema"
+	"qqlx/service"
+	"qqlx/store/rbac"
+	"qqlx/store/userstore"
+	"qqlx/test/testutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// memoryAccessRequestStore 不解析查询选项, List 返回所有申请
+type memoryAccessRequestStore struct {
+	requests map[int]*model.AccessRequest
+	// stale 不为空时 Query 返回该申请, 模拟并发请求读到的旧数据
+	stale *model.AccessRequest
+}
+
+func (receive *memoryAccessRequestStore) Create(_ context.Context, request *model.AccessRequest) error {
+	receive.requests[request.ID] = request
+	return nil
+}
+
+func (receive *memoryAccessRequestStore) Query(_ context.Context, id int) (*model.AccessRequest, error) {
+	if receive.stale != nil {
+		request := *receive.stale
+		return &request, nil
+	}
+	request, ok := receive.requests[id]
+	if !ok {
+		return nil, errors.New("record not found")
+	}
+	res := *request
+	return &res, nil
+}
+
+func (receive *memoryAccessRequestStore) Save(_ context.Context, request *model.AccessRequest) error {
+	receive.requests[request.ID] = request
+	return nil
+}
+
+func (receive *memoryAccessRequestStore) Decide(_ context.Context, request *model.AccessRequest, from string) (bool, error) {
+	current, ok := receive.requests[request.ID]
+	if !ok || current.Status != from {
+		return false, nil
+	}
+	res := *request
+	receive.requests[request.ID] = &res
+	return true, nil
+}
+
+func (receive *memoryAccessRequestStore) List(context.Context, int, int, ...rbac.AccessRequestQueryOption) (int64, []model.AccessRequest, error) {
+	res := make([]model.AccessRequest, 0, len(receive.requests))
+	for _, request := range receive.requests {
+		res = append(res, *request)
+	}
+	return int64(len(res)), res, nil
+}
+
+type memoryRoleStore struct {
+	roles []model.Role
+}
+
+func (receive *memoryRoleStore) Query(context.Context, ...rbac.RoleQueryOption) (*model.Role, error) {
+	return &receive.roles[0], nil
+}
+
+func (receive *memoryRoleStore) Create(context.Context, *model.Role) error { return nil }
+
+func (receive *memoryRoleStore) Save(context.Context, *model.Role) error { return nil }
+
+func (receive *memoryRoleStore) Delete(context.Context, *model.Role, ...rbac.RoleDeleteOption) error {
+	return nil
+}
+
+func (receive *memoryRoleStore) List(context.Context, int, int, ...rbac.RoleQueryOption) (int64, []model.Role, error) {
+	return int64(len(receive.roles)), receive.roles, nil
+}
+
+type memoryUserStore struct {
+	user *model.User
+}
+
+func (receive *memoryUserStore) Query(context.Context, ...userstore.QueryOption) (*model.User, error) {
+	return receive.user, nil
+}
+
+func (receive *memoryUserStore) Create(context.Context, *model.User) error { return nil }
+
+func (receive *memoryUserStore) Save(context.Context, *model.User) error { return nil }
+
+func (receive *memoryUserStore) Delete(context.Context, *model.User, ...userstore.DeleteOption) error {
+	return nil
+}
+
+func (receive *memoryUserStore) List(context.Context, int, int, ...userstore.QueryOption) (int64, []model.User, error) {
+	return 1, []model.User{*receive.user}, nil
+}
+
+// memoryUserRoleStore 记录授予的角色和过期时间
+type memoryUserRoleStore struct {
+	user      *model.User
+	expiresAt int
+	reason    string
+}
+
+func (receive *memoryUserRoleStore) AppendRoles(_ context.Context, _ *model.User, roles []model.Role) error {
+	receive.user.Roles = append(receive.user.Roles, roles...)
+	return nil
+}
+
+func (receive *memoryUserRoleStore) DeleteRoles(context.Context, *model.User, []model.Role) error {
+	return nil
+}
+
+func (receive *memoryUserRoleStore) SetRoleExpiry(_ context.Context, _ int, _ []int, expiresAt int, reason string) error {
+	receive.expiresAt = expiresAt
+	receive.reason = reason
+	return nil
+}
+
+func (receive *memoryUserRoleStore) ListAssignments(context.Context, int) ([]model.UserRole, error) {
+	return nil, nil
+}
+
+func (receive *memoryUserRoleStore) ListActive(context.Context, int, int) ([]model.UserRole, error) {
+	return nil, nil
+}
+
+func (receive *memoryUserRoleStore) ListExpired(context.Context, int) ([]model.UserRole, error) {
+	return nil, nil
+}
+
+type fixture struct {
+	ctx      context.Context
+	svc      *service.AccessRequestSVC
+	store    *memoryAccessRequestStore
+	userRole *memoryUserRoleStore
+	alice    *model.User
+}
+
+// newFixture alice 申请 dba, bob 是 dba 的审批人
+func newFixture(t *testing.T) *fixture {
+	viper.Set("server.salt", "test")
+	status := model.UserStatusAvailable
+	alice := &model.User{ID: 1, Name: "alice", Password: "hash", Email: "alice@example.com", Status: &status}
+	bob := model.User{ID: 2, Name: "bob", Status: &status}
+	dba := model.Role{ID: 10, Name: "dba", Approvers: []model.User{bob}}
+	store := &memoryAccessRequestStore{requests: map[int]*model.AccessRequest{
+		100: {
+			ID:            100,
+			UserID:        alice.ID,
+			RoleID:        dba.ID,
+			Justification: "incident",
+			Duration:      "4h",
+			Status:        model.AccessRequestPending,
+			ExpiresAt:     int(time.Now().Add(time.Hour).Unix()),
+			User:          alice,
+			Role:          &dba,
+		},
+	}}
+	userStore := &memoryUserStore{user: alice}
+	roleStore := &memoryRoleStore{roles: []model.Role{dba}}
+	userRole := &memoryUserRoleStore{user: alice}
+	userSvc, err := service.NewUserSVC(nil, userStore, userRole, roleStore, testutil.NewMemoryCache(), nil, nil, service.UserAuth{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fixture{
+		ctx:      context.WithValue(context.Background(), constant.TraceID, "test"),
+		svc:      service.NewAccessRequestSVC(nil, store, roleStore, nil, userStore, userSvc, nil),
+		store:    store,
+		userRole: userRole,
+		alice:    alice,
+	}
+}
+
+func TestApproverChecks(t *testing.T) {
+	f := newFixture(t)
+	if _, err := f.svc.Approve(f.ctx, &schema.AccessRequestDecideRequest{ID: 100, UserID: 3}); !errors.Is(err, reason.ErrNotApprover) {
+		t.Fatalf("approve by other user, err = %v", err)
+	}
+	if _, err := f.svc.Approve(f.ctx, &schema.AccessRequestDecideRequest{ID: 100, UserID: 1}); !errors.Is(err, reason.ErrSelfApprove) {
+		t.Fatalf("approve by requester, err = %v", err)
+	}
+	res, err := f.svc.Reject(f.ctx, &schema.AccessRequestDecideRequest{ID: 100, UserID: 2, Comment: "not needed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != model.AccessRequestRejected || res.ApproverID != 2 || res.DecidedAt == 0 {
+		t.Fatalf("rejected request = %+v", res)
+	}
+	if _, err = f.svc.Approve(f.ctx, &schema.AccessRequestDecideRequest{ID: 100, UserID: 2}); !errors.Is(err, reason.ErrAccessRequestDecided) {
+		t.Fatalf("approve rejected request, err = %v", err)
+	}
+	if len(f.alice.Roles) != 0 {
+		t.Fatalf("roles = %+v", f.alice.Roles)
+	}
+}
+
+func TestApprove(t *testing.T) {
+	f := newFixture(t)
+	if _, err := f.svc.Approve(f.ctx, &schema.AccessRequestDecideRequest{ID: 100, UserID: 2, Duration: "-1h"}); !errors.Is(err, reason.ErrRoleDuration) {
+		t.Fatalf("approve with invalid duration, err = %v", err)
+	}
+	res, err := f.svc.Approve(f.ctx, &schema.AccessRequestDecideRequest{ID: 100, UserID: 2, Duration: "2h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != model.AccessRequestApproved || res.Duration != "2h" || res.UserName != "alice" || res.RoleName != "dba" {
+		t.Fatalf("approved request = %+v", res)
+	}
+	// 响应只返回申请人的 ID 和名称
+	body, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(body), "hash") || strings.Contains(string(body), "alice@example.com") {
+		t.Fatalf("response = %s", body)
+	}
+	if len(f.alice.Roles) != 1 || f.alice.Roles[0].Name != "dba" {
+		t.Fatalf("roles = %+v", f.alice.Roles)
+	}
+	remaining := f.userRole.expiresAt - int(time.Now().Unix())
+	if remaining <= 7100 || remaining > 7200 || f.userRole.reason != "access request 100" {
+		t.Fatalf("expiresAt remaining = %d, reason = %q", remaining, f.userRole.reason)
+	}
+
+	// 已有角色时不能再申请
+	_, err = f.svc.Create(f.ctx, &schema.AccessRequestCreateRequest{RoleName: "dba", Justification: "again", UserID: 1})
+	if !errors.Is(err, reason.ErrRoleHasRole) {
+		t.Fatalf("create for granted role, err = %v", err)
+	}
+}
+
+func TestApproveOnce(t *testing.T) {
+	f := newFixture(t)
+	// 两个审批请求都读到待审批的申请
+	stale := *f.store.requests[100]
+	f.store.stale = &stale
+	if _, err := f.svc.Approve(f.ctx, &schema.AccessRequestDecideRequest{ID: 100, UserID: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.svc.Approve(f.ctx, &schema.AccessRequestDecideRequest{ID: 100, UserID: 2}); !errors.Is(err, reason.ErrAccessRequestDecided) {
+		t.Fatalf("approve twice, err = %v", err)
+	}
+	if len(f.alice.Roles) != 1 {
+		t.Fatalf("roles = %+v", f.alice.Roles)
+	}
+}
+
+func TestCreatePending(t *testing.T) {
+	f := newFixture(t)
+	_, err := f.svc.Create(f.ctx, &schema.AccessRequestCreateRequest{RoleName: "dba", Justification: "again", UserID: 1})
+	if !errors.Is(err, reason.ErrAccessRequestPending) {
+		t.Fatalf("create duplicate request, err = %v", err)
+	}
+	_, err = f.svc.Create(f.ctx, &schema.AccessRequestCreateRequest{RoleName: "dba", Justification: "again", Duration: "soon", UserID: 1})
+	if !errors.Is(err, reason.ErrRoleDuration) {
+		t.Fatalf("create with invalid duration, err = %v", err)
+	}
+}
+
+func TestExpireRequests(t *testing.T) {
+	f := newFixture(t)
+	f.store.requests[100].ExpiresAt = int(time.Now().Unix()) - 1
+	if _, err := f.svc.Approve(f.ctx, &schema.AccessRequestDecideRequest{ID: 100, UserID: 2}); !errors.Is(err, reason.ErrAccessRequestDecided) {
+		t.Fatalf("approve expired request, err = %v", err)
+	}
+	if status := f.store.requests[100].Status; status != model.AccessRequestExpired {
+		t.Fatalf("status = %s", status)
+	}
+
+	f = newFixture(t)
+	f.store.requests[100].ExpiresAt = int(time.Now().Unix()) - 1
+	if err := f.svc.ExpireRequests(f.ctx); err != nil {
+		t.Fatal(err)
+	}
+	if status := f.store.requests[100].Status; status != model.AccessRequestExpired {
+		t.Fatalf("status = %s", status)
+	}
+	if len(f.alice.Roles) != 0 {
+		t.Fatalf("roles = %+v", f.alice.Roles)
+	}
+}
@@ -0,0 +1,186 @@
+package apikey_test
+
+import (
+	"context"
+	"errors"
+	"qqlx/base/constant"
+	"qqlx/base/helpers"
+	"qqlx/base/reason"
+	"qqlx/model"
+	"qqlx/schema"
+	"qqlx/service"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// memoryApiKeyStore 只实现认证需要的查询
+type memoryApiKeyStore struct {
+	keys    map[string]*model.ApiKey
+	touched int
+}
+
+func (receive *memoryApiKeyStore) Create(_ context.Context, key *model.ApiKey) error {
+	receive.keys[key.Hash] = key
+	return nil
+}
+
+func (receive *memoryApiKeyStore) QueryByHash(_ context.Context, hash string) (*model.ApiKey, error) {
+	key, ok := receive.keys[hash]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return key, nil
+}
+
+func (receive *memoryApiKeyStore) Query(context.Context, int, int) (*model.ApiKey, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (receive *memoryApiKeyStore) List(context.Context, int) ([]model.ApiKey, error) {
+	return nil, nil
+}
+
+func (receive *memoryApiKeyStore) Delete(_ context.Context, key *model.ApiKey) error {
+	delete(receive.keys, key.Hash)
+	return nil
+}
+
+func (receive *memoryApiKeyStore) Touch(_ context.Context, id, lastUsedAt int) error {
+	for _, key := range receive.keys {
+		if key.ID == id {
+			key.LastUsedAt = lastUsedAt
+			receive.touched++
+		}
+	}
+	return nil
+}
+
+func newKey(t *testing.T, store *memoryApiKeyStore, roles string, expiresAt int, status int) string {
+	key, err := service.GenerateApiKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = store.Create(context.Background(), &model.ApiKey{
+		ID:        len(store.keys) + 1,
+		Hash:      service.HashApiKey(key),
+		UserID:    100,
+		Roles:     roles,
+		ExpiresAt: expiresAt,
+		User:      &model.User{ID: 100, Name: "ci", Status: &status},
+	})
+	return key
+}
+
+func TestGenerateApiKey(t *testing.T) {
+	key, err := service.GenerateApiKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(key, constant.ApiKeyPrefix) {
+		t.Fatalf("key %q should start with %q", key, constant.ApiKeyPrefix)
+	}
+	other, _ := service.GenerateApiKey()
+	if key == other {
+		t.Fatal("keys should be random")
+	}
+	if service.HashApiKey(key) == key || len(service.HashApiKey(key)) != 64 {
+		t.Fatal("key should be stored as sha256 hex")
+	}
+}
+
+func TestApiKeyRoles(t *testing.T) {
+	owner := []string{"view", "rbac"}
+	if !service.ApiKeyRolesAllowed(owner, []string{"view"}) {
+		t.Fatal("subset should be allowed")
+	}
+	if !service.ApiKeyRolesAllowed(owner, nil) {
+		t.Fatal("empty scope should be allowed")
+	}
+	if service.ApiKeyRolesAllowed(owner, []string{"admin"}) {
+		t.Fatal("role outside owner roles should be rejected")
+	}
+
+	if got := helpers.ScopeRoles(owner, nil); !slices.Equal(got, owner) {
+		t.Fatalf("nil scope should keep all roles, got %v", got)
+	}
+	if got := helpers.ScopeRoles(owner, []string{"view", "admin"}); !slices.Equal(got, []string{"view"}) {
+		t.Fatalf("scope should intersect roles, got %v", got)
+	}
+	if got := helpers.ScopeRoles(owner, []string{"admin"}); len(got) != 0 {
+		t.Fatalf("removed owner role should not be usable, got %v", got)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	store := &memoryApiKeyStore{keys: map[string]*model.ApiKey{}}
+	svc := service.NewApiKeySVC(nil, nil, store, nil)
+
+	scoped := newKey(t, store, "view", 0, model.UserStatusAvailable)
+	claims, err := svc.Authenticate(ctx, scoped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID != 100 || claims.UserName != "ci" || !slices.Equal(claims.Roles, []string{"view"}) {
+		t.Fatalf("unexpected claims: %#v", claims)
+	}
+	// 一分钟内重复使用不重复更新最近使用时间
+	_, _ = svc.Authenticate(ctx, scoped)
+	if store.touched != 1 {
+		t.Fatalf("last used should be touched once, got %d", store.touched)
+	}
+
+	full := newKey(t, store, "", int(time.Now().Add(time.Hour).Unix()), model.UserStatusAvailable)
+	claims, err = svc.Authenticate(ctx, full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Roles != nil {
+		t.Fatalf("unscoped key should not limit roles, got %v", claims.Roles)
+	}
+
+	expired := newKey(t, store, "", int(time.Now().Add(-time.Second).Unix()), model.UserStatusAvailable)
+	if _, err = svc.Authenticate(ctx, expired); !errors.Is(err, reason.ErrApiKeyExpired) {
+		t.Fatalf("expected expired error, got %v", err)
+	}
+
+	disabled := newKey(t, store, "", 0, model.UserStatusDisable)
+	if _, err = svc.Authenticate(ctx, disabled); !errors.Is(err, reason.ErrUserIsDisable) {
+		t.Fatalf("expected disabled error, got %v", err)
+	}
+
+	_ = store.Delete(ctx, &model.ApiKey{Hash: service.HashApiKey(scoped)})
+	if _, err = svc.Authenticate(ctx, scoped); !errors.Is(err, reason.ErrApiKeyInvalid) {
+		t.Fatalf("revoked key should be rejected, got %v", err)
+	}
+	if _, err = svc.Authenticate(ctx, "not-a-key"); !errors.Is(err, reason.ErrApiKeyInvalid) {
+		t.Fatalf("expected invalid error, got %v", err)
+	}
+}
+
+func TestApiKeyCallerCannotManageKeys(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	store := &memoryApiKeyStore{keys: map[string]*model.ApiKey{}}
+	svc := service.NewApiKeySVC(nil, nil, store, nil)
+
+	key := newKey(t, store, "view", 0, model.UserStatusAvailable)
+	claims, err := svc.Authenticate(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx = context.WithValue(ctx, constant.AuthMidwareKey, claims)
+	_, err = svc.Create(ctx, &schema.ApiKeyCreateRequest{UserID: claims.UserID, Name: "escalate"})
+	if !errors.Is(err, reason.ErrApiKeyCaller) {
+		t.Fatalf("expected api key caller to be rejected, got %v", err)
+	}
+	if err = svc.Revoke(ctx, &schema.ApiKeyQueryRequest{UserID: claims.UserID, ID: 1}); !errors.Is(err, reason.ErrApiKeyCaller) {
+		t.Fatalf("expected api key caller to be rejected, got %v", err)
+	}
+	if len(store.keys) != 1 {
+		t.Fatalf("key should not be revoked, got %d keys", len(store.keys))
+	}
+}
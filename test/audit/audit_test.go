@@ -0,0 +1,304 @@
+package audit_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"qqlx/base/constant"
+	"qqlx/base/reason"
+	"qqlx/model"
+	"qqlx/pkg/jwt"
+	"qqlx/schema"
+	"qqlx/service"
+	"qqlx/store/audit"
+	"qqlx/store/rbac"
+	"qqlx/test/testutil"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// memoryAuditStore 不解析查询选项, List 按 id 倒序返回前 limit 条
+type memoryAuditStore struct {
+	events []model.AuditEvent
+	chain  model.AuditChain
+}
+
+func (receive *memoryAuditStore) Append(ctx context.Context, event *model.AuditEvent, seal func(*model.AuditEvent) string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	event.ID = receive.chain.LastID + 1
+	event.PrevHash = receive.chain.Hash
+	event.Hash = seal(event)
+	receive.events = append(receive.events, *event)
+	receive.chain.LastID, receive.chain.Hash = event.ID, event.Hash
+	return nil
+}
+
+func (receive *memoryAuditStore) Query(_ context.Context, id int) (*model.AuditEvent, error) {
+	for i := range receive.events {
+		if receive.events[i].ID == id {
+			return &receive.events[i], nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (receive *memoryAuditStore) Chain(context.Context) (*model.AuditChain, error) {
+	chain := receive.chain
+	return &chain, nil
+}
+
+func (receive *memoryAuditStore) ListAfter(_ context.Context, afterID, limit int) ([]model.AuditEvent, error) {
+	res := make([]model.AuditEvent, 0, limit)
+	for _, event := range receive.events {
+		if event.ID > afterID && len(res) < limit {
+			res = append(res, event)
+		}
+	}
+	return res, nil
+}
+
+func (receive *memoryAuditStore) List(_ context.Context, limit int, _ ...audit.QueryOption) ([]model.AuditEvent, error) {
+	res := make([]model.AuditEvent, 0, limit)
+	for i := len(receive.events) - 1; i >= 0 && len(res) < limit; i-- {
+		res = append(res, receive.events[i])
+	}
+	return res, nil
+}
+
+type memoryPolicyStore struct {
+	policy *model.Policy
+}
+
+func (receive *memoryPolicyStore) Query(context.Context, ...rbac.PolicyQueryOption) (*model.Policy, error) {
+	return receive.policy, nil
+}
+
+func (receive *memoryPolicyStore) Create(context.Context, *model.Policy) error { return nil }
+
+func (receive *memoryPolicyStore) Save(context.Context, *model.Policy) error { return nil }
+
+func (receive *memoryPolicyStore) Delete(context.Context, *model.Policy, ...rbac.PolicyDeleteOption) error {
+	return nil
+}
+
+func (receive *memoryPolicyStore) List(context.Context, int, int, ...rbac.PolicyQueryOption) (int64, []model.Policy, error) {
+	return 1, []model.Policy{*receive.policy}, nil
+}
+
+func newAuditSVC(t *testing.T, store *memoryAuditStore) *service.AuditSVC {
+	viper.Set("audit.hmacKey", "secret")
+	t.Cleanup(func() { viper.Set("audit.hmacKey", "") })
+	svc, err := service.NewAuditSVC(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc
+}
+
+func TestAuditHmacKeyRequired(t *testing.T) {
+	viper.Set("audit.hmacKey", "")
+	if _, err := service.NewAuditSVC(&memoryAuditStore{}); err == nil {
+		t.Fatal("empty audit.hmacKey should be rejected")
+	}
+}
+
+func newContext() context.Context {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "trace-1")
+	ctx = context.WithValue(ctx, constant.ClientIPKey, "10.0.0.1")
+	return context.WithValue(ctx, constant.AuthMidwareKey, &jwt.MyClaims{UserID: 7, UserName: "ops"})
+}
+
+func TestRecordPolicyChanges(t *testing.T) {
+	ctx := newContext()
+	store := &memoryAuditStore{}
+	policies := &memoryPolicyStore{policy: &model.Policy{ID: 3, Name: "userList", Path: "/api/v1/users", Method: "GET", Describe: "old"}}
+	svc := service.NewPolicySVC(nil, policies, nil, nil, nil, newAuditSVC(t, store))
+
+	if err := svc.UpdatePolicy(ctx, &schema.PolicyUpdateRequest{ID: 3, Describe: "new"}); err != nil {
+		t.Fatal(err)
+	}
+	policies.policy.Roles = []model.Role{{ID: 1, Name: "dev"}}
+	if err := svc.DeletePolicy(ctx, &schema.PolicyIDRequest{ID: 3}); !errors.Is(err, reason.ErrPolicyUsedByRole) {
+		t.Fatalf("delete used policy, err = %v", err)
+	}
+
+	if len(store.events) != 2 {
+		t.Fatalf("events = %+v", store.events)
+	}
+	update := store.events[0]
+	if update.Action != constant.AuditPolicyUpdate || update.TargetType != constant.AuditTargetPolicy || update.TargetID != "3" {
+		t.Fatalf("update event = %+v", update)
+	}
+	if update.ActorID != 7 || update.ActorName != "ops" || update.ClientIP != "10.0.0.1" || update.TraceID != "trace-1" {
+		t.Fatalf("update event actor = %+v", update)
+	}
+	var before, after model.Policy
+	if err := json.Unmarshal(update.Before, &before); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(update.After, &after); err != nil {
+		t.Fatal(err)
+	}
+	if update.Result != model.AuditResultSuccess || before.Describe != "old" || after.Describe != "new" {
+		t.Fatalf("update event diff = %+v", update)
+	}
+	failed := store.events[1]
+	if failed.Action != constant.AuditPolicyDelete || failed.Result != model.AuditResultFailure || !strings.Contains(failed.Error, "dev") {
+		t.Fatalf("delete event = %+v", failed)
+	}
+}
+
+func TestRecordSessionKill(t *testing.T) {
+	ctx := newContext()
+	store := &memoryAuditStore{}
+	svc := service.NewTokenSVC(testutil.NewMemoryCache(), testutil.NewMemorySessionStore(), newAuditSVC(t, store))
+
+	if err := svc.KillSession(ctx, &schema.SessionQueryRequest{ID: "missing", UserID: 9}); !errors.Is(err, reason.ErrSessionNotFound) {
+		t.Fatalf("kill missing session, err = %v", err)
+	}
+	if len(store.events) != 1 {
+		t.Fatalf("events = %+v", store.events)
+	}
+	event := store.events[0]
+	if event.Action != constant.AuditSessionKill || event.TargetID != "missing" || event.ActorID != 7 || event.Result != model.AuditResultFailure {
+		t.Fatalf("kill event = %+v", event)
+	}
+}
+
+func TestRecordCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(newContext())
+	cancel()
+	store := &memoryAuditStore{}
+	svc := service.NewTokenSVC(testutil.NewMemoryCache(), testutil.NewMemorySessionStore(), newAuditSVC(t, store))
+
+	_ = svc.KillSession(ctx, &schema.SessionQueryRequest{ID: "missing", UserID: 9})
+	if len(store.events) != 1 || store.events[0].ActorID != 7 {
+		t.Fatalf("events = %+v", store.events)
+	}
+}
+
+func TestListAndExport(t *testing.T) {
+	ctx := newContext()
+	store := &memoryAuditStore{}
+	svc := newAuditSVC(t, store)
+	for _, action := range []string{constant.AuditUserLogin, constant.AuditUserDisable, constant.AuditUserEnable} {
+		svc.Record(ctx, &model.AuditEvent{Action: action, TargetType: constant.AuditTargetUser, TargetID: "1"}, nil)
+	}
+
+	res, err := svc.List(ctx, &schema.AuditListRequest{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Items) != 2 || res.Items[0].ID != 3 || res.NextCursor != 2 {
+		t.Fatalf("first page = %+v", res)
+	}
+	res, err = svc.List(ctx, &schema.AuditListRequest{Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Items) != 3 || res.NextCursor != 0 {
+		t.Fatalf("last page = %+v", res)
+	}
+
+	buf := new(bytes.Buffer)
+	if err = svc.Export(ctx, &schema.AuditListRequest{}, buf); err != nil {
+		t.Fatal(err)
+	}
+	scanner := bufio.NewScanner(buf)
+	var actions []string
+	for scanner.Scan() {
+		var event model.AuditEvent
+		if err = json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatal(err)
+		}
+		actions = append(actions, event.Action)
+	}
+	want := []string{constant.AuditUserEnable, constant.AuditUserDisable, constant.AuditUserLogin}
+	if strings.Join(actions, ",") != strings.Join(want, ",") {
+		t.Fatalf("exported actions = %v", actions)
+	}
+}
+
+func newChain(t *testing.T) (*service.AuditSVC, *memoryAuditStore) {
+	viper.Set("audit.checkpointFile", filepath.Join(t.TempDir(), "checkpoints.jsonl"))
+	t.Cleanup(func() {
+		viper.Set("audit.checkpointFile", "")
+	})
+	store := &memoryAuditStore{}
+	svc := newAuditSVC(t, store)
+	ctx := newContext()
+	for _, action := range []string{constant.AuditUserLogin, constant.AuditUserDisable, constant.AuditUserEnable} {
+		svc.Record(ctx, &model.AuditEvent{Action: action, TargetType: constant.AuditTargetUser, TargetID: "1"}, nil)
+	}
+	return svc, store
+}
+
+func TestVerifyChain(t *testing.T) {
+	ctx := newContext()
+	svc, store := newChain(t)
+	res, err := svc.Verify(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Valid || res.Checked != 3 || res.LastID != 3 || store.events[1].PrevHash != store.events[0].Hash {
+		t.Fatalf("valid chain = %+v", res)
+	}
+
+	store.events[1].TargetID = "2"
+	if res, _ = svc.Verify(ctx, nil); res.Valid || res.BrokenID != 2 {
+		t.Fatalf("modified record = %+v", res)
+	}
+	store.events[1].TargetID = "1"
+
+	store.events = append(store.events[:1], store.events[2:]...)
+	if res, _ = svc.Verify(ctx, nil); res.Valid || res.BrokenID != 3 {
+		t.Fatalf("deleted record = %+v", res)
+	}
+
+	svc, store = newChain(t)
+	store.events = store.events[:2]
+	if res, _ = svc.Verify(ctx, nil); res.Valid || res.BrokenID != 3 {
+		t.Fatalf("deleted tail = %+v", res)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	ctx := newContext()
+	svc, _ := newChain(t)
+	if err := svc.Checkpoint(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// 链头没有变化时不重复写入
+	if err := svc.Checkpoint(ctx); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(viper.GetString("audit.checkpointFile"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(content), "\n") != 1 {
+		t.Fatalf("checkpoints = %s", content)
+	}
+	res, err := svc.Verify(ctx, bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Valid || res.Checkpoints != 1 {
+		t.Fatalf("checkpoint verify = %+v", res)
+	}
+
+	forged := bytes.Replace(content, []byte(`"lastId":3`), []byte(`"lastId":2`), 1)
+	if res, _ = svc.Verify(ctx, bytes.NewReader(forged)); res.Valid || !strings.Contains(res.Reason, "signature") {
+		t.Fatalf("forged checkpoint = %+v", res)
+	}
+
+}
@@ -0,0 +1,201 @@
+package authz_test
+
+import (
+	"context"
+	"qqlx/base/constant"
+	"qqlx/base/helpers"
+	"qqlx/model"
+	"qqlx/schema"
+	"qqlx/service"
+	"qqlx/store/rbac"
+	"qqlx/store/userstore"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+)
+
+// memoryUserRoleStore 把用户的角色作为永久授权返回
+type memoryUserRoleStore struct {
+	user *model.User
+}
+
+func (receive *memoryUserRoleStore) AppendRoles(context.Context, *model.User, []model.Role) error {
+	return nil
+}
+
+func (receive *memoryUserRoleStore) DeleteRoles(context.Context, *model.User, []model.Role) error {
+	return nil
+}
+
+func (receive *memoryUserRoleStore) SetRoleExpiry(context.Context, int, []int, int, string) error {
+	return nil
+}
+
+func (receive *memoryUserRoleStore) ListAssignments(ctx context.Context, userID int) ([]model.UserRole, error) {
+	return receive.ListActive(ctx, userID, 0)
+}
+
+func (receive *memoryUserRoleStore) ListActive(context.Context, int, int) ([]model.UserRole, error) {
+	assignments := make([]model.UserRole, len(receive.user.Roles))
+	for i := range receive.user.Roles {
+		assignments[i] = model.UserRole{UserID: receive.user.ID, RoleID: receive.user.Roles[i].ID, Role: &receive.user.Roles[i]}
+	}
+	return assignments, nil
+}
+
+func (receive *memoryUserRoleStore) ListExpired(context.Context, int) ([]model.UserRole, error) {
+	return nil, nil
+}
+
+// memoryRoleStore 只用于根据 casbin 匹配的策略查找 model.Policy, 查询时返回包含所有策略的角色
+type memoryRoleStore struct {
+	policies []model.Policy
+}
+
+func (receive *memoryRoleStore) Query(context.Context, ...rbac.RoleQueryOption) (*model.Role, error) {
+	return &model.Role{Policys: receive.policies}, nil
+}
+
+func (receive *memoryRoleStore) Create(context.Context, *model.Role) error { return nil }
+
+func (receive *memoryRoleStore) Save(context.Context, *model.Role) error { return nil }
+
+func (receive *memoryRoleStore) Delete(context.Context, *model.Role, ...rbac.RoleDeleteOption) error {
+	return nil
+}
+
+func (receive *memoryRoleStore) List(context.Context, int, int, ...rbac.RoleQueryOption) (int64, []model.Role, error) {
+	return 0, nil, nil
+}
+
+// memoryUserStore 查询时总是返回同一个用户
+type memoryUserStore struct {
+	user *model.User
+}
+
+func (receive *memoryUserStore) Query(context.Context, ...userstore.QueryOption) (*model.User, error) {
+	return receive.user, nil
+}
+
+func (receive *memoryUserStore) Create(context.Context, *model.User) error { return nil }
+
+func (receive *memoryUserStore) Save(context.Context, *model.User) error { return nil }
+
+func (receive *memoryUserStore) Delete(context.Context, *model.User, ...userstore.DeleteOption) error {
+	return nil
+}
+
+func (receive *memoryUserStore) List(context.Context, int, int, ...userstore.QueryOption) (int64, []model.User, error) {
+	return 0, nil, nil
+}
+
+func TestCheckExplain(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	enforcer, err := casbin.NewEnforcer("../../model.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	casbinStore := rbac.NewCasbinStore(enforcer)
+	view := model.Policy{ID: 1, Name: "view", Path: "*", Method: "GET"}
+	audit := model.Policy{ID: 2, Name: "denyAudit", Path: "/api/v1/audit", Method: "GET", Effect: constant.PolicyEffectDeny}
+	hosts := model.Policy{ID: 3, Name: "deleteHost", Path: "/api/v1/hosts/:id", Method: "DELETE"}
+	rules := helpers.GetCasbinRole(&model.Role{Name: "view"}, []model.Policy{view, audit})
+	rules = append(rules, helpers.GetCasbinRole(&model.Role{Name: "ops"}, []model.Policy{hosts})...)
+	if err = casbinStore.CreateRolePolices(ctx, rules); err != nil {
+		t.Fatal(err)
+	}
+	if err = casbinStore.SetRoleParents(ctx, "ops-lead", []string{"ops"}); err != nil {
+		t.Fatal(err)
+	}
+	svc := service.NewAuthzSVC(nil, nil, &memoryRoleStore{policies: []model.Policy{view, audit, hosts}}, nil, rbac.NewAuthentication(enforcer))
+
+	cases := []struct {
+		role, path, method string
+		allowed            bool
+		policyID           int
+		explainRole        string
+	}{
+		{"view", "/api/v1/users", "get", true, 1, "view"},
+		{"view", "/api/v1/audit", "GET", false, 2, "view"},
+		// 继承的策略返回来源角色
+		{"ops-lead", "/api/v1/hosts/7", "DELETE", true, 3, "ops"},
+		// 没有匹配的策略
+		{"ops-lead", "/api/v1/users", "GET", false, 0, ""},
+	}
+	for _, c := range cases {
+		res, err := svc.Check(ctx, &schema.AuthzCheckRequest{Role: c.role, Path: c.path, Method: c.method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Allowed != c.allowed || res.PolicyID != c.policyID {
+			t.Fatalf("%s %s %s: want allowed=%v policy=%d, got %+v", c.role, c.method, c.path, c.allowed, c.policyID, res)
+		}
+		if len(res.Results) != 1 {
+			t.Fatalf("want 1 role result, got %d", len(res.Results))
+		}
+		explain := res.Results[0].Explain
+		if c.explainRole == "" && len(explain) != 0 || c.explainRole != "" && (len(explain) == 0 || explain[0] != c.explainRole) {
+			t.Fatalf("%s %s: unexpected explain %v", c.role, c.path, explain)
+		}
+		wantDecision := constant.PolicyEffectDeny
+		if c.allowed {
+			wantDecision = constant.PolicyEffectAllow
+		}
+		if res.Decision != wantDecision {
+			t.Fatalf("want decision %s, got %s", wantDecision, res.Decision)
+		}
+	}
+}
+
+func TestDenyAcrossRoles(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	enforcer, err := casbin.NewEnforcer("../../model.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	casbinStore := rbac.NewCasbinStore(enforcer)
+	auditRead := model.Policy{ID: 1, Name: "auditRead", Path: "/api/v1/audit", Method: "GET"}
+	view := model.Policy{ID: 2, Name: "view", Path: "*", Method: "GET"}
+	denyAudit := model.Policy{ID: 3, Name: "denyAudit", Path: "/api/v1/audit", Method: "GET", Effect: constant.PolicyEffectDeny}
+	rules := helpers.GetCasbinRole(&model.Role{Name: "auditor"}, []model.Policy{auditRead})
+	rules = append(rules, helpers.GetCasbinRole(&model.Role{Name: "view"}, []model.Policy{view, denyAudit})...)
+	if err = casbinStore.CreateRolePolices(ctx, rules); err != nil {
+		t.Fatal(err)
+	}
+	authorizer := rbac.NewAuthentication(enforcer)
+
+	cases := []struct {
+		roles []string
+		path  string
+		want  bool
+	}{
+		{[]string{"auditor"}, "/api/v1/audit", true},
+		// 任一角色 deny 时拒绝, 与角色顺序无关
+		{[]string{"auditor", "view"}, "/api/v1/audit", false},
+		{[]string{"view", "auditor"}, "/api/v1/audit", false},
+		{[]string{"auditor", "view"}, "/api/v1/users", true},
+	}
+	for _, c := range cases {
+		ok, err := authorizer.EnforceRolesWithCtx(ctx, c.roles, constant.CasbinGlobalDomain, c.path, "GET", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != c.want {
+			t.Fatalf("%v GET %s: want %v, got %v", c.roles, c.path, c.want, ok)
+		}
+	}
+
+	user := &model.User{ID: 1, Name: "alice", Roles: []model.Role{{Name: "auditor"}, {Name: "view"}}}
+	roleStore := &memoryRoleStore{policies: []model.Policy{auditRead, view, denyAudit}}
+	svc := service.NewAuthzSVC(&memoryUserStore{user: user}, &memoryUserRoleStore{user: user}, roleStore, nil, authorizer)
+	res, err := svc.Check(ctx, &schema.AuthzCheckRequest{User: "alice", Path: "/api/v1/audit", Method: "GET"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Allowed || res.Decision != constant.PolicyEffectDeny || res.PolicyID != denyAudit.ID {
+		t.Fatalf("want deny by policy %d, got %+v", denyAudit.ID, res)
+	}
+	if len(res.Results) != 2 || !res.Results[0].Allowed {
+		t.Fatalf("want both role results with auditor allowed, got %+v", res.Results)
+	}
+}
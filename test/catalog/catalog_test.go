@@ -0,0 +1,83 @@
+package catalog_test
+
+import (
+	"context"
+	"qqlx/base/constant"
+	"qqlx/model"
+	"qqlx/schema"
+	"qqlx/service"
+	"qqlx/store/rbac"
+	"testing"
+)
+
+type memoryPolicyStore struct {
+	policies []model.Policy
+}
+
+func (receive *memoryPolicyStore) Query(context.Context, ...rbac.PolicyQueryOption) (*model.Policy, error) {
+	return nil, nil
+}
+
+func (receive *memoryPolicyStore) Create(_ context.Context, policy *model.Policy) error {
+	receive.policies = append(receive.policies, *policy)
+	return nil
+}
+
+func (receive *memoryPolicyStore) Save(context.Context, *model.Policy) error { return nil }
+
+func (receive *memoryPolicyStore) Delete(context.Context, *model.Policy, ...rbac.PolicyDeleteOption) error {
+	return nil
+}
+
+func (receive *memoryPolicyStore) List(context.Context, int, int, ...rbac.PolicyQueryOption) (int64, []model.Policy, error) {
+	return int64(len(receive.policies)), receive.policies, nil
+}
+
+type staticCatalog []schema.CatalogRoute
+
+func (receive staticCatalog) Routes() []schema.CatalogRoute { return receive }
+
+func TestCatalogReport(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	routes := staticCatalog{
+		{Method: "GET", Path: "/api/v1/users", Protected: true},
+		{Method: "GET", Path: "/api/v1/users/:id", Protected: true},
+		{Method: "PUT", Path: "/api/v1/users/:id", Protected: true},
+		{Method: "DELETE", Path: "/api/v1/roles/:id", Protected: true},
+		{Method: "POST", Path: "/api/v1/users/login"},
+	}
+	store := &memoryPolicyStore{policies: []model.Policy{
+		{ID: 1, Name: "view", Path: "*", Method: "GET"},
+		{ID: 2, Name: "userList", Path: "/api/v1/users", Method: "GET"},
+		{ID: 3, Name: "getUser", Path: "/api/v1/users/:id", Method: "GET"},
+		{ID: 4, Name: "hostList", Path: "/api/v1/hosts", Method: "GET"},
+		{ID: 5, Name: "deleteRoles", Path: "/api/v1/roles/*", Method: "DELETE"},
+	}}
+	svc := service.NewPolicySVC(nil, store, nil, routes, nil, nil)
+
+	res, err := svc.Catalog(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Routes) != len(routes) {
+		t.Fatalf("routes = %d, want %d", len(res.Routes), len(routes))
+	}
+	// 与 casbin 一致使用 keyMatch 匹配, /api/v1/roles/* 覆盖 /api/v1/roles/:id, 路径为 * 的策略不算作路由的策略
+	if len(res.Missing) != 1 || res.Missing[0].Method != "PUT" || res.Missing[0].Path != "/api/v1/users/:id" {
+		t.Fatalf("missing = %+v", res.Missing)
+	}
+	if len(res.Orphaned) != 1 || res.Orphaned[0].Name != "hostList" {
+		t.Fatalf("orphaned = %+v", res.Orphaned)
+	}
+	if len(res.Created) != 0 || len(store.policies) != 5 {
+		t.Fatalf("report mode must not create policies")
+	}
+}
+
+func TestCatalogNotLoaded(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	svc := service.NewPolicySVC(nil, &memoryPolicyStore{}, nil, nil, nil, nil)
+	if _, err := svc.Catalog(ctx, false); err == nil {
+		t.Fatal("catalog without routes should fail")
+	}
+}
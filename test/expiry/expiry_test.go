@@ -0,0 +1,334 @@
+package expiry_test
+
+import (
+	"context"
+	"errors"
+	"qqlx/base/constant"
+	"qqlx/base/helpers"
+	"qqlx/base/server"
+	"qqlx/model"
+	"qqlx/schema"
+	"qqlx/service"
+	"qqlx/store/rbac"
+	"qqlx/store/userstore"
+	"qqlx/test/testutil"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+type memoryUserStore struct {
+	users map[int]*model.User
+}
+
+func (receive *memoryUserStore) Query(context.Context, ...userstore.QueryOption) (*model.User, error) {
+	// 测试只按 ID 查询, 直接返回唯一的用户
+	for _, user := range receive.users {
+		return user, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (receive *memoryUserStore) Create(context.Context, *model.User) error { return nil }
+
+func (receive *memoryUserStore) Save(context.Context, *model.User) error { return nil }
+
+func (receive *memoryUserStore) Delete(context.Context, *model.User, ...userstore.DeleteOption) error {
+	return nil
+}
+
+func (receive *memoryUserStore) List(context.Context, int, int, ...userstore.QueryOption) (int64, []model.User, error) {
+	return 0, nil, nil
+}
+
+type memoryUserRoleStore struct {
+	assignments []model.UserRole
+	// failUser 删除该用户的角色时返回错误
+	failUser int
+}
+
+func (receive *memoryUserRoleStore) AppendRoles(_ context.Context, user *model.User, roles []model.Role) error {
+	for _, role := range roles {
+		held := false
+		for _, assignment := range receive.assignments {
+			if assignment.UserID == user.ID && assignment.RoleID == role.ID {
+				held = true
+			}
+		}
+		if !held {
+			receive.assignments = append(receive.assignments, model.UserRole{UserID: user.ID, RoleID: role.ID, Role: &role})
+		}
+	}
+	return nil
+}
+
+func (receive *memoryUserRoleStore) DeleteRoles(_ context.Context, user *model.User, roles []model.Role) error {
+	if user.ID == receive.failUser {
+		return errors.New("delete roles failed")
+	}
+	kept := receive.assignments[:0]
+	for _, assignment := range receive.assignments {
+		removed := false
+		for _, role := range roles {
+			if assignment.UserID == user.ID && assignment.RoleID == role.ID {
+				removed = true
+			}
+		}
+		if !removed {
+			kept = append(kept, assignment)
+		}
+	}
+	receive.assignments = kept
+	return nil
+}
+
+func (receive *memoryUserRoleStore) SetRoleExpiry(_ context.Context, userID int, roleIDs []int, expiresAt int, reason string) error {
+	for i := range receive.assignments {
+		if receive.assignments[i].UserID == userID && slices.Contains(roleIDs, receive.assignments[i].RoleID) {
+			receive.assignments[i].ExpiresAt = expiresAt
+			receive.assignments[i].Reason = reason
+		}
+	}
+	return nil
+}
+
+type memoryRoleStore struct {
+	roles []model.Role
+}
+
+func (receive *memoryRoleStore) Query(context.Context, ...rbac.RoleQueryOption) (*model.Role, error) {
+	return nil, nil
+}
+
+func (receive *memoryRoleStore) Create(context.Context, *model.Role) error { return nil }
+
+func (receive *memoryRoleStore) Save(context.Context, *model.Role) error { return nil }
+
+func (receive *memoryRoleStore) Delete(context.Context, *model.Role, ...rbac.RoleDeleteOption) error {
+	return nil
+}
+
+func (receive *memoryRoleStore) List(context.Context, int, int, ...rbac.RoleQueryOption) (int64, []model.Role, error) {
+	return int64(len(receive.roles)), receive.roles, nil
+}
+
+func (receive *memoryUserRoleStore) ListAssignments(_ context.Context, userID int) ([]model.UserRole, error) {
+	var res []model.UserRole
+	for _, assignment := range receive.assignments {
+		if assignment.UserID == userID {
+			res = append(res, assignment)
+		}
+	}
+	return res, nil
+}
+
+func (receive *memoryUserRoleStore) ListActive(_ context.Context, userID int, now int) ([]model.UserRole, error) {
+	var res []model.UserRole
+	for _, assignment := range receive.assignments {
+		if assignment.UserID == userID && (assignment.ExpiresAt == 0 || assignment.ExpiresAt > now) {
+			res = append(res, assignment)
+		}
+	}
+	return res, nil
+}
+
+func (receive *memoryUserRoleStore) ListExpired(_ context.Context, now int) ([]model.UserRole, error) {
+	var res []model.UserRole
+	for _, assignment := range receive.assignments {
+		if assignment.ExpiresAt > 0 && assignment.ExpiresAt <= now {
+			res = append(res, assignment)
+		}
+	}
+	return res, nil
+}
+
+func TestExpireRoles(t *testing.T) {
+	viper.Set("server.salt", "test")
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	status := model.UserStatusAvailable
+	alice := &model.User{ID: 1, Name: "alice", Status: &status}
+	dba := model.Role{ID: 10, Name: "dba"}
+	view := model.Role{ID: 11, Name: "view"}
+	now := int(time.Now().Unix())
+	roleStore := &memoryUserRoleStore{assignments: []model.UserRole{
+		{UserID: 1, RoleID: 10, ExpiresAt: now - 1, Reason: "incident", Role: &dba},
+		{UserID: 1, RoleID: 11, Role: &view},
+	}}
+	cache := testutil.NewMemoryCache()
+	userSvc, err := service.NewUserSVC(nil, &memoryUserStore{users: map[int]*model.User{1: alice}}, roleStore, nil, cache, nil, nil, service.UserAuth{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = userSvc.ExpireRoles(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(roleStore.assignments) != 1 || roleStore.assignments[0].RoleID != view.ID {
+		t.Fatalf("assignments = %+v", roleStore.assignments)
+	}
+
+	roleStore.assignments = append(roleStore.assignments, model.UserRole{UserID: 1, RoleID: 10, ExpiresAt: now + 3600, Reason: "incident", Role: &dba})
+	res, err := userSvc.Info(ctx, &schema.UserQueryRequest{ID: 1, Query: []string{"roles"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"view": false, "dba": true}
+	if len(res.Assignments) != len(want) {
+		t.Fatalf("assignments = %+v", res.Assignments)
+	}
+	for _, assignment := range res.Assignments {
+		temporary := want[assignment.Role]
+		if temporary != (assignment.Remaining > 3500 && assignment.Remaining <= 3600) {
+			t.Fatalf("assignment = %+v", assignment)
+		}
+		if !temporary && assignment.ExpiresAt != 0 {
+			t.Fatalf("permanent assignment = %+v", assignment)
+		}
+	}
+}
+
+func TestTemporaryGrantKeepsPermanentRole(t *testing.T) {
+	viper.Set("server.salt", "test")
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	status := model.UserStatusAvailable
+	alice := &model.User{ID: 1, Name: "alice", Status: &status}
+	dba := model.Role{ID: 10, Name: "dba"}
+	view := model.Role{ID: 11, Name: "view"}
+	roleStore := &memoryUserRoleStore{assignments: []model.UserRole{{UserID: 1, RoleID: 11, Role: &view}}}
+	userSvc, err := service.NewUserSVC(nil, &memoryUserStore{users: map[int]*model.User{1: alice}}, roleStore,
+		&memoryRoleStore{roles: []model.Role{dba, view}}, testutil.NewMemoryCache(), nil, nil, service.UserAuth{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = userSvc.UserAddRole(ctx, &schema.UserUpdateRoleRequest{ID: 1, RoleNames: []string{"dba", "view"}, Duration: "1h", Reason: "incident"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, assignment := range roleStore.assignments {
+		switch assignment.RoleID {
+		case view.ID:
+			if assignment.ExpiresAt != 0 {
+				t.Fatalf("permanent role became temporary: %+v", assignment)
+			}
+		case dba.ID:
+			if assignment.ExpiresAt == 0 || assignment.Reason != "incident" {
+				t.Fatalf("new role has no expiry: %+v", assignment)
+			}
+		}
+	}
+}
+
+func TestRegrantExtendsExpiry(t *testing.T) {
+	viper.Set("server.salt", "test")
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	status := model.UserStatusAvailable
+	alice := &model.User{ID: 1, Name: "alice", Status: &status}
+	dba := model.Role{ID: 10, Name: "dba"}
+	view := model.Role{ID: 11, Name: "view"}
+	soon := int(time.Now().Add(time.Minute).Unix())
+	roleStore := &memoryUserRoleStore{assignments: []model.UserRole{
+		{UserID: 1, RoleID: 10, ExpiresAt: soon, Role: &dba},
+		{UserID: 1, RoleID: 11, ExpiresAt: soon, Role: &view},
+	}}
+	userSvc, err := service.NewUserSVC(nil, &memoryUserStore{users: map[int]*model.User{1: alice}}, roleStore,
+		&memoryRoleStore{roles: []model.Role{dba}}, testutil.NewMemoryCache(), nil, nil, service.UserAuth{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 更长的临时授权延长期限
+	if err = userSvc.UserAddRole(ctx, &schema.UserUpdateRoleRequest{ID: 1, RoleNames: []string{"dba"}, Duration: "1h", Reason: "extend"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := roleStore.assignments[0]; got.ExpiresAt <= soon || got.Reason != "extend" {
+		t.Fatalf("temporary role not extended: %+v", got)
+	}
+	extended := roleStore.assignments[0].ExpiresAt
+	// 更短的临时授权不缩短期限
+	if err = userSvc.UserAddRole(ctx, &schema.UserUpdateRoleRequest{ID: 1, RoleNames: []string{"dba"}, Duration: "30m", Reason: "shorter"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := roleStore.assignments[0]; got.ExpiresAt != extended || got.Reason != "extend" {
+		t.Fatalf("temporary role shortened: %+v", got)
+	}
+	// 永久授权优先
+	if err = userSvc.UserAddRole(ctx, &schema.UserUpdateRoleRequest{ID: 1, RoleNames: []string{"dba"}, Reason: "permanent"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := roleStore.assignments[0]; got.ExpiresAt != 0 || got.Reason != "permanent" {
+		t.Fatalf("temporary role not made permanent: %+v", got)
+	}
+	if got := roleStore.assignments[1]; got.ExpiresAt != soon {
+		t.Fatalf("other role changed: %+v", got)
+	}
+}
+
+func TestActiveRoleNames(t *testing.T) {
+	now := time.Now()
+	dba := model.Role{ID: 10, Name: "dba"}
+	view := model.Role{ID: 11, Name: "view"}
+	ops := model.Role{ID: 12, Name: "ops"}
+	names, ttl := helpers.ActiveRoleNames([]model.UserRole{
+		{RoleID: 10, ExpiresAt: int(now.Add(-time.Second).Unix()), Role: &dba},
+		{RoleID: 11, Role: &view},
+		{RoleID: 12, ExpiresAt: int(now.Add(time.Hour).Unix()), Role: &ops},
+	}, now)
+	if !slices.Equal(names, []string{"view", "ops"}) {
+		t.Fatalf("names = %v", names)
+	}
+	if ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Fatalf("ttl = %s", ttl)
+	}
+}
+
+func TestExpireRolesContinuesOnError(t *testing.T) {
+	viper.Set("server.salt", "test")
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	dba := model.Role{ID: 10, Name: "dba"}
+	now := int(time.Now().Unix())
+	// 两个用户都已删除, 清理用户 1 的关联失败
+	roleStore := &memoryUserRoleStore{failUser: 1, assignments: []model.UserRole{
+		{UserID: 1, RoleID: 10, ExpiresAt: now - 1, Role: &dba},
+		{UserID: 2, RoleID: 10, ExpiresAt: now - 1, Role: &dba},
+	}}
+	userSvc, err := service.NewUserSVC(nil, &memoryUserStore{}, roleStore, nil, testutil.NewMemoryCache(), nil, nil, service.UserAuth{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = userSvc.ExpireRoles(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(roleStore.assignments) != 1 || roleStore.assignments[0].UserID != 1 {
+		t.Fatalf("assignments = %+v", roleStore.assignments)
+	}
+}
+
+func TestWorkerShutdown(t *testing.T) {
+	var runs atomic.Int32
+	worker := server.NewWorker("test", time.Millisecond, func(ctx context.Context) error {
+		if ctx.Value(constant.TraceID) != "test" {
+			t.Error("worker context has no trace id")
+		}
+		runs.Add(1)
+		return nil
+	})
+	go func() { _ = worker.Start() }()
+	time.Sleep(20 * time.Millisecond)
+	if err := worker.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if runs.Load() == 0 {
+		t.Fatal("worker did not run")
+	}
+	stopped := runs.Load()
+	time.Sleep(5 * time.Millisecond)
+	if runs.Load() != stopped {
+		t.Fatal("worker ran after shutdown")
+	}
+}
@@ -0,0 +1,72 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"qqlx/base/health"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+func get(t *testing.T, r *gin.Engine, path string) (int, *health.Response) {
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	res := new(health.Response)
+	if err := json.Unmarshal(rec.Body.Bytes(), res); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, res
+}
+
+func TestReadyz(t *testing.T) {
+	viper.Set("server.healthTimeout", "50ms")
+	defer viper.Set("server.healthTimeout", "")
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/livez", health.LivezHandler)
+	r.GET("/readyz", health.ReadyzHandler)
+
+	health.Register("mysql", func(context.Context) error { return nil })
+	health.Register("redis", func(context.Context) error { return nil })
+	if code, res := get(t, r, "/readyz"); code != http.StatusOK || res.Status != health.StatusOK || len(res.Components) != 2 {
+		t.Fatalf("ready = %d %+v", code, res)
+	}
+
+	health.Register("redis", func(context.Context) error { return errors.New("connection refused") })
+	// 不响应 ctx 的检查按超时处理
+	health.Register("ldap", func(context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	start := time.Now()
+	code, res := get(t, r, "/readyz")
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("readyz waited %s", time.Since(start))
+	}
+	if code != http.StatusServiceUnavailable || res.Status != health.StatusFail {
+		t.Fatalf("not ready = %d %+v", code, res)
+	}
+	if c := res.Components["mysql"]; c.Status != health.StatusOK {
+		t.Fatalf("mysql = %+v", c)
+	}
+	if c := res.Components["redis"]; c.Status != health.StatusFail || c.Error != "connection refused" {
+		t.Fatalf("redis = %+v", c)
+	}
+	if c := res.Components["ldap"]; c.Status != health.StatusFail || c.Error != "health check timed out" {
+		t.Fatalf("ldap = %+v", c)
+	}
+
+	health.Drain()
+	if code, res = get(t, r, "/readyz"); code != http.StatusServiceUnavailable || res.Status != health.StatusShuttingDown {
+		t.Fatalf("draining = %d %+v", code, res)
+	}
+	if code, res = get(t, r, "/livez"); code != http.StatusOK || res.Status != health.StatusOK {
+		t.Fatalf("livez = %d %+v", code, res)
+	}
+}
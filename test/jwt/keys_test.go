@@ -0,0 +1,173 @@
+package jwt_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"qqlx/pkg/jwt"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// writeKeyPair 生成密钥对并写入 PEM 文件
+func writeKeyPair(t *testing.T, dir, kid string, private crypto.Signer) (privatePath, publicPath string) {
+	t.Helper()
+	privateDer, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDer, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	privatePath = filepath.Join(dir, kid+".pem")
+	publicPath = filepath.Join(dir, kid+".pub.pem")
+	if err = os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDer}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDer}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return privatePath, publicPath
+}
+
+func TestAsymmetricSigning(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		algorithm string
+		kty       string
+		newKey    func() crypto.Signer
+	}{
+		{algorithm: jwt.AlgorithmRS256, kty: "RSA", newKey: func() crypto.Signer { return rsaKey }},
+		{algorithm: jwt.AlgorithmES256, kty: "EC", newKey: func() crypto.Signer { return ecKey }},
+		{algorithm: jwt.AlgorithmEdDSA, kty: "OKP", newKey: func() crypto.Signer { return edKey }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			dir := t.TempDir()
+			privatePath, _ := writeKeyPair(t, dir, "key-1", tt.newKey())
+			viper.Set("jwt.algorithm", tt.algorithm)
+			viper.Set("jwt.activeKid", "key-1")
+			viper.Set("jwt.keys", []map[string]string{{"kid": "key-1", "privateKey": privatePath}})
+			if err := jwt.InitConf(); err != nil {
+				t.Fatal(err)
+			}
+
+			token, err := jwt.NewClaims(1, "alice").GenerateToken()
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := jwt.ParseToken(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.UserName != "alice" {
+				t.Fatalf("userName = %s, want alice", claims.UserName)
+			}
+
+			jwks := jwt.GetJWKS()
+			if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != "key-1" || jwks.Keys[0].Kty != tt.kty {
+				t.Fatalf("unexpected jwks: %#v", jwks)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	oldPrivate, oldPublic := writeKeyPair(t, dir, "key-1", oldKey)
+	newPrivate, _ := writeKeyPair(t, dir, "key-2", newKey)
+
+	viper.Set("jwt.algorithm", jwt.AlgorithmRS256)
+	viper.Set("jwt.activeKid", "key-1")
+	viper.Set("jwt.keys", []map[string]string{{"kid": "key-1", "privateKey": oldPrivate}})
+	if err := jwt.InitConf(); err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := jwt.NewClaims(1, "alice").GenerateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 轮换: key-2 签名, key-1 只保留公钥验签
+	viper.Set("jwt.activeKid", "key-2")
+	viper.Set("jwt.keys", []map[string]string{
+		{"kid": "key-2", "privateKey": newPrivate},
+		{"kid": "key-1", "publicKey": oldPublic},
+	})
+	if err = jwt.InitConf(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = jwt.ParseToken(oldToken); err != nil {
+		t.Fatalf("token signed by rotated key should still verify: %v", err)
+	}
+	if len(jwt.GetJWKS().Keys) != 2 {
+		t.Fatalf("jwks should expose both keys during rotation")
+	}
+
+	// 下线 key-1 后旧 token 失效
+	viper.Set("jwt.keys", []map[string]string{{"kid": "key-2", "privateKey": newPrivate}})
+	if err = jwt.InitConf(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = jwt.ParseToken(oldToken); err == nil {
+		t.Fatal("token signed by retired key should be rejected")
+	}
+}
+
+func TestRejectAlgorithmConfusion(t *testing.T) {
+	viper.Set("jwt.algorithm", jwt.AlgorithmHS256)
+	viper.Set("jwt.secret", "test-secret")
+	if err := jwt.InitConf(); err != nil {
+		t.Fatal(err)
+	}
+	hsToken, err := jwt.NewClaims(1, "alice").GenerateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	privatePath, _ := writeKeyPair(t, dir, "key-1", key)
+	viper.Set("jwt.algorithm", jwt.AlgorithmRS256)
+	viper.Set("jwt.activeKid", "key-1")
+	viper.Set("jwt.keys", []map[string]string{{"kid": "key-1", "privateKey": privatePath}})
+	if err = jwt.InitConf(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = jwt.ParseToken(hsToken); err == nil {
+		t.Fatal("HS256 token should be rejected when RS256 is configured")
+	}
+}
+
+func TestRejectMismatchedKeyPair(t *testing.T) {
+	dir := t.TempDir()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	privatePath, publicPath := writeKeyPair(t, dir, "key-1", key)
+	_, otherPublic := writeKeyPair(t, dir, "key-2", other)
+
+	viper.Set("jwt.algorithm", jwt.AlgorithmES256)
+	viper.Set("jwt.activeKid", "key-1")
+	viper.Set("jwt.keys", []map[string]string{{"kid": "key-1", "privateKey": privatePath, "publicKey": publicPath}})
+	if err := jwt.InitConf(); err != nil {
+		t.Fatalf("matching key pair: %v", err)
+	}
+	viper.Set("jwt.keys", []map[string]string{{"kid": "key-1", "privateKey": privatePath, "publicKey": otherPublic}})
+	if err := jwt.InitConf(); err == nil {
+		t.Fatal("mismatched key pair should fail")
+	}
+}
@@ -0,0 +1,152 @@
+package login_test
+
+import (
+	"context"
+	"errors"
+	"qqlx/base/apierr"
+	"qqlx/base/constant"
+	"qqlx/base/reason"
+	"qqlx/service"
+	"qqlx/test/testutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func newGuard(t *testing.T, delayBase string) *service.LoginGuardSVC {
+	viper.Set("login.maxFailures", 3)
+	viper.Set("login.maxIPFailures", 5)
+	viper.Set("login.delayBase", delayBase)
+	guard, err := service.NewLoginGuardSVC(testutil.NewMemoryCache())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return guard
+}
+
+func TestAccountLockout(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	guard := newGuard(t, "0s")
+
+	for i := 0; i < 3; i++ {
+		if err := guard.Check(ctx, "Alice@qqlx.net", "10.0.0.1"); err != nil {
+			t.Fatalf("attempt %d should be allowed: %v", i, err)
+		}
+		if err := guard.Fail(ctx, "alice@qqlx.net", "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := guard.Check(ctx, "alice@qqlx.net", "10.0.0.2")
+	if !errors.Is(err, reason.ErrUserLocked) {
+		t.Fatalf("expected account locked, got: %v", err)
+	}
+	var ae *apierr.ApiError
+	if !errors.As(err, &ae) || ae.Code != apierr.LockedErrCode {
+		t.Fatalf("expected locked error code, got: %v", err)
+	}
+	// 其他账号不受影响
+	if err = guard.Check(ctx, "bob@qqlx.net", "10.0.0.2"); err != nil {
+		t.Fatalf("other account should be allowed: %v", err)
+	}
+
+	if err = guard.Unlock(ctx, "alice@qqlx.net", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err = guard.Check(ctx, "alice@qqlx.net", "10.0.0.2"); err != nil {
+		t.Fatalf("unlocked account should be allowed: %v", err)
+	}
+}
+
+func TestIPLockout(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	guard := newGuard(t, "0s")
+
+	// 每个账号只失败一次, 来源 IP 累计达到上限
+	for _, account := range []string{"a", "b", "c", "d", "e"} {
+		if err := guard.Fail(ctx, account, "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := guard.Check(ctx, "f", "10.0.0.1"); !errors.Is(err, reason.ErrLoginIPLocked) {
+		t.Fatalf("expected ip locked, got: %v", err)
+	}
+	if err := guard.Check(ctx, "f", "10.0.0.2"); err != nil {
+		t.Fatalf("other ip should be allowed: %v", err)
+	}
+}
+
+func TestProgressiveDelay(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	guard := newGuard(t, "1s")
+
+	if err := guard.Fail(ctx, "alice@qqlx.net", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := guard.Check(ctx, "alice@qqlx.net", "10.0.0.1"); !errors.Is(err, reason.ErrLoginThrottled) {
+		t.Fatalf("expected throttled, got: %v", err)
+	}
+	// 登录成功后清空计数和等待
+	if err := guard.Succeed(ctx, "alice@qqlx.net"); err != nil {
+		t.Fatal(err)
+	}
+	if err := guard.Check(ctx, "alice@qqlx.net", "10.0.0.1"); err != nil {
+		t.Fatalf("expected allowed after success, got: %v", err)
+	}
+}
+
+// windowCache 记录失败计数 key 创建时的过期时间
+type windowCache struct {
+	*testutil.MemoryCache
+	windows map[string]time.Duration
+	expires int
+}
+
+func (receive *windowCache) SetNX(ctx context.Context, key, value string, expireTime *time.Duration) (bool, error) {
+	ok, err := receive.MemoryCache.SetNX(ctx, key, value, expireTime)
+	if ok && expireTime != nil {
+		receive.windows[key] = *expireTime
+	}
+	return ok, err
+}
+
+func (receive *windowCache) Expire(context.Context, string, time.Duration) error {
+	receive.expires++
+	return nil
+}
+
+func TestFailureWindowSetOnCreate(t *testing.T) {
+	viper.Set("login.maxFailures", 3)
+	viper.Set("login.maxIPFailures", 5)
+	viper.Set("login.delayBase", "0s")
+	viper.Set("login.failureWindow", "15m")
+	defer viper.Set("login.failureWindow", "")
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	cache := &windowCache{MemoryCache: testutil.NewMemoryCache(), windows: map[string]time.Duration{}}
+	guard, err := service.NewLoginGuardSVC(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err = guard.Fail(ctx, "alice@qqlx.net", "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cache.expires != 0 {
+		t.Fatalf("expected window to be set with the counter, got %d separate expire calls", cache.expires)
+	}
+	var counters int
+	for key, window := range cache.windows {
+		if !strings.Contains(key, constant.LoginFailureCacheKeyPrefix) {
+			continue
+		}
+		counters++
+		if window != 15*time.Minute {
+			t.Fatalf("unexpected window for %s: %s", key, window)
+		}
+	}
+	if counters != 2 {
+		t.Fatalf("expected account and ip counters, got %d", counters)
+	}
+}
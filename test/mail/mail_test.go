@@ -0,0 +1,244 @@
+package mail_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"qqlx/base/constant"
+	"qqlx/base/reason"
+	"qqlx/base/validator"
+	"qqlx/model"
+	"qqlx/pkg/mailer"
+	"qqlx/schema"
+	"qqlx/service"
+	"qqlx/store/userstore"
+	"qqlx/test/testutil"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// smtpStub 本地 SMTP 服务, 只实现发送邮件所需的命令, 记录收到的邮件
+type smtpStub struct {
+	listener net.Listener
+	mu       sync.Mutex
+	from     string
+	rcpt     []string
+	data     string
+	done     chan struct{}
+}
+
+func newSMTPStub(t *testing.T) *smtpStub {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpStub{listener: listener, done: make(chan struct{})}
+	t.Cleanup(func() { _ = listener.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *smtpStub) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	defer close(s.done)
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 localhost ESMTP stub")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			_ = tp.PrintfLine("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			s.mu.Lock()
+			s.from = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+			s.mu.Unlock()
+			_ = tp.PrintfLine("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			s.mu.Lock()
+			s.rcpt = append(s.rcpt, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			s.mu.Unlock()
+			_ = tp.PrintfLine("250 OK")
+		case cmd == "DATA":
+			_ = tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.data = string(data)
+			s.mu.Unlock()
+			_ = tp.PrintfLine("250 OK")
+		case cmd == "QUIT":
+			_ = tp.PrintfLine("221 Bye")
+			return
+		default:
+			_ = tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	stub := newSMTPStub(t)
+	addr := stub.listener.Addr().(*net.TCPAddr)
+	m := mailer.NewSMTPMailer(&mailer.SMTPConfig{
+		Host: "127.0.0.1",
+		Port: addr.Port,
+		From: "noreply@qqlx.net",
+	})
+	if err := m.Send(context.Background(), []string{"alice@qqlx.net"}, "hello", "line1\nline2"); err != nil {
+		t.Fatal(err)
+	}
+	<-stub.done
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if stub.from != "noreply@qqlx.net" || len(stub.rcpt) != 1 || stub.rcpt[0] != "alice@qqlx.net" {
+		t.Fatalf("unexpected envelope, from: %s, rcpt: %v", stub.from, stub.rcpt)
+	}
+	msg, err := textproto.NewReader(bufio.NewReader(strings.NewReader(stub.data))).ReadMIMEHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Get("Subject") != "hello" {
+		t.Fatalf("unexpected subject: %s", msg.Get("Subject"))
+	}
+	if !strings.Contains(stub.data, "line1\nline2") {
+		t.Fatalf("unexpected body: %q", stub.data)
+	}
+}
+
+// recordMailer 记录最后一封邮件
+type recordMailer struct {
+	body string
+}
+
+func (m *recordMailer) Send(_ context.Context, _ []string, _, body string) error {
+	m.body = body
+	return nil
+}
+
+func TestMailTokenSingleUse(t *testing.T) {
+	viper.Set("mail.tokenKey", "test-key")
+	viper.Set("mail.resetURL", "http://localhost/reset?token=%s")
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	record := &recordMailer{}
+	mailSvc, err := service.NewMailSVC(testutil.NewMemoryCache(), record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := &model.User{ID: 1, Name: "alice", Email: "alice@qqlx.net"}
+	if err = mailSvc.SendResetMail(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	_, after, ok := strings.Cut(record.body, "token=")
+	if !ok {
+		t.Fatalf("reset link not found in mail: %s", record.body)
+	}
+	token := strings.TrimSpace(after)
+
+	// 用途不同的 token 不能混用
+	if _, err = mailSvc.ConsumeToken(ctx, service.MailTokenPurposeVerify, token); !errors.Is(err, reason.ErrMailTokenInvalid) {
+		t.Fatalf("expected purpose mismatch to be rejected, got: %v", err)
+	}
+	// 篡改签名
+	if _, err = mailSvc.ConsumeToken(ctx, service.MailTokenPurposeReset, token+"x"); !errors.Is(err, reason.ErrMailTokenInvalid) {
+		t.Fatalf("expected tampered token to be rejected, got: %v", err)
+	}
+
+	userID, err := mailSvc.ConsumeToken(ctx, service.MailTokenPurposeReset, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID != user.ID {
+		t.Fatalf("expected user id %d, got %d", user.ID, userID)
+	}
+	if _, err = mailSvc.ConsumeToken(ctx, service.MailTokenPurposeReset, token); !errors.Is(err, reason.ErrMailTokenInvalid) {
+		t.Fatalf("expected reused token to be rejected, got: %v", err)
+	}
+}
+
+// memoryUserStore 只保存一个用户, 查询忽略条件
+type memoryUserStore struct {
+	user *model.User
+}
+
+func (receive *memoryUserStore) Query(context.Context, ...userstore.QueryOption) (*model.User, error) {
+	return receive.user, nil
+}
+
+func (receive *memoryUserStore) Create(context.Context, *model.User) error { return nil }
+
+func (receive *memoryUserStore) Save(context.Context, *model.User) error { return nil }
+
+func (receive *memoryUserStore) Delete(context.Context, *model.User, ...userstore.DeleteOption) error {
+	return nil
+}
+
+func (receive *memoryUserStore) List(context.Context, int, int, ...userstore.QueryOption) (int64, []model.User, error) {
+	return 0, nil, nil
+}
+
+func TestResetPasswordPolicyKeepsToken(t *testing.T) {
+	viper.Set("server.salt", "test")
+	viper.Set("mail.tokenKey", "test-key")
+	viper.Set("mail.resetURL", "http://localhost/reset?token=%s")
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	record := &recordMailer{}
+	mailSvc, err := service.NewMailSVC(testutil.NewMemoryCache(), record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := validator.NewPasswordPolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := model.UserStatusAvailable
+	user := &model.User{ID: 1, Name: "alice", Email: "alice@qqlx.net", Status: &status}
+	userSvc, err := service.NewUserSVC(nil, &memoryUserStore{user: user}, nil, nil, testutil.NewMemoryCache(), nil, nil, service.UserAuth{
+		Token:    service.NewTokenSVC(testutil.NewMemoryCache(), testutil.NewMemorySessionStore(), nil),
+		Mail:     mailSvc,
+		Password: policy,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mailSvc.SendResetMail(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	_, token, _ := strings.Cut(record.body, "token=")
+	token = strings.TrimSpace(token)
+
+	// 新密码不符合策略时 token 仍然可用
+	err = userSvc.ResetPassword(ctx, &schema.UserResetPasswordRequest{Token: token, Password: "short"})
+	if !errors.Is(err, reason.ErrPasswordPolicy) {
+		t.Fatalf("expected password policy error, got: %v", err)
+	}
+	if err = userSvc.ResetPassword(ctx, &schema.UserResetPasswordRequest{Token: token, Password: "Str0ng-Passw0rd"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = mailSvc.ConsumeToken(ctx, service.MailTokenPurposeReset, token); !errors.Is(err, reason.ErrMailTokenInvalid) {
+		t.Fatalf("expected used token to be rejected, got: %v", err)
+	}
+}
+
+func TestMailTokenKeyRequired(t *testing.T) {
+	viper.Set("mail.tokenKey", "")
+	defer viper.Set("mail.tokenKey", "test-key")
+	if _, err := service.NewMailSVC(testutil.NewMemoryCache(), &recordMailer{}); err == nil {
+		t.Fatal("expected empty mail.tokenKey to be rejected")
+	}
+}
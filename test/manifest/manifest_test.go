@@ -0,0 +1,216 @@
+package manifest_test
+
+import (
+	"context"
+	"qqlx/base/constant"
+	"qqlx/model"
+	"qqlx/schema"
+	"qqlx/service"
+	"qqlx/store/rbac"
+	"qqlx/store/userstore"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+type memoryUserStore struct {
+	users []model.User
+}
+
+func (receive *memoryUserStore) Query(context.Context, ...userstore.QueryOption) (*model.User, error) {
+	return nil, nil
+}
+
+func (receive *memoryUserStore) Create(context.Context, *model.User) error { return nil }
+
+func (receive *memoryUserStore) Save(context.Context, *model.User) error { return nil }
+
+func (receive *memoryUserStore) Delete(context.Context, *model.User, ...userstore.DeleteOption) error {
+	return nil
+}
+
+func (receive *memoryUserStore) List(context.Context, int, int, ...userstore.QueryOption) (int64, []model.User, error) {
+	return int64(len(receive.users)), receive.users, nil
+}
+
+type memoryRoleStore struct {
+	roles []model.Role
+}
+
+func (receive *memoryRoleStore) Query(context.Context, ...rbac.RoleQueryOption) (*model.Role, error) {
+	return nil, nil
+}
+
+func (receive *memoryRoleStore) Create(context.Context, *model.Role) error { return nil }
+
+func (receive *memoryRoleStore) Save(context.Context, *model.Role) error { return nil }
+
+func (receive *memoryRoleStore) Delete(context.Context, *model.Role, ...rbac.RoleDeleteOption) error {
+	return nil
+}
+
+func (receive *memoryRoleStore) List(context.Context, int, int, ...rbac.RoleQueryOption) (int64, []model.Role, error) {
+	return int64(len(receive.roles)), receive.roles, nil
+}
+
+type memoryPolicyStore struct {
+	policies []model.Policy
+}
+
+func (receive *memoryPolicyStore) Query(context.Context, ...rbac.PolicyQueryOption) (*model.Policy, error) {
+	return nil, nil
+}
+
+func (receive *memoryPolicyStore) Create(context.Context, *model.Policy) error { return nil }
+
+func (receive *memoryPolicyStore) Save(context.Context, *model.Policy) error { return nil }
+
+func (receive *memoryPolicyStore) Delete(context.Context, *model.Policy, ...rbac.PolicyDeleteOption) error {
+	return nil
+}
+
+func (receive *memoryPolicyStore) List(context.Context, int, int, ...rbac.PolicyQueryOption) (int64, []model.Policy, error) {
+	return int64(len(receive.policies)), receive.policies, nil
+}
+
+func newManifestSVC() *service.ManifestSVC {
+	listUser := model.Policy{ID: 1, Name: "userList", Path: "/api/v1/users", Method: "GET", Describe: "获取用户列表", Effect: constant.PolicyEffectAllow}
+	deleteUser := model.Policy{ID: 2, Name: "deleteUser", Path: "/api/v1/users/:id", Method: "DELETE", Describe: "删除用户", Effect: constant.PolicyEffectAllow}
+	base := model.Role{ID: 10, Name: "base", Description: "基础角色", Policys: []model.Policy{listUser}}
+	ops := model.Role{ID: 11, Name: "ops", Description: "运维", Policys: []model.Policy{deleteUser}, Parents: []model.Role{base}}
+	legacy := model.Role{ID: 12, Name: "legacy", Description: "旧角色"}
+	return service.NewManifestSVC(
+		&memoryUserStore{users: []model.User{{ID: 100, Name: "alice", Roles: []model.Role{ops, legacy}}}},
+		&memoryRoleStore{roles: []model.Role{base, ops, legacy}},
+		&memoryPolicyStore{policies: []model.Policy{listUser, deleteUser}},
+		nil, nil, nil,
+	)
+}
+
+func TestManifestExportRoundTrip(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	svc := newManifestSVC()
+	manifest, err := svc.Export(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := yaml.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := new(schema.RbacManifest)
+	if err = yaml.Unmarshal(out, parsed); err != nil {
+		t.Fatal(err)
+	}
+	// 导出的配置应用到同一数据库时没有变更
+	res, err := svc.Apply(ctx, parsed, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Changes) != 0 {
+		t.Fatalf("changes = %+v", res.Changes)
+	}
+}
+
+func TestManifestPlan(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	manifest := &schema.RbacManifest{
+		Policies: []schema.ManifestPolicy{
+			{Name: "userList", Path: "/api/v1/users", Method: "GET", Describe: "用户列表"},
+			{Name: "roleList", Path: "/api/v1/roles", Method: "GET", Describe: "获取角色列表"},
+		},
+		Roles: []schema.ManifestRole{
+			{Name: "base", Description: "基础角色", Policies: []string{"userList", "roleList"}},
+			{Name: "ops", Description: "运维", Parents: []string{"base"}},
+			{Name: "audit", Description: "审计", Policies: []string{"roleList"}},
+		},
+		Users: []schema.ManifestUser{{Name: "alice", Roles: []string{"ops", "audit"}}},
+	}
+
+	cases := []struct {
+		prune bool
+		want  []schema.RbacChange
+	}{
+		{false, []schema.RbacChange{
+			{Action: "update", Kind: "policy", Name: "userList"},
+			{Action: "create", Kind: "policy", Name: "roleList"},
+			{Action: "create", Kind: "role", Name: "audit"},
+			{Action: "create", Kind: "rolePolicy", Name: "base"},
+			{Action: "create", Kind: "rolePolicy", Name: "audit"},
+			{Action: "create", Kind: "userRole", Name: "alice"},
+		}},
+		{true, []schema.RbacChange{
+			{Action: "update", Kind: "policy", Name: "userList"},
+			{Action: "create", Kind: "policy", Name: "roleList"},
+			{Action: "create", Kind: "role", Name: "audit"},
+			{Action: "create", Kind: "rolePolicy", Name: "base"},
+			{Action: "delete", Kind: "rolePolicy", Name: "ops"},
+			{Action: "create", Kind: "rolePolicy", Name: "audit"},
+			{Action: "create", Kind: "userRole", Name: "alice"},
+			{Action: "delete", Kind: "userRole", Name: "alice"},
+			{Action: "delete", Kind: "role", Name: "legacy"},
+			{Action: "delete", Kind: "policy", Name: "deleteUser"},
+		}},
+	}
+	for _, c := range cases {
+		res, err := newManifestSVC().Apply(ctx, manifest, true, c.prune)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Changes) != len(c.want) {
+			t.Fatalf("prune=%v changes = %+v", c.prune, res.Changes)
+		}
+		for i, want := range c.want {
+			got := res.Changes[i]
+			if got.Action != want.Action || got.Kind != want.Kind || got.Name != want.Name {
+				t.Fatalf("prune=%v change %d = %+v, want %+v", c.prune, i, got, want)
+			}
+		}
+	}
+}
+
+func TestManifestInvalid(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	manifests := []*schema.RbacManifest{
+		{Roles: []schema.ManifestRole{{Name: "ops", Policies: []string{"missing"}}}},
+		{Roles: []schema.ManifestRole{{Name: "ops", Parents: []string{"missing"}}}},
+		{Users: []schema.ManifestUser{{Name: "alice", Roles: []string{"missing"}}}},
+		{Policies: []schema.ManifestPolicy{{Name: "userList", Path: "/api/v1/users/list", Method: "GET"}}},
+		{Users: []schema.ManifestUser{{Name: "bob"}}},
+	}
+	for i, manifest := range manifests {
+		if _, err := newManifestSVC().Apply(ctx, manifest, true, false); err == nil {
+			t.Fatalf("manifest %d should be invalid", i)
+		}
+	}
+}
+
+func TestManifestDuplicatePolicyName(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	svc := service.NewManifestSVC(&memoryUserStore{}, &memoryRoleStore{}, &memoryPolicyStore{policies: []model.Policy{
+		{ID: 1, Name: "userList", Path: "/api/v1/users", Method: "GET"},
+		{ID: 2, Name: "userList", Path: "/api/v1/tenants/:tenant/users", Method: "GET"},
+	}}, nil, nil, nil)
+	if _, err := svc.Export(ctx); err == nil {
+		t.Fatal("export should reject duplicate policy names")
+	}
+	if _, err := svc.Apply(ctx, &schema.RbacManifest{}, true, true); err == nil {
+		t.Fatal("apply should reject duplicate policy names")
+	}
+}
+
+func TestManifestPruneKeepsTenantPolicy(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	tenantUsers := model.Policy{ID: 1, Name: "tenantUsers", Path: "/api/v1/tenants/:tenant/users", Method: "GET",
+		Roles: []model.Role{{ID: 20, Name: "member", TenantID: 1}}}
+	unused := model.Policy{ID: 2, Name: "unused", Path: "/api/v1/unused", Method: "GET"}
+	svc := service.NewManifestSVC(&memoryUserStore{}, &memoryRoleStore{},
+		&memoryPolicyStore{policies: []model.Policy{tenantUsers, unused}}, nil, nil, nil)
+	res, err := svc.Apply(ctx, &schema.RbacManifest{}, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Changes) != 1 || res.Changes[0].Name != "unused" {
+		t.Fatalf("changes = %+v", res.Changes)
+	}
+}
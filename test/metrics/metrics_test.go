@@ -0,0 +1,122 @@
+package metrics_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"qqlx/base/constant"
+	"qqlx/base/helpers"
+	"qqlx/base/metrics"
+	"qqlx/base/middleware"
+	"qqlx/model"
+	"qqlx/store/rbac"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func scrape(t *testing.T) string {
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.MetricsMiddleware())
+	r.GET("/users/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	for _, path := range []string{"/users/42", "/users/43", "/missing"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	body := scrape(t)
+	for _, want := range []string{
+		`qqlx_http_request_duration_seconds_count{method="GET",route="/users/:id",status="200"} 2`,
+		`qqlx_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("missing %s", want)
+		}
+	}
+	if strings.Contains(body, "/users/42") {
+		t.Fatal("raw path used as label")
+	}
+}
+
+func TestUnknownMethod(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.MetricsMiddleware())
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("BREW", "/coffee", nil))
+
+	body := scrape(t)
+	if !strings.Contains(body, `qqlx_http_request_duration_seconds_count{method="other",route="unmatched",status="404"} 1`) {
+		t.Fatal("unknown method not counted as other")
+	}
+	if strings.Contains(body, `method="BREW"`) {
+		t.Fatal("raw method used as label")
+	}
+}
+
+func TestAuthFailures(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", middleware.NewAuthentication(nil, nil, nil).Authentication(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	empty := metrics.AuthFailures.WithLabelValues("authentication", "auth in the request header is empty")
+	malformed := metrics.AuthFailures.WithLabelValues("authentication", "the auth format in the request header is incorrect")
+	before := testutil.ToFloat64(empty)
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Basic xxx")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got := testutil.ToFloat64(empty) - before; got != 1 {
+		t.Fatalf("empty header failures = %v", got)
+	}
+	if got := testutil.ToFloat64(malformed); got != 1 {
+		t.Fatalf("malformed header failures = %v", got)
+	}
+}
+
+func TestCasbinDecisions(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	enforcer, err := casbin.NewEnforcer("../../model.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := helpers.GetCasbinRole(&model.Role{Name: "view"}, []model.Policy{{ID: 1, Name: "view", Path: "*", Method: "GET"}})
+	if err = rbac.NewCasbinStore(enforcer).CreateRolePolices(ctx, rules); err != nil {
+		t.Fatal(err)
+	}
+	authorizer := rbac.NewAuthentication(enforcer)
+	allow := testutil.ToFloat64(metrics.CasbinDecisions.WithLabelValues(metrics.ResultAllow))
+	deny := testutil.ToFloat64(metrics.CasbinDecisions.WithLabelValues(metrics.ResultDeny))
+
+	env := rbac.NewConditionEnv(0, 0, 0, time.Now(), nil)
+	domain := helpers.GetCasbinDomain(0)
+	for _, method := range []string{"GET", "DELETE"} {
+		if _, err = authorizer.EnforceWithCtx(ctx, "view", domain, "/api/v1/users", method, env); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := testutil.ToFloat64(metrics.CasbinDecisions.WithLabelValues(metrics.ResultAllow)) - allow; got != 1 {
+		t.Fatalf("allow decisions = %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.CasbinDecisions.WithLabelValues(metrics.ResultDeny)) - deny; got != 1 {
+		t.Fatalf("deny decisions = %v", got)
+	}
+	if !strings.Contains(scrape(t), "qqlx_casbin_enforce_duration_seconds_count 2") {
+		t.Fatal("missing casbin enforce latency")
+	}
+}
@@ -0,0 +1,171 @@
+package mfa_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"qqlx/base/constant"
+	"qqlx/base/reason"
+	"qqlx/model"
+	"qqlx/pkg/totp"
+	"qqlx/schema"
+	"qqlx/service"
+	"qqlx/store/userstore"
+	"qqlx/test/testutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// memoryUserStore 只保存一个用户, 查询忽略条件
+type memoryUserStore struct {
+	mu   sync.Mutex
+	user model.User
+	// stale 为 true 时 Save 不生效, 模拟并发请求互相覆盖
+	stale bool
+}
+
+func (receive *memoryUserStore) Query(context.Context, ...userstore.QueryOption) (*model.User, error) {
+	receive.mu.Lock()
+	defer receive.mu.Unlock()
+	user := receive.user
+	return &user, nil
+}
+
+func (receive *memoryUserStore) Create(context.Context, *model.User) error { return nil }
+
+func (receive *memoryUserStore) Save(_ context.Context, user *model.User) error {
+	receive.mu.Lock()
+	defer receive.mu.Unlock()
+	if !receive.stale {
+		receive.user = *user
+	}
+	return nil
+}
+
+func (receive *memoryUserStore) Delete(context.Context, *model.User, ...userstore.DeleteOption) error {
+	return nil
+}
+
+func (receive *memoryUserStore) List(context.Context, int, int, ...userstore.QueryOption) (int64, []model.User, error) {
+	return 0, nil, nil
+}
+
+func newUser(t *testing.T, recoveryCode string) *model.User {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(recoveryCode))
+	status := model.UserStatusAvailable
+	return &model.User{
+		ID:               1,
+		Name:             "alice",
+		Status:           &status,
+		MfaEnable:        true,
+		MfaSecret:        secret,
+		MfaRecoveryCodes: hex.EncodeToString(sum[:]),
+	}
+}
+
+func TestTicketAttemptsExceeded(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	user := newUser(t, "recovery")
+	mfa := service.NewMfaSVC(&memoryUserStore{user: *user}, testutil.NewMemoryCache(), nil, nil)
+	res, err := mfa.NewTicket(ctx, user, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &schema.MfaLoginRequest{Ticket: res.MfaTicket, Code: "000000"}
+	for i := 0; i < constant.MfaTicketMaxAttempts; i++ {
+		if _, _, _, err = mfa.VerifyTicket(ctx, req); !errors.Is(err, reason.ErrMfaCodeInvalid) {
+			t.Fatalf("attempt %d: expected invalid code, got %v", i, err)
+		}
+	}
+	code, err := totp.GenerateCode(user.MfaSecret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Code = code
+	if _, _, _, err = mfa.VerifyTicket(ctx, req); !errors.Is(err, reason.ErrMfaTicketInvalid) {
+		t.Fatalf("expected ticket to be invalidated, got %v", err)
+	}
+}
+
+func TestOidcTicket(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	user := newUser(t, "recovery")
+	mfa := service.NewMfaSVC(&memoryUserStore{user: *user}, testutil.NewMemoryCache(), nil, nil)
+	res, err := mfa.NewOidcTicket(ctx, user, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := totp.GenerateCode(user.MfaSecret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, oidcLogin, err := mfa.VerifyTicket(ctx, &schema.MfaLoginRequest{Ticket: res.MfaTicket, Code: code})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !oidcLogin {
+		t.Fatal("expected oidc ticket")
+	}
+}
+
+func TestRecoveryCodeUsedOnce(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	user := newUser(t, "recovery")
+	// Save 不生效时数据库中恢复码仍然存在, 只能依靠使用标记拒绝
+	mfa := service.NewMfaSVC(&memoryUserStore{user: *user, stale: true}, testutil.NewMemoryCache(), nil, nil)
+	for i := 0; i < 2; i++ {
+		res, err := mfa.NewTicket(ctx, user, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, _, err = mfa.VerifyTicket(ctx, &schema.MfaLoginRequest{Ticket: res.MfaTicket, Code: "recovery"})
+		if i == 0 && err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 && !errors.Is(err, reason.ErrMfaCodeInvalid) {
+			t.Fatalf("expected reused recovery code to be rejected, got %v", err)
+		}
+	}
+}
+
+func TestCodeFailuresLockAccount(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	viper.Set("login.maxFailures", 3)
+	viper.Set("login.delayBase", "0s")
+	guard, err := service.NewLoginGuardSVC(testutil.NewMemoryCache())
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := newUser(t, "recovery")
+	user.Email = "alice@qqlx.net"
+	mfa := service.NewMfaSVC(&memoryUserStore{user: *user}, testutil.NewMemoryCache(), guard, nil)
+	// 每次使用新票据, 验证码错误仍然累计到账号
+	for i := 0; i < 3; i++ {
+		res, err := mfa.NewTicket(ctx, user, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, _, err = mfa.VerifyTicket(ctx, &schema.MfaLoginRequest{Ticket: res.MfaTicket, Code: "000000"}); !errors.Is(err, reason.ErrMfaCodeInvalid) {
+			t.Fatalf("attempt %d: expected invalid code, got %v", i, err)
+		}
+	}
+	res, err := mfa.NewTicket(ctx, user, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := totp.GenerateCode(user.MfaSecret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err = mfa.VerifyTicket(ctx, &schema.MfaLoginRequest{Ticket: res.MfaTicket, Code: code}); !errors.Is(err, reason.ErrUserLocked) {
+		t.Fatalf("expected account locked, got %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"qqlx/base/constant"
+	"qqlx/base/reason"
+	"qqlx/pkg/oidc"
+	"qqlx/schema"
+	"qqlx/service"
+	"qqlx/test/testutil"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockProvider 本地 OIDC 身份提供方, 提供 discovery, jwks 和 token 接口
+type mockProvider struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	nonce    string
+	verifier string
+	claims   jwt.MapClaims
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &mockProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                p.server.URL,
+			"authorization_endpoint":                p.server.URL + "/authorize",
+			"token_endpoint":                        p.server.URL + "/token",
+			"jwks_uri":                              p.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.PostForm.Get("code") != "good-code" || r.PostForm.Get("code_verifier") != p.verifier {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		claims := jwt.MapClaims{
+			"iss":   p.server.URL,
+			"aud":   "qqlx",
+			"sub":   "subject-1",
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"iat":   time.Now().Unix(),
+			"nonce": p.nonce,
+		}
+		for k, v := range p.claims {
+			claims[k] = v
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "test"
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   60,
+			"id_token":     idToken,
+		})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func newClient(t *testing.T, p *mockProvider) *oidc.Client {
+	client, err := oidc.NewClient(context.Background(), &oidc.Config{
+		Issuer:        p.server.URL,
+		ClientID:      "qqlx",
+		ClientSecret:  "secret",
+		RedirectURL:   "http://localhost/callback",
+		NameClaim:     "preferred_username",
+		EmailClaim:    "email",
+		NickNameClaim: "name",
+		GroupsClaim:   "groups",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestExchange(t *testing.T) {
+	p := newMockProvider(t)
+	p.claims = jwt.MapClaims{
+		"preferred_username": "alice",
+		"email":              "alice@example.com",
+		"email_verified":     true,
+		"name":               "Alice",
+		"groups":             []string{"ops", "dev"},
+	}
+	client := newClient(t, p)
+
+	state, nonce, verifier, err := oidc.NewState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := url.Parse(client.AuthCodeURL(state, nonce, verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := authURL.Query()
+	if query.Get("state") != state || query.Get("nonce") != nonce || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected auth url: %s", authURL)
+	}
+
+	p.nonce, p.verifier = nonce, verifier
+	identity, err := client.Exchange(context.Background(), "good-code", nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Subject != "subject-1" || identity.Name != "alice" || identity.Username != "alice" || identity.Email != "alice@example.com" || identity.NickName != "Alice" {
+		t.Fatalf("unexpected identity: %+v", identity)
+	}
+	if len(identity.Groups) != 2 || identity.Groups[0] != "ops" {
+		t.Fatalf("unexpected groups: %v", identity.Groups)
+	}
+	if !identity.EmailVerified {
+		t.Fatal("expected email to be verified")
+	}
+}
+
+func TestExchangeEmailVerified(t *testing.T) {
+	cases := []struct {
+		name     string
+		claim    any
+		verified bool
+	}{
+		{name: "missing", claim: nil, verified: false},
+		{name: "false", claim: false, verified: false},
+		{name: "string", claim: "true", verified: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newMockProvider(t)
+			p.claims = jwt.MapClaims{"email": "alice@example.com"}
+			if tc.claim != nil {
+				p.claims["email_verified"] = tc.claim
+			}
+			client := newClient(t, p)
+			_, nonce, verifier, err := oidc.NewState()
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.nonce, p.verifier = nonce, verifier
+			identity, err := client.Exchange(context.Background(), "good-code", nonce, verifier)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if identity.EmailVerified != tc.verified {
+				t.Fatalf("expected email verified %v, got %v", tc.verified, identity.EmailVerified)
+			}
+		})
+	}
+}
+
+func TestExchangeRejectNonce(t *testing.T) {
+	p := newMockProvider(t)
+	client := newClient(t, p)
+	_, nonce, verifier, err := oidc.NewState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.nonce, p.verifier = "other-nonce", verifier
+	if _, err = client.Exchange(context.Background(), "good-code", nonce, verifier); err == nil {
+		t.Fatal("expected nonce mismatch to be rejected")
+	}
+}
+
+func TestExchangeRejectVerifier(t *testing.T) {
+	p := newMockProvider(t)
+	client := newClient(t, p)
+	_, nonce, verifier, err := oidc.NewState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.nonce, p.verifier = nonce, "other-verifier"
+	if _, err = client.Exchange(context.Background(), "good-code", nonce, verifier); err == nil {
+		t.Fatal("expected PKCE verifier mismatch to be rejected")
+	}
+}
+
+func TestCallbackRequiresStateCookie(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	p := newMockProvider(t)
+	svc, err := service.NewOidcSVC(newClient(t, p), testutil.NewMemoryCache(), nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := svc.Login(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 没有 cookie 或 cookie 与 state 不一致时拒绝, state 不会被消费
+	for _, cookie := range []string{"", "other"} {
+		_, err = svc.Callback(ctx, &schema.OidcCallbackRequest{Code: "good-code", State: res.State, Cookie: cookie})
+		if !errors.Is(err, reason.ErrOidcState) {
+			t.Fatalf("cookie %q: expected invalid state, got %v", cookie, err)
+		}
+	}
+	// cookie 一致时继续校验授权码
+	_, err = svc.Callback(ctx, &schema.OidcCallbackRequest{Code: "bad-code", State: res.State, Cookie: res.State})
+	if err == nil || errors.Is(err, reason.ErrOidcState) {
+		t.Fatalf("expected code exchange error, got %v", err)
+	}
+}
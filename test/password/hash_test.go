@@ -12,6 +12,8 @@ import (
 	"qqlx/base/constant"
 	"qqlx/base/data"
 	"qqlx/base/logger"
+	"qqlx/base/validator"
+	"qqlx/pkg/mailer"
 	"qqlx/pkg/sonyflake"
 	"qqlx/schema"
 	"qqlx/service"
@@ -78,7 +80,26 @@ func TestLdapSha256Crypt(t *testing.T) {
 	}
 	defer f3()
 	generateID := sonyflake.NewGenerateID(context.Background(), cacheStore)
-	userSVC, err := service.NewUserSVC(generateID, userStore, nil, nil, cacheStore, nil, ldapStore)
+	mailSVC, err := service.NewMailSVC(cacheStore, mailer.NewLogMailer())
+	if err != nil {
+		t.Fatalf("new mail svc faild: %v", err)
+	}
+	passwordPolicy, err := validator.NewPasswordPolicy()
+	if err != nil {
+		t.Fatalf("new password policy faild: %v", err)
+	}
+	loginGuardSVC, err := service.NewLoginGuardSVC(cacheStore)
+	if err != nil {
+		t.Fatalf("new login guard svc faild: %v", err)
+	}
+	userSVC, err := service.NewUserSVC(generateID, userStore, nil, nil, cacheStore, nil, ldapStore, service.UserAuth{
+		Token:    service.NewTokenSVC(cacheStore, userstore.NewSessionStore(mysql), nil),
+		Mfa:      service.NewMfaSVC(userStore, cacheStore, loginGuardSVC, nil),
+		Mail:     mailSVC,
+		Guard:    loginGuardSVC,
+		Password: passwordPolicy,
+		History:  userstore.NewPasswordHistoryStore(mysql),
+	}, nil)
 	if err != nil {
 		t.Fatalf("new user svc faild: %v", err)
 	}
@@ -0,0 +1,218 @@
+package revision_test
+
+import (
+	"context"
+	"errors"
+	"qqlx/base/constant"
+	"qqlx/base/helpers"
+	"qqlx/model"
+	"qqlx/schema"
+	"qqlx/service"
+	"qqlx/store/rbac"
+	"slices"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+)
+
+type memoryRevisionStore struct {
+	revisions map[int]*model.RbacRevision
+	// commitErr 模拟 sync 成功后事务提交失败
+	commitErr error
+	// snapshotErr 模拟回滚生效后记录版本失败
+	snapshotErr error
+	restored    *rbac.RevisionRestore
+}
+
+func (receive *memoryRevisionStore) Create(_ context.Context, revision *model.RbacRevision) error {
+	receive.revisions[revision.ID] = revision
+	return nil
+}
+
+func (receive *memoryRevisionStore) Query(_ context.Context, id int) (*model.RbacRevision, error) {
+	return receive.revisions[id], nil
+}
+
+func (receive *memoryRevisionStore) List(context.Context, int, int) (int64, []model.RbacRevision, error) {
+	return 0, nil, nil
+}
+
+func (receive *memoryRevisionStore) Snapshot(context.Context) (map[string][]int, error) {
+	return nil, receive.snapshotErr
+}
+
+func (receive *memoryRevisionStore) Restore(_ context.Context, restore *rbac.RevisionRestore, sync func() error) error {
+	if err := sync(); err != nil {
+		return err
+	}
+	if receive.commitErr != nil {
+		return receive.commitErr
+	}
+	receive.restored = restore
+	return nil
+}
+
+type memoryRoleStore struct {
+	roles []model.Role
+}
+
+func (receive *memoryRoleStore) Query(context.Context, ...rbac.RoleQueryOption) (*model.Role, error) {
+	return nil, nil
+}
+
+func (receive *memoryRoleStore) Create(context.Context, *model.Role) error { return nil }
+
+func (receive *memoryRoleStore) Save(context.Context, *model.Role) error { return nil }
+
+func (receive *memoryRoleStore) Delete(context.Context, *model.Role, ...rbac.RoleDeleteOption) error {
+	return nil
+}
+
+func (receive *memoryRoleStore) List(context.Context, int, int, ...rbac.RoleQueryOption) (int64, []model.Role, error) {
+	return int64(len(receive.roles)), receive.roles, nil
+}
+
+type memoryPolicyStore struct {
+	policies []model.Policy
+}
+
+func (receive *memoryPolicyStore) Query(context.Context, ...rbac.PolicyQueryOption) (*model.Policy, error) {
+	return nil, nil
+}
+
+func (receive *memoryPolicyStore) Create(context.Context, *model.Policy) error { return nil }
+
+func (receive *memoryPolicyStore) Save(context.Context, *model.Policy) error { return nil }
+
+func (receive *memoryPolicyStore) Delete(context.Context, *model.Policy, ...rbac.PolicyDeleteOption) error {
+	return nil
+}
+
+func (receive *memoryPolicyStore) List(context.Context, int, int, ...rbac.PolicyQueryOption) (int64, []model.Policy, error) {
+	return int64(len(receive.policies)), receive.policies, nil
+}
+
+var (
+	listUser   = model.Policy{ID: 1, Name: "userList", Path: "/api/v1/users", Method: "GET"}
+	deleteUser = model.Policy{ID: 2, Name: "deleteUser", Path: "/api/v1/users/:id", Method: "DELETE"}
+	listRole   = model.Policy{ID: 3, Name: "roleList", Path: "/api/v1/roles", Method: "GET"}
+)
+
+func TestRevisionDiff(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	store := &memoryRevisionStore{revisions: map[int]*model.RbacRevision{
+		1: {ID: 1, Snapshot: map[string][]int{"ops": {1, 2}, "audit": {3}}},
+		2: {ID: 2, Snapshot: map[string][]int{"ops": {1, 3}, "dev": {4}}},
+	}}
+	svc := service.NewRevisionSVC(nil, store, nil, &memoryPolicyStore{policies: []model.Policy{listUser, deleteUser, listRole}}, nil, nil)
+
+	res, err := svc.Diff(ctx, &schema.RevisionDiffRequest{From: 1, To: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][2][]int{
+		"audit": {nil, {3}},
+		"dev":   {{4}, nil},
+		"ops":   {{3}, {2}},
+	}
+	if len(res.Roles) != len(want) {
+		t.Fatalf("roles = %+v", res.Roles)
+	}
+	for _, diff := range res.Roles {
+		w := want[diff.Role]
+		if !slices.Equal(helpers.GetIDs(diff.Added), w[0]) {
+			t.Fatalf("%s added = %v, want %v", diff.Role, helpers.GetIDs(diff.Added), w[0])
+		}
+		if !slices.Equal(helpers.GetIDs(diff.Removed), w[1]) {
+			t.Fatalf("%s removed = %v, want %v", diff.Role, helpers.GetIDs(diff.Removed), w[1])
+		}
+	}
+	// 已删除的策略只有 ID
+	if res.Roles[1].Role != "dev" || res.Roles[1].Added[0].Name != "" {
+		t.Fatalf("deleted policy = %+v", res.Roles[1].Added)
+	}
+}
+
+func TestRevisionRollbackCommitFailed(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	enforcer, err := casbin.NewEnforcer("../../model.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	casbinStore := rbac.NewCasbinStore(enforcer)
+	ops := model.Role{ID: 10, Name: "ops"}
+	current := helpers.GetCasbinRole(&ops, []model.Policy{listUser, deleteUser})
+	if err = casbinStore.CreateRolePolices(ctx, current); err != nil {
+		t.Fatal(err)
+	}
+	store := &memoryRevisionStore{
+		revisions: map[int]*model.RbacRevision{1: {ID: 1, Snapshot: map[string][]int{"ops": {1, 3}, "removed": {1}}}},
+		commitErr: errors.New("commit failed"),
+	}
+	svc := service.NewRevisionSVC(nil, store, &memoryRoleStore{roles: []model.Role{ops}},
+		&memoryPolicyStore{policies: []model.Policy{listUser, deleteUser, listRole}}, casbinStore, nil)
+
+	if _, err = svc.Rollback(ctx, &schema.RevisionIDRequest{ID: 1}); err == nil {
+		t.Fatal("rollback should fail when commit fails")
+	}
+	rules, err := casbinStore.GetRolePolicyByName(ctx, "ops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != len(current) {
+		t.Fatalf("casbin rules = %v, want %v", rules, current)
+	}
+	for _, rule := range current {
+		if ok, _ := enforcer.HasPolicy(rule); !ok {
+			t.Fatalf("casbin rule %v not restored", rule)
+		}
+	}
+}
+
+func TestRevisionRollbackParentsAndEffect(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	enforcer, err := casbin.NewEnforcer("../../model.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	casbinStore := rbac.NewCasbinStore(enforcer)
+	audit := model.Role{ID: 11, Name: "audit"}
+	ops := model.Role{ID: 10, Name: "ops", Parents: []model.Role{audit}}
+	if err = casbinStore.CreateRolePolices(ctx, helpers.GetCasbinRole(&ops, []model.Policy{deleteUser})); err != nil {
+		t.Fatal(err)
+	}
+	if err = casbinStore.SetRoleParents(ctx, "ops", []string{"audit"}); err != nil {
+		t.Fatal(err)
+	}
+	// 目标版本中 ops 没有父角色, deleteUser 为 deny
+	store := &memoryRevisionStore{
+		revisions: map[int]*model.RbacRevision{1: {
+			ID:       1,
+			Snapshot: map[string][]int{"ops": {2}},
+			Parents:  map[string][]string{},
+			Polices:  map[int]model.RevisionPolicy{2: {Effect: constant.PolicyEffectDeny}},
+		}},
+		snapshotErr: errors.New("snapshot failed"),
+	}
+	svc := service.NewRevisionSVC(nil, store, &memoryRoleStore{roles: []model.Role{ops, audit}},
+		&memoryPolicyStore{policies: []model.Policy{listUser, deleteUser, listRole}}, casbinStore, nil)
+
+	// 回滚生效后记录版本失败不影响结果
+	if _, err = svc.Rollback(ctx, &schema.RevisionIDRequest{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.restored.RoleParents[ops.ID]) != 0 {
+		t.Fatalf("restored parents = %v", store.restored.RoleParents)
+	}
+	if len(store.restored.Polices) != 1 || store.restored.Polices[0].Effect != constant.PolicyEffectDeny {
+		t.Fatalf("restored polices = %+v", store.restored.Polices)
+	}
+	if ok, _ := enforcer.HasGroupingPolicy("ops", "audit"); ok {
+		t.Fatal("casbin g rule not removed")
+	}
+	deny := deleteUser
+	deny.Effect = constant.PolicyEffectDeny
+	if ok, _ := enforcer.HasPolicy(helpers.GetCasbinRole(&ops, []model.Policy{deny})[0]); !ok {
+		t.Fatal("casbin deny rule not restored")
+	}
+}
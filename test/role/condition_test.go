@@ -0,0 +1,84 @@
+package role_test
+
+import (
+	"context"
+	"qqlx/base/constant"
+	"qqlx/base/helpers"
+	"qqlx/model"
+	"qqlx/store/rbac"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+)
+
+func TestConditionPolicy(t *testing.T) {
+	ctx := context.Background()
+	enforcer, err := casbin.NewEnforcer("../../model.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := rbac.NewCasbinStore(enforcer)
+	authorizer := rbac.NewAuthentication(enforcer)
+
+	self := model.Policy{Path: "/api/v1/users/:id", Method: "PUT", Condition: "r.env.Owner && userAvailable(r.env)"}
+	office := model.Policy{Path: "/api/v1/reports", Method: "GET", Condition: "r.env.Hour >= 9 && r.env.Hour < 18"}
+	plain := model.Policy{Path: "/api/v1/users", Method: "GET"}
+	rules := helpers.GetCasbinRole(&model.Role{Name: "self"}, []model.Policy{self, office, plain})
+	if rules[2][5] != constant.PolicyConditionNone {
+		t.Fatalf("policy without condition should always match, got %v", rules[2])
+	}
+	if err = store.CreateRolePolices(ctx, rules); err != nil {
+		t.Fatal(err)
+	}
+
+	available, disable := model.UserStatusAvailable, model.UserStatusDisable
+	users := map[int]*model.User{1: {ID: 1, Status: &available}, 2: {ID: 2, Status: &available}, 3: {ID: 3, Status: &disable}}
+	loads := 0
+	loadUser := func(id int) (*model.User, error) {
+		loads++
+		return users[id], nil
+	}
+	morning := time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)
+	night := time.Date(2024, 1, 1, 22, 0, 0, 0, time.Local)
+
+	cases := []struct {
+		name      string
+		path, act string
+		env       *rbac.ConditionEnv
+		want      bool
+	}{
+		{"update self", "/api/v1/users/1", "PUT", rbac.NewConditionEnv(1, 1, 0, morning, loadUser), true},
+		{"update other", "/api/v1/users/2", "PUT", rbac.NewConditionEnv(1, 2, 0, morning, loadUser), false},
+		{"update disabled self", "/api/v1/users/3", "PUT", rbac.NewConditionEnv(3, 3, 0, morning, loadUser), false},
+		{"office hours", "/api/v1/reports", "GET", rbac.NewConditionEnv(1, 0, 0, morning, loadUser), true},
+		{"after hours", "/api/v1/reports", "GET", rbac.NewConditionEnv(1, 0, 0, night, loadUser), false},
+		{"no condition", "/api/v1/users", "GET", rbac.NewConditionEnv(1, 0, 0, night, loadUser), true},
+	}
+	for _, c := range cases {
+		ok, err := authorizer.EnforceWithCtx(ctx, "self", constant.CasbinGlobalDomain, c.path, c.act, c.env)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if ok != c.want {
+			t.Fatalf("%s: want %v, got %v", c.name, c.want, ok)
+		}
+	}
+	// 只有 Owner 成立时才需要查询用户
+	if loads != 2 {
+		t.Fatalf("user should be loaded only when owner matched, loads=%d", loads)
+	}
+}
+
+func TestValidCondition(t *testing.T) {
+	for _, condition := range []string{"r.env.Owner", "r.env.Weekday != 0 && userAvailable(r.env)", "r.env.Tenant == 0"} {
+		if err := rbac.ValidCondition(condition); err != nil {
+			t.Fatalf("%q should be valid: %v", condition, err)
+		}
+	}
+	for _, condition := range []string{"r.env.Missing", "r.env.Hour +", "r.env.Hour", "unknown(r.env)"} {
+		if err := rbac.ValidCondition(condition); err == nil {
+			t.Fatalf("%q should be invalid", condition)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package role_test
+
+import (
+	"context"
+	"errors"
+	"qqlx/base/constant"
+	"qqlx/base/reason"
+	"qqlx/model"
+	"qqlx/schema"
+	"qqlx/service"
+	"qqlx/store/rbac"
+	"testing"
+)
+
+// memoryRoleStore 记录创建的角色, 查询时角色总是不存在
+type memoryRoleStore struct {
+	created []model.Role
+}
+
+func (receive *memoryRoleStore) Query(context.Context, ...rbac.RoleQueryOption) (*model.Role, error) {
+	return nil, reason.ErrRoleNotFound
+}
+
+func (receive *memoryRoleStore) Create(_ context.Context, role *model.Role) error {
+	receive.created = append(receive.created, *role)
+	return nil
+}
+
+func (receive *memoryRoleStore) Save(context.Context, *model.Role) error { return nil }
+
+func (receive *memoryRoleStore) Delete(context.Context, *model.Role, ...rbac.RoleDeleteOption) error {
+	return nil
+}
+
+func (receive *memoryRoleStore) List(context.Context, int, int, ...rbac.RoleQueryOption) (int64, []model.Role, error) {
+	return 0, nil, nil
+}
+
+// memoryPolicyStore 查询时返回全部策略
+type memoryPolicyStore struct {
+	policies []model.Policy
+}
+
+func (receive *memoryPolicyStore) Query(context.Context, ...rbac.PolicyQueryOption) (*model.Policy, error) {
+	return nil, reason.ErrPolicyNotFound
+}
+
+func (receive *memoryPolicyStore) Create(context.Context, *model.Policy) error { return nil }
+
+func (receive *memoryPolicyStore) Save(context.Context, *model.Policy) error { return nil }
+
+func (receive *memoryPolicyStore) Delete(context.Context, *model.Policy, ...rbac.PolicyDeleteOption) error {
+	return nil
+}
+
+func (receive *memoryPolicyStore) List(context.Context, int, int, ...rbac.PolicyQueryOption) (int64, []model.Policy, error) {
+	return int64(len(receive.policies)), receive.policies, nil
+}
+
+func TestCreateTenantRoleRejectsPolicyBeforeCreate(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	roleStore := &memoryRoleStore{}
+	policyStore := &memoryPolicyStore{policies: []model.Policy{{ID: 1, Name: "listUsers", Path: "/api/v1/users", Method: "GET"}}}
+	svc := service.NewRoleSVC(nil, roleStore, policyStore, nil, nil, nil, nil, nil)
+
+	err := svc.CreateRole(ctx, &schema.RoleCreateRequest{Name: "tenantEditor", TenantID: 1, PolicyIds: []int{1}})
+	if !errors.Is(err, reason.ErrTenantPolicyNotAllow) {
+		t.Fatalf("expected tenant policy to be rejected, got %v", err)
+	}
+	if len(roleStore.created) != 0 {
+		t.Fatalf("role should not be created when policies are invalid, got %v", roleStore.created)
+	}
+	err = svc.CreateRole(ctx, &schema.RoleCreateRequest{Name: "tenantEditor", TenantID: 1, ParentIDs: []int{9}})
+	if !errors.Is(err, reason.ErrRoleNotFound) {
+		t.Fatalf("expected missing parent to be rejected, got %v", err)
+	}
+	if len(roleStore.created) != 0 {
+		t.Fatalf("role should not be created when parents are invalid, got %v", roleStore.created)
+	}
+}
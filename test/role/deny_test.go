@@ -0,0 +1,63 @@
+package role_test
+
+import (
+	"context"
+	"qqlx/base/constant"
+	"qqlx/base/helpers"
+	"qqlx/model"
+	"qqlx/store/rbac"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+)
+
+func TestDenyOverridesAllow(t *testing.T) {
+	ctx := context.Background()
+	enforcer, err := casbin.NewEnforcer("../../model.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := rbac.NewCasbinStore(enforcer)
+	authorizer := rbac.NewAuthentication(enforcer)
+
+	view := model.Policy{Path: "*", Method: "GET"}
+	audit := model.Policy{Path: "/api/v1/audit", Method: "GET", Effect: constant.PolicyEffectDeny}
+	rules := helpers.GetCasbinRole(&model.Role{Name: "view"}, []model.Policy{view, audit})
+	if rules[0][3] != constant.PolicyEffectAllow {
+		t.Fatalf("policy without effect should be allow, got %v", rules[0])
+	}
+	if err = store.CreateRolePolices(ctx, rules); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.SetRoleParents(ctx, "auditor-lite", []string{"view"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		role, path string
+		want       bool
+	}{
+		{"view", "/api/v1/users", true},
+		{"view", "/api/v1/audit", false},
+		// 继承的 deny 同样生效
+		{"auditor-lite", "/api/v1/audit", false},
+		{"auditor-lite", "/api/v1/roles", true},
+	}
+	for _, c := range cases {
+		ok, err := authorizer.EnforceWithCtx(ctx, c.role, "1", c.path, "GET", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != c.want {
+			t.Fatalf("%s GET %s: want %v, got %v", c.role, c.path, c.want, ok)
+		}
+	}
+
+	// 删除 deny 后恢复访问
+	if err = store.DeleteRolePolices(ctx, helpers.GetCasbinRole(&model.Role{Name: "view"}, []model.Policy{audit})); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := authorizer.EnforceWithCtx(ctx, "view", constant.CasbinGlobalDomain, "/api/v1/audit", "GET", nil); !ok {
+		t.Fatal("removing deny should restore access")
+	}
+}
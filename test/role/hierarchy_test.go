@@ -0,0 +1,87 @@
+package role_test
+
+import (
+	"context"
+	"qqlx/base/constant"
+	"qqlx/base/helpers"
+	"qqlx/store/rbac"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+)
+
+func TestRoleHasCycle(t *testing.T) {
+	// 3 继承 2, 2 继承 1
+	parents := map[int][]int{3: {2}, 2: {1}}
+	if helpers.RoleHasCycle(parents, 4, []int{3}) {
+		t.Fatal("4 -> 3 should not be a cycle")
+	}
+	if !helpers.RoleHasCycle(parents, 1, []int{3}) {
+		t.Fatal("1 -> 3 -> 2 -> 1 should be a cycle")
+	}
+	if !helpers.RoleHasCycle(parents, 1, []int{1}) {
+		t.Fatal("self inheritance should be a cycle")
+	}
+	if helpers.RoleHasCycle(parents, 3, nil) {
+		t.Fatal("clearing parents should not be a cycle")
+	}
+}
+
+func TestRoleInheritance(t *testing.T) {
+	ctx := context.Background()
+	enforcer, err := casbin.NewEnforcer("../../model.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := rbac.NewCasbinStore(enforcer)
+	authorizer := rbac.NewAuthentication(enforcer)
+	err = store.CreateRolePolices(ctx, [][]string{
+		{"ops", "/api/v1/hosts", "GET", "allow", "*", "true"},
+		{"ops-lead", "/api/v1/hosts/:id", "DELETE", "allow", "*", "true"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.SetRoleParents(ctx, "ops-lead", []string{"ops"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		role, path, method string
+		want               bool
+	}{
+		{"ops-lead", "/api/v1/hosts", "GET", true},
+		{"ops-lead", "/api/v1/hosts/1", "DELETE", true},
+		{"ops", "/api/v1/hosts/1", "DELETE", false},
+		{"ops", "/api/v1/hosts", "GET", true},
+	}
+	for _, c := range cases {
+		ok, err := authorizer.EnforceWithCtx(ctx, c.role, constant.CasbinGlobalDomain, c.path, c.method, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != c.want {
+			t.Fatalf("%s %s %s: want %v, got %v", c.role, c.method, c.path, c.want, ok)
+		}
+	}
+
+	polices, err := store.GetImplicitRolePolices(ctx, "ops-lead")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(polices) != 2 {
+		t.Fatalf("want 2 effective polices, got %v", polices)
+	}
+	direct, _ := store.GetRolePolicyByName(ctx, "ops-lead")
+	if len(direct) != 1 {
+		t.Fatalf("want 1 direct policy, got %v", direct)
+	}
+
+	// 清空父角色后不再继承
+	if err = store.SetRoleParents(ctx, "ops-lead", nil); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := authorizer.EnforceWithCtx(ctx, "ops-lead", constant.CasbinGlobalDomain, "/api/v1/hosts", "GET", nil); ok {
+		t.Fatal("inherited policy should be removed with parent")
+	}
+}
@@ -0,0 +1,83 @@
+package role_test
+
+import (
+	"context"
+	"qqlx/base/constant"
+	"qqlx/base/helpers"
+	"qqlx/model"
+	"qqlx/store/rbac"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+)
+
+func TestTenantDomain(t *testing.T) {
+	ctx := context.Background()
+	enforcer, err := casbin.NewEnforcer("../../model.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := rbac.NewCasbinStore(enforcer)
+	authorizer := rbac.NewAuthentication(enforcer)
+
+	members := model.Policy{Path: "/api/v1/tenants/:tenant/members", Method: "GET"}
+	rules := helpers.GetCasbinRole(&model.Role{Name: "tenant-a-editor", TenantID: 1}, []model.Policy{members})
+	editor := helpers.GetCasbinSubject(1, "tenant-a-editor")
+	if rules[0][0] != editor || rules[0][4] != "1" {
+		t.Fatalf("tenant role should use tenant domain, got %v", rules[0])
+	}
+	rules = append(rules, helpers.GetCasbinRole(&model.Role{Name: constant.TenantAdminRole}, []model.Policy{members})...)
+	if rules[1][4] != constant.CasbinGlobalDomain {
+		t.Fatalf("global role should use global domain, got %v", rules[1])
+	}
+	if err = store.CreateRolePolices(ctx, rules); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		role, dom, path string
+		want            bool
+	}{
+		{editor, "1", "/api/v1/tenants/1/members", true},
+		// 租户角色在其他租户内无效
+		{editor, "2", "/api/v1/tenants/2/members", false},
+		{editor, constant.CasbinGlobalDomain, "/api/v1/tenants/1/members", false},
+		// 同名的全局角色和其他租户的角色不共用租户角色的策略
+		{"tenant-a-editor", "1", "/api/v1/tenants/1/members", false},
+		{helpers.GetCasbinSubject(2, "tenant-a-editor"), "1", "/api/v1/tenants/1/members", false},
+		// 全局角色在所有租户内生效
+		{constant.TenantAdminRole, "1", "/api/v1/tenants/1/members", true},
+		{constant.TenantAdminRole, "2", "/api/v1/tenants/2/members", true},
+	}
+	for _, c := range cases {
+		ok, err := authorizer.EnforceWithCtx(ctx, c.role, c.dom, c.path, "GET", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != c.want {
+			t.Fatalf("%s@%s GET %s: want %v, got %v", c.role, c.dom, c.path, c.want, ok)
+		}
+	}
+}
+
+func TestCasbinSubject(t *testing.T) {
+	cases := []struct {
+		tenantID int
+		name     string
+		subject  string
+	}{
+		{0, "viewer", "viewer"},
+		{1, "viewer", "tenant:1:viewer"},
+		{2, "a:b", "tenant:2:a:b"},
+	}
+	for _, c := range cases {
+		subject := helpers.GetCasbinSubject(c.tenantID, c.name)
+		if subject != c.subject {
+			t.Fatalf("subject of %d/%s: want %s, got %s", c.tenantID, c.name, c.subject, subject)
+		}
+		tenantID, name := helpers.ParseCasbinSubject(subject)
+		if tenantID != c.tenantID || name != c.name {
+			t.Fatalf("parse %s: want %d/%s, got %d/%s", subject, c.tenantID, c.name, tenantID, name)
+		}
+	}
+}
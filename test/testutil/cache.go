@@ -0,0 +1,75 @@
+package testutil
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MemoryCache 基于 map 的缓存, 实现 CacheInterface, 仅用于测试
+type MemoryCache struct {
+	mu   sync.Mutex
+	data map[string]string
+}
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{data: map[string]string{}}
+}
+
+func (m *MemoryCache) GetSet(_ context.Context, _ string) ([]string, error) { return nil, nil }
+func (m *MemoryCache) SetSet(_ context.Context, _ string, _ []any, _ *time.Duration) error {
+	return nil
+}
+func (m *MemoryCache) GetString(_ context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.data[key], nil
+}
+func (m *MemoryCache) SetString(_ context.Context, key, value string, _ *time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[key] = value
+	return nil
+}
+func (m *MemoryCache) SetNX(_ context.Context, key, value string, _ *time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.data[key]; ok {
+		return false, nil
+	}
+	m.data[key] = value
+	return true, nil
+}
+func (m *MemoryCache) GetInt64(_ context.Context, key string) (*int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.data[key]
+	if !ok {
+		return nil, nil
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	return &i, err
+}
+func (m *MemoryCache) SetInt64(_ context.Context, key string, value int64, _ *time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[key] = strconv.FormatInt(value, 10)
+	return nil
+}
+func (m *MemoryCache) Incr(_ context.Context, key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, _ := strconv.ParseInt(m.data[key], 10, 64)
+	i++
+	m.data[key] = strconv.FormatInt(i, 10)
+	return i, nil
+}
+func (m *MemoryCache) Expire(_ context.Context, _ string, _ time.Duration) error { return nil }
+func (m *MemoryCache) Del(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data, key)
+	return nil
+}
+func (m *MemoryCache) Flush(_ context.Context) error { return nil }
@@ -0,0 +1,100 @@
+package testutil
+
+import (
+	"context"
+	"qqlx/model"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MemorySessionStore 基于 map 的会话存储, 实现 SessionStoreInterface, 仅用于测试
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*model.Session
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: map[string]*model.Session{}}
+}
+
+func (m *MemorySessionStore) Create(_ context.Context, session *model.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session.CreatedAt = int(time.Now().Unix())
+	m.sessions[session.ID] = session
+	return nil
+}
+
+func (m *MemorySessionStore) Query(_ context.Context, id string, userID int) (*model.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[id]
+	if !ok || session.UserID != userID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return session, nil
+}
+
+func (m *MemorySessionStore) List(_ context.Context, userID int) ([]model.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sessions := make([]model.Session, 0)
+	for _, session := range m.sessions {
+		if session.UserID == userID && int64(session.ExpiresAt) > time.Now().Unix() {
+			sessions = append(sessions, *session)
+		}
+	}
+	return sessions, nil
+}
+
+func (m *MemorySessionStore) Rotate(_ context.Context, id, tokenID string, expiresAt int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if session, ok := m.sessions[id]; ok {
+		session.TokenID = tokenID
+		session.ExpiresAt = expiresAt
+	}
+	return nil
+}
+
+func (m *MemorySessionStore) Touch(_ context.Context, id string, lastSeenAt int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if session, ok := m.sessions[id]; ok {
+		session.LastSeenAt = lastSeenAt
+	}
+	return nil
+}
+
+func (m *MemorySessionStore) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *MemorySessionStore) Purge(_ context.Context, now int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var count int64
+	for id, session := range m.sessions {
+		if session.ExpiresAt <= now {
+			delete(m.sessions, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemorySessionStore) DeleteByUser(_ context.Context, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, session := range m.sessions {
+		if session.UserID == userID {
+			delete(m.sessions, id)
+		}
+	}
+	return nil
+}
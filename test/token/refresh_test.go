@@ -0,0 +1,122 @@
+package token_test
+
+import (
+	"context"
+	"errors"
+	"qqlx/base/constant"
+	"qqlx/base/reason"
+	"qqlx/model"
+	"qqlx/pkg/jwt"
+	"qqlx/service"
+	"qqlx/test/testutil"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestRefreshTokenRotation(t *testing.T) {
+	viper.Set("jwt.secret", "test-secret")
+	if err := jwt.InitConf(); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	tokenSvc := service.NewTokenSVC(testutil.NewMemoryCache(), testutil.NewMemorySessionStore(), nil)
+	user := &model.User{ID: 1, Name: "alice"}
+
+	first, err := tokenSvc.IssueToken(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Token == "" || first.RefreshToken == "" {
+		t.Fatalf("login response missing token: %#v", first)
+	}
+
+	info, err := tokenSvc.ConsumeRefreshToken(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("first refresh failed: %v", err)
+	}
+	second, err := tokenSvc.RotateToken(ctx, user, info.FamilyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+
+	// 重复使用旧的 refresh token, 整个家族被吊销
+	if _, err = tokenSvc.ConsumeRefreshToken(ctx, first.RefreshToken); !errors.Is(err, reason.ErrRefreshReused) {
+		t.Fatalf("reuse old refresh token, want %v, got %v", reason.ErrRefreshReused, err)
+	}
+	if _, err = tokenSvc.ConsumeRefreshToken(ctx, second.RefreshToken); !errors.Is(err, reason.ErrRefreshInvalid) {
+		t.Fatalf("refresh after family revoked, want %v, got %v", reason.ErrRefreshInvalid, err)
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	viper.Set("jwt.secret", "test-secret")
+	if err := jwt.InitConf(); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	tokenSvc := service.NewTokenSVC(testutil.NewMemoryCache(), testutil.NewMemorySessionStore(), nil)
+	user := &model.User{ID: 2, Name: "bob"}
+
+	t.Run("logout", func(t *testing.T) {
+		res, err := tokenSvc.IssueToken(ctx, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := jwt.ParseToken(res.Token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claims.ID == "" {
+			t.Fatal("token has no jti")
+		}
+		if err = tokenSvc.RevokeToken(ctx, claims); err != nil {
+			t.Fatal(err)
+		}
+		if revoked, _ := tokenSvc.IsRevoked(ctx, claims); !revoked {
+			t.Fatal("token is still valid after logout")
+		}
+		if _, err = tokenSvc.ConsumeRefreshToken(ctx, res.RefreshToken); !errors.Is(err, reason.ErrRefreshInvalid) {
+			t.Fatalf("refresh after logout, want %v, got %v", reason.ErrRefreshInvalid, err)
+		}
+	})
+
+	t.Run("revoke all user tokens", func(t *testing.T) {
+		claims := jwt.NewClaims(user.ID, user.Name)
+		claims.IssuedAtMicro = time.Now().Add(-time.Minute).UnixMicro()
+		if err := tokenSvc.RevokeUserTokens(ctx, user.ID); err != nil {
+			t.Fatal(err)
+		}
+		if revoked, _ := tokenSvc.IsRevoked(ctx, claims); !revoked {
+			t.Fatal("token issued before revocation is still valid")
+		}
+		if revoked, _ := tokenSvc.IsRevoked(ctx, jwt.NewClaims(user.ID, user.Name)); revoked {
+			t.Fatal("token issued after revocation should be valid")
+		}
+	})
+
+	// 与吊销在同一秒内签发的 token 也要失效
+	t.Run("same second", func(t *testing.T) {
+		res, err := tokenSvc.IssueToken(ctx, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := jwt.ParseToken(res.Token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = tokenSvc.RevokeUserTokens(ctx, user.ID); err != nil {
+			t.Fatal(err)
+		}
+		if revoked, _ := tokenSvc.IsRevoked(ctx, claims); !revoked {
+			t.Fatal("access token issued in the same second is still valid")
+		}
+		if _, err = tokenSvc.ConsumeRefreshToken(ctx, res.RefreshToken); !errors.Is(err, reason.ErrTokenRevoked) {
+			t.Fatalf("refresh issued in the same second, want %v, got %v", reason.ErrTokenRevoked, err)
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package token_test
+
+import (
+	"context"
+	"errors"
+	"qqlx/base/constant"
+	"qqlx/base/reason"
+	"qqlx/model"
+	"qqlx/pkg/jwt"
+	"qqlx/schema"
+	"qqlx/service"
+	"qqlx/test/testutil"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestSessionLifecycle(t *testing.T) {
+	viper.Set("jwt.secret", "test-secret")
+	if err := jwt.InitConf(); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	ctx = context.WithValue(ctx, constant.ClientIPKey, "10.0.0.1")
+	ctx = context.WithValue(ctx, constant.UserAgentKey, "curl/8.0")
+	sessions := testutil.NewMemorySessionStore()
+	tokenSvc := service.NewTokenSVC(testutil.NewMemoryCache(), sessions, nil)
+	user := &model.User{ID: 3, Name: "carol"}
+
+	first, err := tokenSvc.IssueToken(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := tokenSvc.IssueToken(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstClaims, _ := jwt.ParseToken(first.Token)
+	secondClaims, _ := jwt.ParseToken(second.Token)
+
+	list, err := tokenSvc.ListSessions(ctx, user.ID, firstClaims.FamilyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("want 2 sessions, got %d", len(list))
+	}
+	for _, session := range list {
+		if session.IP != "10.0.0.1" || session.UserAgent != "curl/8.0" {
+			t.Fatalf("client info not recorded: %#v", session)
+		}
+		if session.Current != (session.ID == firstClaims.FamilyID) {
+			t.Fatalf("current session mismatch: %#v", session)
+		}
+	}
+
+	// 刷新 token 后会话保持不变, 记录新的 jti
+	info, err := tokenSvc.ConsumeRefreshToken(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := tokenSvc.RotateToken(ctx, user, info.FamilyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotatedClaims, _ := jwt.ParseToken(rotated.Token)
+	session, err := sessions.Query(ctx, firstClaims.FamilyID, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.TokenID != rotatedClaims.ID {
+		t.Fatalf("session token id not rotated, want %s, got %s", rotatedClaims.ID, session.TokenID)
+	}
+
+	// 其他用户不能结束该会话
+	err = tokenSvc.KillSession(ctx, &schema.SessionQueryRequest{UserID: 99, ID: firstClaims.FamilyID})
+	if !errors.Is(err, reason.ErrSessionNotFound) {
+		t.Fatalf("want %v, got %v", reason.ErrSessionNotFound, err)
+	}
+	if err = tokenSvc.KillSession(ctx, &schema.SessionQueryRequest{UserID: user.ID, ID: firstClaims.FamilyID}); err != nil {
+		t.Fatal(err)
+	}
+	for _, claims := range []*jwt.MyClaims{firstClaims, rotatedClaims} {
+		if revoked, _ := tokenSvc.IsRevoked(ctx, claims); !revoked {
+			t.Fatal("token is still valid after session killed")
+		}
+	}
+	if revoked, _ := tokenSvc.IsRevoked(ctx, secondClaims); revoked {
+		t.Fatal("other session should not be affected")
+	}
+	if _, err = tokenSvc.ConsumeRefreshToken(ctx, rotated.RefreshToken); !errors.Is(err, reason.ErrRefreshInvalid) {
+		t.Fatalf("refresh after session killed, want %v, got %v", reason.ErrRefreshInvalid, err)
+	}
+	list, _ = tokenSvc.ListSessions(ctx, user.ID, "")
+	if len(list) != 1 || list[0].ID != secondClaims.FamilyID {
+		t.Fatalf("killed session still listed: %#v", list)
+	}
+}
+
+func TestPurgeSessions(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceID, "test")
+	sessions := testutil.NewMemorySessionStore()
+	tokenSvc := service.NewTokenSVC(testutil.NewMemoryCache(), sessions, nil)
+	now := int(time.Now().Unix())
+	for _, session := range []*model.Session{
+		{ID: "expired", UserID: 1, ExpiresAt: now - 1},
+		{ID: "active", UserID: 1, ExpiresAt: now + 3600},
+	} {
+		if err := sessions.Create(ctx, session); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tokenSvc.PurgeSessions(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sessions.Query(ctx, "expired", 1); err == nil {
+		t.Fatal("expired session should be purged")
+	}
+	if _, err := sessions.Query(ctx, "active", 1); err != nil {
+		t.Fatalf("active session should be kept, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package totp
+
+import (
+	"net/url"
+	"qqlx/pkg/totp"
+	"testing"
+	"time"
+)
+
+// RFC 6238 附录 B 的测试密钥 "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerateCode(t *testing.T) {
+	cases := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, want := range cases {
+		got, err := totp.GenerateCode(rfcSecret, totp.Step(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("time %d: want %s, got %s", unix, want, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	code, err := totp.GenerateCode(secret, totp.Step(now)-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step, ok := totp.Validate(secret, code, now)
+	if !ok || step != totp.Step(now)-1 {
+		t.Fatalf("expected previous period code to be accepted, step: %d", step)
+	}
+
+	stale, _ := totp.GenerateCode(secret, totp.Step(now)-3)
+	if _, ok = totp.Validate(secret, stale, now); ok {
+		t.Fatal("expected stale code to be rejected")
+	}
+	if _, ok = totp.Validate(secret, "12345", now); ok {
+		t.Fatal("expected short code to be rejected")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri, err := url.Parse(totp.URI("qqlx", "alice@example.com", rfcSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" {
+		t.Fatalf("unexpected uri: %s", uri)
+	}
+	if uri.Path != "/qqlx:alice@example.com" {
+		t.Fatalf("unexpected label: %s", uri.Path)
+	}
+	query := uri.Query()
+	if query.Get("secret") != rfcSecret || query.Get("issuer") != "qqlx" || query.Get("digits") != "6" {
+		t.Fatalf("unexpected query: %s", uri.RawQuery)
+	}
+}
@@ -0,0 +1,97 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"qqlx/base/middleware"
+	"qqlx/model"
+	"qqlx/schema"
+	"qqlx/service"
+	"qqlx/store/rbac"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	upstreamTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	upstreamSpanID  = "00f067aa0ba902b7"
+)
+
+// memoryPolicyStore 记录查询时 ctx 中的 span, 代替 gorm 检查 ctx 的传递
+type memoryPolicyStore struct {
+	span trace.SpanContext
+}
+
+func (receive *memoryPolicyStore) Query(ctx context.Context, _ ...rbac.PolicyQueryOption) (*model.Policy, error) {
+	receive.span = trace.SpanContextFromContext(ctx)
+	return &model.Policy{ID: 1, Name: "userList"}, nil
+}
+
+func (receive *memoryPolicyStore) Create(context.Context, *model.Policy) error { return nil }
+
+func (receive *memoryPolicyStore) Save(context.Context, *model.Policy) error { return nil }
+
+func (receive *memoryPolicyStore) Delete(context.Context, *model.Policy, ...rbac.PolicyDeleteOption) error {
+	return nil
+}
+
+func (receive *memoryPolicyStore) List(context.Context, int, int, ...rbac.PolicyQueryOption) (int64, []model.Policy, error) {
+	return 0, nil, nil
+}
+
+func TestPropagation(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	store := &memoryPolicyStore{}
+	svc := service.NewPolicySVC(nil, store, nil, nil, nil, nil)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.ContextWithFallback = true
+	r.Use(middleware.TracingMiddleware(), middleware.RequestIDMiddleware())
+	r.GET("/policies/:id", func(c *gin.Context) {
+		if _, err := svc.GetPolicy(c, &schema.PolicyIDRequest{ID: 1}); err != nil {
+			t.Error(err)
+		}
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/policies/1", nil)
+	req.Header.Set("traceparent", "00-"+upstreamTraceID+"-"+upstreamSpanID+"-01")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("X-Request-ID"); got != upstreamTraceID {
+		t.Fatalf("request id = %s", got)
+	}
+	if got := rec.Header().Get("traceparent"); !strings.Contains(got, upstreamTraceID) || strings.Contains(got, upstreamSpanID) {
+		t.Fatalf("response traceparent = %s", got)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("spans = %d", len(spans))
+	}
+	svcSpan, server := spans[0], spans[1]
+	if server.Name() != "GET /policies/:id" || server.SpanKind() != trace.SpanKindServer {
+		t.Fatalf("server span = %s %s", server.Name(), server.SpanKind())
+	}
+	if server.SpanContext().TraceID().String() != upstreamTraceID || server.Parent().SpanID().String() != upstreamSpanID {
+		t.Fatalf("server span parent = %s", server.Parent().SpanID())
+	}
+	if svcSpan.Name() != "PolicySVC.GetPolicy" || svcSpan.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Fatalf("service span = %s, parent = %s", svcSpan.Name(), svcSpan.Parent().SpanID())
+	}
+	if store.span.SpanID() != svcSpan.SpanContext().SpanID() {
+		t.Fatalf("store ctx span = %s", store.span.SpanID())
+	}
+}
@@ -0,0 +1,103 @@
+package validator_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"qqlx/base/validator"
+	"qqlx/schema"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func newPolicy(t *testing.T) *validator.PasswordPolicy {
+	banned := filepath.Join(t.TempDir(), "banned.txt")
+	if err := os.WriteFile(banned, []byte("# common\nPassword123!\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("password.minLength", 10)
+	viper.Set("password.requireUpper", true)
+	viper.Set("password.requireDigit", true)
+	viper.Set("password.requireSymbol", true)
+	viper.Set("password.banIdentity", true)
+	viper.Set("password.bannedListFile", banned)
+	viper.Set("password.history", 3)
+	viper.Set("password.maxAge", "720h")
+	policy, err := validator.NewPasswordPolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return policy
+}
+
+func tags(violations []validator.PasswordViolation) []string {
+	res := make([]string, 0, len(violations))
+	for _, v := range violations {
+		res = append(res, v.Tag)
+	}
+	return res
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	policy := newPolicy(t)
+
+	got := tags(policy.Check("short"))
+	want := []string{validator.TagPasswordMin, validator.TagPasswordUpper, validator.TagPasswordDigit, validator.TagPasswordSymbol}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	if got = tags(policy.Check("welcome123!")); len(got) != 1 || got[0] != validator.TagPasswordUpper {
+		t.Fatalf("unexpected violations: %v", got)
+	}
+	if got = tags(policy.Check("PASSWORD123!")); len(got) != 1 || got[0] != validator.TagPasswordBanned {
+		t.Fatalf("banned list should be case-insensitive: %v", got)
+	}
+	if got = tags(policy.Check("Alice-2024-xyz", "alice", "alice@qqlx.net")); len(got) != 1 || got[0] != validator.TagPasswordIdentity {
+		t.Fatalf("identity should be rejected: %v", got)
+	}
+	if got = tags(policy.Check("Correct-Horse-9")); len(got) != 0 {
+		t.Fatalf("expected valid password, got: %v", got)
+	}
+}
+
+func TestPasswordPolicyExpired(t *testing.T) {
+	policy := newPolicy(t)
+	if policy.Expired(0) {
+		t.Fatal("unknown change time should not be expired")
+	}
+	if !policy.Expired(1) {
+		t.Fatal("expected old password to be expired")
+	}
+}
+
+func TestPasswordValidationMessage(t *testing.T) {
+	v := validator.NewValidator(newPolicy(t))
+	msg, err := v.CheckReq(context.Background(), &schema.UserRegistryRequest{
+		Name:     "alice",
+		Email:    "alice@qqlx.net",
+		Password: "alice",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Password长度不能少于10个字符", "Password必须包含大写字母", "Password不能包含用户名或邮箱"} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("message %q should contain %q", msg, want)
+		}
+	}
+
+	msg, err = v.CheckReq(context.Background(), &schema.UserUpdatePasswordRequest{
+		OldPassword: "old-password",
+		NewPassword: "Correct-Horse-9",
+	})
+	if err != nil || msg != "" {
+		t.Fatalf("expected valid request, msg: %q, err: %v", msg, err)
+	}
+
+	policy := newPolicy(t)
+	if got := policy.Translate("Password", []validator.PasswordViolation{policy.Reused()}); got != "Password不能与最近3次使用的密码相同" {
+		t.Fatalf("unexpected reused message: %s", got)
+	}
+}